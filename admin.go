@@ -0,0 +1,65 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+//
+// Counters published via expvar on the admin endpoint
+//
+var (
+	statProbes      = expvar.NewInt("probes")
+	statProbeErrors = expvar.NewInt("probe_errors")
+)
+
+//
+// checkLoopbackAddress - check that address is a loopback address. The
+// admin endpoint exposes process internals, and replayed responses may
+// hold captured production data, so they may only be bound to one.
+//
+func checkLoopbackAddress(address string) error {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("not a loopback address: %s", address)
+	}
+	return nil
+}
+
+//
+// startAdminServer - serve net/http/pprof and expvar endpoints on the
+// given localhost address, for debugging the tool itself during long
+// running modes. Runs in the background for the life of the process.
+//
+func startAdminServer(address string) error {
+
+	if err := checkLoopbackAddress(address); err != nil {
+		return fmt.Errorf("admin address: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	go http.Serve(listener, mux)
+	return nil
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Default address the agent listens on: the local host only, since an
+// agent probes URLs for whoever holds the token
+var defaultAgentAddress = "127.0.0.1:7070"
+
+// Environment variable holding the agent's shared token
+var agentTokenEnv = "GOHTTP_AGENT_TOKEN"
+
+// Path of the agent's probe endpoint
+var agentPath = "/v1/probe"
+
+// Time allowed for an agent to reply beyond the probe's own timeout
+var agentMargin = 5 * time.Second
+
+//
+// AgentRequest - a probe request sent to an agent
+//
+type AgentRequest struct {
+	URL     string              `json:"url"`
+	Method  string              `json:"method,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Timeout float64             `json:"timeout_ms,omitempty"`
+}
+
+//
+// AgentResult - an agent's reply: the probe result, without the body,
+// from the agent's vantage point
+//
+type AgentResult struct {
+	Agent      string      `json:"agent"`
+	Exit       int         `json:"exit_status"`
+	BodySHA256 string      `json:"body_sha256,omitempty"`
+	Result     *ResultJSON `json:"result"`
+}
+
+//
+// agentToken - the shared token agents and their clients authenticate
+// requests with: the contents of file if given, else the environment
+//
+func agentToken(file string) (string, error) {
+
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("%s is empty", file)
+	}
+	if token := os.Getenv(agentTokenEnv); token != "" {
+		return token, nil
+	}
+	return "", fmt.Errorf("no token: use -token-file or set %s", agentTokenEnv)
+}
+
+//
+// agentAuthorized - does the request carry the agent's token, as a
+// Bearer token?
+//
+func agentAuthorized(req *http.Request, token string) bool {
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	given := auth[len("Bearer "):]
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+//
+// agentHandler - probe the URL of each authorized request, and reply
+// with the result. Requests may shorten the agent's timeout, but not
+// lengthen it.
+//
+func agentHandler(name, token, cacert string, timeout time.Duration) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		if !agentAuthorized(req, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if req.Method != http.MethodPost {
+			http.Error(w, "POST a probe request", http.StatusMethodNotAllowed)
+			return
+		}
+		var ar AgentRequest
+		if err := json.NewDecoder(io.LimitReader(req.Body, 1<<20)).Decode(&ar); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ar.Method == "" {
+			ar.Method = http.MethodGet
+		}
+		switch ar.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			http.Error(w, "only GET, HEAD and OPTIONS probes are supported", http.StatusBadRequest)
+			return
+		}
+
+		opts := probe.DefaultOptions()
+		opts.Timeout = timeout
+		if d := time.Duration(ar.Timeout * float64(time.Millisecond)); d > 0 && d < timeout {
+			opts.Timeout = d
+		}
+		opts.Headers = http.Header(ar.Headers)
+		opts.CACert = cacert
+		prober, err := probe.NewProber(opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		request, err := prober.NewRequest(ar.Method, ar.URL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		session := prober.NewSession("")
+		result := readResponse(session, request)
+
+		reply := AgentResult{Agent: name, Exit: ExitOK}
+		reply.Result = newResultJSON(request, session.RemoteAddr(), result)
+		reply.Result.Body, reply.Result.BodyBase64 = "", nil
+		if result.Err != nil {
+			reply.Exit = classifyError(result.Err)
+		} else {
+			sum := sha256.Sum256(result.Body)
+			reply.BodySHA256 = hex.EncodeToString(sum[:])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&reply)
+		fmt.Fprintf(os.Stderr, "%s %s %s -> %s\n",
+			req.RemoteAddr, ar.Method, ar.URL, remoteOutcome(reply.Result))
+	}
+}
+
+//
+// agent - the agent command: serve probe requests from gohttp -remote
+// clients holding the shared token. Returns the exit status.
+//
+func agent(args []string) int {
+
+	hostname, _ := os.Hostname()
+	flags := flag.NewFlagSet("agent", flag.ContinueOnError)
+	listen := flags.String("listen", defaultAgentAddress, "Address to listen on")
+	name := flags.String("name", hostname, "Name the agent reports itself as")
+	tokenfile := flags.String("token-file", "", "File containing the shared token")
+	timeout := flags.Duration("t", defaultTimeout, "Maximum probe timeout")
+	cacert := flags.String("cacert", "", "PEM format CA certificates file")
+	certfile := flags.String("agent-cert", "", "PEM format certificate file to serve TLS with")
+	keyfile := flags.String("agent-key", "", "PEM format private key file for -agent-cert")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s agent [Options]
+
+    Probe URLs on behalf of gohttp -remote clients, so that a URL can be
+    tested from several vantage points at once. Requests must carry the
+    shared token, read from -token-file or $%s, as a Bearer token.
+
+    An agent fetches any URL it is asked to, from its own network: anyone
+    holding the token can use it to reach hosts and services that only
+    the agent can (internal addresses, cloud metadata endpoints and the
+    like). Listen on the local host (the default) or a trusted network,
+    keep the token secret, and serve TLS (-agent-cert and -agent-key) so
+    that the token isn't sent in the clear.
+
+    Options:
+	-listen addr      Address to listen on (default %s)
+	-name name        Name to report results under (default the hostname)
+	-token-file file  File containing the shared token
+	-t Ns             Maximum probe timeout (default %v)
+	-cacert file      PEM format CA certificates file to verify with
+	-agent-cert file  PEM format certificate (chain) file: serve HTTPS,
+	                  for clients to give as https://host:port
+	-agent-key file   PEM format private key file for -agent-cert
+`, progname, agentTokenEnv, defaultAgentAddress, defaultTimeout)
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	if flags.NArg() != 0 || *timeout <= 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	if (*certfile == "") != (*keyfile == "") {
+		fmt.Fprintf(os.Stderr, "ERROR: -agent-cert and -agent-key must be given together\n")
+		return ExitUsage
+	}
+	token, err := agentToken(*tokenfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return ExitUsage
+	}
+
+	var config *tls.Config
+	if *certfile != "" {
+		cert, err := tls.LoadX509KeyPair(*certfile, *keyfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return ExitUsage
+		}
+		config = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(agentPath, agentHandler(*name, token, *cacert, *timeout))
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return ExitOther
+	}
+	scheme := "http"
+	if config != nil {
+		listener = tls.NewListener(listener, config)
+		scheme = "https"
+	}
+	fmt.Fprintf(os.Stderr, "Agent %s serving probes on %s://%s\n", *name, scheme, listener.Addr())
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+		fmt.Fprintf(os.Stderr, "WARNING: anyone who can reach %s with the token can have it fetch URLs from this network\n", listener.Addr())
+		if config == nil {
+			fmt.Fprintf(os.Stderr, "WARNING: without -agent-cert, the token is sent in the clear\n")
+		}
+	}
+	if err := http.Serve(listener, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return ExitOther
+	}
+	return ExitOK
+}
+
+//
+// agentURL - the probe endpoint of an agent given as host:port or URL
+//
+func agentURL(agent string) string {
+
+	if !strings.Contains(agent, "://") {
+		agent = "http://" + agent
+	}
+	return strings.TrimSuffix(agent, "/") + agentPath
+}
+
+//
+// askAgent - have an agent probe urlstring
+//
+func askAgent(client *http.Client, agent, token, urlstring string) (*AgentResult, error) {
+
+	body, err := json.Marshal(&AgentRequest{
+		URL:     urlstring,
+		Method:  options.method,
+		Headers: options.headers,
+		Timeout: milliseconds(options.timeout),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, agentURL(agent), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(msg)))
+	}
+	reply := new(AgentResult)
+	if err := json.NewDecoder(response.Body).Decode(reply); err != nil {
+		return nil, err
+	}
+	if reply.Result == nil {
+		return nil, errors.New("agent returned no result")
+	}
+	return reply, nil
+}
+
+//
+// remoteOutcome - a one line summary of a remote probe result
+//
+func remoteOutcome(r *ResultJSON) string {
+
+	if r.Error != "" {
+		return "ERROR: " + r.Error
+	}
+	s := fmt.Sprintf("%d %s, %s", r.Status, r.Proto, fmtDuration(time.Duration(r.ResponseTime*float64(time.Millisecond))))
+	if r.Address != "" {
+		s += " from " + r.Address
+	}
+	if r.TLS != nil {
+		s += ", " + r.TLS.Version
+	}
+	return s
+}
+
+//
+// remoteDifferences - how the agents' results differ: whether they got
+// a response, and between those that did, the status, protocol, address
+// connected to, leaf certificate, body and final URL. Results without a
+// body digest aren't compared by body.
+//
+func remoteDifferences(results []*AgentResult) []string {
+
+	properties := []struct {
+		name  string
+		value func(*AgentResult) string
+	}{
+		{"status", func(a *AgentResult) string { return fmt.Sprint(a.Result.Status) }},
+		{"protocol", func(a *AgentResult) string { return a.Result.Proto }},
+		{"address", func(a *AgentResult) string { return a.Result.Address }},
+		{"certificate", func(a *AgentResult) string {
+			if a.Result.TLS == nil || len(a.Result.TLS.Certs) == 0 {
+				return ""
+			}
+			return a.Result.TLS.Certs[0].Serial
+		}},
+		{"body", func(a *AgentResult) string { return a.BodySHA256 }},
+		{"final URL", func(a *AgentResult) string { return a.Result.URL }},
+	}
+
+	var diffs []string
+	var failed, succeeded []string
+	for _, a := range results {
+		if a != nil && a.Result.Error != "" {
+			failed = append(failed, a.Agent)
+		} else if a != nil {
+			succeeded = append(succeeded, a.Agent)
+		}
+	}
+	if len(failed) > 0 && len(succeeded) > 0 {
+		diffs = append(diffs, fmt.Sprintf("outcome: response (%s) vs error (%s)",
+			strings.Join(succeeded, ", "), strings.Join(failed, ", ")))
+	}
+	for _, p := range properties {
+		seen := make(map[string][]string)
+		var order []string
+		for _, a := range results {
+			if a == nil || a.Result.Error != "" {
+				continue
+			}
+			v := p.value(a)
+			if v == "" && p.name == "body" {
+				continue
+			}
+			if seen[v] == nil {
+				order = append(order, v)
+			}
+			seen[v] = append(seen[v], a.Agent)
+		}
+		if len(order) < 2 {
+			continue
+		}
+		var parts []string
+		for _, v := range order {
+			if v == "" {
+				v = "none"
+			}
+			parts = append(parts, fmt.Sprintf("%s (%s)", v, strings.Join(seen[v], ", ")))
+		}
+		diffs = append(diffs, p.name+": "+strings.Join(parts, " vs "))
+	}
+	return diffs
+}
+
+//
+// remoteProbe - have every agent probe urlstring at once, and print
+// their results side by side, followed by how they differ
+//
+func remoteProbe(w io.Writer, client *http.Client, token, urlstring string) {
+
+	results := make([]*AgentResult, len(options.remote))
+	errs := make([]error, len(options.remote))
+	var wg sync.WaitGroup
+	for i, agent := range options.remote {
+		wg.Add(1)
+		go func(i int, agent string) {
+			defer wg.Done()
+			results[i], errs[i] = askAgent(client, agent, token, urlstring)
+		}(i, agent)
+	}
+	wg.Wait()
+
+	fmt.Fprintf(w, "URL: %s\n", urlstring)
+	fmt.Fprintf(w, "## Remote Probes: %d agents\n", len(options.remote))
+	for i, agent := range options.remote {
+		if errs[i] != nil {
+			fmt.Fprintf(w, "   %s: AGENT ERROR: %v\n", agent, errs[i])
+			setExitStatus(classifyError(errs[i]))
+			continue
+		}
+		a := results[i]
+		fmt.Fprintf(w, "   %s (%s): %s\n", a.Agent, agent, remoteOutcome(a.Result))
+		setExitStatus(a.Exit)
+		if options.failhttp && a.Result.Status >= 400 {
+			setExitStatus(ExitHTTPError)
+		}
+	}
+	if diffs := remoteDifferences(results); len(diffs) > 0 {
+		fmt.Fprintln(w, "## Differences:")
+		for _, d := range diffs {
+			fmt.Fprintf(w, "   %s\n", d)
+		}
+	} else {
+		fmt.Fprintln(w, "## Differences: none")
+	}
+}
+
+//
+// remote - probe the URLs through the -remote agents instead of from
+// here. Returns the exit status.
+//
+func remote(urls []string) int {
+
+	token, err := agentToken(options.remotetoken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -remote: %v\n", err)
+		return ExitUsage
+	}
+	client := &http.Client{
+		Timeout: options.timeout + agentMargin,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: options.rootcas},
+		},
+	}
+	for i, urlstring := range urls {
+		if i > 0 {
+			fmt.Println()
+		}
+		remoteProbe(os.Stdout, client, token, urlstring)
+	}
+	return exitStatus
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Lifetime of an alternative without an ma parameter (RFC 7838)
+const defaultAltSvcMaxAge = 24 * time.Hour
+
+//
+// AltService - an alternative service advertised in Alt-Svc
+//
+type AltService struct {
+	Protocol string        // ALPN protocol ID, e.g. h3 or h2
+	Host     string        // Host, "" for the origin's
+	Port     string        // Port
+	MaxAge   time.Duration // How long the alternative may be used
+	Persist  bool          // Kept across network changes
+}
+
+//
+// authority - the alternative's host:port, filling in the origin host
+//
+func (a *AltService) authority(origin string) string {
+
+	host := a.Host
+	if host == "" {
+		host = origin
+	}
+	return net.JoinHostPort(host, a.Port)
+}
+
+//
+// splitQuoted - split s at sep, except inside double quotes
+//
+func splitQuoted(s string, sep byte) []string {
+
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+//
+// unquote - the value of a token or quoted-string
+//
+func unquote(s string) string {
+
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+//
+// parseAltSvc - parse Alt-Svc header values (RFC 7838 section 3),
+// returning the alternatives, or clear if the server withdrew them all
+//
+func parseAltSvc(values []string) (services []AltService, clear bool, err error) {
+
+	for _, value := range values {
+		for _, entry := range splitQuoted(value, ',') {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			if entry == "clear" {
+				clear = true
+				continue
+			}
+			params := splitQuoted(entry, ';')
+			i := strings.IndexByte(params[0], '=')
+			if i < 0 {
+				return nil, false, fmt.Errorf("invalid alternative %q", entry)
+			}
+			protocol, err := url.PathUnescape(strings.TrimSpace(params[0][:i]))
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid protocol in %q", entry)
+			}
+			host, port, err := net.SplitHostPort(unquote(strings.TrimSpace(params[0][i+1:])))
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid authority in %q: %v", entry, err)
+			}
+			if _, err := parsePort(port); err != nil {
+				return nil, false, fmt.Errorf("invalid authority in %q: %v", entry, err)
+			}
+			a := AltService{Protocol: protocol, Host: host, Port: port, MaxAge: defaultAltSvcMaxAge}
+			for _, param := range params[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				switch strings.ToLower(kv[0]) {
+				case "ma":
+					if secs, err := strconv.ParseUint(unquote(kv[1]), 10, 32); err == nil {
+						a.MaxAge = time.Duration(secs) * time.Second
+					}
+				case "persist":
+					a.Persist = unquote(kv[1]) == "1"
+				}
+			}
+			services = append(services, a)
+		}
+	}
+	return services, clear, nil
+}
+
+//
+// altSvcFollowable - can gohttp make a request to the alternative? It
+// has no HTTP/3 (QUIC) support, so only h2 and http/1.1 alternatives
+// can be followed.
+//
+func altSvcFollowable(a AltService) bool {
+	return a.Protocol == "h2" || a.Protocol == "http/1.1"
+}
+
+//
+// followAltSvc - make the request again, connecting to the alternative
+// instead, and compare the result with the origin's
+//
+func followAltSvc(w io.Writer, prober *probe.Prober, request *http.Request, a AltService, origin *probe.ProbeResult) {
+
+	authority := a.authority(request.URL.Hostname())
+	fmt.Fprintf(w, "   Following %s at %s:\n", a.Protocol, authority)
+	req := request.Clone(request.Context())
+	if request.GetBody != nil {
+		req.Body, _ = request.GetBody()
+	}
+	result := readResponse(prober.NewSession(authority), req)
+	if result.Err != nil {
+		fmt.Fprintf(w, "      ERROR: %v\n", result.Err)
+		return
+	}
+	response := result.Response
+	fmt.Fprintf(w, "      Status: %d (origin %d)\n", response.StatusCode, origin.Response.StatusCode)
+	fmt.Fprintf(w, "      Protocol: %s (origin %s)\n", response.Proto, origin.Response.Proto)
+	if response.Proto != "HTTP/2.0" && a.Protocol == "h2" {
+		fmt.Fprintln(w, "      WARNING: h2 was advertised, but not negotiated")
+	}
+	fmt.Fprintf(w, "      Connected: %s\n", result.RemoteAddr)
+	fmt.Fprintf(w, "      ResponseTime: %s (origin %s)\n", fmtDuration(result.ResponseTime),
+		fmtDuration(origin.ResponseTime))
+	if bytes.Equal(result.Body, origin.Body) {
+		fmt.Fprintf(w, "      Body: same (%d bytes)\n", result.BodySize)
+	} else {
+		fmt.Fprintf(w, "      Body: DIFFERENT (%d bytes, origin %d)\n", result.BodySize, origin.BodySize)
+	}
+}
+
+//
+// printAltSvc - print the alternative services the response advertised
+// in Alt-Svc, and with -follow-altsvc, make the request again to each
+// one that can be followed and compare the results
+//
+func printAltSvc(w io.Writer, prober *probe.Prober, request *http.Request, result *probe.ProbeResult) {
+
+	values := result.Response.Header.Values("Alt-Svc")
+	if len(values) == 0 {
+		if options.followaltsvc {
+			fmt.Fprintln(w, "## Alt-Svc: none advertised")
+		}
+		return
+	}
+	fmt.Fprintln(w, "## Alt-Svc:")
+	services, clear, err := parseAltSvc(values)
+	if err != nil {
+		fmt.Fprintf(w, "   ERROR: %v\n", err)
+		return
+	}
+	if clear {
+		fmt.Fprintln(w, "   clear: all alternatives withdrawn")
+	}
+	for _, a := range services {
+		persist := ""
+		if a.Persist {
+			persist = ", persist"
+		}
+		fmt.Fprintf(w, "   %s at %s, max age %s%s\n", a.Protocol,
+			a.authority(request.URL.Hostname()), a.MaxAge, persist)
+	}
+
+	if !options.followaltsvc {
+		return
+	}
+	followed := false
+	for _, a := range services {
+		if altSvcFollowable(a) {
+			followAltSvc(w, prober, request, a, result)
+			followed = true
+		}
+	}
+	if !followed {
+		fmt.Fprintln(w, "   No alternatives to follow (HTTP/3 is not supported)")
+	}
+}
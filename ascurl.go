@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// shellQuote - quote s for a POSIX shell, if it needs it
+//
+func shellQuote(s string) string {
+
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@,+%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+//
+// curlSeconds - a duration as a curl timeout argument
+//
+func curlSeconds(d time.Duration) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.3f", d.Seconds()), "0"), ".")
+}
+
+//
+// curlCommand - a curl command line making the same request as gohttp
+// would, connecting to address if it is given, and notes on what curl
+// can't reproduce
+//
+func curlCommand(request *http.Request, address string) (string, []string) {
+
+	var args, notes []string
+	add := func(a ...string) {
+		for _, s := range a {
+			args = append(args, shellQuote(s))
+		}
+	}
+	header := request.Header.Clone()
+
+	switch {
+	case request.Method == http.MethodHead:
+		add("-I")
+	case request.Method == http.MethodPost && options.data != nil:
+	case request.Method != http.MethodGet:
+		add("-X", request.Method)
+	}
+	if ua := header.Get("User-Agent"); ua != "" {
+		add("-A", ua)
+		header.Del("User-Agent")
+	}
+	if options.username != "" {
+		if options.digestauth {
+			add("--digest")
+		}
+		add("-u", options.username+":"+options.password)
+		if !options.digestauth {
+			header.Del("Authorization")
+		}
+	}
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range header[key] {
+			add("-H", key+": "+value)
+		}
+	}
+	if request.Host != "" && request.Host != request.URL.Host {
+		add("-H", "Host: "+request.Host)
+	}
+	if options.aws != nil {
+		add("--aws-sigv4", "aws:amz:"+options.aws.Region+":"+options.aws.Service,
+			"-u", options.aws.Credentials.AccessKeyID+":"+options.aws.Credentials.SecretAccessKey)
+		if token := options.aws.Credentials.SessionToken; token != "" {
+			add("-H", "X-Amz-Security-Token: "+token)
+		}
+	}
+
+	switch {
+	case options.datafile != "":
+		add("--data-binary", "@"+options.datafile)
+	case options.data != nil && utf8.Valid(options.data):
+		add("--data-binary", string(options.data))
+	case options.data != nil:
+		notes = append(notes, "the -data body is binary: save it to a file and use --data-binary @file")
+	}
+	if options.trailers != nil {
+		notes = append(notes, "curl can't send request trailers (-trailer)")
+	}
+
+	if options.cookiejarfile != "" {
+		add("-b", options.cookiejarfile, "-c", options.cookiejarfile)
+	}
+	switch {
+	case len(options.cacert) > 1:
+		add("--cacert", options.cacert[0])
+		notes = append(notes, "curl takes one --cacert: concatenate the -cacert bundles into one file")
+	case len(options.cacert) == 1:
+		add("--cacert", options.cacert[0])
+	case options.roots == "mozilla":
+		notes = append(notes, "for -roots mozilla, use --cacert with https://curl.se/ca/cacert.pem")
+	case options.roots != "system":
+		add("--cacert", options.roots)
+	}
+	if options.clientcert != "" {
+		add("--cert", options.clientcert)
+	}
+	if options.clientkey != "" {
+		add("--key", options.clientkey)
+	}
+	if options.noverify {
+		add("-k")
+	}
+	if options.absoluteform {
+		add("--http1.1", "--request-target", request.URL.Scheme+":"+request.URL.Opaque)
+	}
+	if options.ipv4only {
+		add("-4")
+	}
+	if options.ipv6only {
+		add("-6")
+	}
+	if options.proxy != nil {
+		add("-x", options.proxy.String())
+	}
+	if !options.noredirect {
+		add("-L")
+	}
+	if options.conntimeout > 0 {
+		add("--connect-timeout", curlSeconds(options.conntimeout))
+	}
+	maxtime := options.timeout
+	if options.maxtime > 0 {
+		maxtime = options.maxtime
+	}
+	add("--max-time", curlSeconds(maxtime))
+
+	u := *request.URL
+	u.Opaque = ""
+	port := u.Port()
+	if port == "" {
+		port = portMap[u.Scheme]
+	}
+	if address != "" {
+		ip, _, _ := net.SplitHostPort(address)
+		add("--resolve", u.Hostname()+":"+port+":"+ip)
+	}
+	if options.sni != "" && options.sni != u.Hostname() {
+		// curl sends the URL's hostname as SNI, so name the SNI host in
+		// the URL, and connect to the real one
+		add("--connect-to", options.sni+":"+port+":"+u.Hostname()+":"+port)
+		if header.Get("Host") == "" && options.hostheader == "" {
+			add("-H", "Host: "+u.Host)
+		}
+		u.Host = net.JoinHostPort(options.sni, port)
+	}
+	add(u.String())
+	return "curl " + strings.Join(args, " "), notes
+}
+
+//
+// asCurl - the -as-curl mode: print the curl command line for each of
+// the URLs (for each address, with -queryall) instead of probing them
+//
+func asCurl(prober *probe.Prober, urls []string) int {
+
+	for _, urlstring := range urls {
+		request := getRequest(prober, urlstring)
+		addresses := []string{""}
+		if options.queryall && options.proxy == nil {
+			iplist, _, err := getIpList(request.URL.Hostname())
+			if err != nil {
+				fmt.Printf("# %s: %v\n", urlstring, err)
+				setExitStatus(ExitDNS)
+				continue
+			}
+			port := request.URL.Port()
+			if port == "" {
+				port = portMap[request.URL.Scheme]
+			}
+			addresses = nil
+			for _, ip := range iplist {
+				addresses = append(addresses, addressString(ip, port))
+			}
+		}
+		for _, address := range addresses {
+			command, notes := curlCommand(request, address)
+			for _, note := range notes {
+				fmt.Printf("# Note: %s\n", note)
+			}
+			fmt.Println(command)
+		}
+	}
+	return exitStatus
+}
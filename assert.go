@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// HeaderAssertion - a header name and a regular expression one of its
+// values must match.
+//
+type HeaderAssertion struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+//
+// Assertions - checks of the response requested on the command line
+//
+type Assertions struct {
+	status  []string // status codes or classes like "2xx"
+	headers []HeaderAssertion
+	body    *regexp.Regexp
+}
+
+//
+// Active - were any assertions specified?
+//
+func (a *Assertions) Active() bool {
+	return a.status != nil || a.headers != nil || a.body != nil
+}
+
+//
+// parseExpectStatus - parse a comma separated list of status codes or
+// status classes (e.g. "200,301" or "2xx")
+//
+func parseExpectStatus(s string) ([]string, error) {
+
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if len(item) == 3 && item[0] >= '1' && item[0] <= '5' && item[1:] == "xx" {
+			list = append(list, item)
+			continue
+		}
+		code, err := strconv.Atoi(item)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status code: %q", item)
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+//
+// parseExpectHeader - parse a "name:regex" header assertion
+//
+func parseExpectHeader(s string) (HeaderAssertion, error) {
+
+	tmp := strings.SplitN(s, ":", 2)
+	if len(tmp) != 2 || strings.TrimSpace(tmp[0]) == "" {
+		return HeaderAssertion{}, fmt.Errorf("invalid header assertion %q: must be name:regex", s)
+	}
+	regex, err := regexp.Compile(strings.TrimSpace(tmp[1]))
+	if err != nil {
+		return HeaderAssertion{}, fmt.Errorf("invalid header assertion %q: %v", s, err)
+	}
+	return HeaderAssertion{name: strings.TrimSpace(tmp[0]), regex: regex}, nil
+}
+
+//
+// statusMatches - does the status code match the code or class?
+//
+func statusMatches(code int, expect string) bool {
+
+	if strings.HasSuffix(expect, "xx") {
+		return strconv.Itoa(code)[0] == expect[0]
+	}
+	return strconv.Itoa(code) == expect
+}
+
+//
+// checkAssertions - evaluate the assertions against the result.
+// Returns a list of pass/fail descriptions, and whether all passed.
+//
+func checkAssertions(a *Assertions, result *probe.ProbeResult) (lines []string, ok bool) {
+
+	ok = true
+	report := func(passed bool, format string, args ...interface{}) {
+		status := "PASS"
+		if !passed {
+			status = "FAIL"
+			ok = false
+		}
+		lines = append(lines, status+": "+fmt.Sprintf(format, args...))
+	}
+
+	if a.status != nil {
+		passed := false
+		for _, expect := range a.status {
+			if statusMatches(result.Response.StatusCode, expect) {
+				passed = true
+				break
+			}
+		}
+		report(passed, "status %d, expected %s", result.Response.StatusCode,
+			strings.Join(a.status, " or "))
+	}
+
+	for _, h := range a.headers {
+		values := result.Response.Header.Values(h.name)
+		if values == nil {
+			report(false, "header %s missing, expected /%s/", h.name, h.regex)
+			continue
+		}
+		passed := false
+		for _, value := range values {
+			if h.regex.MatchString(value) {
+				passed = true
+				break
+			}
+		}
+		report(passed, "header %s: %s, expected /%s/", h.name,
+			strings.Join(values, ","), h.regex)
+	}
+
+	if a.body != nil {
+		report(a.body.Match(result.Body), "body matches /%s/", a.body)
+	}
+
+	return lines, ok
+}
+
+//
+// printAssertions - evaluate and print the assertions, setting the exit
+// status if any fail.
+//
+func printAssertions(w io.Writer, a *Assertions, result *probe.ProbeResult) {
+
+	lines, ok := checkAssertions(a, result)
+	fmt.Fprintln(w, "## Assertions:")
+	for _, line := range lines {
+		fmt.Fprintf(w, "   %s\n", line)
+	}
+	if !ok {
+		setExitStatus(ExitAssertion)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// parseAWSScope - parse a -aws-sigv4 "region/service" string
+//
+func parseAWSScope(s string) (region, service string, err error) {
+
+	tmp := strings.SplitN(s, "/", 2)
+	if len(tmp) != 2 || tmp[0] == "" || tmp[1] == "" {
+		return "", "", fmt.Errorf("invalid scope %q: must be region/service", s)
+	}
+	return tmp[0], tmp[1], nil
+}
+
+//
+// awsFile - the AWS shared file named by the environment variable, or
+// the default name in ~/.aws
+//
+func awsFile(envvar, name string) string {
+
+	if filename := os.Getenv(envvar); filename != "" {
+		return filename
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", name)
+}
+
+//
+// readAWSProfile - the key/value settings of the profile's section of
+// an AWS shared credentials or config file. In the config file, the
+// sections of profiles other than default are named "profile name".
+//
+func readAWSProfile(filename, section string) (map[string]string, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	settings := make(map[string]string)
+	found := false
+	insection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			insection = strings.TrimSpace(line[1:len(line)-1]) == section
+			found = found || insection
+		case insection:
+			tmp := strings.SplitN(line, "=", 2)
+			if len(tmp) == 2 {
+				settings[strings.TrimSpace(tmp[0])] = strings.TrimSpace(tmp[1])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: no [%s] section", filename, section)
+	}
+	return settings, nil
+}
+
+//
+// awsCredentials - the credentials to sign with, from the environment
+// (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN), else
+// the AWS_PROFILE (or default) profile of the shared credentials file,
+// else of the shared config file. Returns where they were found.
+//
+func awsCredentials() (probe.AWSCredentials, string, error) {
+
+	creds := probe.AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		return creds, "environment", nil
+	}
+
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	configsection := "profile " + profile
+	if profile == "default" {
+		configsection = profile
+	}
+	sources := []struct{ filename, section string }{
+		{awsFile("AWS_SHARED_CREDENTIALS_FILE", "credentials"), profile},
+		{awsFile("AWS_CONFIG_FILE", "config"), configsection},
+	}
+	for _, source := range sources {
+		settings, err := readAWSProfile(source.filename, source.section)
+		if err != nil {
+			continue
+		}
+		creds = probe.AWSCredentials{
+			AccessKeyID:     settings["aws_access_key_id"],
+			SecretAccessKey: settings["aws_secret_access_key"],
+			SessionToken:    settings["aws_session_token"],
+		}
+		if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+			return creds, fmt.Sprintf("%s [%s]", source.filename, source.section), nil
+		}
+	}
+	return creds, "", fmt.Errorf("no AWS credentials in the environment or for profile %q", profile)
+}
+
+//
+// printSigV4 - print the signature of the last request made, and with
+// -verbose, the canonical request and string to sign, for comparison
+// with those in a SignatureDoesNotMatch error
+//
+func printSigV4(w io.Writer, signer *probe.AWSSigner) {
+
+	sig := signer.Last()
+	if sig == nil {
+		return
+	}
+	fmt.Fprintln(w, "## AWS SigV4:")
+	fmt.Fprintf(w, "   Credentials: %s (from %s)\n", signer.Credentials.AccessKeyID,
+		options.awssource)
+	fmt.Fprintf(w, "   Scope: %s\n", sig.Scope)
+	fmt.Fprintf(w, "   Signed Headers: %s\n", sig.SignedHeaders)
+	if options.verbose {
+		fmt.Fprintln(w, "   Canonical Request:")
+		printIndented(w, sig.CanonicalRequest)
+		fmt.Fprintln(w, "   String to Sign:")
+		printIndented(w, sig.StringToSign)
+	}
+}
+
+//
+// printIndented - print each line of text, indented under a heading
+//
+func printIndented(w io.Writer, text string) {
+
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintln(w, strings.TrimRight("      "+line, " "))
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// readURLList - read URLs one per line from the named file, or stdin
+// if it is "-", calling fn with each as it is read, until fn returns
+// false. Blank lines and lines starting with # are ignored. A line that
+// isn't a valid URL is passed to fn with the error.
+//
+func readURLList(filename string, fn func(urlstring string, err error) bool) error {
+
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = targetURL(line)
+		var err error
+		if _, err = parseURL(line); err != nil {
+			err = fmt.Errorf("%s:%d: %v", filename, lineno, err)
+		}
+		if !fn(line, err) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// How many of the URLs and addresses not probed printBudget lists
+const notProbedShown = 20
+
+//
+// Summary - aggregate results of probing several URLs
+//
+type Summary struct {
+	mu        sync.Mutex
+	probes    int
+	failed    int
+	timeouts  int
+	skipped   int
+	cancelled int      // Probes in progress when the run was stopped
+	notprobed []string // The first URLs and addresses skipped
+	unread    bool     // The rest of -urls stdin wasn't read
+	statuses  map[int]int
+	latencies LatencyHistogram
+}
+
+func newSummary() *Summary {
+	return &Summary{statuses: make(map[int]int)}
+}
+
+//
+// record - add a probe result to the summary. A nil result is a probe
+// that failed before a request could be made (e.g. DNS failure).
+//
+func (s *Summary) record(result *probe.ProbeResult) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.probes++
+	if result == nil || result.Err != nil {
+		s.failed++
+		if result != nil && classifyError(result.Err) == ExitTimeout {
+			s.timeouts++
+		}
+		if result != nil && runContext.Err() != nil {
+			s.cancelled++
+		}
+		return
+	}
+	if result.Response != nil {
+		s.statuses[result.Response.StatusCode]++
+	}
+	s.latencies.Record(result.ResponseTime)
+}
+
+//
+// skip - count a URL or address not probed because the -deadline or
+// -max-total-time passed, or the program was interrupted
+//
+func (s *Summary) skip(what string) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipped++
+	if len(s.notprobed) < notProbedShown {
+		s.notprobed = append(s.notprobed, what)
+	}
+}
+
+//
+// printBudget - print what -max-total-time cut short: the probes still
+// in progress, and those not started
+//
+func (s *Summary) printBudget(w io.Writer) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "\n## Run Budget: -max-total-time %s ran out after %s\n",
+		options.maxtotal, fmtDuration(time.Since(runStart)))
+	fmt.Fprintf(w, "   Probes completed: %d\n", s.probes-s.cancelled)
+	fmt.Fprintf(w, "   Probes cancelled in progress: %d\n", s.cancelled)
+	fmt.Fprintf(w, "   Not probed: %d\n", s.skipped)
+	for _, what := range s.notprobed {
+		fmt.Fprintf(w, "      %s\n", what)
+	}
+	if more := s.skipped - len(s.notprobed); more > 0 {
+		fmt.Fprintf(w, "      ... and %d more\n", more)
+	}
+	if s.unread {
+		fmt.Fprintf(w, "   Not read: the rest of the -urls input\n")
+	}
+}
+
+//
+// print - print the summary
+//
+func (s *Summary) print(w io.Writer) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintln(w, "\n## Summary:")
+	fmt.Fprintf(w, "   Probes: %d (%d succeeded, %d failed)\n",
+		s.probes, s.probes-s.failed, s.failed)
+
+	var codes []int
+	for code := range s.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "   Status %d: %d\n", code, s.statuses[code])
+	}
+
+	if s.latencies.Count() > 0 {
+		fmt.Fprintf(w, "   Response time: min %s, mean %s, max %s\n",
+			fmtDuration(s.latencies.Min()), fmtDuration(s.latencies.Mean()),
+			fmtDuration(s.latencies.Max()))
+		fmt.Fprintf(w, "   Response time: p50 %s, p90 %s, p99 %s\n",
+			fmtDuration(s.latencies.Quantile(0.5)), fmtDuration(s.latencies.Quantile(0.9)),
+			fmtDuration(s.latencies.Quantile(0.99)))
+	}
+	fmt.Fprintf(w, "   Hosts: %d OK, %d failed, %d timed out", s.probes-s.failed,
+		s.failed-s.timeouts, s.timeouts)
+	if s.skipped > 0 {
+		when := stopReason()
+		fmt.Fprintf(w, ", %d not probed before %s", s.skipped, when)
+	}
+	if s.unread {
+		fmt.Fprintf(w, ", the rest of the -urls input not read")
+	}
+	fmt.Fprintln(w)
+}
+
+//
+// probeAll - probe each of the URLs, and then those in the -urls file
+// as it is read, running up to options.parallel probes at a time. With
+// a -deadline, probes still running when it passes are abandoned, and
+// URLs not yet probed are skipped, as they are when the program is
+// interrupted; the rest of -urls stdin is then left unread.
+//
+func probeAll(prober *probe.Prober, urls []string, summary *Summary) {
+
+	var wg sync.WaitGroup
+	multi := len(urls) > 1 || options.urlsfile != ""
+
+	work := make(chan string)
+	for i := 0; i < options.parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for urlstring := range work {
+				if runContext.Err() != nil {
+					summary.skip(urlstring)
+					continue
+				}
+				probeURL(prober, urlstring, multi, summary)
+			}
+		}()
+	}
+	for _, urlstring := range urls {
+		work <- urlstring
+	}
+	if options.urlsfile != "" {
+		err := readURLList(options.urlsfile, func(urlstring string, err error) bool {
+			// Once stopped, a file is read to count what is skipped,
+			// but stdin isn't waited on.
+			if runContext.Err() != nil {
+				summary.skip(urlstring)
+				if options.urlsfile != "-" {
+					return true
+				}
+				summary.mu.Lock()
+				summary.unread = true
+				summary.mu.Unlock()
+				return false
+			}
+			if err == nil {
+				urlstring, err = prepareURL(urlstring)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: -urls: %v\n", err)
+				setExitStatus(ExitUsage)
+				summary.record(nil)
+				options.formatter.Failed(urlstring, err)
+				return true
+			}
+			work <- urlstring
+			return true
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: -urls: %v\n", err)
+			setExitStatus(ExitUsage)
+		}
+	}
+	close(work)
+	wg.Wait()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+//
+// Response headers that describe what an endpoint supports, reported
+// for OPTIONS requests: the methods allowed, and the CORS policy
+//
+var capabilityHeaders = []string{
+	"Allow",
+	"Accept-Patch",
+	"Accept-Post",
+	"Accept-Ranges",
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Expose-Headers",
+	"Access-Control-Max-Age",
+	"Vary",
+}
+
+//
+// printCapabilities - print the headers of an OPTIONS response that
+// describe what the endpoint supports
+//
+func printCapabilities(w io.Writer, header http.Header) {
+
+	fmt.Fprintln(w, "## Capabilities:")
+	if len(header.Values("Allow")) == 0 {
+		fmt.Fprintln(w, "   Allow: (not sent)")
+	}
+	found := false
+	for _, key := range capabilityHeaders {
+		if values := header.Values(key); len(values) > 0 {
+			fmt.Fprintf(w, "   %s: %s\n", key, strings.Join(values, ", "))
+			found = found || strings.HasPrefix(key, "Access-Control-")
+		}
+	}
+	if !found {
+		fmt.Fprintln(w, "   CORS: no Access-Control-* headers (send an Origin header to")
+		fmt.Fprintln(w, "         see the policy for it, e.g. -header 'Origin: https://a.example')")
+	}
+}
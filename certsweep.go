@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Parallel connections made by certsweep by default
+var defaultSweepParallel = 10
+
+// Days within which a certificate's expiry is reported by -previous
+var defaultSweepThreshold = 30
+
+//
+// SweepResult - the leaf certificate found at one host:port
+//
+type SweepResult struct {
+	Host     string     `json:"host"`
+	Port     string     `json:"port"`
+	Address  string     `json:"address,omitempty"`
+	NotAfter *time.Time `json:"not_after,omitempty"`
+	DaysLeft int        `json:"days_left"`
+	Subject  string     `json:"subject,omitempty"`
+	Issuer   string     `json:"issuer,omitempty"`
+	SPKI     string     `json:"spki_sha256,omitempty"`
+	Verified bool       `json:"verified"`
+	Problem  string     `json:"problem,omitempty"`
+	Error    string     `json:"error,omitempty"`
+
+	timeout bool // The connection or handshake timed out
+}
+
+//
+// readSweepHosts - read the host[:port] lines of a hosts file, skipping
+// blank lines and # comments. The port defaults to 443.
+//
+func readSweepHosts(r io.Reader) ([][2]string, error) {
+
+	var hosts [][2]string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+		if line == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(line)
+		if err != nil {
+			host, port = strings.Trim(line, "[]"), "443"
+		}
+		if host == "" {
+			return nil, fmt.Errorf("line %d: no host: %q", n, line)
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, fmt.Errorf("line %d: invalid port: %q", n, line)
+		}
+		hosts = append(hosts, [2]string{host, port})
+	}
+	return hosts, scanner.Err()
+}
+
+//
+// sweepHost - connect to host:port, and collect the details of the leaf
+// certificate it presents. The chain is verified separately, so that
+// certificates that fail verification are still inventoried.
+//
+func sweepHost(ctx context.Context, host, port string, roots *x509.CertPool, timeout time.Duration) *SweepResult {
+
+	result := &SweepResult{Host: host, Port: port}
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    &tls.Config{ServerName: strings.TrimSuffix(host, "."), InsecureSkipVerify: true},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		result.Error = err.Error()
+		result.timeout = classifyError(err) == ExitTimeout
+		return result
+	}
+	defer conn.Close()
+	result.Address = conn.RemoteAddr().String()
+
+	cs := conn.(*tls.Conn).ConnectionState()
+	leaf := cs.PeerCertificates[0]
+	result.NotAfter = &leaf.NotAfter
+	result.DaysLeft = int(time.Until(leaf.NotAfter).Hours() / 24)
+	result.Subject = leaf.Subject.CommonName
+	if result.Subject == "" && len(leaf.DNSNames) > 0 {
+		result.Subject = leaf.DNSNames[0]
+	}
+	result.Issuer = leaf.Issuer.CommonName
+	spki := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+	result.SPKI = base64.StdEncoding.EncodeToString(spki[:])
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName:       strings.TrimSuffix(host, "."),
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	result.Verified = err == nil
+	if err != nil {
+		result.Problem = err.Error()
+	}
+	return result
+}
+
+//
+// sortSweep - sort results soonest expiry first, with hosts that
+// couldn't be reached last, in file order
+//
+func sortSweep(results []*SweepResult) {
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if (a.Error == "") != (b.Error == "") {
+			return a.Error == ""
+		}
+		return a.Error == "" && a.NotAfter.Before(*b.NotAfter)
+	})
+}
+
+//
+// printSweepText - print the report as a table, with a line per expiry
+// date heading the certificates that expire on it
+//
+func printSweepText(w io.Writer, results []*SweepResult) {
+
+	day := ""
+	for _, r := range results {
+		if r.Error != "" {
+			continue
+		}
+		if d := r.NotAfter.UTC().Format("2006-01-02"); d != day {
+			day = d
+			fmt.Fprintf(w, "## %s (%d days):\n", day, r.DaysLeft)
+		}
+		status := "OK"
+		if !r.Verified {
+			status = "UNVERIFIED: " + r.Problem
+		}
+		fmt.Fprintf(w, "   %s:%s subject=%s issuer=%s spki=%s %s\n", r.Host, r.Port,
+			r.Subject, r.Issuer, r.SPKI, status)
+	}
+	header := false
+	for _, r := range results {
+		if r.Error == "" {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(w, "## Unreachable:")
+			header = true
+		}
+		fmt.Fprintf(w, "   %s:%s ERROR: %s\n", r.Host, r.Port, r.Error)
+	}
+}
+
+//
+// printSweepCSV - print the report as CSV, with a header row
+//
+func printSweepCSV(w io.Writer, results []*SweepResult) error {
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"host", "port", "address", "not_after", "days_left", "subject",
+		"issuer", "spki_sha256", "verified", "problem", "error"})
+	for _, r := range results {
+		notafter, days := "", ""
+		if r.Error == "" {
+			notafter = r.NotAfter.UTC().Format(time.RFC3339)
+			days = strconv.Itoa(r.DaysLeft)
+		}
+		cw.Write([]string{r.Host, r.Port, r.Address, notafter, days, r.Subject,
+			r.Issuer, r.SPKI, strconv.FormatBool(r.Verified), r.Problem, r.Error})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//
+// SweepChange - a difference between a host's certificate in this sweep
+// and in the previous one
+//
+type SweepChange struct {
+	Host     string       `json:"host"`
+	Port     string       `json:"port"`
+	Change   string       `json:"change"`
+	Detail   string       `json:"detail"`
+	Previous *SweepResult `json:"previous,omitempty"`
+	Current  *SweepResult `json:"current,omitempty"`
+}
+
+//
+// readSweep - read the results of a previous sweep, saved in JSON format
+//
+func readSweep(filename string) ([]*SweepResult, error) {
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var results []*SweepResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("%s: %v (must be saved by certsweep -format json or -save)",
+			filename, err)
+	}
+	for _, r := range results {
+		if r.Error == "" && r.NotAfter == nil {
+			return nil, fmt.Errorf("%s: %s:%s has no not_after", filename, r.Host, r.Port)
+		}
+	}
+	return results, nil
+}
+
+//
+// sweepDate - the expiry date of a result, for change details
+//
+func sweepDate(r *SweepResult) string {
+	return r.NotAfter.UTC().Format("2006-01-02")
+}
+
+//
+// diffSweep - the changes between the previous sweep and this one:
+// hosts added, removed, newly unreachable or reachable again, renewed
+// certificates, changed issuers or keys, certificates that now expire
+// within threshold days and did not before, and ones that now fail
+// verification
+//
+func diffSweep(previous, current []*SweepResult, threshold int) []*SweepChange {
+
+	key := func(r *SweepResult) string { return net.JoinHostPort(r.Host, r.Port) }
+	before := make(map[string]*SweepResult)
+	for _, r := range previous {
+		before[key(r)] = r
+	}
+
+	var changes []*SweepChange
+	add := func(prev, cur *SweepResult, change, detail string) {
+		r := cur
+		if r == nil {
+			r = prev
+		}
+		changes = append(changes, &SweepChange{Host: r.Host, Port: r.Port, Change: change,
+			Detail: detail, Previous: prev, Current: cur})
+	}
+
+	seen := make(map[string]bool)
+	for _, cur := range current {
+		seen[key(cur)] = true
+		prev := before[key(cur)]
+		switch {
+		case prev == nil:
+			add(nil, cur, "new host", "not in previous sweep")
+			continue
+		case cur.Error != "" && prev.Error == "":
+			add(prev, cur, "unreachable", cur.Error)
+			continue
+		case cur.Error != "":
+			continue
+		case prev.Error != "":
+			add(prev, cur, "reachable", "certificate expires "+sweepDate(cur))
+			continue
+		}
+		if !cur.NotAfter.Equal(*prev.NotAfter) {
+			add(prev, cur, "renewed", fmt.Sprintf("expiry %s -> %s",
+				sweepDate(prev), sweepDate(cur)))
+		} else if cur.SPKI != prev.SPKI {
+			add(prev, cur, "key changed", fmt.Sprintf("SPKI %s -> %s", prev.SPKI, cur.SPKI))
+		}
+		if cur.Issuer != prev.Issuer {
+			add(prev, cur, "issuer changed", fmt.Sprintf("%q -> %q", prev.Issuer, cur.Issuer))
+		}
+		if cur.DaysLeft < threshold && prev.DaysLeft >= threshold {
+			add(prev, cur, "expiring", fmt.Sprintf("expires %s, in %d days",
+				sweepDate(cur), cur.DaysLeft))
+		}
+		if !cur.Verified && prev.Verified {
+			add(prev, cur, "verification failed", cur.Problem)
+		}
+	}
+	for _, prev := range previous {
+		if !seen[key(prev)] {
+			add(prev, nil, "removed host", "not in this sweep")
+		}
+	}
+	return changes
+}
+
+//
+// printSweepChanges - print the changes in the given format
+//
+func printSweepChanges(w io.Writer, changes []*SweepChange, format string) error {
+
+	switch format {
+	case "json":
+		if changes == nil {
+			changes = []*SweepChange{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"host", "port", "change", "detail"})
+		for _, c := range changes {
+			cw.Write([]string{c.Host, c.Port, c.Change, c.Detail})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "## No changes since the previous sweep")
+		return nil
+	}
+	fmt.Fprintf(w, "## Changes since the previous sweep: %d\n", len(changes))
+	for _, c := range changes {
+		fmt.Fprintf(w, "   %s:%s %s: %s\n", c.Host, c.Port, strings.ToUpper(c.Change), c.Detail)
+	}
+	return nil
+}
+
+//
+// saveSweep - save the results in JSON format, for a later -previous
+//
+func saveSweep(filename string, results []*SweepResult) error {
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+//
+// printSweepStats - print the final statistics line: hosts whose
+// certificate was retrieved, and those that failed or timed out
+//
+func printSweepStats(w io.Writer, results []*SweepResult) {
+
+	ok, failed, timedout := 0, 0, 0
+	for _, r := range results {
+		switch {
+		case r.Error == "":
+			ok++
+		case r.timeout:
+			timedout++
+		default:
+			failed++
+		}
+	}
+	fmt.Fprintf(w, "Hosts: %d OK, %d failed, %d timed out\n", ok, failed, timedout)
+}
+
+//
+// certSweep - the certsweep subcommand: inventory the certificates of
+// every host in a file, soonest expiry first
+//
+func certSweep(args []string) int {
+
+	flags := flag.NewFlagSet("certsweep", flag.ContinueOnError)
+	format := flags.String("format", "text", "Output format: text, csv or json")
+	parallel := flags.Int("parallel", defaultSweepParallel, "Number of hosts to connect to at once")
+	timeout := flags.Duration("t", defaultTimeout, "Connection timeout")
+	cacert := flags.String("cacert", "", "PEM format CA certificates file")
+	warndays := flags.Int("warn-days", 0, "Exit 1 if any certificate expires within N days")
+	previous := flags.String("previous", "", "Report only changes since this saved sweep")
+	threshold := flags.Int("threshold", defaultSweepThreshold, "Days within which expiry is reported as a change")
+	save := flags.String("save", "", "Save this sweep's results, in JSON format")
+	deadline := flags.Duration("deadline", 0, "Time limit for the whole sweep")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s certsweep [Options] <hosts.txt>
+
+    Connect to each host[:port] listed in the file (port 443 by default,
+    '-' reads standard input), and report the expiry, subject, issuer and
+    SPKI SHA-256 of the leaf certificate, soonest expiry first.
+
+    Options:
+	-format fmt       Output format: text, csv or json (default text)
+	-parallel N       Number of hosts to connect to at once (default %d)
+	-t Ns             Connection and handshake timeout per host (default %v)
+	-deadline Ns      Time limit for the whole sweep; hosts not reached
+	                  by then are reported as timed out
+	-cacert file      PEM format CA certificates file to verify with
+	-warn-days N      Exit with status 1 if any certificate expires within
+	                  N days, fails verification or can't be retrieved
+	-previous file    Report only what changed since the sweep saved in
+	                  file: new, removed or unreachable hosts, renewals,
+	                  issuer or key changes, certificates newly expiring
+	                  within the threshold, and verification failures
+	-threshold N      Days within which expiry is a change (default %d)
+	-save file        Save this sweep's results in JSON format, to be
+	                  compared with the next one
+`, progname, defaultSweepParallel, defaultTimeout, defaultSweepThreshold)
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	if flags.NArg() != 1 || *parallel < 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+	switch *format {
+	case "text", "csv", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: -format: must be text, csv or json\n")
+		return ExitUsage
+	}
+
+	var roots *x509.CertPool
+	if *cacert != "" {
+		pem, err := ioutil.ReadFile(*cacert)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: -cacert: %v\n", err)
+			return ExitUsage
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			fmt.Fprintf(os.Stderr, "ERROR: -cacert: no certificates in %s\n", *cacert)
+			return ExitUsage
+		}
+	}
+
+	in := os.Stdin
+	if name := flags.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return ExitUsage
+		}
+		defer f.Close()
+		in = f
+	}
+	var before []*SweepResult
+	if *previous != "" {
+		var err error
+		if before, err = readSweep(*previous); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: -previous: %v\n", err)
+			return ExitUsage
+		}
+	}
+
+	hosts, err := readSweepHosts(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", flags.Arg(0), err)
+		return ExitUsage
+	}
+
+	ctx := context.Background()
+	if *deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *deadline)
+		defer cancel()
+	}
+	results := make([]*SweepResult, len(hosts))
+	sem := make(chan struct{}, *parallel)
+	var wg sync.WaitGroup
+	for i, hp := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, host, port string) {
+			defer wg.Done()
+			results[i] = sweepHost(ctx, host, port, roots, *timeout)
+			<-sem
+		}(i, hp[0], hp[1])
+	}
+	wg.Wait()
+	sortSweep(results)
+	printSweepStats(os.Stderr, results)
+
+	if *save != "" {
+		if err := saveSweep(*save, results); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: -save: %v\n", err)
+			return ExitOther
+		}
+	}
+
+	switch {
+	case *previous != "":
+		err = printSweepChanges(os.Stdout, diffSweep(before, results, *threshold), *format)
+	case *format == "csv":
+		err = printSweepCSV(os.Stdout, results)
+	case *format == "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	default:
+		printSweepText(os.Stdout, results)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return ExitOther
+	}
+
+	if *warndays > 0 {
+		for _, r := range results {
+			if r.Error != "" || !r.Verified || r.DaysLeft < *warndays {
+				return ExitAssertion
+			}
+		}
+	}
+	return ExitOK
+}
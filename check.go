@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// How long a -check command may run
+var checkTimeout = 30 * time.Second
+
+//
+// CheckResult - outcome of running one -check command
+//
+type CheckResult struct {
+	command  string
+	passed   bool
+	findings []string
+	err      error
+}
+
+//
+// runCheck - run a custom check command. The command is given the JSON
+// result on stdin. Each line it prints on stdout is a finding; it exits
+// 0 if the check passed, and 1 if it failed. Any other exit status, or
+// failure to run it at all, is an error.
+//
+func runCheck(command string, input []byte) *CheckResult {
+
+	cr := &CheckResult{command: command}
+
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		cr.err = fmt.Errorf("empty command")
+		return cr
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			cr.findings = append(cr.findings, line)
+		}
+	}
+
+	var exiterr *exec.ExitError
+	switch {
+	case err == nil:
+		cr.passed = true
+	case ctx.Err() != nil:
+		cr.err = fmt.Errorf("timed out after %v", checkTimeout)
+	case errors.As(err, &exiterr) && exiterr.ExitCode() == 1:
+		cr.passed = false
+	default:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		cr.err = err
+	}
+	return cr
+}
+
+//
+// printChecks - run the -check commands against the result and print
+// their outcomes. A failed check sets exit status 1, like a failed
+// assertion.
+//
+func printChecks(w io.Writer, request *http.Request, address string, result *probe.ProbeResult) {
+
+	input, err := json.Marshal(newResultJSON(request, address, result))
+	if err != nil {
+		fmt.Fprintf(w, "ERROR: -check: %v\n", err)
+		setExitStatus(ExitOther)
+		return
+	}
+
+	fmt.Fprintln(w, "## Custom Checks:")
+	for _, command := range options.checks {
+		cr := runCheck(command, input)
+		switch {
+		case cr.err != nil:
+			fmt.Fprintf(w, "   ERROR %s: %v\n", cr.command, cr.err)
+			setExitStatus(ExitOther)
+		case cr.passed:
+			fmt.Fprintf(w, "   PASS  %s\n", cr.command)
+		default:
+			fmt.Fprintf(w, "   FAIL  %s\n", cr.command)
+			setExitStatus(ExitAssertion)
+		}
+		for _, finding := range cr.findings {
+			fmt.Fprintf(w, "         %s\n", finding)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+//
+// Severities of report lines, for -color and -quiet
+//
+const (
+	SevInfo = iota
+	SevOK
+	SevWarning
+	SevError
+)
+
+// -quiet levels: the least severe lines still printed
+var quietLevels = map[string]int{
+	"warning": SevWarning,
+	"error":   SevError,
+}
+
+// ANSI colors of the severities
+var sevColors = map[int]string{
+	SevOK:      "\x1b[32m",
+	SevWarning: "\x1b[33m",
+	SevError:   "\x1b[31m",
+}
+
+const colorReset = "\x1b[0m"
+
+var (
+	errorWords   = regexp.MustCompile(`\b(ERROR|FAIL|FAILED|DOWN|UNVERIFIED|NOT ELIGIBLE|EXPIRED)\b`)
+	warningWords = regexp.MustCompile(`\b(WARNING|WARN|DIFFERENT)\b`)
+	okWords      = regexp.MustCompile(`\b(OK|PASS|ELIGIBLE|UP)\b`)
+	statusLine   = regexp.MustCompile(`^\s*HTTP Status: ([1-5])\d\d\b`)
+)
+
+//
+// lineSeverity - the severity of a report line, from the words the
+// report uses for problems and successes, and the HTTP status line
+// (4xx and 5xx are warnings)
+//
+func lineSeverity(line string) int {
+
+	if m := statusLine.FindStringSubmatch(line); m != nil {
+		if m[1] == "4" || m[1] == "5" {
+			return SevWarning
+		}
+		return SevOK
+	}
+	switch {
+	case errorWords.MatchString(line):
+		return SevError
+	case warningWords.MatchString(line):
+		return SevWarning
+	case okWords.MatchString(line):
+		return SevOK
+	}
+	return SevInfo
+}
+
+//
+// paint - the line in the color of the severity, with -color
+//
+func paint(line string, severity int) string {
+
+	color, ok := sevColors[severity]
+	if !ok || !options.color {
+		return line
+	}
+	return color + line + colorReset
+}
+
+//
+// isTerminal - is the writer a terminal?
+//
+func isTerminal(w io.Writer) bool {
+
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+//
+// colorMode - whether to color the output, for -color never, always or
+// auto: if diagnostic output goes to a terminal, and NO_COLOR isn't set
+//
+func colorMode(mode string) (bool, error) {
+
+	switch mode {
+	case "never":
+		return false, nil
+	case "always":
+		return true, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		return isTerminal(diagOut), nil
+	}
+	return false, fmt.Errorf("must be never, auto or always")
+}
+
+//
+// renderLine - a report line as printed, colored, or "" if -quiet
+// filters it out. Header field lines are left alone, since their values
+// are the server's words, not gohttp's.
+//
+func (r *Report) renderLine(line string) string {
+
+	if strings.HasPrefix(line, "## ") {
+		r.headers = strings.HasPrefix(line, "## HTTP Headers:") || strings.HasPrefix(line, "## HTTP Trailers:")
+	}
+	severity := SevInfo
+	if !r.headers {
+		severity = lineSeverity(line)
+	}
+	if severity < options.quiet {
+		return ""
+	}
+	return paint(strings.TrimSuffix(line, "\n"), severity) + "\n"
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// Config file and environment variable names for the options that
+// have short or ambiguous flag names. All other options use their
+// flag names, e.g. "cacert" or "user-agent".
+//
+var settingAliases = map[string]string{
+	"timeout":     "t",
+	"ipv4":        "4",
+	"ipv6":        "6",
+	"output":      "o",
+	"remote-name": "O",
+}
+
+//
+// Flags that can't be set from a config file or the environment
+//
+var settingExcluded = map[string]bool{
+	"h":      true,
+	"config": true,
+	"t":      true,
+	"4":      true,
+	"6":      true,
+	"o":      true,
+	"O":      true,
+}
+
+//
+// setting - one option value from a config file or the environment
+//
+type setting struct {
+	key    string
+	value  string
+	source string
+}
+
+//
+// defaultConfigFile - the config file read if -config isn't given
+//
+func defaultConfigFile() string {
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gohttp", "config.toml")
+}
+
+//
+// configFile - the config file to read, from -config in args if given,
+// else GOHTTP_CONFIG, else the default. explicit is true if the user
+// named the file, in which case it is an error for it to be missing.
+// This has to be found before the command line is parsed, so that
+// the command line can override the settings in the file.
+//
+func configFile(args []string) (filename string, explicit bool) {
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if strings.HasPrefix(arg, "config=") {
+			return strings.TrimPrefix(arg, "config="), true
+		}
+		if arg == "config" && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	if filename = os.Getenv("GOHTTP_CONFIG"); filename != "" {
+		return filename, true
+	}
+	return defaultConfigFile(), false
+}
+
+//
+// parseConfigValue - parse a TOML value: a basic or literal string,
+// boolean, number, or an array of those. Returns the values as strings.
+//
+func parseConfigValue(s string) ([]string, error) {
+
+	if strings.HasPrefix(s, "[") {
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated array: %s", s)
+		}
+		var values []string
+		elements, err := splitConfigArray(s[1 : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		for _, element := range elements {
+			v, err := parseConfigValue(element)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v...)
+		}
+		return values, nil
+	}
+
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string: %s", s)
+		}
+		return []string{v}, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("invalid string: %s", s)
+		}
+		return []string{s[1 : len(s)-1]}, nil
+	case s == "":
+		return nil, fmt.Errorf("missing value")
+	default:
+		return []string{s}, nil
+	}
+}
+
+//
+// splitConfigArray - split the contents of a TOML array at the commas
+// that aren't inside strings
+//
+func splitConfigArray(s string) ([]string, error) {
+
+	var elements []string
+	var quote rune
+	var escaped bool
+	start := 0
+
+	for i, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && c == '\\':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			elements = append(elements, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated string in array")
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		elements = append(elements, last)
+	}
+	return elements, nil
+}
+
+//
+// stripComment - remove a trailing # comment that isn't inside a string
+//
+func stripComment(line string) string {
+
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+//
+// parseConfig - parse a config file in a subset of TOML: "key = value"
+// lines, with # comments. Tables are not supported.
+//
+func parseConfig(r io.Reader, filename string) ([]setting, error) {
+
+	var settings []setting
+
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		source := fmt.Sprintf("%s:%d", filename, lineno)
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("%s: tables are not supported", source)
+		}
+		tmp := strings.SplitN(line, "=", 2)
+		if len(tmp) != 2 {
+			return nil, fmt.Errorf("%s: expected key = value", source)
+		}
+		key := strings.Trim(strings.TrimSpace(tmp[0]), `"`)
+		values, err := parseConfigValue(strings.TrimSpace(tmp[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", source, key, err)
+		}
+		for _, value := range values {
+			settings = append(settings, setting{key: key, value: value, source: source})
+		}
+	}
+	return settings, scanner.Err()
+}
+
+//
+// readConfig - read the settings in the config file, if there is one
+//
+func readConfig(filename string, explicit bool) ([]setting, error) {
+
+	if filename == "" {
+		return nil, nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return parseConfig(f, filename)
+}
+
+//
+// envName - the environment variable for a setting, e.g. GOHTTP_USER_AGENT
+//
+func envName(key string) string {
+	return "GOHTTP_" + strings.ToUpper(strings.Replace(key, "-", "_", -1))
+}
+
+//
+// envSettings - the settings made by GOHTTP_* environment variables
+//
+func envSettings() []setting {
+
+	var settings []setting
+	var keys []string
+
+	flag.VisitAll(func(f *flag.Flag) {
+		if !settingExcluded[f.Name] {
+			keys = append(keys, f.Name)
+		}
+	})
+	for alias := range settingAliases {
+		keys = append(keys, alias)
+	}
+	for _, key := range keys {
+		name := envName(key)
+		if value, ok := os.LookupEnv(name); ok {
+			settings = append(settings, setting{key: key, value: value, source: name})
+		}
+	}
+	return settings
+}
+
+//
+// applySetting - set the flag a setting refers to
+//
+func applySetting(s setting) error {
+
+	name := s.key
+	if flagname, ok := settingAliases[name]; ok {
+		name = flagname
+	} else if settingExcluded[name] {
+		return fmt.Errorf("%s: option %q cannot be set here", s.source, s.key)
+	}
+	f := flag.Lookup(name)
+	if f == nil {
+		return fmt.Errorf("%s: unknown option %q", s.source, s.key)
+	}
+
+	value := s.value
+	if getter, ok := f.Value.(flag.Getter); ok {
+		if _, isduration := getter.Get().(time.Duration); isduration {
+			// Allow a plain number of seconds, as TOML has no durations.
+			if _, err := strconv.ParseFloat(value, 64); err == nil {
+				value += "s"
+			}
+		}
+	}
+	if err := f.Value.Set(value); err != nil {
+		return fmt.Errorf("%s: invalid value %q for %s: %v", s.source, s.value, s.key, err)
+	}
+	return nil
+}
+
+//
+// loadDefaults - apply the config file and then environment variable
+// settings to the flags, before the command line is parsed, so that
+// the environment overrides the config file and the command line
+// overrides both. Repeatable options like -header accumulate.
+//
+func loadDefaults(args []string) error {
+
+	filename, explicit := configFile(args)
+	settings, err := readConfig(filename, explicit)
+	if err != nil {
+		return err
+	}
+	settings = append(settings, envSettings()...)
+
+	for _, s := range settings {
+		if err := applySetting(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//
+// configHelp - documentation of the config file for the usage message
+//
+const configHelp = `
+    Config file and environment:
+	Options can be given defaults in the config file, one per line as
+	TOML "name = value", e.g. timeout = 10, cacert = "/etc/ca.pem" or
+	header = ["X-A: 1", "X-B: 2"], and in GOHTTP_<NAME> environment
+	variables, e.g. GOHTTP_USER_AGENT. Names are the option names, or
+	timeout, ipv4, ipv6, output and remote-name for -t, -4, -6, -o and
+	-O, and output-format for -output. The environment overrides the
+	file, and the command line overrides both. GOHTTP_CONFIG names an
+	alternate config file.
+`
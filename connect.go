@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// connectSingle - connect to the server for the request (or address, if
+// non-empty), and with -tls-only perform the TLS handshake, reporting
+// the transport without sending the request. Returns a result without
+// a response, holding the total time and any error.
+//
+func connectSingle(w *Report, prober *probe.Prober, request *http.Request, address string) *probe.ProbeResult {
+
+	session := prober.NewSession(address)
+	c := session.Connect(request.URL, options.tlsonly)
+	result := &probe.ProbeResult{
+		ResponseTime: c.ConnectTime + c.HandshakeTime,
+		RemoteAddr:   c.RemoteAddr,
+		LocalAddr:    c.LocalAddr,
+		Err:          c.Err,
+	}
+
+	if c.RemoteAddr != "" {
+		printConnection(w, result)
+		fmt.Fprintf(w, "## Connect Time: %s\n", fmtDuration(c.ConnectTime))
+	}
+	if c.Err != nil {
+		setExitStatus(classifyError(c.Err))
+		fmt.Fprintln(w, c.Err)
+		return result
+	}
+	if options.tlsonly {
+		if c.TLS == nil {
+			fmt.Fprintln(w, "## TLS Connection Info: NONE (not an https URL)")
+			return result
+		}
+		fmt.Fprintf(w, "## TLS Handshake Time: %s\n", fmtDuration(c.HandshakeTime))
+		printTLSinfo(w, &http.Response{TLS: c.TLS})
+	}
+	return result
+}
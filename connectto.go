@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// matches - does the -connect-to rule apply to host and port?
+//
+func (r ConnectToRule) matches(host, port string) bool {
+
+	return (r.host == "" || r.host == strings.ToLower(host)) && (r.port == "" || r.port == port)
+}
+
+//
+// resolveOverride - the -resolve addresses for hostname at port, nil
+// if none were given
+//
+func resolveOverride(hostname, port string) []net.IP {
+	return options.resolve[net.JoinHostPort(strings.ToLower(hostname), port)]
+}
+
+//
+// connectTarget - the host:port -connect-to sends connections to addr
+// (host:port) to instead, "" if no rule applies. The first rule that
+// matches applies, as with curl.
+//
+func connectTarget(addr string) string {
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	for _, rule := range options.connectto {
+		if !rule.matches(host, port) {
+			continue
+		}
+		if rule.tohost != "" {
+			host = rule.tohost
+		}
+		if rule.toport != "" {
+			port = rule.toport
+		}
+		return net.JoinHostPort(host, port)
+	}
+	return ""
+}
+
+//
+// connectHost - the host and port connections for hostname and port go
+// to, with -connect-to
+//
+func connectHost(hostname, port string) (string, string) {
+
+	if target := connectTarget(net.JoinHostPort(hostname, port)); target != "" {
+		if host, tport, err := net.SplitHostPort(target); err == nil {
+			return host, tport
+		}
+	}
+	return hostname, port
+}
+
+//
+// connectTo - the addresses to connect to for addr (host:port): those
+// -resolve gives, for the host:port -connect-to sends it to, if any,
+// else that host:port; nil to connect to addr as usual
+//
+func connectTo(addr string) []string {
+
+	target := connectTarget(addr)
+	if target == "" {
+		target = addr
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil
+	}
+	if ips := resolveOverride(host, port); ips != nil {
+		addrs := []string{}
+		for _, ip := range filterAddresses(ips) {
+			addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+		}
+		return addrs
+	}
+	if target != addr {
+		return []string{target}
+	}
+	return nil
+}
+
+//
+// connectToFunc - the probe's ConnectTo function, if -resolve or
+// -connect-to were given
+//
+func connectToFunc() probe.ConnectToFunc {
+
+	if options.resolve == nil && options.connectto == nil {
+		return nil
+	}
+	return connectTo
+}
+
+//
+// printConnectTo - report where -connect-to sends the connections for
+// hostname and port, and that -resolve gave their addresses
+//
+func printConnectTo(w io.Writer, hostname, port string) {
+
+	host, tport := connectHost(hostname, port)
+	if host != hostname || tport != port {
+		fmt.Fprintf(w, "Connect to: %s (-connect-to)\n", net.JoinHostPort(host, tport))
+	}
+	if resolveOverride(host, tport) != nil {
+		fmt.Fprintln(w, "Addresses from: -resolve")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// printContinue - report how the server handled Expect: 100-continue:
+// whether it sent 100 Continue and when, after the request headers, or
+// answered with the final response before the body was sent
+//
+func printContinue(w io.Writer, c *probe.ContinueReport, response *http.Response) {
+
+	fmt.Fprintln(w, "## Expect: 100-continue:")
+	switch {
+	case !c.BodySent:
+		fmt.Fprintf(w, "   REJECTED before the body was sent: %d %s after %s\n",
+			response.StatusCode, http.StatusText(response.StatusCode), fmtDuration(c.FinalTime))
+		return
+	case c.Continued && c.ContinueTime <= c.BodyTime:
+		fmt.Fprintf(w, "   100 Continue after %s, body sent\n", fmtDuration(c.ContinueTime))
+	default:
+		fmt.Fprintf(w, "   No 100 Continue within %s, body sent anyway after %s\n",
+			fmtDuration(defaultContinueWait), fmtDuration(c.BodyTime))
+		if c.Continued {
+			fmt.Fprintf(w, "   100 Continue came late, after %s\n", fmtDuration(c.ContinueTime))
+		}
+	}
+	fmt.Fprintf(w, "   Final response after %s\n", fmtDuration(c.FinalTime))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Longest cookie lifetime browsers honor; longer ones are capped
+var maxCookieLifetime = 400 * 24 * time.Hour
+
+//
+// auditCookie - the findings for a cookie set by a response to request
+//
+func auditCookie(request *http.Request, c *http.Cookie) []string {
+
+	var findings []string
+	secure := request.URL.Scheme == "https"
+	host := strings.ToLower(request.URL.Hostname())
+
+	if !c.Secure {
+		findings = append(findings, "no Secure attribute; sent over plain HTTP too")
+	} else if !secure {
+		findings = append(findings, "Secure cookie set over plain HTTP; browsers will reject it")
+	}
+	if !c.HttpOnly {
+		findings = append(findings, "no HttpOnly attribute; readable by scripts")
+	}
+	switch c.SameSite {
+	case 0:
+		findings = append(findings, "no SameSite attribute; browsers default to Lax")
+	case http.SameSiteDefaultMode:
+		findings = append(findings, "invalid SameSite value")
+	case http.SameSiteNoneMode:
+		if !c.Secure {
+			findings = append(findings, "SameSite=None without Secure; browsers will reject it")
+		} else {
+			findings = append(findings, "SameSite=None; sent on cross-site requests")
+		}
+	}
+
+	var lifetime time.Duration
+	switch {
+	case c.MaxAge > 0:
+		lifetime = time.Duration(c.MaxAge) * time.Second
+	case c.MaxAge == 0 && !c.Expires.IsZero():
+		lifetime = time.Until(c.Expires)
+	}
+	if lifetime > maxCookieLifetime {
+		findings = append(findings, fmt.Sprintf(
+			"lifetime of %d days exceeds the %d days browsers allow",
+			int(lifetime.Hours()/24), int(maxCookieLifetime.Hours()/24)))
+	}
+
+	if c.Domain != "" {
+		domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		switch {
+		case isPublicSuffix(domain):
+			findings = append(findings, fmt.Sprintf(
+				"Domain=%s is a public suffix; browsers will reject it", c.Domain))
+		case !domainMatch(host, domain):
+			findings = append(findings, fmt.Sprintf(
+				"Domain=%s does not domain-match %s; browsers will reject it", c.Domain, host))
+		case domain != host:
+			findings = append(findings, fmt.Sprintf(
+				"Domain=%s; sent to every subdomain of %s", c.Domain, domain))
+		}
+	}
+	if c.Path == "" {
+		findings = append(findings, "no Path attribute; scoped to the request's directory")
+	}
+
+	switch {
+	case strings.HasPrefix(c.Name, "__Host-"):
+		if !c.Secure || !secure || c.Domain != "" || c.Path != "/" {
+			findings = append(findings,
+				"__Host- prefix requires Secure, an https origin, no Domain and Path=/; browsers will reject it")
+		}
+	case strings.HasPrefix(c.Name, "__Secure-"):
+		if !c.Secure || !secure {
+			findings = append(findings,
+				"__Secure- prefix requires Secure and an https origin; browsers will reject it")
+		}
+	}
+	return findings
+}
+
+//
+// printCookieAudit - audit the cookies set by the response and by any
+// redirects that led to it
+//
+func printCookieAudit(w io.Writer, response *http.Response) {
+
+	fmt.Fprintln(w, "## Cookie Audit:")
+	ncookies, nfindings := 0, 0
+	for _, r := range redirectChain(response) {
+		for _, c := range r.Cookies() {
+			ncookies++
+			findings := auditCookie(r.Request, c)
+			nfindings += len(findings)
+			fmt.Fprintf(w, "   %s (from %s):", c.Name, r.Request.URL)
+			if len(findings) == 0 {
+				fmt.Fprintln(w, " OK")
+				continue
+			}
+			fmt.Fprintln(w)
+			for _, finding := range findings {
+				fmt.Fprintf(w, "      WARNING: %s\n", finding)
+			}
+		}
+	}
+	if ncookies == 0 {
+		fmt.Fprintln(w, "   No cookies set")
+		return
+	}
+	fmt.Fprintf(w, "   %d cookies, %d findings\n", ncookies, nfindings)
+}
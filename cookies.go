@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Prefix marking HttpOnly cookies in Netscape cookie files
+const httpOnlyPrefix = "#HttpOnly_"
+
+//
+// jarEntry - a cookie as stored in a Netscape format cookie file
+//
+type jarEntry struct {
+	domain     string // Without a leading dot
+	subdomains bool   // Sent to subdomains, i.e. a Domain cookie
+	path       string
+	secure     bool
+	httponly   bool
+	expires    time.Time // Zero for a session cookie
+	name       string
+	value      string
+}
+
+func (e *jarEntry) key() string {
+	return e.domain + "\t" + e.path + "\t" + e.name
+}
+
+//
+// CookieJar - an http.CookieJar that also keeps the cookies it accepts
+// in a form that can be saved to a cookie file
+//
+type CookieJar struct {
+	jar     *cookiejar.Jar
+	mu      sync.Mutex
+	entries map[string]*jarEntry
+}
+
+func newCookieJar() *CookieJar {
+
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &CookieJar{jar: jar, entries: make(map[string]*jarEntry)}
+}
+
+//
+// Cookies - the cookies to send to u
+//
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+//
+// defaultCookiePath - the default path of a cookie set by a response
+// to u (RFC 6265 section 5.1.4)
+//
+func defaultCookiePath(u *url.URL) string {
+
+	dir := path.Dir(u.Path)
+	if !strings.HasPrefix(u.Path, "/") || dir == "." {
+		return "/"
+	}
+	return dir
+}
+
+//
+// SetCookies - store the cookies set by a response to u, recording
+// those the underlying jar accepted, and forgetting deleted ones
+//
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+
+	j.jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, c := range cookies {
+		e := &jarEntry{
+			domain:   strings.ToLower(u.Hostname()),
+			path:     c.Path,
+			secure:   c.Secure,
+			httponly: c.HttpOnly,
+			name:     c.Name,
+			value:    c.Value,
+		}
+		if c.Domain != "" {
+			e.domain = strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+			e.subdomains = true
+		}
+		if e.path == "" || e.path[0] != '/' {
+			e.path = defaultCookiePath(u)
+		}
+		switch {
+		case c.MaxAge > 0:
+			e.expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case c.MaxAge < 0:
+			e.expires = now
+		case !c.Expires.IsZero():
+			e.expires = c.Expires
+		}
+		if !e.expires.IsZero() && !e.expires.After(now) {
+			delete(j.entries, e.key())
+			continue
+		}
+		if j.accepted(u, e) {
+			j.entries[e.key()] = e
+		}
+	}
+}
+
+//
+// accepted - did the underlying jar accept the cookie set by a response
+// to u? It did if the cookie would now be sent back to u at its path.
+//
+func (j *CookieJar) accepted(u *url.URL, e *jarEntry) bool {
+
+	target := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: e.path}
+	for _, c := range j.jar.Cookies(target) {
+		if c.Name == e.name && c.Value == e.value {
+			return true
+		}
+	}
+	return false
+}
+
+//
+// Load - add the cookies in a Netscape format cookie file, as written
+// by curl and browsers. Expired cookies are skipped.
+//
+func (j *CookieJar) Load(r io.Reader) error {
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httponly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httponly {
+			line = line[len(httpOnlyPrefix):]
+		} else if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("line %d: expected 7 tab separated fields", n)
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid expiry: %q", n, fields[4])
+		}
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httponly,
+		}
+		if expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+			if cookie.Expires.Before(time.Now()) {
+				continue
+			}
+		}
+		domain := strings.TrimPrefix(fields[0], ".")
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+		u := &url.URL{Scheme: "http", Host: domain, Path: cookie.Path}
+		if cookie.Secure {
+			u.Scheme = "https"
+		}
+		j.SetCookies(u, []*http.Cookie{cookie})
+	}
+	return scanner.Err()
+}
+
+//
+// Save - write the cookies in Netscape cookie file format, including
+// session cookies, with an expiry of 0
+//
+func (j *CookieJar) Save(w io.Writer) error {
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var keys []string
+	for key := range j.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Netscape HTTP Cookie File")
+	fmt.Fprintf(bw, "# Written by %s %s\n\n", progname, Version)
+	tf := map[bool]string{true: "TRUE", false: "FALSE"}
+	for _, key := range keys {
+		e := j.entries[key]
+		if !e.expires.IsZero() && e.expires.Before(time.Now()) {
+			continue
+		}
+		domain := e.domain
+		if e.subdomains {
+			domain = "." + domain
+		}
+		if e.httponly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expiry int64
+		if !e.expires.IsZero() {
+			expiry = e.expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, tf[e.subdomains],
+			e.path, tf[e.secure], expiry, e.name, e.value)
+	}
+	return bw.Flush()
+}
+
+//
+// loadCookieJar - create the jar, loading the -cookie-jar file if it
+// exists
+//
+func loadCookieJar(filename string) (*CookieJar, error) {
+
+	jar := newCookieJar()
+	if filename == "" {
+		return jar, nil
+	}
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := jar.Load(f); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return jar, nil
+}
+
+//
+// saveCookieJar - save the jar to the -cookie-jar file, if one was given
+//
+func saveCookieJar() {
+
+	if options.cookiejarfile == "" {
+		return
+	}
+	f, err := os.OpenFile(options.cookiejarfile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err == nil {
+		err = options.cookiejar.Save(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -cookie-jar: %v\n", err)
+		setExitStatus(ExitOther)
+	}
+}
+
+//
+// parseCookies - parse a -cookie "name=value" string, which may hold
+// several cookies separated by semicolons
+//
+func parseCookies(s string) ([]*http.Cookie, error) {
+
+	var cookies []*http.Cookie
+	for _, pair := range strings.Split(s, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		tmp := strings.SplitN(pair, "=", 2)
+		if len(tmp) != 2 || tmp[0] == "" || !httpguts.ValidHeaderFieldName(tmp[0]) {
+			return nil, fmt.Errorf("invalid cookie %q: must be name=value", pair)
+		}
+		if !httpguts.ValidHeaderFieldValue(tmp[1]) || strings.ContainsAny(tmp[1], "; ") {
+			return nil, fmt.Errorf("invalid cookie %q: illegal character in value", pair)
+		}
+		cookies = append(cookies, &http.Cookie{Name: tmp[0], Value: tmp[1]})
+	}
+	if cookies == nil {
+		return nil, fmt.Errorf("invalid cookie %q: must be name=value", s)
+	}
+	return cookies, nil
+}
+
+//
+// printSetCookies - print the attributes of each cookie the response
+// sets
+//
+func printSetCookies(w io.Writer, response *http.Response) {
+
+	cookies := response.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+	sameSite := map[http.SameSite]string{
+		http.SameSiteLaxMode:    "Lax",
+		http.SameSiteStrictMode: "Strict",
+		http.SameSiteNoneMode:   "None",
+	}
+	fmt.Fprintln(w, "## Set-Cookie:")
+	for _, c := range cookies {
+		var attrs []string
+		if c.Domain != "" {
+			attrs = append(attrs, "Domain="+c.Domain)
+		} else {
+			attrs = append(attrs, "host-only")
+		}
+		if c.Path != "" {
+			attrs = append(attrs, "Path="+c.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			attrs = append(attrs, "Max-Age=0 (deletes the cookie)")
+		case c.MaxAge > 0:
+			attrs = append(attrs, fmt.Sprintf("Max-Age=%d", c.MaxAge))
+		case !c.Expires.IsZero():
+			attrs = append(attrs, "Expires="+formatTime(c.Expires))
+		default:
+			attrs = append(attrs, "session")
+		}
+		if c.Secure {
+			attrs = append(attrs, "Secure")
+		}
+		if c.HttpOnly {
+			attrs = append(attrs, "HttpOnly")
+		}
+		if s, ok := sameSite[c.SameSite]; ok {
+			attrs = append(attrs, "SameSite="+s)
+		} else {
+			attrs = append(attrs, "SameSite not set")
+		}
+		fmt.Fprintf(w, "   %s: %s\n", c.Name, strings.Join(attrs, ", "))
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+	"golang.org/x/net/http/httpguts"
+)
+
+//
+// CORSSpec - the cross-origin request to check a preflight for
+//
+type CORSSpec struct {
+	origin  string
+	method  string
+	headers []string
+}
+
+// Methods that don't need to be allowed by a preflight (Fetch standard)
+var corsSafeMethods = map[string]bool{"GET": true, "HEAD": true, "POST": true}
+
+//
+// Request headers that don't need to be allowed by a preflight. Content-
+// Type is only safelisted for form and text/plain values, so is treated
+// as needing to be allowed, as for JSON bodies.
+//
+var corsSafeHeaders = map[string]bool{
+	"accept":           true,
+	"accept-language":  true,
+	"content-language": true,
+}
+
+//
+// parseCORSHeaders - the lower case, sorted, de-duplicated header names
+// of a comma separated -cors-headers list
+//
+func parseCORSHeaders(s string) ([]string, error) {
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("invalid header name: %q", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+//
+// listContains - does the comma separated header value list contain
+// item, or the wildcard if wildcard is true?
+//
+func listContains(values []string, item string, fold, wildcard bool) bool {
+
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if (wildcard && v == "*") || v == item || (fold && strings.EqualFold(v, item)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//
+// evaluateCORS - check the preflight response against the intended
+// request, as a browser would for a request without credentials, and
+// return the reasons it would be blocked (none if it is permitted)
+//
+func evaluateCORS(spec *CORSSpec, response *http.Response) []string {
+
+	var problems []string
+	header := response.Header
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		problems = append(problems, fmt.Sprintf("preflight status %d is not 2xx", response.StatusCode))
+	}
+
+	acao := header.Values("Access-Control-Allow-Origin")
+	switch {
+	case len(acao) == 0:
+		problems = append(problems, "no Access-Control-Allow-Origin")
+	case len(acao) > 1 || strings.Contains(acao[0], ","):
+		problems = append(problems, "several Access-Control-Allow-Origin values")
+	case acao[0] != "*" && acao[0] != spec.origin:
+		problems = append(problems, fmt.Sprintf("Access-Control-Allow-Origin %q doesn't match %s", acao[0], spec.origin))
+	}
+
+	methods := header.Values("Access-Control-Allow-Methods")
+	if !corsSafeMethods[spec.method] && !listContains(methods, spec.method, false, true) {
+		problems = append(problems, fmt.Sprintf("method %s not in Access-Control-Allow-Methods", spec.method))
+	}
+
+	allowed := header.Values("Access-Control-Allow-Headers")
+	for _, name := range spec.headers {
+		if corsSafeHeaders[name] {
+			continue
+		}
+		// The wildcard doesn't cover Authorization.
+		if !listContains(allowed, name, true, name != "authorization") {
+			problems = append(problems, fmt.Sprintf("header %s not in Access-Control-Allow-Headers", name))
+		}
+	}
+	return problems
+}
+
+//
+// checkCORS - send the CORS preflight for the -cors-* request to the
+// URL, and report whether the response permits it. A blocked request
+// sets exit status 1, as a failed assertion does.
+//
+func checkCORS(w io.Writer, session *probe.Session, request *http.Request) {
+
+	spec := options.cors
+	preflight, err := http.NewRequestWithContext(request.Context(), http.MethodOptions, request.URL.String(), nil)
+	if err != nil {
+		fmt.Fprintf(w, "## CORS Preflight: %v\n", err)
+		return
+	}
+	preflight.Header.Set("Origin", spec.origin)
+	preflight.Header.Set("Access-Control-Request-Method", spec.method)
+	if len(spec.headers) > 0 {
+		preflight.Header.Set("Access-Control-Request-Headers", strings.Join(spec.headers, ","))
+	}
+	if ua := request.Header.Get("User-Agent"); ua != "" {
+		preflight.Header.Set("User-Agent", ua)
+	}
+
+	fmt.Fprintf(w, "## CORS Preflight: %s from %s", spec.method, spec.origin)
+	if len(spec.headers) > 0 {
+		fmt.Fprintf(w, " with %s", strings.Join(spec.headers, ", "))
+	}
+	fmt.Fprintln(w)
+	result := session.Do(preflight)
+	if result.Err != nil {
+		fmt.Fprintf(w, "   Preflight failed: %v\n", result.Err)
+		setExitStatus(ExitAssertion)
+		return
+	}
+	response := result.Response
+	fmt.Fprintf(w, "   Status: %s\n", response.Status)
+	for _, key := range capabilityHeaders {
+		if strings.HasPrefix(key, "Access-Control-") || key == "Vary" {
+			if values := response.Header.Values(key); len(values) > 0 {
+				fmt.Fprintf(w, "   %s: %s\n", key, strings.Join(values, ", "))
+			}
+		}
+	}
+
+	if corsSafeMethods[spec.method] {
+		simple := true
+		for _, name := range spec.headers {
+			simple = simple && corsSafeHeaders[name]
+		}
+		if simple {
+			fmt.Fprintln(w, "   Note: browsers send this request without a preflight; only")
+			fmt.Fprintln(w, "         Access-Control-Allow-Origin on its response matters")
+		}
+	}
+	if problems := evaluateCORS(spec, response); len(problems) > 0 {
+		fmt.Fprintln(w, "   Result: BLOCKED")
+		for _, p := range problems {
+			fmt.Fprintf(w, "      %s\n", p)
+		}
+		setExitStatus(ExitAssertion)
+		return
+	}
+	fmt.Fprintln(w, "   Result: PERMITTED")
+	if response.Header.Get("Access-Control-Allow-Origin") == "*" {
+		fmt.Fprintln(w, "   Note: the wildcard origin doesn't permit requests with credentials")
+	} else if !strings.EqualFold(response.Header.Get("Access-Control-Allow-Credentials"), "true") {
+		fmt.Fprintln(w, "   Note: requests with credentials need Access-Control-Allow-Credentials: true")
+	}
+}
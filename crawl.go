@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Limits on a crawl: redirects followed for each URL, and URLs probed
+var (
+	crawlMaxRedirects = 10
+	crawlMaxURLs      = 500
+)
+
+//
+// CrawlTarget - a URL to probe in a crawl, and where it was found
+//
+type CrawlTarget struct {
+	url      string
+	depth    int
+	referrer string // Page linking to it, "" for the starting URLs
+	origin   string // Host of the starting URL it was reached from
+}
+
+//
+// CrawlResult - the outcome of probing a URL in a crawl
+//
+type CrawlResult struct {
+	target CrawlTarget
+	chain  []string // Redirects followed: "301 https://..."
+	status int      // Final status, 0 if the request failed
+	err    error
+	mixed  []string // http:// subresources of an https page
+}
+
+//
+// crawlLinks - the http and https URLs of the page's links, resolved
+// and without fragments, and the mixed content among them
+//
+func crawlLinks(page *url.URL, body []byte) (links, mixed []string) {
+
+	summary := parseHTMLSummary(body)
+	base := pageBase(page, summary)
+	for _, link := range summary.Links {
+		u, err := url.Parse(resolveLink(base, link.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		u.Fragment = ""
+		links = append(links, u.String())
+	}
+	for _, ref := range mixedContent(page, base, summary.Links) {
+		mixed = append(mixed, ref.Tag+" "+ref.URL)
+	}
+	return links, mixed
+}
+
+//
+// crawlOne - probe the target, following redirects (recording each hop)
+// up to crawlMaxRedirects, and return the result and, if the final
+// response is an HTML page, its links
+//
+func crawlOne(prober *probe.Prober, target CrawlTarget) (*CrawlResult, []string) {
+
+	cr := &CrawlResult{target: target}
+	hops, result, err := traceRedirects(prober, target.url, crawlMaxRedirects)
+	for _, hop := range hops {
+		cr.chain = append(cr.chain, fmt.Sprintf("%d %s", hop.Status, hop.Next))
+	}
+	if err == nil {
+		err = result.Err
+	}
+	if err != nil {
+		cr.err = err
+		return cr, nil
+	}
+
+	response := result.Response
+	cr.status = response.StatusCode
+	mediatype, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if response.StatusCode != http.StatusOK || mediatype != "text/html" {
+		return cr, nil
+	}
+	links, mixed := crawlLinks(response.Request.URL, result.Body)
+	cr.mixed = mixed
+	return cr, links
+}
+
+//
+// crawl - the -crawl mode: probe the URLs, and breadth-first, the links
+// on the HTML pages found, to -depth levels (only those on the same host
+// as the starting URL with -same-host), printing a line per URL, and
+// then summaries of the broken links, redirect chains and mixed content.
+// Returns the exit status.
+//
+func crawl(prober *probe.Prober, urls []string) int {
+
+	// Redirects are followed by crawlOne, to record the chains.
+	opts := prober.Options
+	opts.NoRedirect = true
+	prober, err := probe.NewProber(opts)
+	if err != nil {
+		fatal(ExitOther, err)
+	}
+
+	var queue []CrawlTarget
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		if !seen[u] {
+			seen[u] = true
+			parsed, _ := url.Parse(u)
+			queue = append(queue, CrawlTarget{url: u, origin: parsed.Hostname()})
+		}
+	}
+
+	fmt.Printf("## Crawl (depth %d):\n", options.crawldepth)
+	var results []*CrawlResult
+	for len(queue) > 0 && len(results) < crawlMaxURLs {
+		target := queue[0]
+		queue = queue[1:]
+		cr, links := crawlOne(prober, target)
+		results = append(results, cr)
+
+		outcome := fmt.Sprint(cr.status)
+		if cr.err != nil {
+			outcome = "ERROR"
+		}
+		if len(cr.chain) > 0 {
+			outcome = fmt.Sprintf("%s after %d redirects", outcome, len(cr.chain))
+		}
+		fmt.Printf("   [%d] %s %s\n", target.depth, target.url, outcome)
+
+		if target.depth >= options.crawldepth {
+			continue
+		}
+		for _, link := range links {
+			u, _ := url.Parse(link)
+			if seen[link] || (options.samehost && u.Hostname() != target.origin) {
+				continue
+			}
+			seen[link] = true
+			queue = append(queue, CrawlTarget{url: link, depth: target.depth + 1,
+				referrer: target.url, origin: target.origin})
+		}
+	}
+	if len(queue) > 0 {
+		fmt.Printf("   Stopped after %d URLs, %d not probed\n", crawlMaxURLs, len(queue))
+	}
+
+	status := ExitOK
+	fmt.Printf("## Crawl Summary: %d URLs probed\n", len(results))
+	fmt.Println("## Broken Links:")
+	broken := 0
+	for _, cr := range results {
+		if cr.err == nil && cr.status < 400 {
+			continue
+		}
+		broken++
+		what := fmt.Sprint(cr.status)
+		if cr.err != nil {
+			what = "ERROR: " + cr.err.Error()
+		}
+		fmt.Printf("   %s %s\n", cr.target.url, what)
+		if cr.target.referrer != "" {
+			fmt.Printf("      linked from %s\n", cr.target.referrer)
+		}
+		status = ExitHTTPError
+	}
+	if broken == 0 {
+		fmt.Println("   none")
+	}
+
+	fmt.Println("## Redirect Chains:")
+	chains := 0
+	for _, cr := range results {
+		if len(cr.chain) == 0 {
+			continue
+		}
+		chains++
+		fmt.Printf("   %s\n", cr.target.url)
+		for _, hop := range cr.chain {
+			fmt.Printf("      %s\n", hop)
+		}
+		if cr.err == nil {
+			fmt.Printf("      %d\n", cr.status)
+		}
+	}
+	if chains == 0 {
+		fmt.Println("   none")
+	}
+
+	fmt.Println("## Mixed Content:")
+	mixed := 0
+	for _, cr := range results {
+		for _, m := range cr.mixed {
+			mixed++
+			fmt.Printf("   %s: %s\n", cr.target.url, m)
+		}
+	}
+	if mixed == 0 {
+		fmt.Println("   none")
+	}
+	return status
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Latencies shown in a dashboard sparkline
+const sparkWidth = 30
+
+// Sparkline levels, lowest to highest
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// Terminal control sequences of the dashboard
+const (
+	termAltScreen  = "\x1b[?1049h\x1b[?25l" // Switch to the alternate screen, hide the cursor
+	termMainScreen = "\x1b[?25h\x1b[?1049l" // Show the cursor, back to the main screen
+	termHome       = "\x1b[H\x1b[2J"        // Clear the screen
+)
+
+//
+// dashboardSize - the size of the terminal the dashboard is drawn on,
+// from the terminal, else the COLUMNS and LINES environment variables,
+// else 80x24
+//
+func dashboardSize() (cols, rows int) {
+
+	cols, rows = terminalSize(os.Stdout)
+	if cols == 0 {
+		cols, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+	}
+	if rows == 0 {
+		rows, _ = strconv.Atoi(os.Getenv("LINES"))
+	}
+	if cols <= 0 {
+		cols = 80
+	}
+	if rows <= 0 {
+		rows = 24
+	}
+	return cols, rows
+}
+
+//
+// sparkline - the recent latencies as a bar each, scaled between the
+// lowest and highest of them; failed probes are an x
+//
+func sparkline(trend []time.Duration) string {
+
+	var min, max time.Duration
+	for _, d := range trend {
+		if d < 0 {
+			continue
+		}
+		if min == 0 || d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+	}
+	var b strings.Builder
+	for _, d := range trend {
+		switch {
+		case d < 0:
+			b.WriteString(paint("x", SevError))
+		case max == min:
+			b.WriteRune(sparkLevels[0])
+		default:
+			level := int(float64(d-min) / float64(max-min) * float64(len(sparkLevels)-1))
+			b.WriteRune(sparkLevels[level])
+		}
+	}
+	return b.String()
+}
+
+//
+// certCountdown - time left before the certificate expires, as days
+// and hours, and its severity: a warning within certExpiryWarning days
+//
+func certCountdown(expiry time.Time) (string, int) {
+
+	if expiry.IsZero() {
+		return "-", SevInfo
+	}
+	left := time.Until(expiry)
+	if left < 0 {
+		return "EXPIRED", SevError
+	}
+	days, hours := int(left.Hours())/24, int(left.Hours())%24
+	text := fmt.Sprintf("%dd %dh", days, hours)
+	if days < certExpiryWarning {
+		return text, SevWarning
+	}
+	return text, SevOK
+}
+
+//
+// shortDuration - a response time rounded for the dashboard's columns
+//
+func shortDuration(d time.Duration) string {
+
+	switch {
+	case d >= time.Second:
+		d = d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		d = d.Round(10 * time.Microsecond)
+	}
+	return fmtDuration(d)
+}
+
+//
+// cell - the text padded to width, then colored: color codes take no
+// room on the screen, so they are added after padding
+//
+func cell(text string, width, severity int) string {
+
+	if n := utf8.RuneCountInString(text); n < width {
+		text += strings.Repeat(" ", width-n)
+	}
+	return paint(text, severity)
+}
+
+//
+// dashboardRow - the dashboard line for one monitored URL
+//
+func dashboardRow(m *MonitorStats, urlwidth int) string {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, status, latency := "-", "-", "-"
+	severity := SevInfo
+	if r := m.last; r != nil {
+		switch {
+		case r.Err != nil:
+			state, status, severity = "DOWN", "error", SevError
+		case r.Response.StatusCode >= 400:
+			state, status, severity = "DOWN", strconv.Itoa(r.Response.StatusCode), SevWarning
+		default:
+			state, status, severity = "UP", strconv.Itoa(r.Response.StatusCode), SevOK
+		}
+		if r.Err == nil {
+			latency = shortDuration(r.ResponseTime)
+		}
+	}
+	avail := "-"
+	if m.probes > 0 {
+		avail = fmt.Sprintf("%.2f%%", m.availability())
+	}
+	cert, certsev := certCountdown(m.expiry)
+
+	urlstring := m.url
+	if utf8.RuneCountInString(urlstring) > urlwidth {
+		urlstring = string([]rune(urlstring)[:urlwidth-1]) + "…"
+	}
+	return cell(state, 5, severity) + " " + cell(status, 6, severity) + " " +
+		cell(latency, 10, SevInfo) + " " + cell(avail, 8, SevInfo) + " " +
+		cell(cert, 9, certsev) + " " + sparkline(m.trend) +
+		strings.Repeat(" ", sparkWidth-len(m.trend)) + " " + urlstring
+}
+
+//
+// drawDashboard - redraw the -dashboard screen: a line per URL with its
+// latest state, status and response time, its availability, the time
+// left on its certificate and a sparkline of its recent latencies
+//
+func drawDashboard(w io.Writer, stats []*MonitorStats, next time.Time) {
+
+	cols, rows := dashboardSize()
+	var b bytes.Buffer
+	b.WriteString(termHome)
+	wait := time.Until(next).Round(time.Second)
+	if wait < 0 {
+		wait = 0
+	}
+	fmt.Fprintf(&b, "%s monitor: %d URLs every %s, next probe in %s; Ctrl-C to stop\n",
+		progname, len(stats), options.interval, wait)
+	fmt.Fprintf(&b, "%s\n\n", formatTime(time.Now().Truncate(time.Second)))
+	fmt.Fprintf(&b, "%-5s %-6s %-10s %-8s %-9s %-*s %s\n",
+		"STATE", "STATUS", "LATENCY", "AVAIL", "CERT", sparkWidth, "TREND", "URL")
+
+	urlwidth := cols - (5 + 6 + 10 + 8 + 9 + sparkWidth + 6)
+	if urlwidth < 10 {
+		urlwidth = 10
+	}
+	for i, m := range stats {
+		if i >= rows-5 {
+			fmt.Fprintf(&b, "(%d more URLs not shown)\n", len(stats)-i)
+			break
+		}
+		fmt.Fprintln(&b, dashboardRow(m, urlwidth))
+	}
+	outputLock.Lock()
+	w.Write(b.Bytes())
+	outputLock.Unlock()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// printDigests - print body hashes, and verify any integrity headers
+// the server sent against them.
+//
+func printDigests(w io.Writer, result *probe.ProbeResult) {
+
+	fmt.Fprintln(w, "## Body Digests:")
+	fmt.Fprintf(w, "   SHA-256: %x\n", result.Digests["sha-256"])
+	fmt.Fprintf(w, "   SHA-512: %x\n", result.Digests["sha-512"])
+
+	if result.Truncated {
+		fmt.Fprintln(w, "   NOTE: body was truncated (-max-body); digests cover a prefix only")
+	}
+	if result.Response.Uncompressed {
+		fmt.Fprintln(w, "   NOTE: body was transparently decompressed; digests cover decoded content")
+	}
+
+	for _, d := range probe.ServerDigests(result.Response.Header) {
+		label := fmt.Sprintf("   %s %s:", d.Header, d.Algorithm)
+		computed, ok := result.Digests[d.Algorithm]
+		switch {
+		case d.Err != nil:
+			fmt.Fprintf(w, "%s ERROR %v\n", label, d.Err)
+		case !ok:
+			fmt.Fprintf(w, "%s unsupported algorithm\n", label)
+		case d.Header != "Content-Digest" &&
+			result.Response.StatusCode == http.StatusPartialContent:
+			fmt.Fprintf(w, "%s cannot verify against partial content\n", label)
+		case bytes.Equal(computed, d.Value):
+			fmt.Fprintf(w, "%s OK\n", label)
+		default:
+			fmt.Fprintf(w, "%s MISMATCH (server %x, body %x)\n", label, d.Value, computed)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// TTL assumed for names whose TTL can't be learned from DNS, e.g.
+// those in the hosts file
+var defaultDNSTTL = 60 * time.Second
+
+//
+// DNSLookup - how a hostname was resolved through the cache
+//
+type DNSLookup struct {
+	Hit       bool          // Answered from the cache
+	TTL       time.Duration // TTL the entry is cached for
+	Remaining time.Duration // Time left before the entry expires
+	Source    string        // Where the TTL came from
+}
+
+//
+// String - a description of the lookup for reports
+//
+func (l DNSLookup) String() string {
+
+	if l.Hit {
+		return fmt.Sprintf("cache hit, expires in %s (TTL %s, %s)",
+			fmtTTL(l.Remaining), fmtTTL(l.TTL), l.Source)
+	}
+	return fmt.Sprintf("cache miss, cached for %s (%s)", fmtTTL(l.TTL), l.Source)
+}
+
+type dnsEntry struct {
+	addrs   []net.IP
+	ttl     time.Duration
+	source  string
+	expires time.Time
+	hits    int
+	misses  int
+}
+
+//
+// DNSCache - caches hostname resolutions for the duration of a run in
+// the multi-request modes, honoring the record TTLs (or -dns-ttl-override)
+//
+type DNSCache struct {
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+// The run's DNS cache, if one is in use
+var dnsCache *DNSCache
+
+func newDNSCache() *DNSCache {
+	return &DNSCache{entries: make(map[string]*dnsEntry)}
+}
+
+//
+// dnsResolver - the resolver for the prober's dialer: the DNS cache, if
+// one is in use
+//
+func dnsResolver() probe.ResolveFunc {
+
+	if dnsCache == nil {
+		return nil
+	}
+	return dnsCache.Resolve
+}
+
+//
+// ttlFor - the TTL to cache hostname's addresses for, and its source
+//
+func ttlFor(hostname string) (time.Duration, string) {
+
+	if options.dnsttl >= 0 {
+		return options.dnsttl, "override"
+	}
+	if ttl, err := lookupTTL(hostname); err == nil {
+		return ttl, "record TTL"
+	}
+	return defaultDNSTTL, "default, record TTL unavailable"
+}
+
+//
+// lookup - resolve hostname, from the cache if its entry hasn't
+// expired. If count is false, the lookup isn't counted as a hit or
+// miss, as for the dialer's use of an entry just reported on.
+//
+func (c *DNSCache) lookup(ctx context.Context, hostname string, count bool) ([]net.IP, DNSLookup, error) {
+
+	c.mu.Lock()
+	entry := c.entries[hostname]
+	if now := time.Now(); entry != nil && now.Before(entry.expires) {
+		if count {
+			entry.hits++
+		}
+		lookup := DNSLookup{Hit: true, TTL: entry.ttl,
+			Remaining: entry.expires.Sub(now), Source: entry.source}
+		c.mu.Unlock()
+		return entry.addrs, lookup, nil
+	}
+	c.mu.Unlock()
+
+	addrs, err := net.DefaultResolver.LookupIP(ctx, "ip", hostname)
+	if err != nil {
+		return nil, DNSLookup{}, err
+	}
+	ttl, source := ttlFor(hostname)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry = c.entries[hostname]; entry == nil {
+		entry = new(dnsEntry)
+		c.entries[hostname] = entry
+	}
+	entry.addrs, entry.ttl, entry.source = addrs, ttl, source
+	entry.expires = time.Now().Add(ttl)
+	if count {
+		entry.misses++
+	}
+	return addrs, DNSLookup{TTL: ttl, Source: source}, nil
+}
+
+//
+// Lookup - resolve hostname through the cache, counting the lookup
+//
+func (c *DNSCache) Lookup(hostname string) ([]net.IP, DNSLookup, error) {
+	return c.lookup(runContext, hostname, true)
+}
+
+//
+// Resolve - resolve hostname through the cache for the dialer
+//
+func (c *DNSCache) Resolve(ctx context.Context, hostname string) ([]net.IP, error) {
+
+	addrs, _, err := c.lookup(ctx, hostname, false)
+	return addrs, err
+}
+
+//
+// print - print the cache hits and misses for each hostname, if the
+// cache is in use
+//
+func (c *DNSCache) print(w io.Writer) {
+
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var names []string
+	for name := range c.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "\n## DNS Cache:")
+	for _, name := range names {
+		e := c.entries[name]
+		fmt.Fprintf(w, "   %s: %d lookups, %d hits, %d misses (TTL %s, %s)\n",
+			name, e.hits+e.misses, e.hits, e.misses, fmtTTL(e.ttl), e.source)
+	}
+}
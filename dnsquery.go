@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Resolver configuration file, for the nameserver to query directly
+var resolvConf = "/etc/resolv.conf"
+
+//
+// systemResolver - the address of the first nameserver in resolv.conf,
+// or the local host if there is none
+//
+func systemResolver() string {
+
+	f, err := os.Open(resolvConf)
+	if err != nil {
+		return "127.0.0.1:53"
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(strings.Split(fields[1], "%")[0], "53")
+		}
+	}
+	return "127.0.0.1:53"
+}
+
+//
+// DNSReply - the parts of a DNS response that are reported
+//
+type DNSReply struct {
+	RCode         dnsmessage.RCode
+	Answers       []dnsmessage.Resource
+	Latency       time.Duration
+	Server        string
+	Authenticated bool // AD bit: the resolver validated the answers
+	OverTCP       bool // The UDP reply was truncated, so TCP was used
+}
+
+//
+// dnsQuery - send a query for name and qtype to the system resolver
+// over UDP, and return its reply. If the reply is truncated (the TC
+// bit), the query is sent again over TCP (RFC 7766), and if that fails
+// the error says the UDP reply was truncated. If dnssec is true, the
+// query asks for DNSSEC records and the resolver's validation status
+// (the DO and AD bits, RFC 4035 and RFC 6840).
+//
+func dnsQuery(name string, qtype dnsmessage.Type, dnssec bool) (*DNSReply, error) {
+
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Intn(65536))
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	if dnssec {
+		msg.Header.AuthenticData = true
+		opt := dnsmessage.Resource{Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(".")},
+			Body: &dnsmessage.OPTResource{}}
+		if err := opt.Header.SetEDNS0(1232, dnsmessage.RCodeSuccess, true); err != nil {
+			return nil, err
+		}
+		msg.Additionals = []dnsmessage.Resource{opt}
+	}
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	server := systemResolver()
+	start := time.Now()
+	response, err := dnsExchange("udp", server, query, id)
+	if err != nil {
+		return nil, err
+	}
+	overtcp := response.Header.Truncated
+	if overtcp {
+		if response, err = dnsExchange("tcp", server, query, id); err != nil {
+			return nil, fmt.Errorf("reply over UDP truncated, and the query over TCP failed: %v", err)
+		}
+	}
+	return &DNSReply{
+		RCode:         response.Header.RCode,
+		Answers:       response.Answers,
+		Latency:       time.Since(start),
+		Server:        server,
+		Authenticated: response.Header.AuthenticData,
+		OverTCP:       overtcp,
+	}, nil
+}
+
+//
+// dnsExchange - send the packed query with the given ID to server over
+// network, udp or tcp (where messages have a two byte length prefix),
+// and return the reply to it
+//
+func dnsExchange(network, server string, query []byte, id uint16) (*dnsmessage.Message, error) {
+
+	dialer := &net.Dialer{Timeout: options.timeout}
+	conn, err := dialer.DialContext(runContext, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(options.timeout))
+
+	if network == "tcp" {
+		query = append([]byte{byte(len(query) >> 8), byte(len(query))}, query...)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		var n int
+		if network == "tcp" {
+			if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+				return nil, err
+			}
+			n, err = io.ReadFull(conn, buf[:int(buf[0])<<8|int(buf[1])])
+		} else {
+			n, err = conn.Read(buf)
+		}
+		if err != nil {
+			return nil, err
+		}
+		var response dnsmessage.Message
+		if err := response.Unpack(buf[:n]); err != nil {
+			return nil, err
+		}
+		if response.Header.ID != id || !response.Header.Response {
+			continue
+		}
+		return &response, nil
+	}
+}
+
+//
+// lookupTTL - the smallest TTL of the A and AAAA records (and any CNAMEs
+// leading to them) for name, as reported by the system resolver
+//
+func lookupTTL(name string) (time.Duration, error) {
+
+	var ttl uint32
+	found := false
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		reply, err := dnsQuery(name, qtype, false)
+		if err != nil {
+			return 0, err
+		}
+		for _, rr := range reply.Answers {
+			if !found || rr.Header.TTL < ttl {
+				ttl = rr.Header.TTL
+				found = true
+			}
+		}
+	}
+	if !found {
+		return 0, errors.New("no address records in DNS")
+	}
+	return time.Duration(ttl) * time.Second, nil
+}
+
+//
+// fmtTTL - a TTL as a whole number of seconds
+//
+func fmtTTL(ttl time.Duration) string {
+	return fmt.Sprintf("%ds", int64(ttl.Round(time.Second)/time.Second))
+}
+
+// Response codes by their usual mnemonics
+var rcodeNames = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+//
+// rrString - a resource record in presentation format
+//
+func rrString(rr dnsmessage.Resource) string {
+
+	var data string
+	switch body := rr.Body.(type) {
+	case *dnsmessage.AResource:
+		data = net.IP(body.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		data = net.IP(body.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		data = body.CNAME.String()
+	default:
+		data = fmt.Sprintf("(%d bytes)", rr.Header.Length)
+	}
+	return fmt.Sprintf("%s %d %s %s", rr.Header.Name, rr.Header.TTL,
+		strings.TrimPrefix(rr.Header.Type.String(), "Type"), data)
+}
+
+//
+// cnameChain - the names, starting with name, that the CNAME records of
+// the answers lead through
+//
+func cnameChain(name string, answers []dnsmessage.Resource) []string {
+
+	chain := []string{strings.TrimSuffix(name, ".") + "."}
+	for i := 0; i < len(answers); i++ {
+		for _, rr := range answers {
+			cname, ok := rr.Body.(*dnsmessage.CNAMEResource)
+			if ok && strings.EqualFold(rr.Header.Name.String(), chain[len(chain)-1]) {
+				chain = append(chain, cname.CNAME.String())
+				break
+			}
+		}
+	}
+	return chain
+}
+
+//
+// printDNSDetail - query the system resolver directly for the A and AAAA
+// records of hostname, and report each answer with its TTL, the CNAME
+// chain, the resolver used and the latency of each query
+//
+func printDNSDetail(w io.Writer, hostname string) error {
+
+	fmt.Fprintln(w, "## DNS Resolution:")
+	if net.ParseIP(hostname) != nil {
+		fmt.Fprintln(w, "   IP address literal, not resolved")
+		return nil
+	}
+	var chain []string
+	var minttl uint32
+	found := false
+	var failed error
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		name := strings.TrimPrefix(qtype.String(), "Type")
+		reply, err := dnsQuery(hostname, qtype, false)
+		if err != nil {
+			fmt.Fprintf(w, "   %s: %v\n", name, err)
+			failed = err
+			continue
+		}
+		rcode, ok := rcodeNames[reply.RCode]
+		if !ok {
+			rcode = reply.RCode.String()
+		}
+		transport := ""
+		if reply.OverTCP {
+			transport = " over TCP (UDP reply truncated)"
+		}
+		fmt.Fprintf(w, "   %s: %s from %s%s in %s\n", name, rcode, reply.Server, transport, fmtDuration(reply.Latency))
+		for _, rr := range reply.Answers {
+			fmt.Fprintf(w, "      %s\n", rrString(rr))
+			if !found || rr.Header.TTL < minttl {
+				minttl = rr.Header.TTL
+				found = true
+			}
+		}
+		if c := cnameChain(hostname, reply.Answers); len(c) > len(chain) {
+			chain = c
+		}
+	}
+	if len(chain) > 1 {
+		fmt.Fprintf(w, "   CNAME chain: %s\n", strings.Join(chain, " -> "))
+	}
+	if found {
+		fmt.Fprintf(w, "   Minimum TTL: %s\n", fmtTTL(time.Duration(minttl)*time.Second))
+	} else if failed == nil {
+		fmt.Fprintln(w, "   No address records in DNS (the name may be in the hosts file)")
+	}
+	return failed
+}
+
+//
+// dnsOnly - resolve the hostname of each URL, and report the resolution
+// instead of probing. Returns the exit status.
+//
+func dnsOnly(urls []string) int {
+
+	for i, urlstring := range urls {
+		if i > 0 {
+			fmt.Println()
+		}
+		hostname, _, err := url2addressport(urlstring)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			setExitStatus(ExitUsage)
+			continue
+		}
+		fmt.Printf("URL: %s\nHostname: %s\n", urlstring, hostname)
+		start := time.Now()
+		iplist, _, err := getIpList(hostname)
+		if err != nil {
+			fmt.Printf("Addresses: %v\n", err)
+			setExitStatus(ExitDNS)
+		} else {
+			fmt.Printf("Addresses (system resolver, %s):\n", fmtDuration(time.Since(start)))
+			for _, ipaddress := range iplist {
+				fmt.Printf("\t%s\n", ipaddress)
+			}
+		}
+		if options.dnsdetail {
+			if err := printDNSDetail(os.Stdout, hostname); err != nil {
+				setExitStatus(ExitDNS)
+			}
+		}
+		if options.dnssec {
+			printDNSSEC(os.Stdout, hostname)
+		}
+	}
+	return exitStatus
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Record types unknown to dnsmessage: HTTPS (RFC 9460) and RRSIG
+var (
+	typeHTTPS = dnsmessage.Type(65)
+	typeRRSIG = dnsmessage.Type(46)
+)
+
+//
+// DNSSECAnswer - the validation status of the answer for one type
+//
+type DNSSECAnswer struct {
+	Type          string
+	Reply         *DNSReply
+	Err           error
+	Records       int  // Records of the type in the answer
+	Signed        bool // The answer came with RRSIG records
+	Authenticated bool
+}
+
+//
+// dnssecAnswers - query the system resolver for the A, AAAA and HTTPS
+// records of hostname, asking for DNSSEC, and return the validation
+// status of each answer
+//
+func dnssecAnswers(hostname string) []DNSSECAnswer {
+
+	var answers []DNSSECAnswer
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA, typeHTTPS} {
+		a := DNSSECAnswer{Type: "HTTPS"}
+		if qtype != typeHTTPS {
+			a.Type = qtype.String()[len("Type"):]
+		}
+		a.Reply, a.Err = dnsQuery(hostname, qtype, true)
+		if a.Err == nil {
+			a.Authenticated = a.Reply.Authenticated
+			for _, rr := range a.Reply.Answers {
+				switch rr.Header.Type {
+				case qtype:
+					a.Records++
+				case typeRRSIG:
+					a.Signed = true
+				}
+			}
+		}
+		answers = append(answers, a)
+	}
+	return answers
+}
+
+//
+// printDNSSEC - report whether the resolver authenticated the A, AAAA
+// and HTTPS answers for hostname with DNSSEC. The AD bit is set by a
+// validating resolver, and can only be trusted as far as the path to
+// it is (e.g. a resolver on the local host). Returns false if any
+// answer wasn't authenticated.
+//
+func printDNSSEC(w io.Writer, hostname string) bool {
+
+	fmt.Fprintln(w, "## DNSSEC:")
+	if net.ParseIP(hostname) != nil {
+		fmt.Fprintln(w, "   IP address literal, not resolved")
+		return true
+	}
+
+	secure, validating := true, false
+	server := ""
+	for _, a := range dnssecAnswers(hostname) {
+		if a.Err != nil {
+			fmt.Fprintf(w, "   %s: %v\n", a.Type, a.Err)
+			secure = false
+			continue
+		}
+		server = a.Reply.Server
+		rcode, ok := rcodeNames[a.Reply.RCode]
+		if !ok {
+			rcode = a.Reply.RCode.String()
+		}
+		var status string
+		switch {
+		case a.Reply.RCode == dnsmessage.RCodeServerFailure:
+			status = "SERVFAIL (validation failure?)"
+		case a.Authenticated && a.Records == 0:
+			status = fmt.Sprintf("authenticated denial of existence (%s)", rcode)
+		case a.Authenticated:
+			status = fmt.Sprintf("authenticated, %d records", a.Records)
+		case a.Signed:
+			status = fmt.Sprintf("NOT authenticated, %d records, signed", a.Records)
+		default:
+			status = fmt.Sprintf("NOT authenticated, %d records (%s)", a.Records, rcode)
+		}
+		fmt.Fprintf(w, "   %s: %s\n", a.Type, status)
+		if a.Authenticated {
+			validating = true
+		} else {
+			secure = false
+		}
+	}
+
+	switch {
+	case secure:
+		fmt.Fprintln(w, "   Status: all answers authenticated (secure)")
+	case validating:
+		fmt.Fprintln(w, "   Status: some answers not authenticated")
+	default:
+		fmt.Fprintln(w, "   Status: insecure (unsigned zone, or the resolver doesn't validate)")
+	}
+	if server != "" {
+		fmt.Fprintf(w, "   Resolver: %s (the AD bit is only as trustworthy as the path to it)\n", server)
+	}
+	return secure
+}
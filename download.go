@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// outputFilename - determine the file to save the body in for -O, from
+// the Content-Disposition filename parameter if present, otherwise the
+// last component of the URL path. Only the base name is ever used, so
+// a hostile server cannot direct the write to another directory, and
+// a name of "-" is not taken to mean stdout: only -o can ask for that.
+//
+func outputFilename(response *http.Response) string {
+
+	if cd := response.Header.Get("Content-Disposition"); cd != "" {
+		if _, params, err := mime.ParseMediaType(cd); err == nil {
+			if name := baseFilename(params["filename"]); name != "" {
+				return name
+			}
+		}
+	}
+	if response.Request != nil {
+		if name := baseFilename(path.Base(response.Request.URL.Path)); name != "" {
+			return name
+		}
+	}
+	return "index.html"
+}
+
+//
+// baseFilename - the base name of a server supplied filename, or "" if
+// it is not usable as one
+//
+func baseFilename(name string) string {
+
+	name = filepath.Base(filepath.Clean("/" + name))
+	switch name {
+	case "", ".", "/", "..", "-":
+		return ""
+	}
+	return name
+}
+
+//
+// printProgress - print transfer progress to stderr
+//
+func printProgress(n, total int64, elapsed time.Duration) {
+
+	rate := float64(n) / elapsed.Seconds()
+	if total > 0 {
+		fmt.Fprintf(os.Stderr, "\r   %d/%d bytes (%.1f%%) %.0f bytes/sec",
+			n, total, 100*float64(n)/float64(total), rate)
+	} else {
+		fmt.Fprintf(os.Stderr, "\r   %d bytes %.0f bytes/sec", n, rate)
+	}
+}
+
+// Whether -upload-file progress has been printed, and needs ending
+var uploadProgressShown int32
+
+//
+// printUploadProgress - print -upload-file progress to stderr
+//
+func printUploadProgress(n, total int64, elapsed time.Duration) {
+
+	atomic.StoreInt32(&uploadProgressShown, 1)
+	printProgress(n, total, elapsed)
+}
+
+//
+// endUploadProgress - end the -upload-file progress line, if one was
+// printed
+//
+func endUploadProgress() {
+
+	if atomic.SwapInt32(&uploadProgressShown, 0) == 1 {
+		fmt.Fprintf(os.Stderr, "\n")
+	}
+}
+
+//
+// saveBody - make the request, streaming the response body to a file
+// rather than reading it into memory. Returns the result, and the name
+// of the file written.
+//
+func saveBody(session *probe.Session, request *http.Request) (*probe.ProbeResult, string) {
+
+	var filename string
+	var f *os.File
+
+	open := func(response *http.Response) (io.Writer, error) {
+		filename = options.outfile
+		if options.remotename {
+			filename = outputFilename(response)
+		}
+		if filename == "-" && !options.remotename {
+			return os.Stdout, nil
+		}
+		var err error
+		f, err = os.Create(filename)
+		return f, err
+	}
+
+	var progress probe.ProgressFunc
+	if options.outfile != "-" && !options.bodyonly {
+		progress = printProgress
+	}
+
+	statProbes.Add(1)
+	result := session.Save(request, open, progress)
+	if result.Err != nil {
+		statProbeErrors.Add(1)
+	}
+	if f != nil {
+		if progress != nil {
+			fmt.Fprintf(os.Stderr, "\n")
+		}
+		if err := f.Close(); err != nil && result.Err == nil {
+			result.Err = err
+		}
+	}
+	return result, filename
+}
+
+//
+// printDownloadInfo - print where the body was saved
+//
+func printDownloadInfo(w io.Writer, filename string, result *probe.ProbeResult) {
+
+	fmt.Fprintln(w, "## Download:")
+	fmt.Fprintf(w, "   File: %s\n", filename)
+	fmt.Fprintf(w, "   Bytes written: %d\n", result.BodySize)
+}
+
+//
+// outputToFile - are we saving the body instead of buffering it?
+//
+func outputToFile() bool {
+	return options.outfile != "" || options.remotename
+}
+
+//
+// checkOutfileOptions - sanity check -o and -O
+//
+func checkOutfileOptions() error {
+
+	if options.outfile != "" && options.remotename {
+		return fmt.Errorf("cannot specify both -o and -O")
+	}
+	if options.outfile == "-" && !options.bodyonly {
+		return fmt.Errorf("-o - requires -bodyonly")
+	}
+	if strings.TrimSpace(options.outfile) != options.outfile {
+		return fmt.Errorf("invalid output filename: %q", options.outfile)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// StallSpec - when and for how long to stop reading, for -stall-read
+//
+type StallSpec struct {
+	after int64
+	pause time.Duration
+}
+
+//
+// parseStall - parse a -stall-read "N:duration" specification, e.g.
+// 16k:10s
+//
+func parseStall(s string) (*StallSpec, error) {
+
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid stall %q: must be bytes:duration", s)
+	}
+	after, err := probe.ParseSize(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	pause, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if pause <= 0 {
+		return nil, fmt.Errorf("invalid stall %q: duration must be positive", s)
+	}
+	return &StallSpec{after: after, pause: pause}, nil
+}
+
+//
+// printCloseReport - print how the server reacted
+//
+func printCloseReport(w io.Writer, report *probe.CloseReport) {
+
+	if report.Status != 0 {
+		fmt.Fprintf(w, "   Response: %d, %d bytes received, complete: %v\n",
+			report.Status, report.BytesRead, report.Complete)
+	} else {
+		fmt.Fprintf(w, "   Response: none, %d bytes received\n", report.BytesRead)
+	}
+	fmt.Fprintf(w, "   Connection: %s after %s\n", report.Ending, fmtDuration(report.Elapsed))
+}
+
+//
+// checkDraining - run the -half-close and -stall-read tests, each on
+// a new HTTP/1.1 connection
+//
+func checkDraining(w io.Writer, session *probe.Session, request *http.Request) {
+
+	if options.halfclose {
+		fmt.Fprintln(w, "## Half-Close (request sent, then write side closed):")
+		report, err := session.HalfClose(request)
+		if err != nil {
+			fmt.Fprintf(w, "   ERROR: %v\n", err)
+		} else {
+			printCloseReport(w, report)
+		}
+	}
+	if s := options.stallread; s != nil {
+		fmt.Fprintf(w, "## Stalled Read (reading stopped after %d bytes for %s):\n",
+			s.after, s.pause)
+		report, err := session.StallRead(request, s.after, s.pause)
+		if err != nil {
+			fmt.Fprintf(w, "   ERROR: %v\n", err)
+		} else {
+			printCloseReport(w, report)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+)
+
+//
+// printDump - print a dumped message head, one line per line of it,
+// each prefixed with marker
+//
+func printDump(w io.Writer, marker string, dump []byte) {
+
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+		fmt.Fprintf(w, "   %s %s\n", marker, line)
+	}
+}
+
+//
+// printRequestDump - print the request as it will be sent: the request
+// line and headers that the HTTP/1.1 transport writes for it. Over
+// HTTP/2, the same fields are sent as a HEADERS frame instead.
+//
+func printRequestDump(w io.Writer, request *http.Request) {
+
+	fmt.Fprintln(w, "## Request:")
+	if options.rawrequest != nil {
+		printDump(w, ">", options.rawrequest)
+		return
+	}
+	dump, err := httputil.DumpRequestOut(request, false)
+	if err != nil {
+		fmt.Fprintf(w, "   ERROR: %v\n", err)
+		return
+	}
+	printDump(w, ">", dump)
+}
+
+//
+// printResponseDump - print the head of the response: its status line
+// and headers as received, though in the order net/http writes them,
+// since it doesn't record the order they arrived in.
+//
+func printResponseDump(w io.Writer, response *http.Response) {
+
+	fmt.Fprintln(w, "## Response:")
+	dump, err := httputil.DumpResponse(response, false)
+	if err != nil {
+		fmt.Fprintf(w, "   ERROR: %v\n", err)
+		return
+	}
+	printDump(w, "<", dump)
+}
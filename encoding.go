@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// printEncodingInfo - print the negotiated content encoding, and the
+// compressed and decompressed body sizes.
+//
+func printEncodingInfo(w io.Writer, result *probe.ProbeResult) {
+
+	chosen := result.Response.Header.Get("Content-Encoding")
+	if chosen == "" {
+		chosen = "(none)"
+	}
+	fmt.Fprintln(w, "## Content Encoding:")
+	fmt.Fprintf(w, "   Requested: %s\n", strings.Join(options.encodings, ", "))
+	fmt.Fprintf(w, "   Chosen: %s\n", chosen)
+	fmt.Fprintf(w, "   Compressed size: %d\n", result.EncodedSize)
+	fmt.Fprintf(w, "   Decompressed size: %d\n", result.BodySize)
+	if result.EncodedSize > 0 {
+		fmt.Fprintf(w, "   Compression ratio: %.2f\n",
+			float64(result.BodySize)/float64(result.EncodedSize))
+	}
+	if result.DecodeErr != nil {
+		fmt.Fprintf(w, "   ERROR: %v\n", result.DecodeErr)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+//
+// Exit codes, by failure class. When several probes fail, the exit code
+// reflects the first failure recorded.
+//
+const (
+	ExitOK        = 0 // Success
+	ExitAssertion = 1 // An -expect-* assertion failed
+	ExitHTTPError = 2 // HTTP status >= 400, with -fail
+	ExitDNS       = 3 // DNS resolution failed
+	ExitUsage     = 4 // Invalid command line
+	ExitConnect   = 5 // TCP connection failed
+	ExitTLS       = 6 // TLS handshake failed
+	ExitCertError = 7 // Certificate verification failed
+	ExitTimeout   = 8 // Timed out
+	ExitOther     = 9 // Any other error
+
+	ExitInterrupted = 10 // Interrupted by SIGINT or SIGTERM
+)
+
+//
+// Exit status of the program
+//
+var (
+	exitStatus     int
+	exitStatusLock sync.Mutex
+)
+
+//
+// setExitStatus - record the exit status, keeping the first failure
+//
+func setExitStatus(status int) {
+
+	exitStatusLock.Lock()
+	defer exitStatusLock.Unlock()
+	if exitStatus == ExitOK {
+		exitStatus = status
+	}
+}
+
+//
+// overrideExitStatus - replace the exit status, for output formats with
+// exit codes of their own
+//
+func overrideExitStatus(status int) {
+
+	exitStatusLock.Lock()
+	defer exitStatusLock.Unlock()
+	exitStatus = status
+}
+
+//
+// fatal - print an error and exit immediately with the given code
+//
+func fatal(code int, err error) {
+	fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+	os.Exit(code)
+}
+
+//
+// classifyError - map an error from resolving, connecting or making
+// the request to its exit code.
+//
+func classifyError(err error) int {
+
+	var dnserr *net.DNSError
+	var operr *net.OpError
+	var timeouterr interface{ Timeout() bool }
+	var unknownauth x509.UnknownAuthorityError
+	var certinvalid x509.CertificateInvalidError
+	var hostnameerr x509.HostnameError
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.As(err, &dnserr):
+		return ExitDNS
+	case errors.As(err, &timeouterr) && timeouterr.Timeout():
+		return ExitTimeout
+	case errors.As(err, &unknownauth), errors.As(err, &certinvalid),
+		errors.As(err, &hostnameerr):
+		return ExitCertError
+	case strings.Contains(err.Error(), "tls: "):
+		return ExitTLS
+	case errors.As(err, &operr) && operr.Op == "dial":
+		return ExitConnect
+	default:
+		return ExitOther
+	}
+}
+
+//
+// exitCodesHelp - documentation of the exit codes for the usage message
+//
+const exitCodesHelp = `
+    Exit codes:
+	0  Success
+	1  Assertion (-expect-*) failed
+	2  HTTP status >= 400 (with -fail)
+	3  DNS resolution failure
+	4  Usage error
+	5  TCP connection failure
+	6  TLS handshake failure
+	7  Certificate verification failure
+	8  Timeout
+	9  Other error
+	10 Interrupted (SIGINT or SIGTERM): the probes in progress are
+	   cancelled, and what was done so far is printed
+`
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+//
+// HTMLLink - a URL referred to by an HTML element attribute
+//
+type HTMLLink struct {
+	Tag      string // Element, e.g. a or img
+	Attr     string // Attribute, href or src
+	URL      string // URL as written
+	Rel      string // rel attribute, if any
+	Absolute bool   // Written as an absolute (or network-path) URL
+}
+
+//
+// HTMLSummary - the structural parts of an HTML page
+//
+type HTMLSummary struct {
+	Title     string
+	Base      string // <base href>, if any
+	Canonical string // <link rel=canonical href>, if any
+	Refresh   string // <meta http-equiv=refresh content>, if any
+	Links     []HTMLLink
+}
+
+//
+// hasToken - does the space separated attribute value contain token?
+//
+func hasToken(value, token string) bool {
+
+	for _, t := range strings.Fields(value) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+//
+// parseHTMLSummary - tokenize the page, collecting its title, base,
+// canonical and refresh URLs, and the href and src attributes of all
+// elements
+//
+func parseHTMLSummary(body []byte) *HTMLSummary {
+
+	summary := new(HTMLSummary)
+	seen := make(map[HTMLLink]bool)
+	intitle := false
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			summary.Title = strings.Join(strings.Fields(summary.Title), " ")
+			return summary
+		case html.TextToken:
+			if intitle {
+				summary.Title += string(z.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Title {
+				intitle = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			attrs := make(map[string]string)
+			for _, a := range token.Attr {
+				attrs[a.Key] = strings.TrimSpace(a.Val)
+			}
+			switch token.DataAtom {
+			case atom.Title:
+				intitle = summary.Title == ""
+			case atom.Base:
+				if summary.Base == "" {
+					summary.Base = attrs["href"]
+				}
+			case atom.Link:
+				if hasToken(attrs["rel"], "canonical") && summary.Canonical == "" {
+					summary.Canonical = attrs["href"]
+				}
+			case atom.Meta:
+				if strings.EqualFold(attrs["http-equiv"], "refresh") {
+					summary.Refresh = attrs["content"]
+				}
+			}
+			for _, attr := range []string{"href", "src"} {
+				value, ok := attrs[attr]
+				if !ok || value == "" || token.DataAtom == atom.Base {
+					continue
+				}
+				u, err := url.Parse(value)
+				link := HTMLLink{Tag: token.Data, Attr: attr, URL: value, Rel: attrs["rel"],
+					Absolute: err == nil && (u.IsAbs() || u.Host != "")}
+				if !seen[link] {
+					seen[link] = true
+					summary.Links = append(summary.Links, link)
+				}
+			}
+		}
+	}
+}
+
+//
+// refreshTarget - the delay and URL of a meta refresh content value,
+// e.g. "5; url=/next"
+//
+func refreshTarget(content string) (delay, target string) {
+
+	parts := strings.SplitN(content, ";", 2)
+	if len(parts) == 1 {
+		parts = strings.SplitN(content, ",", 2)
+	}
+	delay = strings.TrimSpace(parts[0])
+	if len(parts) == 2 {
+		target = strings.TrimSpace(parts[1])
+		if len(target) > 4 && strings.EqualFold(target[:4], "url=") {
+			target = strings.TrimSpace(target[4:])
+		}
+		target = strings.Trim(target, `'"`)
+	}
+	return delay, target
+}
+
+//
+// resolveLink - the absolute form of a URL in the page
+//
+func resolveLink(base *url.URL, link string) string {
+
+	u, err := url.Parse(link)
+	if err != nil || base == nil {
+		return link
+	}
+	return base.ResolveReference(u).String()
+}
+
+//
+// pageBase - the URL the page's relative links are resolved against:
+// its <base href>, if it has one, else its URL
+//
+func pageBase(page *url.URL, summary *HTMLSummary) *url.URL {
+
+	if summary.Base != "" {
+		if u, err := url.Parse(resolveLink(page, summary.Base)); err == nil {
+			return u
+		}
+	}
+	return page
+}
+
+//
+// printExtract - for an HTML response, print the page title, canonical
+// URL, meta refresh target, and the URLs of its links and resources,
+// resolved against the page's URL (with -extract-absolute, only those
+// written as absolute URLs)
+//
+func printExtract(w io.Writer, response *http.Response, body []byte) {
+
+	mediatype, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediatype != "text/html" && mediatype != "application/xhtml+xml" {
+		fmt.Fprintf(w, "## HTML Extract: NONE (Content-Type %q is not HTML)\n", mediatype)
+		return
+	}
+	summary := parseHTMLSummary(body)
+
+	var base *url.URL
+	if response.Request != nil {
+		base = pageBase(response.Request.URL, summary)
+	}
+
+	fmt.Fprintln(w, "## HTML Extract:")
+	fmt.Fprintf(w, "   Title: %s\n", summary.Title)
+	if summary.Base != "" {
+		fmt.Fprintf(w, "   Base: %s\n", base)
+	}
+	if summary.Canonical != "" {
+		fmt.Fprintf(w, "   Canonical: %s\n", resolveLink(base, summary.Canonical))
+	}
+	if summary.Refresh != "" {
+		delay, target := refreshTarget(summary.Refresh)
+		if target == "" {
+			fmt.Fprintf(w, "   Meta refresh: %ss (reload)\n", delay)
+		} else {
+			fmt.Fprintf(w, "   Meta refresh: %ss -> %s\n", delay, resolveLink(base, target))
+		}
+	}
+
+	var links []HTMLLink
+	for _, link := range summary.Links {
+		if link.Absolute || !options.extractabs {
+			links = append(links, link)
+		}
+	}
+	fmt.Fprintf(w, "   Links: %d\n", len(links))
+	for _, link := range links {
+		fmt.Fprintf(w, "      %-12s %s\n", link.Tag+" "+link.Attr+":", resolveLink(base, link.URL))
+	}
+}
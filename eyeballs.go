@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// family - the name of an attempt's address family
+//
+func family(a probe.ConnectAttempt) string {
+
+	if a.IPv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+//
+// printHappyEyeballs - race connections to the addresses as RFC 8305
+// describes, and report each attempt, which address family won, and
+// how long the first attempt of each family took
+//
+func printHappyEyeballs(w io.Writer, prober *probe.Prober, iplist []net.IP, port string) {
+
+	race := prober.RaceConnect(iplist, port, options.attemptdelay)
+	fmt.Fprintf(w, "## Happy Eyeballs (attempt delay %s):\n", fmtDuration(race.Delay))
+	for i, a := range race.Attempts {
+		var outcome string
+		switch {
+		case i == race.Winner:
+			outcome = fmt.Sprintf("connected in %s, WINNER", fmtDuration(a.Elapsed))
+		case a.Aborted:
+			outcome = fmt.Sprintf("abandoned after %s", fmtDuration(a.Elapsed))
+		case a.Err != nil:
+			outcome = fmt.Sprintf("failed after %s: %v", fmtDuration(a.Elapsed), a.Err)
+		default:
+			outcome = fmt.Sprintf("connected in %s, too late", fmtDuration(a.Elapsed))
+		}
+		fmt.Fprintf(w, "   +%-9s %s %s %s\n", fmtDuration(a.Started), family(a), a.Address, outcome)
+	}
+
+	// The first attempt of each family shows its connection time, or
+	// how long it had before the race was decided.
+	for _, name := range []string{"IPv6", "IPv4"} {
+		found := false
+		for _, a := range race.Attempts {
+			if family(a) != name {
+				continue
+			}
+			found = true
+			switch {
+			case a.Aborted:
+				fmt.Fprintf(w, "   %s: first attempt unfinished after %s\n", name, fmtDuration(a.Elapsed))
+			case a.Err != nil:
+				fmt.Fprintf(w, "   %s: first attempt failed after %s\n", name, fmtDuration(a.Elapsed))
+			default:
+				fmt.Fprintf(w, "   %s: first attempt connected in %s\n", name, fmtDuration(a.Elapsed))
+			}
+			break
+		}
+		if !found {
+			fmt.Fprintf(w, "   %s: no attempts (no addresses, or the race was won first)\n", name)
+		}
+	}
+
+	if race.Winner < 0 {
+		fmt.Fprintf(w, "   Result: no connection after %s\n", fmtDuration(race.Elapsed))
+		return
+	}
+	winner := race.Attempts[race.Winner]
+	fallback := ""
+	if race.Winner > 0 {
+		fallback = fmt.Sprintf(", after falling back %s into the race", fmtDuration(winner.Started))
+	}
+	fmt.Fprintf(w, "   Result: %s won (%s) in %s%s\n", family(winner), winner.Address,
+		fmtDuration(race.Elapsed), fallback)
+}
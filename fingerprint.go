@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//
+// FingerprintRule - a header or certificate pattern indicating a CDN or
+// hosting provider. With an empty pattern, the header's presence is the
+// indicator; otherwise its value (or the certificate issuer or a SAN)
+// must contain pattern, case-insensitively. A header ending in "*" is
+// a prefix, matching any header beginning with it.
+//
+type FingerprintRule struct {
+	provider string
+	header   string // Header name, or "" for a certificate rule
+	pattern  string
+}
+
+var fingerprintRules = []FingerprintRule{
+	{"Cloudflare", "CF-Ray", ""},
+	{"Cloudflare", "CF-Cache-Status", ""},
+	{"Cloudflare", "Server", "cloudflare"},
+	{"Cloudflare", "", "cloudflare"},
+	{"Amazon CloudFront", "X-Amz-Cf-Id", ""},
+	{"Amazon CloudFront", "X-Amz-Cf-Pop", ""},
+	{"Amazon CloudFront", "Via", "cloudfront"},
+	{"Amazon CloudFront", "X-Cache", "cloudfront"},
+	{"Amazon CloudFront", "", "cloudfront.net"},
+	{"Amazon S3", "Server", "AmazonS3"},
+	{"Amazon S3", "X-Amz-Request-Id", ""},
+	{"Fastly", "X-Served-By", "cache-"},
+	{"Fastly", "X-Fastly-Request-ID", ""},
+	{"Fastly", "Fastly-Debug-Digest", ""},
+	{"Fastly", "", "fastly"},
+	{"Akamai", "X-Akamai-*", ""},
+	{"Akamai", "Akamai-GRN", ""},
+	{"Akamai", "Server", "AkamaiGHost"},
+	{"Akamai", "Server", "AkamaiNetStorage"},
+	{"Akamai", "X-Cache", "akamai"},
+	{"Akamai", "", "akamai"},
+	{"Google", "Server", "gws"},
+	{"Google", "Server", "Google Frontend"},
+	{"Google", "Via", "google"},
+	{"Google", "", "Google Trust Services"},
+	{"Microsoft Azure Front Door", "X-Azure-Ref", ""},
+	{"Microsoft Azure Front Door", "X-MSEdge-Ref", ""},
+	{"Vercel", "X-Vercel-Id", ""},
+	{"Vercel", "Server", "Vercel"},
+	{"Vercel", "", "vercel.app"},
+	{"Netlify", "X-NF-Request-ID", ""},
+	{"Netlify", "Server", "Netlify"},
+	{"Netlify", "", "netlify.app"},
+	{"GitHub Pages", "X-GitHub-Request-Id", ""},
+	{"GitHub Pages", "Server", "GitHub.com"},
+	{"GitHub Pages", "", "github.io"},
+	{"Heroku", "Via", "vegur"},
+	{"Heroku", "", "herokuapp.com"},
+	{"Edgio (Edgecast)", "Server", "ECAcc"},
+	{"Edgio (Edgecast)", "Server", "ECS ("},
+	{"Bunny CDN", "Server", "BunnyCDN"},
+	{"Bunny CDN", "CDN-PullZone", ""},
+	{"KeyCDN", "Server", "keycdn"},
+	{"Sucuri", "X-Sucuri-ID", ""},
+	{"Sucuri", "Server", "Sucuri"},
+	{"Imperva", "X-Iinfo", ""},
+	{"Imperva", "X-CDN", "Incapsula"},
+	{"Imperva", "X-CDN", "Imperva"},
+	{"Varnish", "X-Varnish", ""},
+	{"Varnish", "Via", "varnish"},
+}
+
+//
+// headerEvidence - the response headers matching the rule, as
+// "Name: value" strings
+//
+func headerEvidence(rule FingerprintRule, header http.Header) []string {
+
+	var evidence []string
+	prefix := strings.TrimSuffix(rule.header, "*")
+	for name, values := range header {
+		wildcard := prefix != rule.header && strings.HasPrefix(name, http.CanonicalHeaderKey(prefix))
+		if name != http.CanonicalHeaderKey(rule.header) && !wildcard {
+			continue
+		}
+		for _, value := range values {
+			if rule.pattern == "" || strings.Contains(strings.ToLower(value), strings.ToLower(rule.pattern)) {
+				evidence = append(evidence, fmt.Sprintf("%s: %s", name, value))
+			}
+		}
+	}
+	sort.Strings(evidence)
+	return evidence
+}
+
+//
+// certEvidence - the leaf certificate's issuer or SANs matching the rule
+//
+func certEvidence(rule FingerprintRule, cert *x509.Certificate) []string {
+
+	pattern := strings.ToLower(rule.pattern)
+	if strings.Contains(strings.ToLower(cert.Issuer.String()), pattern) {
+		return []string{"certificate issuer: " + cert.Issuer.String()}
+	}
+	for _, name := range cert.DNSNames {
+		if strings.Contains(strings.ToLower(name), pattern) {
+			return []string{"certificate SAN: " + name}
+		}
+	}
+	return nil
+}
+
+//
+// printFingerprint - identify the CDN or hosting provider in front of
+// the origin from the response headers and the TLS certificate, and
+// print the evidence for each candidate, the likeliest first
+//
+func printFingerprint(w io.Writer, response *http.Response) {
+
+	evidence := make(map[string][]string)
+	seen := make(map[string]bool)
+	var providers []string
+	for _, rule := range fingerprintRules {
+		var found []string
+		if rule.header != "" {
+			found = headerEvidence(rule, response.Header)
+		} else if response.TLS != nil && len(response.TLS.PeerCertificates) > 0 {
+			found = certEvidence(rule, response.TLS.PeerCertificates[0])
+		}
+		for _, e := range found {
+			if evidence[rule.provider] == nil {
+				providers = append(providers, rule.provider)
+			}
+			if !seen[rule.provider+"\n"+e] {
+				seen[rule.provider+"\n"+e] = true
+				evidence[rule.provider] = append(evidence[rule.provider], e)
+			}
+		}
+	}
+	sort.SliceStable(providers, func(i, j int) bool {
+		return len(evidence[providers[i]]) > len(evidence[providers[j]])
+	})
+
+	fmt.Fprintln(w, "## CDN Fingerprint:")
+	if server := response.Header.Get("Server"); server != "" {
+		fmt.Fprintf(w, "   Server: %s\n", server)
+	}
+	if len(providers) == 0 {
+		fmt.Fprintln(w, "   Conclusion: no CDN or hosting provider identified")
+		return
+	}
+	for _, provider := range providers {
+		fmt.Fprintf(w, "   %s:\n", provider)
+		for _, e := range evidence[provider] {
+			fmt.Fprintf(w, "      %s\n", e)
+		}
+	}
+	conclusion := providers[0]
+	if len(providers) > 1 && len(evidence[providers[1]]) == len(evidence[conclusion]) {
+		conclusion += " (or " + providers[1] + ", with as much evidence)"
+	}
+	fmt.Fprintf(w, "   Conclusion: %s\n", conclusion)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+//
+// splitFormField - split a -form or -form-file argument at its first =
+//
+func splitFormField(s string) (key, value string, err error) {
+
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return "", "", fmt.Errorf("invalid form field %q: must be key=value", s)
+	}
+	return s[:i], s[i+1:], nil
+}
+
+//
+// buildForm - the request body and Content-Type for the -form fields
+// and -form-file files: URL-encoded if there are only fields, else
+// multipart/form-data. Files are given as field=@path, optionally
+// followed by ;type=mediatype (by default, guessed from the extension).
+//
+func buildForm(fields, files []string) ([]byte, string, error) {
+
+	if len(files) == 0 {
+		values := url.Values{}
+		for _, f := range fields {
+			key, value, err := splitFormField(f)
+			if err != nil {
+				return nil, "", err
+			}
+			values.Add(key, value)
+		}
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range fields {
+		key, value, err := splitFormField(f)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := mw.WriteField(key, value); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, f := range files {
+		key, value, err := splitFormField(f)
+		if err != nil {
+			return nil, "", err
+		}
+		if !strings.HasPrefix(value, "@") {
+			return nil, "", fmt.Errorf("invalid form file %q: must be field=@path", f)
+		}
+		path, mediatype := value[1:], ""
+		if i := strings.Index(path, ";type="); i >= 0 {
+			path, mediatype = path[:i], path[i+len(";type="):]
+		}
+		if mediatype == "" {
+			mediatype = mime.TypeByExtension(filepath.Ext(path))
+		}
+		if mediatype == "" {
+			mediatype = "application/octet-stream"
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", mime.FormatMediaType("form-data",
+			map[string]string{"name": key, "filename": filepath.Base(path)}))
+		header.Set("Content-Type", mediatype)
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		part.Write(content)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), mw.FormDataContentType(), nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// FormatResult - the result of a probe as seen by a -format template,
+// e.g. '{{.Status}} {{.TimingTotal}} {{.TLS.Version}}'. TLS is the zero
+// value for plain HTTP, so that its fields can be used unconditionally.
+//
+type FormatResult struct {
+	URL            string
+	Method         string
+	Address        string
+	LocalAddress   string
+	Time           time.Time
+	Error          string
+	Status         int
+	StatusText     string
+	Proto          string
+	Header         http.Header
+	TLS            TLSJSON
+	CertDaysLeft   int // Days until the certificate expires, if TLS
+	TimingHeader   time.Duration
+	TimingTransfer time.Duration
+	TimingTotal    time.Duration
+	EncodedSize    int64
+	BodySize       int64
+	WireBytes      int64
+	Truncated      bool
+	Digests        map[string]string
+	Body           string
+}
+
+//
+// briefFormat - the -brief template: a line per probe of the status,
+// protocol, total time, TLS version, days until the certificate
+// expires, body size and URL, in columns
+//
+const briefFormat = `{{if .Error}}ERR {{.URL}} {{.Error}}{{else}}{{.Status}} ` +
+	`{{printf "%-8s" .Proto}} {{printf "%8.1fms" (ms .TimingTotal)}} ` +
+	`{{printf "%-6s" (or .TLS.Version "-")}} ` +
+	`{{if .TLS.Version}}{{printf "%4dd" .CertDaysLeft}}{{else}}    -{{end}} ` +
+	`{{printf "%9d" .BodySize}} {{.URL}}{{end}}`
+
+// Functions available to -format templates, besides the builtins
+var formatFuncs = template.FuncMap{
+	"ms": milliseconds,
+	"join": func(sep string, values []string) string {
+		return strings.Join(values, sep)
+	},
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Backslash escapes interpreted in -format strings, like curl's -w
+var formatEscapes = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\\`, `\`)
+
+//
+// parseFormat - parse a -format template
+//
+func parseFormat(s string) (*template.Template, error) {
+	return template.New("format").Funcs(formatFuncs).Parse(formatEscapes.Replace(s))
+}
+
+//
+// newFormatResult - the -format view of a result for request
+//
+func newFormatResult(request *http.Request, address string, result *probe.ProbeResult) *FormatResult {
+
+	r := newResultJSON(request, address, result)
+	f := &FormatResult{
+		URL:            r.URL,
+		Method:         r.Method,
+		Address:        r.Address,
+		LocalAddress:   r.LocalAddress,
+		Time:           result.Start,
+		Error:          r.Error,
+		Status:         r.Status,
+		Proto:          r.Proto,
+		Header:         r.Headers,
+		TimingHeader:   result.HeaderTime,
+		TimingTransfer: result.TransferTime,
+		TimingTotal:    result.ResponseTime,
+		EncodedSize:    r.EncodedSize,
+		BodySize:       r.BodySize,
+		WireBytes:      r.WireBytes,
+		Truncated:      r.Truncated,
+		Digests:        r.Digests,
+		Body:           string(result.Body),
+	}
+	if result.Response != nil {
+		f.StatusText = result.Response.Status
+	}
+	if r.TLS != nil {
+		f.TLS = *r.TLS
+		if certs := result.Response.TLS.PeerCertificates; len(certs) > 0 {
+			f.CertDaysLeft = int(time.Until(certs[0].NotAfter).Hours() / 24)
+		}
+	}
+	return f
+}
+
+//
+// formatResult - the output of the -format template for a result,
+// ending with a newline
+//
+func formatResult(request *http.Request, address string, result *probe.ProbeResult) (string, error) {
+
+	var buf bytes.Buffer
+	if err := options.format.Execute(&buf, newFormatResult(request, address, result)); err != nil {
+		return "", err
+	}
+	if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	return buf.String(), nil
+}
+
+//
+// printFormat - print the result with the -format template
+//
+func printFormat(w io.Writer, request *http.Request, address string, result *probe.ProbeResult) {
+
+	out, err := formatResult(request, address, result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -format: %v\n", err)
+		setExitStatus(ExitOther)
+		return
+	}
+	io.WriteString(w, out)
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// ProbeOutput - what there is to print about one probe: the request,
+// its result, and the session it was made on, for the parts of the
+// text report that make further requests
+//
+type ProbeOutput struct {
+	Prober   *probe.Prober
+	Session  *probe.Session
+	Request  *http.Request
+	Address  string // Address connected to, "" for the hostname's
+	Result   *probe.ProbeResult
+	Filename string // File the body was saved to, with -o or -O
+}
+
+//
+// Formatter - prints the results of probes in one output format, chosen
+// with -output. Details is called first, then, if the request
+// succeeded, the -expect-*, -check and -script verdicts are printed,
+// then Result. Close is called after the last probe.
+//
+type Formatter interface {
+	Details(w *Report, out *ProbeOutput)
+	Result(w *Report, out *ProbeOutput)
+	Failed(urlstring string, err error) // The URL couldn't be probed
+	Close()
+}
+
+//
+// newFormatter - the formatter for an -output name, or nil
+//
+func newFormatter(name string) Formatter {
+
+	switch name {
+	case "text":
+		return textFormatter{}
+	case "brief":
+		return templateFormatter{}
+	case "ndjson":
+		return ndjsonFormatter{}
+	case "json":
+		return new(jsonFormatter)
+	case "nagios":
+		return new(nagiosFormatter)
+	case "har":
+		return new(harFormatter)
+	}
+	return nil
+}
+
+//
+// textFormatter - the report, section by section, and with -printbody
+// or -bodyonly, the body
+//
+type textFormatter struct{}
+
+func (textFormatter) Failed(urlstring string, err error) {}
+func (textFormatter) Close()                             {}
+
+//
+// templateFormatter - a line per result from the -format template (or
+// the -brief one)
+//
+type templateFormatter struct{}
+
+func (templateFormatter) Details(w *Report, out *ProbeOutput) {}
+func (templateFormatter) Failed(urlstring string, err error)  {}
+func (templateFormatter) Close()                              {}
+
+func (templateFormatter) Result(w *Report, out *ProbeOutput) {
+	printFormat(w.Payload(), out.Request, out.Address, out.Result)
+}
+
+//
+// ndjsonFormatter - a JSON object per result, on one line
+//
+type ndjsonFormatter struct{}
+
+func (ndjsonFormatter) Details(w *Report, out *ProbeOutput) {}
+func (ndjsonFormatter) Close()                              {}
+
+func (ndjsonFormatter) Result(w *Report, out *ProbeOutput) {
+	fmt.Fprintln(w.Payload(), ndjsonLine(out.Request, out.Address, out.Result))
+}
+
+func (ndjsonFormatter) Failed(urlstring string, err error) {
+
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	fmt.Println(ndjsonError(urlstring, err))
+}
+
+//
+// jsonFormatter - a JSON array of the results, printed at the end
+//
+type jsonFormatter struct {
+	mu      sync.Mutex
+	results []*ResultJSON
+}
+
+func (f *jsonFormatter) Details(w *Report, out *ProbeOutput) {}
+
+func (f *jsonFormatter) Result(w *Report, out *ProbeOutput) {
+
+	r := newResultJSON(out.Request, out.Address, out.Result)
+	r.Target = out.Request.URL.String()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, r)
+}
+
+func (f *jsonFormatter) Failed(urlstring string, err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, errorResultJSON(urlstring, err))
+}
+
+func (f *jsonFormatter) Close() {
+
+	if f.results == nil {
+		f.results = []*ResultJSON{}
+	}
+	data, _ := json.MarshalIndent(f.results, "", "  ")
+	fmt.Printf("%s\n", data)
+}
+
+//
+// Nagios plugin states, and their exit codes
+//
+const (
+	NagiosOK       = 0
+	NagiosWarning  = 1
+	NagiosCritical = 2
+	NagiosUnknown  = 3
+)
+
+var nagiosWords = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+//
+// nagiosFormatter - a Nagios plugin line per result, with performance
+// data, e.g. "HTTP OK: 200 OK - 1200 bytes in 0.012 second response
+// time |time=0.012s;;;0 size=1200B;;;0". The state is the one
+// -probe-status-only reports, and the exit code is the worst state, or
+// UNKNOWN if the run ran out of -max-total-time or was interrupted, so
+// that not every URL was checked.
+//
+type nagiosFormatter struct {
+	mu    sync.Mutex
+	worst int
+}
+
+func (f *nagiosFormatter) Details(w *Report, out *ProbeOutput) {}
+
+func (f *nagiosFormatter) record(state int) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if state > f.worst {
+		f.worst = state
+	}
+}
+
+func (f *nagiosFormatter) Result(w *Report, out *ProbeOutput) {
+
+	result := out.Result
+	state := probeState(result)
+	f.record(state)
+	if result.Err != nil {
+		fmt.Fprintf(w.Payload(), "HTTP %s: %s - %v\n", nagiosWords[state], out.Request.URL, result.Err)
+		return
+	}
+	secs := result.ResponseTime.Seconds()
+	fmt.Fprintf(w.Payload(), "HTTP %s: %s %s - %d bytes in %.3f second response time |time=%.6fs;;;0 size=%dB;;;0\n",
+		nagiosWords[state], result.Response.Proto, result.Response.Status, result.BodySize,
+		secs, secs, result.BodySize)
+}
+
+func (f *nagiosFormatter) Failed(urlstring string, err error) {
+
+	f.record(NagiosCritical)
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	fmt.Printf("HTTP CRITICAL: %s - %v\n", urlstring, err)
+}
+
+func (f *nagiosFormatter) Close() {
+
+	if budgetExpired() || interrupted() {
+		outputLock.Lock()
+		fmt.Printf("HTTP %s: stopped by %s\n", nagiosWords[NagiosUnknown], stopReason())
+		outputLock.Unlock()
+		overrideExitStatus(NagiosUnknown)
+		return
+	}
+	overrideExitStatus(f.worst)
+}
+
+//
+// HAR 1.2 (HTTP Archive) types, as much of them as gohttp can fill in
+//
+type HARLog struct {
+	Log struct {
+		Version string     `json:"version"`
+		Creator HARCreator `json:"creator"`
+		Entries []HAREntry `json:"entries"`
+	} `json:"log"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+//
+// harHeaders - header fields as HAR name/value pairs, sorted by name
+//
+func harHeaders(header http.Header) []HARNameValue {
+
+	pairs := []HARNameValue{}
+	for _, key := range headerKeys(header) {
+		for _, value := range header[key] {
+			pairs = append(pairs, HARNameValue{Name: key, Value: value})
+		}
+	}
+	return pairs
+}
+
+//
+// harQuery - the query parameters of the URL, sorted by name
+//
+func harQuery(u *url.URL) []HARNameValue {
+
+	pairs := []HARNameValue{}
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range query[key] {
+			pairs = append(pairs, HARNameValue{Name: key, Value: value})
+		}
+	}
+	return pairs
+}
+
+//
+// harRequest - the HAR form of a request, sent with protocol proto
+//
+func harRequest(request *http.Request, proto string) HARRequest {
+
+	return HARRequest{
+		Method:      request.Method,
+		URL:         request.URL.String(),
+		HTTPVersion: proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(request.Header),
+		QueryString: harQuery(request.URL),
+		HeadersSize: -1,
+		BodySize:    request.ContentLength,
+	}
+}
+
+//
+// harResponse - the HAR form of a response, without its body
+//
+func harResponse(response *http.Response) HARResponse {
+
+	r := HARResponse{
+		Status:      response.StatusCode,
+		StatusText:  http.StatusText(response.StatusCode),
+		HTTPVersion: response.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(response.Header),
+		RedirectURL: response.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	r.Content.MimeType = response.Header.Get("Content-Type")
+	return r
+}
+
+//
+// harError - the HAR entry for a request that failed with err: status
+// 0, as browsers record them, with the error as the status text and
+// the entry's comment
+//
+func harError(start time.Time, request HARRequest, err error) HAREntry {
+
+	return HAREntry{
+		StartedDateTime: formatMachineTime(start),
+		Request:         request,
+		Response: HARResponse{StatusText: err.Error(), Cookies: []HARNameValue{},
+			Headers: []HARNameValue{}, HeadersSize: -1, BodySize: -1},
+		Comment: err.Error(),
+	}
+}
+
+//
+// harEntries - the HAR entries of a result: one per request made,
+// following its redirects. Only the final response has its timings
+// and body; a failed request is an entry with status 0, as browsers
+// record them.
+//
+func harEntries(out *ProbeOutput) []HAREntry {
+
+	result := out.Result
+	if result.Response == nil {
+		return []HAREntry{harError(result.Start, harRequest(out.Request, "HTTP/1.1"), result.Err)}
+	}
+
+	chain := redirectChain(result.Response)
+	entries := make([]HAREntry, len(chain))
+	for i, response := range chain {
+		e := &entries[i]
+		e.StartedDateTime = formatMachineTime(result.Start)
+		e.Request = harRequest(response.Request, response.Proto)
+		e.Response = harResponse(response)
+		if i < len(result.Hops) {
+			e.ServerIPAddress, _, _ = net.SplitHostPort(result.Hops[i].RemoteAddr)
+		}
+	}
+
+	last := &entries[len(entries)-1]
+	last.Time = milliseconds(result.ResponseTime)
+	last.Timings = HARTimings{Wait: milliseconds(result.HeaderTime), Receive: milliseconds(result.TransferTime)}
+	last.Response.BodySize = result.EncodedSize
+	last.Response.Content.Size = result.BodySize
+	if utf8.Valid(result.Body) {
+		last.Response.Content.Text = string(result.Body)
+	} else {
+		last.Response.Content.Text = base64.StdEncoding.EncodeToString(result.Body)
+		last.Response.Content.Encoding = "base64"
+	}
+	return entries
+}
+
+//
+// harFormatter - an HTTP Archive of the requests made, printed at the
+// end, for HAR viewers and browser developer tools
+//
+type harFormatter struct {
+	mu  sync.Mutex
+	har HARLog
+}
+
+func (f *harFormatter) Details(w *Report, out *ProbeOutput) {}
+
+func (f *harFormatter) Failed(urlstring string, err error) {
+
+	request := HARRequest{Method: options.method, URL: urlstring, HTTPVersion: "HTTP/1.1",
+		Cookies: []HARNameValue{}, Headers: harHeaders(options.headers), QueryString: []HARNameValue{},
+		HeadersSize: -1, BodySize: -1}
+	if u, err := url.Parse(urlstring); err == nil {
+		request.QueryString = harQuery(u)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.har.Log.Entries = append(f.har.Log.Entries, harError(time.Now(), request, err))
+}
+
+func (f *harFormatter) Result(w *Report, out *ProbeOutput) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.har.Log.Entries = append(f.har.Log.Entries, harEntries(out)...)
+}
+
+func (f *harFormatter) Close() {
+
+	f.har.Log.Version = "1.2"
+	f.har.Log.Creator = HARCreator{Name: progname, Version: Version}
+	if f.har.Log.Entries == nil {
+		f.har.Log.Entries = []HAREntry{}
+	}
+	data, _ := json.MarshalIndent(&f.har, "", "  ")
+	fmt.Printf("%s\n", data)
+}
@@ -1,3 +1,17 @@
 module github.com/shuque/gohttp
 
 go 1.17
+
+require (
+	github.com/andybalholm/brotli v1.0.4
+	github.com/klauspost/compress v1.15.9
+	github.com/refraction-networking/utls v1.1.5
+	go.starlark.net v0.0.0-20230302034142-4b1e35fe2254
+	golang.org/x/net v0.14.0
+	golang.org/x/sys v0.11.0
+)
+
+require (
+	golang.org/x/crypto v0.12.0 // indirect
+	golang.org/x/text v0.12.0 // indirect
+)
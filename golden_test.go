@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the testdata golden files")
+
+//
+// TestMain - run gohttp itself, rather than the tests, when the golden
+// tests run the test binary with GOHTTP_TEST_MAIN set
+//
+func TestMain(m *testing.M) {
+
+	if os.Getenv("GOHTTP_TEST_MAIN") != "" {
+		main()
+		os.Exit(exitStatus)
+	}
+	os.Exit(m.Run())
+}
+
+//
+// runGohttp - run gohttp with args, without the user's config file or
+// GOHTTP_ environment, and return its stdout and exit status
+//
+func runGohttp(t *testing.T, args ...string) (string, int) {
+
+	cmd := exec.Command(os.Args[0], args...)
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "GOHTTP_") && !strings.HasPrefix(env, "HOME=") &&
+			!strings.HasPrefix(env, "XDG_CONFIG_HOME=") {
+			cmd.Env = append(cmd.Env, env)
+		}
+	}
+	cmd.Env = append(cmd.Env, "GOHTTP_TEST_MAIN=1", "HOME="+t.TempDir())
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if exit, ok := err.(*exec.ExitError); ok {
+		return stdout.String(), exit.ExitCode()
+	} else if err != nil {
+		t.Fatalf("running gohttp: %v", err)
+	}
+	return stdout.String(), 0
+}
+
+func TestGoldenReports(t *testing.T) {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Etag", `"v1"`)
+		fmt.Fprintf(w, "hello, world\n")
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	address := server.Listener.Addr().String()
+	_, port, _ := net.SplitHostPort(address)
+
+	tests := []struct {
+		golden string
+		args   []string
+		status int
+	}{
+		{"report.golden", []string{"-printbody", server.URL + "/"}, ExitOK},
+		{"redirect.golden", []string{server.URL + "/moved"}, ExitOK},
+		{"notfound.golden", []string{"-fail", server.URL + "/missing"}, ExitHTTPError},
+	}
+	for _, tt := range tests {
+		args := append([]string{"-stable-output"}, tt.args...)
+		out, status := runGohttp(t, args...)
+		if status != tt.status {
+			t.Errorf("%s: exit status %d, want %d", tt.golden, status, tt.status)
+		}
+
+		// The server's port is picked when it starts.
+		out = strings.ReplaceAll(out, address, "127.0.0.1:<port>")
+		out = strings.ReplaceAll(out, "Port: "+port+"\n", "Port: <port>\n")
+
+		golden := filepath.Join("testdata", tt.golden)
+		if *update {
+			if err := ioutil.WriteFile(golden, []byte(out), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != string(want) {
+			t.Errorf("%s: output differs from the golden file (go test -update rewrites it):\n%s",
+				tt.golden, out)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/shuque/gohttp/probe"
+	"golang.org/x/net/http2"
+)
+
+// Settings reported, and their values if the server doesn't send them
+var h2Settings = []struct {
+	id  http2.SettingID
+	def string
+}{
+	{http2.SettingHeaderTableSize, "4096"},
+	{http2.SettingEnablePush, "1"},
+	{http2.SettingMaxConcurrentStreams, "unlimited"},
+	{http2.SettingInitialWindowSize, "65535"},
+	{http2.SettingMaxFrameSize, "16384"},
+	{http2.SettingMaxHeaderListSize, "unlimited"},
+}
+
+//
+// printH2Info - if the response came over HTTP/2, make the request again
+// on a connection driven frame by frame, and report the server's
+// SETTINGS, flow control, server push and any RST_STREAM or GOAWAY
+//
+func printH2Info(w io.Writer, session *probe.Session, request *http.Request, response *http.Response) {
+
+	fmt.Fprintln(w, "## HTTP/2:")
+	if response.ProtoMajor != 2 {
+		fmt.Fprintf(w, "   Not negotiated (%s)\n", response.Proto)
+		return
+	}
+
+	info, err := session.H2Diagnostics(request)
+	if info == nil {
+		fmt.Fprintf(w, "   ERROR: %v\n", err)
+		return
+	}
+
+	fmt.Fprintln(w, "   Server SETTINGS:")
+	for _, s := range h2Settings {
+		if val, ok := info.Setting(s.id); ok {
+			fmt.Fprintf(w, "      %s: %d\n", s.id, val)
+		} else {
+			fmt.Fprintf(w, "      %s: not sent (default %s)\n", s.id, s.def)
+		}
+	}
+	for _, s := range info.Settings {
+		if s.ID > http2.SettingMaxHeaderListSize {
+			fmt.Fprintf(w, "      %s: %d\n", s.ID, s.Val)
+		}
+	}
+
+	for _, status := range info.Interim {
+		fmt.Fprintf(w, "   Interim response: %d %s\n", status, http.StatusText(status))
+	}
+	if info.Status != 0 {
+		fmt.Fprintf(w, "   Status: %d\n", info.Status)
+	}
+	fmt.Fprintf(w, "   Flow control credit granted: %d bytes\n", info.WindowGrowth)
+	fmt.Fprintf(w, "   Server push: %d PUSH_PROMISE frames (push enabled by client)\n", info.PushPromises)
+	for _, code := range info.RSTStream {
+		fmt.Fprintf(w, "   RST_STREAM: %s\n", code)
+	}
+	if g := info.GoAway; g != nil {
+		fmt.Fprintf(w, "   GOAWAY: %s, last stream %d", g.ErrCode, g.LastStreamID)
+		if g.Debug != "" {
+			fmt.Fprintf(w, ", debug %q", g.Debug)
+		}
+		fmt.Fprintln(w)
+	}
+
+	var types []string
+	for t := range info.Frames {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	fmt.Fprint(w, "   Frames received:")
+	for _, t := range types {
+		fmt.Fprintf(w, " %s=%d", t, info.Frames[t])
+	}
+	fmt.Fprintln(w)
+	if err != nil {
+		fmt.Fprintf(w, "   ERROR: %v\n", err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// setAbsoluteForm - send the request with its request-target in
+// absolute-form (http://host/path) rather than origin-form (/path), as
+// requests to proxies are. Only HTTP/1.1 can send it.
+//
+func setAbsoluteForm(request *http.Request) {
+
+	u := request.URL
+	u.Opaque = "//" + u.Host + u.EscapedPath()
+}
+
+//
+// HostForm - a variation of the request-target or Host header
+//
+type HostForm struct {
+	name     string
+	host     string // Host header, or "" for the URL's host
+	absolute bool   // Send request-target in absolute-form
+}
+
+//
+// hostForms - the variations to try for the request's URL. Variations
+// that don't apply (e.g. a trailing dot on an IP address) are omitted.
+//
+func hostForms(request *http.Request) []HostForm {
+
+	u := request.URL
+	hostname := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = portMap[u.Scheme]
+	}
+	isip := net.ParseIP(hostname) != nil
+
+	forms := []HostForm{
+		{name: "origin-form"},
+		{name: "absolute-form", absolute: true},
+		{name: "absolute-form, other Host", host: "example.invalid", absolute: true},
+	}
+	if u.Port() == "" {
+		forms = append(forms, HostForm{name: "explicit port",
+			host: net.JoinHostPort(hostname, port)})
+	}
+	otherport := "80"
+	if port == otherport {
+		otherport = "443"
+	}
+	forms = append(forms, HostForm{name: "port mismatch",
+		host: net.JoinHostPort(hostname, otherport)})
+	if !isip && !strings.HasSuffix(hostname, ".") {
+		forms = append(forms, HostForm{name: "trailing dot", host: hostname + "."})
+	}
+	if !isip && options.proxy == nil {
+		if ips, err := net.LookupIP(hostname); err == nil && len(ips) > 0 {
+			forms = append(forms, HostForm{name: "IP literal", host: addressString(ips[0], port)})
+		}
+	}
+	return forms
+}
+
+//
+// hostFormOutcome - a short description of a response, to compare
+// those of the different forms
+//
+func hostFormOutcome(result *probe.ProbeResult) string {
+
+	if result.Err != nil {
+		return "error: " + result.Err.Error()
+	}
+	response := result.Response
+	outcome := strconv.Itoa(response.StatusCode)
+	if location := response.Header.Get("Location"); location != "" {
+		outcome += " -> " + location
+	}
+	return outcome
+}
+
+//
+// compareHostForms - send the request with each of forms, over HTTP/1.1
+// and without following redirects, and report how the server (or
+// proxy) responds to each compared to the first
+//
+func compareHostForms(w io.Writer, request *http.Request, address string, forms []HostForm) {
+
+	opts := probeOptions()
+	opts.HTTP1Only = true
+	opts.NoRedirect = true
+	prober, err := probe.NewProber(opts)
+	if err != nil {
+		fmt.Fprintf(w, "   ERROR: %v\n", err)
+		return
+	}
+
+	var baseline string
+	var basesum [sha256.Size]byte
+	for i, form := range forms {
+		req := request.Clone(request.Context())
+		u := *request.URL
+		u.Opaque = ""
+		req.URL = &u
+		if form.host != "" {
+			req.Host = form.host
+		}
+		if form.absolute {
+			setAbsoluteForm(req)
+		}
+
+		result := readResponse(prober.NewSession(address), req)
+		outcome := hostFormOutcome(result)
+		sum := sha256.Sum256(result.Body)
+
+		host := req.Host
+		if host == "" {
+			host = u.Host
+		}
+		fmt.Fprintf(w, "   %-26s %s %s  Host: %s\n", form.name+":", req.Method, req.URL.RequestURI(), host)
+		note := ""
+		switch {
+		case i == 0:
+			baseline, basesum = outcome, sum
+		case outcome != baseline:
+			note = "  (differs)"
+		case sum != basesum:
+			note = "  (same status, different body)"
+		default:
+			note = "  (same)"
+		}
+		fmt.Fprintf(w, "   %-26s %s, %d bytes%s\n", "", outcome, result.BodySize, note)
+	}
+}
+
+//
+// checkHostForms - report how the server handles the request-target
+// and Host header variants of the request
+//
+func checkHostForms(w io.Writer, request *http.Request, address string) {
+
+	fmt.Fprintln(w, "## Host Forms (HTTP/1.1):")
+	compareHostForms(w, request, address, hostForms(request))
+}
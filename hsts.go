@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Minimum max-age the HSTS preload list accepts: one year
+const hstsPreloadMaxAge = 31536000
+
+//
+// HSTSPolicy - the directives of a Strict-Transport-Security header
+//
+type HSTSPolicy struct {
+	MaxAge            int64
+	IncludeSubDomains bool
+	Preload           bool
+}
+
+//
+// parseHSTS - parse a Strict-Transport-Security header value (RFC 6797
+// section 6.1). Directive names are case-insensitive, and a header with
+// a repeated directive, or without max-age, is invalid.
+//
+func parseHSTS(value string) (*HSTSPolicy, error) {
+
+	policy := new(HSTSPolicy)
+	seen := make(map[string]bool)
+	for _, directive := range splitQuoted(value, ';') {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		kv := strings.SplitN(directive, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		if seen[name] {
+			return nil, fmt.Errorf("repeated directive %s", name)
+		}
+		seen[name] = true
+		switch name {
+		case "max-age":
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("max-age without a value")
+			}
+			secs, err := strconv.ParseInt(unquote(strings.TrimSpace(kv[1])), 10, 64)
+			if err != nil || secs < 0 {
+				return nil, fmt.Errorf("invalid max-age %q", kv[1])
+			}
+			policy.MaxAge = secs
+		case "includesubdomains":
+			policy.IncludeSubDomains = true
+		case "preload":
+			policy.Preload = true
+		}
+	}
+	if !seen["max-age"] {
+		return nil, fmt.Errorf("no max-age directive")
+	}
+	return policy, nil
+}
+
+//
+// hstsHTTPRedirect - problems with how the host's plain HTTP site
+// redirects: the preload list requires it, if it listens on port 80,
+// to redirect to HTTPS on the same host before going anywhere else
+//
+func hstsHTTPRedirect(w io.Writer, request *http.Request, host string) []string {
+
+	opts := probeOptions()
+	opts.NoRedirect = true
+	prober, err := probe.NewProber(opts)
+	if err != nil {
+		return []string{fmt.Sprintf("HTTP redirect: %v", err)}
+	}
+	httpurl := "http://" + host + "/"
+	req, err := prober.NewRequest(http.MethodGet, httpurl)
+	if err != nil {
+		return []string{fmt.Sprintf("HTTP redirect: %v", err)}
+	}
+	req = req.WithContext(request.Context())
+	result := readResponse(prober.NewSession(""), req)
+	if result.Err != nil {
+		fmt.Fprintf(w, "   %s: no response (%v)\n", httpurl, result.Err)
+		return nil
+	}
+	response := result.Response
+	location := response.Header.Get("Location")
+	if response.StatusCode < 300 || response.StatusCode >= 400 || location == "" {
+		fmt.Fprintf(w, "   %s: %d\n", httpurl, response.StatusCode)
+		return []string{"HTTP doesn't redirect to HTTPS"}
+	}
+	fmt.Fprintf(w, "   %s: %d -> %s\n", httpurl, response.StatusCode, location)
+	target, err := req.URL.Parse(location)
+	switch {
+	case err != nil:
+		return []string{fmt.Sprintf("HTTP redirect: bad Location %q", location)}
+	case target.Scheme != "https":
+		return []string{"HTTP doesn't redirect to HTTPS"}
+	case !strings.EqualFold(target.Hostname(), host):
+		return []string{fmt.Sprintf("HTTP redirects to %s, not to HTTPS on %s first", target.Host, host)}
+	}
+	return nil
+}
+
+//
+// checkHSTSPreload - evaluate the response's Strict-Transport-Security
+// header, and the site, against the HSTS preload list's requirements,
+// and report whether the domain is eligible, or why not. An ineligible
+// domain sets exit status 1.
+//
+func checkHSTSPreload(w io.Writer, request *http.Request, result *probe.ProbeResult) {
+
+	// The header that matters is on the response to the URL requested,
+	// not one the request was redirected to.
+	first := redirectChain(result.Response)[0]
+	u := request.URL
+	if first.Request != nil {
+		u = first.Request.URL
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	fmt.Fprintf(w, "## HSTS Preload: %s\n", host)
+	var failures []string
+	if u.Scheme != "https" {
+		failures = append(failures, "not requested over HTTPS: browsers ignore HSTS over HTTP")
+	}
+	if domain := registrableDomain(host); domain != host {
+		failures = append(failures, fmt.Sprintf("%s is not the registrable domain: preload %s", host, domain))
+	}
+
+	if u.Path != "" && u.Path != "/" {
+		fmt.Fprintf(w, "   Note: the preload list checks https://%s/, not %s\n", host, u.Path)
+	}
+
+	values := first.Header.Values("Strict-Transport-Security")
+	if len(values) == 0 {
+		failures = append(failures, "no Strict-Transport-Security header")
+	} else {
+		fmt.Fprintf(w, "   Strict-Transport-Security: %s\n", values[0])
+		if len(values) > 1 {
+			fmt.Fprintf(w, "   Note: %d headers, browsers only use the first\n", len(values))
+		}
+		policy, err := parseHSTS(values[0])
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid header: %v", err))
+		} else {
+			if policy.MaxAge < hstsPreloadMaxAge {
+				failures = append(failures, fmt.Sprintf("max-age %d is less than %d (one year)",
+					policy.MaxAge, hstsPreloadMaxAge))
+			}
+			if !policy.IncludeSubDomains {
+				failures = append(failures, "no includeSubDomains directive")
+			}
+			if !policy.Preload {
+				failures = append(failures, "no preload directive")
+			}
+		}
+	}
+	failures = append(failures, hstsHTTPRedirect(w, request, host)...)
+
+	if len(failures) > 0 {
+		fmt.Fprintln(w, "   Result: NOT ELIGIBLE")
+		for _, f := range failures {
+			fmt.Fprintf(w, "      %s\n", f)
+		}
+		setExitStatus(ExitAssertion)
+		return
+	}
+	fmt.Fprintln(w, "   Result: ELIGIBLE")
+}
+
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// RedirectHop - a redirect followed by traceRedirects
+//
+type RedirectHop struct {
+	URL    string // URL requested
+	Status int    // Its redirect status
+	Next   string // Absolute URL it redirected to
+}
+
+//
+// traceRedirects - request the URL with prober, which must not follow
+// redirects itself, following up to max of them, and return each hop
+// and the final result. The error is for a chain that can't be
+// followed; the request's own failure is in the result.
+//
+func traceRedirects(prober *probe.Prober, rawurl string, max int) ([]RedirectHop, *probe.ProbeResult, error) {
+
+	var hops []RedirectHop
+	next := rawurl
+	for {
+		request := getRequest(prober, next)
+		result := readResponse(prober.NewSession(""), request)
+		if result.Err != nil {
+			return hops, result, nil
+		}
+		response := result.Response
+		location := response.Header.Get("Location")
+		if response.StatusCode < 300 || response.StatusCode >= 400 || location == "" {
+			return hops, result, nil
+		}
+		if len(hops) == max {
+			return hops, result, fmt.Errorf("more than %d redirects", max)
+		}
+		u, err := request.URL.Parse(location)
+		if err != nil {
+			return hops, result, fmt.Errorf("bad Location %q: %v", location, err)
+		}
+		hops = append(hops, RedirectHop{URL: next, Status: response.StatusCode, Next: u.String()})
+		next = u.String()
+	}
+}
+
+//
+// httpVariant - the http:// URL to check the redirect from: the URL
+// itself if it is http://, else the same one with http and the default
+// port
+//
+func httpVariant(request *http.Request) string {
+
+	u := *request.URL
+	u.Opaque = ""
+	if u.Scheme == "http" {
+		return u.String()
+	}
+	u.Scheme = "http"
+	u.Host = u.Hostname()
+	if strings.Contains(u.Host, ":") {
+		u.Host = "[" + u.Host + "]"
+	}
+	return u.String()
+}
+
+//
+// checkHTTPSRedirect - fetch the http:// variant of the URL, following
+// its redirects, and report each hop, and whether it redirects straight
+// to HTTPS on the same host, and stays on HTTPS. Problems set exit
+// status 1.
+//
+func checkHTTPSRedirect(w io.Writer, request *http.Request) {
+
+	from := httpVariant(request)
+	fmt.Fprintf(w, "## HTTPS Redirect: %s\n", from)
+	opts := probeOptions()
+	opts.NoRedirect = true
+	prober, err := probe.NewProber(opts)
+	if err != nil {
+		fmt.Fprintf(w, "   ERROR: %v\n", err)
+		setExitStatus(ExitOther)
+		return
+	}
+	hops, result, err := traceRedirects(prober, from, crawlMaxRedirects)
+	for i, hop := range hops {
+		fmt.Fprintf(w, "   [%d] %s %d -> %s\n", i+1, hop.URL, hop.Status, hop.Next)
+	}
+
+	var problems []string
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	if result.Err != nil {
+		fmt.Fprintf(w, "   [%d] ERROR: %v\n", len(hops)+1, result.Err)
+		problems = append(problems, "the chain ends in an error")
+	} else if err == nil {
+		final := result.Response.Request.URL.String()
+		fmt.Fprintf(w, "   [%d] %s %d\n", len(hops)+1, final, result.Response.StatusCode)
+		if !strings.HasPrefix(final, "https://") {
+			problems = append(problems, "the chain doesn't end on HTTPS")
+		}
+	}
+
+	if len(hops) == 0 {
+		if result.Err == nil {
+			problems = append(problems, "no redirect to HTTPS")
+		}
+	} else {
+		first, _ := url.Parse(hops[0].Next)
+		host := strings.TrimSuffix(request.URL.Hostname(), ".")
+		switch {
+		case first.Scheme != "https":
+			problems = append(problems, fmt.Sprintf("the first redirect is to %s, not HTTPS", first.Scheme))
+		case !strings.EqualFold(strings.TrimSuffix(first.Hostname(), "."), host):
+			problems = append(problems, fmt.Sprintf("the first redirect is to %s, not HTTPS on %s", first.Host, host))
+		}
+		for i, hop := range hops {
+			if strings.HasPrefix(hop.URL, "https://") && strings.HasPrefix(hop.Next, "http://") {
+				problems = append(problems, fmt.Sprintf("hop %d downgrades to HTTP: %s", i+1, hop.Next))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		fmt.Fprintln(w, "   Result: FAIL")
+		for _, p := range problems {
+			fmt.Fprintf(w, "      %s\n", p)
+		}
+		setExitStatus(ExitAssertion)
+		return
+	}
+	fmt.Fprintf(w, "   Result: OK (%d hop", len(hops))
+	if len(hops) != 1 {
+		fmt.Fprint(w, "s")
+	}
+	fmt.Fprintln(w, ")")
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/idna"
+)
+
+// Changes normalizeURL made to the URLs probed, by normalized URL
+var urlNotes = make(map[string][]string)
+var urlNotesLock sync.Mutex
+
+//
+// isASCII - is s all ASCII?
+//
+func isASCII(s string) bool {
+
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+//
+// unicodeHostname - the Unicode form of a hostname with punycode
+// (xn--) labels, or "" if it has none
+//
+func unicodeHostname(hostname string) string {
+
+	if !strings.Contains(strings.ToLower(hostname), "xn--") {
+		return ""
+	}
+	u, err := idna.Lookup.ToUnicode(hostname)
+	if err != nil || u == hostname {
+		return ""
+	}
+	return u
+}
+
+//
+// isUnreserved - is c an RFC 3986 unreserved character, which need
+// not be percent-encoded?
+//
+func isUnreserved(c byte) bool {
+
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+//
+// normalizePercent - RFC 3986 percent-encoding normalization: decode
+// the escapes of unreserved characters, and put the hex digits of the
+// others in upper case
+//
+func normalizePercent(s string) string {
+
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		hi, lo := strings.IndexByte(hex, upper(s[i+1])), strings.IndexByte(hex, upper(s[i+2]))
+		if hi < 0 || lo < 0 {
+			b.WriteByte(s[i])
+			continue
+		}
+		if c := byte(hi<<4 | lo); isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hex[hi])
+			b.WriteByte(hex[lo])
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+//
+// upper - an ASCII letter in upper case
+//
+func upper(c byte) byte {
+
+	if 'a' <= c && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+//
+// removeDotSegments - the path with its "." and ".." segments resolved,
+// as RFC 3986 section 5.2.4 does
+//
+func removeDotSegments(path string) string {
+
+	if !strings.HasPrefix(path, "/") {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, segment)
+			continue
+		}
+		if last {
+			out = append(out, "")
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+//
+// normalizeURL - the URL to request for s: a Unicode hostname converted
+// to its IDNA (punycode) form, for DNS and SNI, and unless noNormalize,
+// normalized as RFC 3986 section 6 describes: the hostname in lower
+// case, the default port removed, percent-encoding normalized and dot
+// segments resolved in the path. Also returns the changes made, and
+// notes that the fragment, which is never sent, was dropped.
+//
+func normalizeURL(s string, noNormalize bool) (string, []string, error) {
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", nil, err
+	}
+	var notes []string
+
+	hostname, port := u.Hostname(), u.Port()
+	hostpart := strings.TrimSuffix(u.Host, ":"+port)
+	if net.ParseIP(hostname) == nil {
+		switch {
+		case !isASCII(hostname):
+			ascii, err := idna.Lookup.ToASCII(hostname)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid internationalized hostname %q: %v", hostname, err)
+			}
+			notes = append(notes, fmt.Sprintf("hostname %s converted to %s (IDNA)", hostname, ascii))
+			hostpart = ascii
+		case !noNormalize && hostname != strings.ToLower(hostname):
+			notes = append(notes, fmt.Sprintf("hostname %s lowercased", hostname))
+			hostpart = strings.ToLower(hostname)
+		}
+	}
+	if !noNormalize && port != "" && port == portMap[u.Scheme] {
+		notes = append(notes, fmt.Sprintf("default port :%s removed", port))
+		port = ""
+	}
+	u.Host = hostpart
+	if port != "" {
+		u.Host += ":" + port
+	}
+
+	if !noNormalize {
+		path := u.EscapedPath()
+		if p := normalizePercent(path); p != path {
+			notes = append(notes, fmt.Sprintf("percent-encoding normalized: %s to %s", path, p))
+			path = p
+		}
+		if p := removeDotSegments(path); p != path {
+			notes = append(notes, fmt.Sprintf("dot segments resolved: %s to %s", path, p))
+			path = p
+		}
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			u.Path, u.RawPath = unescaped, path
+		}
+		if q := normalizePercent(u.RawQuery); q != u.RawQuery {
+			notes = append(notes, fmt.Sprintf("percent-encoding normalized: ?%s to ?%s", u.RawQuery, q))
+			u.RawQuery = q
+		}
+	}
+	if u.Fragment != "" {
+		notes = append(notes, fmt.Sprintf("fragment #%s not sent", u.Fragment))
+		u.Fragment, u.RawFragment = "", ""
+	}
+	return u.String(), notes, nil
+}
+
+//
+// prepareURL - the URL to probe for a target URL: with the -port,
+// normalized, and with the -param parameters added. The changes made
+// are kept for printNormalization.
+//
+func prepareURL(urlstring string) (string, error) {
+
+	var portnote []string
+	if options.port != "" {
+		u, err := replacePort(urlstring, options.port)
+		if err != nil {
+			return "", err
+		}
+		urlstring = u
+		portnote = []string{fmt.Sprintf("port %s from -port", options.port)}
+	}
+	u, notes, err := normalizeURL(urlstring, options.nonormalize)
+	if err != nil {
+		return "", err
+	}
+	notes = append(portnote, notes...)
+	if options.params != nil {
+		if u, err = addParams(u, options.params); err != nil {
+			return "", fmt.Errorf("-param: %v", err)
+		}
+	}
+	if notes != nil {
+		urlNotesLock.Lock()
+		urlNotes[u] = notes
+		urlNotesLock.Unlock()
+	}
+	return u, nil
+}
+
+//
+// printNormalization - print the Unicode form of a punycode hostname,
+// and the changes normalizeURL made to the URL
+//
+func printNormalization(w io.Writer, urlstring, hostname string) {
+
+	if u := unicodeHostname(hostname); u != "" {
+		fmt.Fprintf(w, "Hostname (Unicode): %s\n", u)
+	}
+	urlNotesLock.Lock()
+	notes := urlNotes[urlstring]
+	urlNotesLock.Unlock()
+	if notes != nil {
+		fmt.Fprintln(w, "URL Normalization:")
+		for _, note := range notes {
+			fmt.Fprintf(w, "\t%s\n", note)
+		}
+	}
+}
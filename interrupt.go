@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+//
+// runContext - the context DNS lookups, connections and requests are
+// made in. It is cancelled by SIGINT or SIGTERM (see interruptible),
+// and with -deadline, when the deadline passes.
+//
+var runContext = context.Background()
+
+// The part of runContext the signals cancel, if interruptible
+var signalContext context.Context
+
+// The part of runContext -max-total-time ends, with the function that
+// releases it, and when the run began
+var (
+	budgetContext context.Context
+	budgetCancel  context.CancelFunc
+	runStart      time.Time
+)
+
+// The function that releases the -deadline part of runContext
+var deadlineCancel context.CancelFunc
+
+//
+// interruptible - make SIGINT and SIGTERM cancel runContext, so that
+// probes in progress end with an error that is reported like any
+// other, URLs not yet probed are skipped, and the summaries of what was
+// done are still printed. A second signal kills the program at once.
+//
+func interruptible() {
+
+	ctx, stop := signal.NotifyContext(runContext, os.Interrupt, syscall.SIGTERM)
+	runContext, signalContext = ctx, ctx
+	go func() {
+		<-ctx.Done()
+		stop()
+		fmt.Fprintln(os.Stderr, "Interrupted: cancelling the probes in progress (again to quit)")
+	}()
+}
+
+//
+// interrupted - was the program interrupted by a signal?
+//
+func interrupted() bool {
+	return signalContext != nil && signalContext.Err() != nil
+}
+
+//
+// limitRun - end runContext when limit (-max-total-time) has passed:
+// the DNS lookups, connections and requests in progress then, for all
+// of the URLs, addresses and redirects, are cancelled, and any not yet
+// started are skipped
+//
+func limitRun(limit time.Duration) {
+
+	runStart = time.Now()
+	runContext, budgetCancel = context.WithTimeout(runContext, limit)
+	budgetContext = runContext
+}
+
+//
+// limitProbes - end runContext when the -deadline for probing the URLs
+// has passed. It must be set before the prober is made, which makes its
+// requests in runContext.
+//
+func limitProbes(deadline time.Duration) {
+	runContext, deadlineCancel = context.WithTimeout(runContext, deadline)
+}
+
+//
+// budgetExpired - did the run take all of its -max-total-time?
+//
+func budgetExpired() bool {
+	return budgetContext != nil && budgetContext.Err() == context.DeadlineExceeded
+}
+
+//
+// stopReason - why the run stopped before probing everything: it was
+// interrupted, it ran out of -max-total-time, or -deadline passed
+//
+func stopReason() string {
+
+	switch {
+	case interrupted():
+		return "the interrupt"
+	case budgetExpired():
+		return "-max-total-time"
+	}
+	return "the deadline"
+}
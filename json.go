@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//
+// isJSON - does the response declare a JSON media type? This includes
+// application/json and structured syntax suffix types like +json.
+//
+func isJSON(header http.Header) bool {
+
+	mediatype, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediatype == "application/json" || mediatype == "text/json" ||
+		strings.HasSuffix(mediatype, "+json")
+}
+
+//
+// prettyJSON - re-indent a JSON body. Returns the body unchanged if it
+// does not parse.
+//
+func prettyJSON(body []byte) []byte {
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		return body
+	}
+	return out.Bytes()
+}
+
+//
+// pathStep - one component of a JSON path expression: an object key,
+// an array index, or a wildcard matching all members or elements.
+//
+type pathStep struct {
+	key      string
+	index    int
+	isindex  bool
+	wildcard bool
+}
+
+//
+// parseJSONPath - parse a simple JSONPath-like expression, such as
+// "$.items[0].name", ".items[*].id", or "headers.Host". The leading
+// "$" is optional. Keys with special characters can be written as
+// ["some key"].
+//
+func parseJSONPath(expr string) ([]pathStep, error) {
+
+	var steps []pathStep
+
+	s := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			key := s[:end]
+			s = s[end:]
+			switch key {
+			case "":
+				return nil, fmt.Errorf("invalid JSON path %q: empty key", expr)
+			case "*":
+				steps = append(steps, pathStep{wildcard: true})
+			default:
+				steps = append(steps, pathStep{key: key})
+			}
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: unterminated [", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, pathStep{wildcard: true})
+			case strings.HasPrefix(inner, "\"") || strings.HasPrefix(inner, "'"):
+				key, err := strconv.Unquote("\"" + strings.Trim(inner, "\"'") + "\"")
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSON path %q: bad key %s", expr, inner)
+				}
+				steps = append(steps, pathStep{key: key})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSON path %q: bad index %s", expr, inner)
+				}
+				steps = append(steps, pathStep{index: index, isindex: true})
+			}
+		default:
+			// Allow the leading dot to be omitted
+			s = "." + s
+		}
+	}
+	return steps, nil
+}
+
+//
+// evalJSONPath - apply the path steps to a decoded JSON value, returning
+// all matching values.
+//
+func evalJSONPath(value interface{}, steps []pathStep) []interface{} {
+
+	if len(steps) == 0 {
+		return []interface{}{value}
+	}
+
+	var results []interface{}
+	step, rest := steps[0], steps[1:]
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if step.wildcard {
+			for _, member := range v {
+				results = append(results, evalJSONPath(member, rest)...)
+			}
+		} else if member, ok := v[step.key]; ok && !step.isindex {
+			results = evalJSONPath(member, rest)
+		}
+	case []interface{}:
+		if step.wildcard {
+			for _, element := range v {
+				results = append(results, evalJSONPath(element, rest)...)
+			}
+		} else if step.isindex {
+			index := step.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				results = evalJSONPath(v[index], rest)
+			}
+		}
+	}
+	return results
+}
+
+//
+// printJSONPath - print the values in body matched by the path
+// expression, one per line. Strings are printed unquoted, and other
+// values as compact JSON.
+//
+func printJSONPath(w io.Writer, body []byte, steps []pathStep) error {
+
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("body is not valid JSON: %v", err)
+	}
+
+	for _, match := range evalJSONPath(value, steps) {
+		if s, ok := match.(string); ok {
+			fmt.Fprintln(w, s)
+			continue
+		}
+		out, err := json.Marshal(match)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(out))
+	}
+	return nil
+}
@@ -5,17 +5,16 @@ package main
  */
 
 import (
-	"context"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"strings"
-	"time"
+
+	"github.com/shuque/gohttp/probe"
 )
 
 // Version and Program name strings
@@ -27,107 +26,86 @@ var portMap = map[string]string{
 	"https": "443",
 }
 
-// Result structure
-type Result struct {
-	response     *http.Response
-	body         []byte
-	responsetime time.Duration
-	err          error
-}
-
-func printStatus(response *http.Response) {
+func printStatus(w io.Writer, response *http.Response) {
 
-	fmt.Println("## HTTP Status:")
-	fmt.Printf("   HTTP Status: %d %s\n", response.StatusCode, http.StatusText(response.StatusCode))
-	fmt.Printf("   HTTP Protocol: %d %d %s\n", response.ProtoMajor, response.ProtoMinor, response.Proto)
-	fmt.Printf("   HTTP ContentLength: %d\n", response.ContentLength)
-	fmt.Printf("   HTTP Close: %v\n", response.Close)
-	fmt.Printf("   HTTP Uncompressed: %v\n", response.Uncompressed)
+	fmt.Fprintln(w, "## HTTP Status:")
+	fmt.Fprintf(w, "   HTTP Status: %d %s\n", response.StatusCode, http.StatusText(response.StatusCode))
+	fmt.Fprintf(w, "   HTTP Protocol: %d %d %s\n", response.ProtoMajor, response.ProtoMinor, response.Proto)
+	fmt.Fprintf(w, "   HTTP ContentLength: %d\n", response.ContentLength)
+	fmt.Fprintf(w, "   HTTP Close: %v\n", response.Close)
+	fmt.Fprintf(w, "   HTTP Uncompressed: %v\n", response.Uncompressed)
 }
 
-func printHeaders(header http.Header) {
+func printHeaders(w io.Writer, header http.Header) {
 
-	fmt.Println("## HTTP Headers:")
-	for headerkey, headervalue := range header {
-		fmt.Printf("   %s: %s\n", headerkey, strings.Join(headervalue, ","))
+	fmt.Fprintln(w, "## HTTP Headers:")
+	for _, headerkey := range headerKeys(header) {
+		fmt.Fprintf(w, "   %s: %s\n", headerkey, headerValue(headerkey, header[headerkey]))
 	}
-	fmt.Println("## End of HTTP Headers.")
+	fmt.Fprintln(w, "## End of HTTP Headers.")
 }
 
-func readResponse(client http.Client, request *http.Request) (result *Result) {
-
-	var response *http.Response
-	var body []byte
-	var err error
-
-	result = new(Result)
+//
+// printTrailers - print the trailer fields that came after the body,
+// and any that were announced in the Trailer header but not sent
+//
+func printTrailers(w io.Writer, response *http.Response) {
 
-	if options.username != "" {
-		request.SetBasicAuth(options.username, options.password)
-	}
-
-	t0 := time.Now()
-	response, err = client.Do(request)
-	if err != nil {
-		result.err = err
+	if len(response.Trailer) == 0 {
 		return
 	}
-	if response.Body != nil {
-		defer response.Body.Close()
+	fmt.Fprintln(w, "## HTTP Trailers:")
+	for _, key := range headerKeys(response.Trailer) {
+		if values := response.Trailer[key]; len(values) > 0 {
+			fmt.Fprintf(w, "   %s: %s\n", key, headerValue(key, values))
+		} else {
+			fmt.Fprintf(w, "   %s: (announced, but not received)\n", key)
+		}
 	}
+}
 
-	body, err = ioutil.ReadAll(response.Body)
-	result.responsetime = time.Since(t0)
-	result.response = response
-	result.body = body
-	result.err = err
-	return
+//
+// readResponse - make the request, reading the body into memory, and
+// count it in the admin statistics.
+//
+func readResponse(session *probe.Session, request *http.Request) *probe.ProbeResult {
+
+	statProbes.Add(1)
+	result := session.Do(request)
+	if result.Err != nil {
+		statProbeErrors.Add(1)
+	}
+	return result
 }
 
-func getRequest(url string) *http.Request {
+func getRequest(prober *probe.Prober, url string) *http.Request {
 
-	request, err := http.NewRequest(http.MethodGet, url, nil)
+	request, err := newRequest(prober, options.method, url)
 	if err != nil {
-		log.Fatal(err)
-	}
-	request.Header.Add("User-Agent", options.useragent)
-	for _, header := range options.headers {
-		tmp := strings.SplitN(header, ":", 2)
-		key := tmp[0]
-		val := tmp[1]
-		request.Header.Add(key, val)
+		fatal(ExitUsage, err)
 	}
 	return request
 }
 
-func getClient(address string) http.Client {
+//
+// newRequest - a request with the options' cookies, credentials and
+// headers
+//
+func newRequest(prober *probe.Prober, method, url string) (*http.Request, error) {
 
-	client := http.Client{
-		Timeout: options.timeout,
+	request, err := prober.NewRequest(method, url)
+	if err != nil {
+		return nil, err
 	}
-
-	transport := &http.Transport{
-		TLSClientConfig:   getTLSConfig(),
-		ForceAttemptHTTP2: true,
+	for _, cookie := range options.cookies {
+		request.AddCookie(cookie)
 	}
-
-	if address != "" {
-		transport.DialContext = func(ctx context.Context, network, unusedaddress string) (net.Conn, error) {
-			dialer := new(net.Dialer)
-			dialer.Timeout = options.timeout
-			return dialer.Dial(network, address)
-		}
+	applyNetrc(request)
+	applyOAuth2(request)
+	if options.absoluteform {
+		setAbsoluteForm(request)
 	}
-
-	client.Transport = transport
-
-	if options.noredirect {
-		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		}
-	}
-
-	return client
+	return request.WithContext(runContext), nil
 }
 
 func addressString(ipaddress net.IP, port string) string {
@@ -153,33 +131,292 @@ func url2addressport(urlstring string) (hostname, port string, err error) {
 	return hostname, port, nil
 }
 
-func querySingle(request *http.Request, address string) {
+//
+// printConnection - print the addresses of the connection the response
+// came on: which of the server's addresses was used, and from where
+//
+func printConnection(w io.Writer, result *probe.ProbeResult) {
+
+	if result.RemoteAddr == "" {
+		return
+	}
+	via := ""
+	if options.proxy != nil && options.rawrequest == nil {
+		via = " (proxy)"
+	}
+	fmt.Fprintf(w, "## Connected: %s%s from %s\n", result.RemoteAddr, via, fmtLocalAddr(result.LocalAddr))
+}
+
+//
+// printInterim - print the informational (1xx) responses that came
+// before the final response, such as 103 Early Hints, with when each
+// arrived after the request was sent
+//
+func printInterim(w io.Writer, result *probe.ProbeResult) {
+
+	if len(result.Interim) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "## Interim Responses:")
+	for _, r := range result.Interim {
+		fmt.Fprintf(w, "   +%s %d %s\n", fmtDuration(r.Elapsed), r.StatusCode, http.StatusText(r.StatusCode))
+		for _, key := range headerKeys(r.Header) {
+			for _, value := range r.Header[key] {
+				fmt.Fprintf(w, "      %s: %s\n", key, value)
+			}
+		}
+	}
+}
+
+//
+// querySingle - make the request, connecting to address if non-empty,
+// and print the result with the -output formatter. Returns the
+// result.
+//
+func querySingle(w *Report, prober *probe.Prober, request *http.Request, address string) *probe.ProbeResult {
+
+	if options.connectonly || options.tlsonly {
+		return connectSingle(w, prober, request, address)
+	}
+	if options.websocket {
+		return webSocketSingle(w, prober, request, address)
+	}
+	if options.sse {
+		return sseSingle(w, prober, request, address)
+	}
+	return querySession(w, prober, prober.NewSession(address), request, address)
+}
+
+//
+// querySession - make the request on the session, which may have a
+// connection open to reuse, and print the result. Returns the result.
+//
+func querySession(w *Report, prober *probe.Prober, session *probe.Session, request *http.Request, address string) *probe.ProbeResult {
+
+	var result *probe.ProbeResult
+	var filename string
+
+	if options.verbose && !options.bodyonly {
+		printRequestDump(w, request)
+	}
 
-	client := getClient(address)
-	result := readResponse(client, request)
-	if result.err != nil {
-		fmt.Println(result.err)
+	switch {
+	case options.rawrequest != nil:
+		result = readRawResponse(session, request)
+	case outputToFile():
+		result, filename = saveBody(session, request)
+	case options.headfallback && !bodyNeeded():
+		result = retryResponse(w, session, headRequest(request))
+		if result.Err == nil && (result.Response.StatusCode == http.StatusMethodNotAllowed ||
+			result.Response.StatusCode == http.StatusNotImplemented) {
+			fmt.Fprintf(w, "## HEAD rejected with %d, falling back to GET\n",
+				result.Response.StatusCode)
+			result = retryResponse(w, session, request)
+		}
+	default:
+		result = retryResponse(w, session, request)
+	}
+	endUploadProgress()
+	out := &ProbeOutput{Prober: prober, Session: session, Request: request, Address: address,
+		Result: result, Filename: filename}
+	if result.Err != nil {
+		setExitStatus(classifyError(result.Err))
+		options.formatter.Details(w, out)
+		options.formatter.Result(w, out)
+		return result
+	}
+
+	if options.failhttp && result.Response.StatusCode >= 400 {
+		setExitStatus(ExitHTTPError)
+	}
+
+	options.formatter.Details(w, out)
+	printVerdicts(w, out)
+	if options.recorddir != "" {
+		if err := recordResult(request, session.RemoteAddr(), result); err != nil {
+			fmt.Fprintf(w, "ERROR: -record: %v\n", err)
+			setExitStatus(ExitOther)
+		}
+	}
+	options.formatter.Result(w, out)
+	return result
+}
+
+//
+// Details - the report's sections on the probe, or the error if it
+// failed
+//
+func (textFormatter) Details(w *Report, out *ProbeOutput) {
+
+	prober, session, request, address := out.Prober, out.Session, out.Request, out.Address
+	result, filename := out.Result, out.Filename
+	if result.Err != nil {
+		fmt.Fprintf(w, "ERROR: %v\n", result.Err)
 		return
 	}
 
 	if !options.bodyonly {
-		fmt.Printf("## ResponseTime: %v\n", result.responsetime)
-		printTLSinfo(result.response)
-		printStatus(result.response)
-		printHeaders(result.response.Header)
+		fmt.Fprintf(w, "## ResponseTime: %s\n", fmtDuration(result.ResponseTime))
+		printConnection(w, result)
+		printTLSinfo(w, result.Response)
+		printRedirectTLS(w, result)
+		printInterim(w, result)
+		if result.Continue != nil {
+			printContinue(w, result.Continue, result.Response)
+		}
+		printStatus(w, result.Response)
+		if options.verbose {
+			printResponseDump(w, result.Response)
+		} else {
+			printHeaders(w, result.Response.Header)
+		}
+		printTrailers(w, result.Response)
+		printSetCookies(w, result.Response)
+		if options.method == http.MethodOptions {
+			printCapabilities(w, result.Response.Header)
+		}
+		printAltSvc(w, prober, request, result)
+		printTransferInfo(w, result)
+		if options.tcpinfo {
+			printTCPStats(w, session)
+		}
+		if options.aws != nil {
+			printSigV4(w, options.aws)
+		}
+		if options.hash {
+			printDigests(w, result)
+		}
+		if options.domaincheck {
+			printDomainAnalysis(w, result.Response)
+		}
+		if options.hstspreload {
+			checkHSTSPreload(w, request, result)
+		}
+		if options.httpsredirect {
+			checkHTTPSRedirect(w, request)
+		}
+		if options.fingerprint {
+			printFingerprint(w, result.Response)
+		}
+		if options.comparesni {
+			compareSNI(w, request, address, result.RemoteAddr)
+		}
+		if options.clockskew {
+			printClockSkew(w, result)
+		}
+		if options.sniff {
+			printSniffCheck(w, result.Response, result.Body)
+		}
+		if options.extract {
+			printExtract(w, result.Response, result.Body)
+		}
+		if !outputToFile() {
+			printMixedContent(w, result.Response, result.Body)
+		}
+		if options.auditcookies {
+			printCookieAudit(w, result.Response)
+		}
+		if outputToFile() {
+			printDownloadInfo(w, filename, result)
+		} else if options.encodings != nil {
+			printEncodingInfo(w, result)
+		}
+		if options.byterange != nil {
+			printRangeResult(w, options.byterange, result)
+		}
+		if options.checkranges {
+			checkRanges(w, session, request)
+		}
+		if options.reusetest {
+			checkReuse(w, prober, request, address)
+		}
+		if options.cors != nil {
+			checkCORS(w, session, request)
+		}
+		if options.negotiate {
+			checkNegotiation(w, session, request)
+		}
+		if options.hostforms {
+			checkHostForms(w, request, address)
+		}
+		if options.halfclose || options.stallread != nil {
+			checkDraining(w, session, request)
+		}
+		if options.scenario != "" {
+			runScenario(w, session, request)
+		}
+		if options.h2info {
+			printH2Info(w, session, request, result.Response)
+		}
+		if options.trailingdot || strings.HasSuffix(request.URL.Hostname(), ".") {
+			checkTrailingDot(w, request, address, result)
+		}
 	}
+}
+
+//
+// printVerdicts - print the results of the -expect-* assertions, -check
+// commands and -script for a successful request
+//
+func printVerdicts(w *Report, out *ProbeOutput) {
 
-	if options.printbody || options.bodyonly {
-		fmt.Printf("%s\n", result.body)
+	if options.assertions.Active() {
+		printAssertions(w, &options.assertions, out.Result)
+	}
+	if options.checks != nil {
+		printChecks(w, out.Request, out.Address, out.Result)
+	}
+	if options.script != nil {
+		printScript(w, out.Prober, out.Result)
 	}
 }
 
-func getIpList(hostname string) []net.IP {
+//
+// Result - with -printbody or -bodyonly, the body (or the -jsonpath
+// fields of it), unless it was saved to a file
+//
+func (textFormatter) Result(w *Report, out *ProbeOutput) {
 
-	iplist, err := net.LookupIP(hostname)
+	result := out.Result
+	if result.Err != nil || outputToFile() {
+		return
+	}
+	if options.jsonpath != nil {
+		if err := printJSONPath(w.Payload(), result.Body, options.jsonpath); err != nil {
+			fmt.Fprintf(w, "ERROR: -jsonpath: %v\n", err)
+		}
+	} else if options.printbody || options.bodyonly {
+		body := result.Body
+		if options.pretty && isJSON(result.Response.Header) {
+			body = prettyJSON(body)
+		}
+		fmt.Fprintf(w.Payload(), "%s\n", body)
+	}
+}
+
+func getIpList(hostname string) ([]net.IP, *DNSLookup, error) {
+
+	var iplist []net.IP
+	var lookup *DNSLookup
+	var err error
+
+	if dnsCache != nil {
+		var l DNSLookup
+		iplist, l, err = dnsCache.Lookup(hostname)
+		lookup = &l
+	} else {
+		iplist, err = net.DefaultResolver.LookupIP(runContext, "ip", hostname)
+	}
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
+	return filterAddresses(iplist), lookup, nil
+}
+
+//
+// filterAddresses - the addresses of the family chosen with -4 or -6
+//
+func filterAddresses(iplist []net.IP) []net.IP {
 
 	if !(options.ipv6only || options.ipv4only) {
 		return iplist
@@ -201,40 +438,222 @@ func getIpList(hostname string) []net.IP {
 	return filteredlist
 }
 
-func prologue(urlstring, hostname, port string, iplist []net.IP) {
+func prologue(w io.Writer, urlstring, hostname, port string, iplist []net.IP, lookup *DNSLookup) {
 
-	fmt.Printf("URL: %s\nHostname: %s\nPort: %s\n", urlstring, hostname, port)
-	fmt.Println("Addresses:")
+	fmt.Fprintf(w, "URL: %s\nHostname: %s\n", urlstring, hostname)
+	printNormalization(w, urlstring, hostname)
+	fmt.Fprintf(w, "Port: %s\n", port)
+	if options.hostheader != "" {
+		fmt.Fprintf(w, "Host header: %s\n", options.hostheader)
+	}
+	if options.tlsprint != "" {
+		hello, _ := probe.TLSFingerprintHello(options.tlsprint)
+		fmt.Fprintf(w, "TLS fingerprint: %s (%s ClientHello)\n", options.tlsprint, hello)
+	}
+	if options.proxy != nil {
+		fmt.Fprintf(w, "Proxy: %s\n", options.proxy.Redacted())
+	}
+	printConnectTo(w, hostname, port)
+	fmt.Fprintln(w, "Addresses:")
 	for _, ipaddress := range iplist {
-		fmt.Printf("\t%s\n", ipaddress)
+		fmt.Fprintf(w, "\t%s\n", ipaddress)
+	}
+	if lookup != nil {
+		fmt.Fprintf(w, "DNS: %s\n", lookup)
+	}
+	if options.oauth2 != nil {
+		fmt.Fprintf(w, "OAuth2: %s\n", options.oauth2)
 	}
 }
 
-func main() {
-
-	var request *http.Request
-
-	urlstring := doFlags()
+//
+// probeURL - resolve the URL's hostname, and probe it, or each of its
+// addresses with -queryall. If multi is true, reports are identified by
+// URL rather than hostname, since there are several URLs.
+//
+func probeURL(prober *probe.Prober, urlstring string, multi bool, summary *Summary) {
 
 	hostname, port, err := url2addressport(urlstring)
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		setExitStatus(ExitUsage)
+		summary.record(nil)
+		return
+	}
+
+	// With a proxy, the proxy resolves the hostname, not us.
+	// A fully qualified name (with a trailing dot) that doesn't resolve,
+	// as names from the hosts file may not, is connected to by its bare
+	// form, keeping the trailing dot in the Host header. With -connect-to,
+	// the addresses are those of the host connected to instead.
+	var iplist []net.IP
+	var lookup *DNSLookup
+	var address string
+	connhost, connport := connectHost(hostname, port)
+	if ips := resolveOverride(connhost, connport); ips != nil {
+		iplist = filterAddresses(ips)
+	} else if options.proxy == nil {
+		iplist, lookup, err = getIpList(connhost)
+		if err != nil && strings.HasSuffix(connhost, ".") && connhost == hostname {
+			bare, _ := dotForms(hostname)
+			if iplist, lookup, err = getIpList(bare); err == nil {
+				address = net.JoinHostPort(bare, port)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			setExitStatus(ExitDNS)
+			summary.record(nil)
+			options.formatter.Failed(urlstring, err)
+			return
+		}
 	}
-	iplist := getIpList(hostname)
 
+	id := hostname
+	if multi {
+		id = urlstring
+	}
+	report := NewReport(id)
 	if !options.bodyonly {
-		prologue(urlstring, hostname, port, iplist)
+		prologue(report, urlstring, hostname, port, iplist, lookup)
+		if options.dnsdetail && options.proxy == nil {
+			printDNSDetail(report, hostname)
+		}
+		if options.dnssec && options.proxy == nil {
+			printDNSSEC(report, hostname)
+		}
+		if options.happyeyeballs {
+			fmt.Fprintln(report)
+			printHappyEyeballs(report, prober, iplist, connport)
+		}
 	}
 
-	request = getRequest(urlstring)
+	request := getRequest(prober, urlstring)
 
 	if options.queryall {
+		report.Flush()
 		for _, ipaddress := range iplist {
-			fmt.Printf("\nCONNECT: %s %s ..\n", ipaddress, port)
-			querySingle(request, addressString(ipaddress, port))
+			if runContext.Err() != nil {
+				summary.skip(addressString(ipaddress, port) + " " + urlstring)
+				continue
+			}
+			report := NewReport(ipaddress.String())
+			fmt.Fprintf(report, "\nCONNECT: %s %s ..\n", ipaddress, port)
+			summary.record(querySingle(report, prober, request, addressString(ipaddress, port)))
+			report.Flush()
+		}
+	} else {
+		if !options.bodyonly {
+			fmt.Fprintln(report)
+		}
+		summary.record(querySingle(report, prober, request, address))
+		report.Flush()
+	}
+}
+
+func main() {
+
+	if len(os.Args) > 1 && os.Args[1] == "replay-serve" {
+		os.Exit(replayServe(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "certsweep" {
+		os.Exit(certSweep(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		os.Exit(agent(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		os.Exit(merge(os.Args[2:]))
+	}
+
+	urls := doFlags()
+
+	if options.offline != "" {
+		os.Exit(offline(urls))
+	}
+	if options.remote != nil {
+		os.Exit(remote(urls))
+	}
+	if options.dnsonly {
+		os.Exit(dnsOnly(urls))
+	}
+
+	if options.adminaddr != "" {
+		if err := startAdminServer(options.adminaddr); err != nil {
+			fatal(ExitUsage, err)
 		}
+	}
+
+	interruptible()
+	if options.maxtotal > 0 {
+		limitRun(options.maxtotal)
+	}
+	if options.deadline > 0 && !options.monitor && !options.interactive {
+		limitProbes(options.deadline)
+	}
+
+	multi := len(urls) > 1 || options.urlsfile != ""
+	if options.dnscache || ((multi || options.monitor) && !options.nodnscache) {
+		dnsCache = newDNSCache()
+	}
+
+	prober, err := probe.NewProber(probeOptions())
+	if err != nil {
+		fatal(ExitOther, err)
+	}
+
+	if options.oauth2 != nil {
+		if _, err := options.oauth2.Token(); err != nil {
+			fatal(classifyError(err), fmt.Errorf("OAuth2: %v", err))
+		}
+	}
+
+	if options.ascurl {
+		os.Exit(asCurl(prober, urls))
+	}
+
+	if options.statusonly {
+		os.Exit(statusOnly(prober, urls))
+	}
+
+	if options.crawl {
+		os.Exit(crawl(prober, urls))
+	}
+
+	if options.monitor {
+		monitor(prober, urls)
+		saveCookieJar()
+		os.Exit(exitStatus)
+	}
+
+	if options.interactive {
+		status := interactive(prober, urls[0])
+		saveCookieJar()
+		os.Exit(status)
+	}
+
+	summary := newSummary()
+	if options.requestfile != nil {
+		probeRequestFile(summary)
 	} else {
-		fmt.Println()
-		querySingle(request, "")
+		probeAll(prober, urls, summary)
 	}
+	// Set before Close, for formats with exit codes of their own
+	if budgetExpired() {
+		setExitStatus(ExitTimeout)
+	}
+	if interrupted() {
+		overrideExitStatus(ExitInterrupted)
+	}
+	options.formatter.Close()
+	if multi && !options.bodyonly {
+		summary.print(diagOut)
+		dnsCache.print(diagOut)
+	}
+	if budgetExpired() {
+		summary.printBudget(diagOut)
+	}
+
+	saveCookieJar()
+	os.Exit(exitStatus)
 }
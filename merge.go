@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+//
+// mergeInput - a result in any of the JSON forms gohttp writes: a probe
+// result (-record, -ndjson), or an agent's reply holding one
+//
+type mergeInput struct {
+	ResultJSON
+	Agent      string      `json:"agent"`
+	BodySHA256 string      `json:"body_sha256"`
+	Result     *ResultJSON `json:"result"`
+}
+
+//
+// mergeLabel - the label of results read from file: its name, without
+// the .json suffix
+//
+func mergeLabel(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), ".json")
+}
+
+//
+// readMergeFile - read the results in file, which may hold one JSON
+// object or a sequence of them, as -ndjson writes, labelling each with
+// where it came from
+//
+func readMergeFile(file string) ([]*AgentResult, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var results []*AgentResult
+	decoder := json.NewDecoder(f)
+	for {
+		var in mergeInput
+		if err := decoder.Decode(&in); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		a := &AgentResult{Agent: mergeLabel(file), BodySHA256: in.BodySHA256, Result: in.Result}
+		if in.Result == nil {
+			r := in.ResultJSON
+			a.Result = &r
+		} else if in.Agent != "" {
+			a.Agent = in.Agent + "@" + a.Agent
+		}
+		if a.BodySHA256 == "" && (a.Result.Body != "" || a.Result.BodyBase64 != nil) {
+			body := []byte(a.Result.Body)
+			if a.Result.BodyBase64 != nil {
+				body = a.Result.BodyBase64
+			}
+			sum := sha256.Sum256(body)
+			a.BodySHA256 = hex.EncodeToString(sum[:])
+		}
+		if a.Result.URL == "" {
+			return nil, fmt.Errorf("%s: result without a URL", file)
+		}
+		results = append(results, a)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%s: no results", file)
+	}
+	return results, nil
+}
+
+//
+// mergeRows - the rows of the comparison matrix, and how each is read
+// from a result
+//
+var mergeRows = []struct {
+	name  string
+	value func(*AgentResult) string
+}{
+	{"Time", func(a *AgentResult) string {
+		if t, err := time.Parse(time.RFC3339Nano, a.Result.Time); err == nil {
+			return formatTime(t)
+		}
+		return a.Result.Time
+	}},
+	{"Status", func(a *AgentResult) string {
+		if a.Result.Error != "" {
+			return "ERROR"
+		}
+		return fmt.Sprint(a.Result.Status)
+	}},
+	{"Protocol", func(a *AgentResult) string { return a.Result.Proto }},
+	{"Address", func(a *AgentResult) string { return a.Result.Address }},
+	{"Header time", func(a *AgentResult) string {
+		return fmtDuration(time.Duration(a.Result.HeaderTime * float64(time.Millisecond)))
+	}},
+	{"Response time", func(a *AgentResult) string {
+		return fmtDuration(time.Duration(a.Result.ResponseTime * float64(time.Millisecond)))
+	}},
+	{"TLS", func(a *AgentResult) string {
+		if a.Result.TLS == nil {
+			return ""
+		}
+		return a.Result.TLS.Version
+	}},
+	{"Cert serial", func(a *AgentResult) string {
+		if a.Result.TLS == nil || len(a.Result.TLS.Certs) == 0 {
+			return ""
+		}
+		return a.Result.TLS.Certs[0].Serial
+	}},
+	{"Cert expires", func(a *AgentResult) string {
+		if a.Result.TLS == nil || len(a.Result.TLS.Certs) == 0 {
+			return ""
+		}
+		return a.Result.TLS.Certs[0].NotAfter
+	}},
+	{"Body size", func(a *AgentResult) string { return fmt.Sprint(a.Result.BodySize) }},
+	{"Error", func(a *AgentResult) string { return a.Result.Error }},
+}
+
+//
+// printMergeMatrix - print the results for one URL side by side, one
+// column per result, followed by how they differ
+//
+func printMergeMatrix(w io.Writer, urlstring string, results []*AgentResult) {
+
+	fmt.Fprintf(w, "URL: %s\n", urlstring)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprint(tw, "   ")
+	for _, a := range results {
+		fmt.Fprintf(tw, "\t%s", a.Agent)
+	}
+	fmt.Fprintln(tw)
+	for _, row := range mergeRows {
+		fmt.Fprintf(tw, "   %s", row.name)
+		for _, a := range results {
+			v := row.value(a)
+			if v == "" {
+				v = "-"
+			}
+			fmt.Fprintf(tw, "\t%s", v)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	if diffs := remoteDifferences(results); len(diffs) > 0 {
+		fmt.Fprintln(w, "## Differences:")
+		for _, d := range diffs {
+			fmt.Fprintf(w, "   %s\n", d)
+		}
+	} else {
+		fmt.Fprintln(w, "## Differences: none")
+	}
+}
+
+//
+// merge - the merge command: combine JSON results collected on different
+// machines or at different times, and compare them URL by URL. Returns
+// the exit status.
+//
+func merge(args []string) int {
+
+	flags := flag.NewFlagSet("merge", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s merge <result.json> [<result.json> ...]
+
+    Combine JSON results from -record, -ndjson or agents, collected on
+    different machines or at different times, and print a comparison
+    matrix for each URL: time, status, protocol, address connected to,
+    latency, TLS version and certificate, and body size, followed by
+    the ways the results differ.
+`, progname)
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	byURL := make(map[string][]*AgentResult)
+	var urls []string
+	for _, file := range flags.Args() {
+		results, err := readMergeFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return ExitUsage
+		}
+		seen := make(map[string]int)
+		for _, a := range results {
+			u := a.Result.URL
+			if seen[u]++; seen[u] > 1 {
+				a.Agent = fmt.Sprintf("%s#%d", a.Agent, seen[u])
+			}
+			if byURL[u] == nil {
+				urls = append(urls, u)
+			}
+			byURL[u] = append(byURL[u], a)
+		}
+	}
+
+	for i, u := range urls {
+		if i > 0 {
+			fmt.Println()
+		}
+		printMergeMatrix(os.Stdout, u, byURL[u])
+	}
+	return ExitOK
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+//
+// Name fragments found in the CA certificates of common TLS inspection
+// products (corporate proxies, antivirus "web shields", and debugging
+// proxies). Matched case-insensitively against the subject and issuer
+// of every certificate in the presented chain.
+//
+var knownInterceptors = []string{
+	"Zscaler",
+	"Netskope",
+	"Forcepoint",
+	"Websense",
+	"Blue Coat",
+	"BlueCoat",
+	"Symantec Web Security",
+	"Fortinet",
+	"FortiGate",
+	"Palo Alto Networks",
+	"Cisco Umbrella",
+	"Cisco Secure Web",
+	"Sophos",
+	"McAfee Web Gateway",
+	"Skyhigh",
+	"Check Point",
+	"Barracuda",
+	"WatchGuard",
+	"SonicWall",
+	"Untangle",
+	"Menlo Security",
+	"iboss",
+	"Lightspeed",
+	"Securly",
+	"GoGuardian",
+	"Kaspersky",
+	"ESET SSL Filter",
+	"Avast",
+	"AVG Technologies",
+	"Bitdefender",
+	"Norton",
+	"DO_NOT_TRUST_Fiddler",
+	"PortSwigger",
+	"mitmproxy",
+	"Charles Proxy",
+}
+
+// OID of the embedded SCT list certificate extension (RFC 6962)
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+//
+// MitmFinding - a single interception indicator
+//
+type MitmFinding struct {
+	strong  bool
+	message string
+}
+
+//
+// matchInterceptor - return the known interception product matching the
+// certificate's subject or issuer, if any.
+//
+func matchInterceptor(cert *x509.Certificate) string {
+
+	names := strings.ToLower(cert.Subject.String() + " " + cert.Issuer.String())
+	for _, product := range knownInterceptors {
+		if strings.Contains(names, strings.ToLower(product)) {
+			return product
+		}
+	}
+	return ""
+}
+
+//
+// hasEmbeddedSCTs - does the certificate carry an embedded SCT list?
+//
+func hasEmbeddedSCTs(cert *x509.Certificate) bool {
+
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			return true
+		}
+	}
+	return false
+}
+
+//
+// detectMitm - apply interception heuristics to the TLS connection.
+// Publicly trusted server certificates are required to be logged in CT
+// and to carry revocation information; interception CAs typically mint
+// leaf certificates on the fly without either.
+//
+func detectMitm(cs *tls.ConnectionState) []MitmFinding {
+
+	var findings []MitmFinding
+
+	if len(cs.PeerCertificates) == 0 {
+		return findings
+	}
+	leaf := cs.PeerCertificates[0]
+
+	chain := cs.PeerCertificates
+	if len(cs.VerifiedChains) > 0 {
+		chain = cs.VerifiedChains[0]
+	}
+	for _, cert := range chain {
+		if product := matchInterceptor(cert); product != "" {
+			findings = append(findings, MitmFinding{true,
+				fmt.Sprintf("certificate chain contains known interception product %q: %v",
+					product, cert.Subject)})
+			break
+		}
+	}
+
+	if !hasEmbeddedSCTs(leaf) && len(cs.SignedCertificateTimestamps) == 0 {
+		findings = append(findings, MitmFinding{false,
+			"leaf certificate has no Certificate Transparency SCTs (embedded or in TLS)"})
+	}
+
+	if len(leaf.OCSPServer) == 0 && len(leaf.CRLDistributionPoints) == 0 {
+		findings = append(findings, MitmFinding{false,
+			"leaf certificate has no OCSP or CRL revocation information"})
+	}
+
+	if age := time.Since(leaf.NotBefore); age >= 0 && age < 24*time.Hour {
+		findings = append(findings, MitmFinding{false,
+			fmt.Sprintf("leaf certificate was issued very recently (%s ago)",
+				fmtDuration(age.Round(time.Minute)))})
+	}
+
+	if len(cs.VerifiedChains) > 0 {
+		root := chain[len(chain)-1]
+		if time.Since(root.NotBefore) < 2*365*24*time.Hour {
+			findings = append(findings, MitmFinding{false,
+				fmt.Sprintf("trust anchor is unusually young (created %v): %v",
+					formatTime(root.NotBefore), root.Subject)})
+		}
+	}
+
+	return findings
+}
+
+//
+// printMitmDetection - print interception findings and a verdict
+//
+func printMitmDetection(w io.Writer, cs *tls.ConnectionState) {
+
+	var strong, weak int
+
+	fmt.Fprintln(w, "## TLS Interception Check:")
+	for _, finding := range detectMitm(cs) {
+		level := "INFO"
+		if finding.strong {
+			strong++
+			level = "WARN"
+		} else {
+			weak++
+		}
+		fmt.Fprintf(w, "   [%s] %s\n", level, finding.message)
+	}
+
+	switch {
+	case strong > 0 || weak >= 3:
+		fmt.Fprintln(w, "   WARNING: connection appears to be intercepted by a middlebox")
+	case weak > 0:
+		fmt.Fprintln(w, "   Verdict: possible interception, or a privately issued certificate")
+	default:
+		fmt.Fprintln(w, "   Verdict: no signs of interception")
+	}
+}
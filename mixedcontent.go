@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+//
+// subresourceTags - elements whose URLs a browser loads as part of the
+// page, rather than navigating to, and whether browsers block them when
+// they are mixed content (active content), rather than upgrading them
+// to https or loading them with a warning
+//
+var subresourceTags = map[string]bool{
+	"script": true, "link": true, "iframe": true, "frame": true,
+	"embed": true, "object": true,
+	"img": false, "audio": false, "video": false, "source": false,
+	"track": false, "input": false,
+}
+
+//
+// isSubresource - is the link one a browser loads with the page? Of
+// link elements, only those for stylesheets, icons, preloads and
+// manifests are.
+//
+func isSubresource(link HTMLLink) bool {
+
+	if _, ok := subresourceTags[link.Tag]; !ok {
+		return false
+	}
+	if link.Tag != "link" {
+		return true
+	}
+	for _, rel := range []string{"stylesheet", "icon", "preload", "modulepreload", "manifest"} {
+		if hasToken(link.Rel, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+//
+// MixedRef - an http:// subresource of an https page
+//
+type MixedRef struct {
+	Tag     string // Element referring to it
+	URL     string // Resolved URL
+	Blocked bool   // Active content, which browsers block
+}
+
+//
+// mixedContent - the subresources of an https page that would be
+// loaded over http. Links are resolved against base.
+//
+func mixedContent(page, base *url.URL, links []HTMLLink) []MixedRef {
+
+	if page.Scheme != "https" {
+		return nil
+	}
+	var refs []MixedRef
+	for _, link := range links {
+		if !isSubresource(link) {
+			continue
+		}
+		u, err := url.Parse(resolveLink(base, link.URL))
+		if err != nil || u.Scheme != "http" {
+			continue
+		}
+		refs = append(refs, MixedRef{Tag: link.Tag, URL: u.String(), Blocked: subresourceTags[link.Tag]})
+	}
+	return refs
+}
+
+//
+// printMixedContent - for an HTML page fetched over https, report the
+// subresources it refers to over http, with counts by element. Nothing
+// is printed for other responses, or if there are none.
+//
+func printMixedContent(w io.Writer, response *http.Response, body []byte) {
+
+	mediatype, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if response.Request == nil || mediatype != "text/html" {
+		return
+	}
+	page := response.Request.URL
+	if page.Scheme != "https" {
+		return
+	}
+	summary := parseHTMLSummary(body)
+	refs := mixedContent(page, pageBase(page, summary), summary.Links)
+	if len(refs) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "## Mixed Content: %d http:// subresources\n", len(refs))
+	counts := make(map[string]int)
+	for _, ref := range refs {
+		counts[ref.Tag]++
+		kind := "passive, loaded with a warning or upgraded"
+		if ref.Blocked {
+			kind = "active, blocked by browsers"
+		}
+		fmt.Fprintf(w, "   %-7s %s (%s)\n", ref.Tag+":", ref.URL, kind)
+	}
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	fmt.Fprint(w, "   Counts:")
+	for _, tag := range tags {
+		fmt.Fprintf(w, " %s %d", tag, counts[tag])
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Default interval between monitor probes
+var defaultInterval = 30 * time.Second
+
+// Number of recent probes the latency trend is computed over
+const trendWindow = 10
+
+//
+// MonitorStats - availability and latency of one monitored URL
+//
+type MonitorStats struct {
+	mu        sync.Mutex // For -dashboard, which reads them as they change
+	url       string
+	probes    int
+	down      int
+	latencies LatencyHistogram
+	recent    [trendWindow]time.Duration
+	nrecent   int
+	last      *probe.ProbeResult // Latest probe
+	expiry    time.Time          // Expiry of the certificate, if https
+	trend     []time.Duration    // Last sparkWidth response times, -1 if down
+	downsince time.Time          // Start of the current outage, if down
+}
+
+//
+// record - add a probe result, returning whether the URL was up: the
+// request succeeded with a status below 400.
+//
+func (m *MonitorStats) record(result *probe.ProbeResult) bool {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.probes++
+	m.last = result
+	if len(m.trend) == sparkWidth {
+		m.trend = m.trend[1:]
+	}
+	if result.Err != nil || result.Response.StatusCode >= 400 {
+		m.down++
+		m.trend = append(m.trend, -1)
+		return false
+	}
+	m.trend = append(m.trend, result.ResponseTime)
+	if tls := result.Response.TLS; tls != nil && len(tls.PeerCertificates) > 0 {
+		m.expiry = tls.PeerCertificates[0].NotAfter
+	}
+	m.latencies.Record(result.ResponseTime)
+	m.recent[m.nrecent%trendWindow] = result.ResponseTime
+	m.nrecent++
+	return true
+}
+
+//
+// availability - percentage of probes for which the URL was up
+//
+func (m *MonitorStats) availability() float64 {
+
+	if m.probes == 0 {
+		return 0
+	}
+	return 100 * float64(m.probes-m.down) / float64(m.probes)
+}
+
+//
+// recentMean - mean response time of the last trendWindow successful
+// probes
+//
+func (m *MonitorStats) recentMean() time.Duration {
+
+	n := m.nrecent
+	if n > trendWindow {
+		n = trendWindow
+	}
+	if n == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range m.recent[:n] {
+		total += d
+	}
+	return total / time.Duration(n)
+}
+
+//
+// print - print the monitoring summary for the URL
+//
+func (m *MonitorStats) print(w io.Writer) {
+
+	fmt.Fprintf(w, "\n## Monitor Summary: %s\n", m.url)
+	fmt.Fprintf(w, "   Probes: %d, up %d, down %d (availability %.2f%%)\n",
+		m.probes, m.probes-m.down, m.down, m.availability())
+	if m.latencies.Count() == 0 {
+		return
+	}
+	fmt.Fprintf(w, "   Response time: min %s, mean %s, max %s\n",
+		fmtDuration(m.latencies.Min()), fmtDuration(m.latencies.Mean()),
+		fmtDuration(m.latencies.Max()))
+	fmt.Fprintf(w, "   Response time: p50 %s, p90 %s, p99 %s\n",
+		fmtDuration(m.latencies.Quantile(0.5)), fmtDuration(m.latencies.Quantile(0.9)),
+		fmtDuration(m.latencies.Quantile(0.99)))
+	if m.latencies.Count() > trendWindow {
+		recent, mean := m.recentMean(), m.latencies.Mean()
+		fmt.Fprintf(w, "   Trend: last %d mean %s vs overall %s (%+.1f%%)\n",
+			trendWindow, fmtDuration(recent), fmtDuration(mean),
+			100*(float64(recent)-float64(mean))/float64(mean))
+	}
+}
+
+//
+// monitorLine - the one line report of a monitor probe
+//
+func monitorLine(t time.Time, request *http.Request, result *probe.ProbeResult, up bool, dns string) string {
+
+	urlstring := request.URL.String()
+	if options.format != nil {
+		line, err := formatResult(request, "", result)
+		if err != nil {
+			return fmt.Sprintf("%s error: -format: %v", urlstring, err)
+		}
+		return strings.TrimSuffix(line, "\n")
+	}
+	if options.ndjson {
+		return ndjsonLine(request, "", result)
+	}
+
+	state := "UP"
+	if !up {
+		state = "DOWN"
+	}
+	if dns != "" {
+		dns = " dns " + dns
+	}
+	if result.Err != nil {
+		return paint(fmt.Sprintf("%s %s %s error: %v%s", formatMachineTime(t), state, urlstring,
+			result.Err, dns), SevError)
+	}
+	severity := SevOK
+	if !up {
+		severity = SevWarning
+	}
+	return paint(fmt.Sprintf("%s %s %s %d %s %d bytes%s", formatMachineTime(t), state, urlstring,
+		result.Response.StatusCode, fmtDuration(result.ResponseTime), result.BodySize, dns), severity)
+}
+
+//
+// monitorProbe - probe a URL once, on a new connection so that each
+// probe measures connection setup, and print the result line
+//
+func monitorProbe(prober *probe.Prober, urlstring string, stats *MonitorStats) {
+
+	request := getRequest(prober, urlstring)
+	t := time.Now()
+	dns := ""
+	if dnsCache != nil && options.proxy == nil {
+		start := time.Now()
+		if _, lookup, err := dnsCache.Lookup(request.URL.Hostname()); err == nil {
+			dns = "miss " + fmtDuration(time.Since(start))
+			if lookup.Hit {
+				dns = "hit"
+			}
+		}
+	} else if options.nodnscache && options.proxy == nil {
+		dns = "uncached"
+	}
+	result := readResponse(prober.NewSession(""), request)
+	if result.Err != nil && runContext.Err() != nil {
+		// Cancelled by the interrupt that ends the monitor, not down
+		return
+	}
+	up := stats.record(result)
+	switch {
+	case result.Err != nil:
+		setExitStatus(classifyError(result.Err))
+	case !up:
+		setExitStatus(ExitHTTPError)
+	}
+
+	notify(stats, t, request, result, up)
+	if options.dashboard {
+		return
+	}
+	line := monitorLine(t, request, result, up, dns)
+	outputLock.Lock()
+	fmt.Fprintln(os.Stdout, line)
+	outputLock.Unlock()
+}
+
+//
+// monitor - probe each URL every interval until interrupted (which
+// also cancels the probes in progress) or out of -max-total-time, then
+// print a summary of availability and latency. With -dashboard, the
+// results are shown on a screen redrawn every second, not as lines.
+//
+func monitor(prober *probe.Prober, urls []string) {
+
+	stats := make([]*MonitorStats, len(urls))
+	for i, urlstring := range urls {
+		stats[i] = &MonitorStats{url: urlstring}
+	}
+
+	ticker := time.NewTicker(options.interval)
+	defer ticker.Stop()
+	next := time.Now().Add(options.interval)
+
+	var refresh <-chan time.Time
+	if options.dashboard {
+		redraw := time.NewTicker(time.Second)
+		defer redraw.Stop()
+		refresh = redraw.C
+		fmt.Print(termAltScreen)
+		drawDashboard(os.Stdout, stats, next)
+	}
+
+	for {
+		var wg sync.WaitGroup
+		for i, urlstring := range urls {
+			wg.Add(1)
+			go func(urlstring string, stats *MonitorStats) {
+				defer wg.Done()
+				monitorProbe(prober, urlstring, stats)
+			}(urlstring, stats[i])
+		}
+		wg.Wait()
+		if runContext.Err() != nil {
+			monitorSummary(stats)
+			return
+		}
+		if options.dashboard {
+			drawDashboard(os.Stdout, stats, next)
+		}
+
+		for waiting := true; waiting; {
+			select {
+			case t := <-ticker.C:
+				next = t.Add(options.interval)
+				waiting = false
+			case <-refresh:
+				drawDashboard(os.Stdout, stats, next)
+			case <-runContext.Done():
+				monitorSummary(stats)
+				return
+			}
+		}
+	}
+}
+
+//
+// monitorSummary - leave the -dashboard screen, and print the summaries
+//
+func monitorSummary(stats []*MonitorStats) {
+
+	if options.dashboard {
+		fmt.Print(termMainScreen)
+	}
+	if !options.ndjson {
+		for _, s := range stats {
+			s.print(diagOut)
+		}
+		dnsCache.print(diagOut)
+	}
+}
+
+//
+// checkMonitorOptions - sanity check -monitor and the options that it
+// can't be combined with
+//
+func checkMonitorOptions() error {
+
+	_, text := options.formatter.(textFormatter)
+	switch {
+	case options.dashboard && !options.monitor:
+		return fmt.Errorf("-dashboard requires -monitor")
+	case (options.onfailexec != "" || options.onfailhook != "") && !options.monitor:
+		return fmt.Errorf("-on-failure-exec and -on-failure-webhook require -monitor")
+	case !options.monitor:
+		return nil
+	case options.interval <= 0:
+		return fmt.Errorf("-interval must be positive")
+	case options.queryall:
+		return fmt.Errorf("-monitor cannot be used with -queryall, -4 or -6")
+	case outputToFile():
+		return fmt.Errorf("-monitor cannot be used with -o or -O")
+	case options.dashboard && (!text || options.format != nil):
+		return fmt.Errorf("-dashboard cannot be used with -format, -brief, -ndjson or -output")
+	case options.dashboard && !isTerminal(os.Stdout):
+		return fmt.Errorf("-dashboard needs a terminal on standard output")
+	case options.onfailexec != "" && strings.TrimSpace(options.onfailexec) == "":
+		return fmt.Errorf("-on-failure-exec: empty command")
+	}
+	if options.onfailhook != "" {
+		u, err := url.Parse(options.onfailhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("-on-failure-webhook must be an http or https URL")
+		}
+	}
+	switch options.formatter.(type) {
+	case *jsonFormatter, *nagiosFormatter, *harFormatter:
+		return fmt.Errorf("-monitor prints a line per probe: -output must be text, ndjson or brief")
+	}
+	return nil
+}
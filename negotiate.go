@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// acceptPresets - the -accept shortcuts, and the Accept values -negotiate
+// tries, in order
+//
+var acceptPresets = []struct {
+	name  string
+	value string
+}{
+	{"json", "application/json"},
+	{"xml", "application/xml, text/xml;q=0.9"},
+	{"html", "text/html, application/xhtml+xml;q=0.9"},
+	{"text", "text/plain"},
+	{"any", "*/*"},
+}
+
+//
+// acceptValue - the Accept header value for an -accept argument: a
+// preset's, or the argument itself
+//
+func acceptValue(s string) string {
+
+	for _, preset := range acceptPresets {
+		if strings.EqualFold(s, preset.name) {
+			return preset.value
+		}
+	}
+	return s
+}
+
+//
+// varyIncludes - does the Vary header name the request header (or *)?
+//
+func varyIncludes(header http.Header, name string) bool {
+	return listContains(header.Values("Vary"), name, true, true)
+}
+
+//
+// checkNegotiation - send the request with each preset Accept value,
+// and with none, and report the representation returned for each,
+// warning if they differ without Vary: Accept, which lets caches serve
+// one in place of another
+//
+func checkNegotiation(w io.Writer, session *probe.Session, request *http.Request) {
+
+	fmt.Fprintln(w, "## Content Negotiation:")
+	type outcome struct {
+		accept string
+		result *probe.ProbeResult
+	}
+	var outcomes []outcome
+	for _, preset := range append(acceptPresets, struct{ name, value string }{"none", ""}) {
+		req := request.Clone(request.Context())
+		if request.GetBody != nil {
+			req.Body, _ = request.GetBody()
+		}
+		if preset.value == "" {
+			req.Header.Del("Accept")
+		} else {
+			req.Header.Set("Accept", preset.value)
+		}
+		result := readResponse(session, req)
+		outcomes = append(outcomes, outcome{preset.value, result})
+
+		label := preset.value
+		if label == "" {
+			label = "(no Accept)"
+		}
+		fmt.Fprintf(w, "   Accept: %s\n", label)
+		if result.Err != nil {
+			fmt.Fprintf(w, "      ERROR: %v\n", result.Err)
+			continue
+		}
+		response := result.Response
+		contenttype := response.Header.Get("Content-Type")
+		if contenttype == "" {
+			contenttype = "none"
+		}
+		fmt.Fprintf(w, "      %d, Content-Type: %s, %d bytes\n", response.StatusCode, contenttype, result.BodySize)
+		if vary := response.Header.Values("Vary"); len(vary) > 0 {
+			fmt.Fprintf(w, "      Vary: %s\n", strings.Join(vary, ", "))
+		}
+		if location := response.Header.Get("Content-Location"); location != "" {
+			fmt.Fprintf(w, "      Content-Location: %s\n", location)
+		}
+	}
+
+	types := make(map[string]bool)
+	bodies := make(map[[sha256.Size]byte]bool)
+	novary := false
+	for _, o := range outcomes {
+		if o.result.Err != nil || o.result.Response.StatusCode >= 300 {
+			continue
+		}
+		mediatype, _, _ := mime.ParseMediaType(o.result.Response.Header.Get("Content-Type"))
+		types[mediatype] = true
+		bodies[sha256.Sum256(o.result.Body)] = true
+		novary = novary || !varyIncludes(o.result.Response.Header, "Accept")
+	}
+	switch {
+	case len(types) > 1 && novary:
+		fmt.Fprintf(w, "   WARNING: %d representations, but not all responses have Vary: Accept,\n", len(types))
+		fmt.Fprintln(w, "            so caches may serve one in place of another")
+	case len(types) > 1:
+		fmt.Fprintf(w, "   Result: %d representations, with Vary: Accept\n", len(types))
+	case len(bodies) > 1 && novary:
+		fmt.Fprintln(w, "   WARNING: the bodies differ by Accept, but not all responses have Vary: Accept")
+	case len(types) == 1:
+		fmt.Fprintln(w, "   Result: same representation for every Accept value (no negotiation)")
+	}
+}
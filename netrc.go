@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//
+// NetrcEntry - the credentials for a machine from a netrc file. The
+// entry for "default", if any, has an empty machine.
+//
+type NetrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+//
+// defaultNetrcFile - the netrc file read by -netrc: $NETRC if set,
+// else ~/.netrc
+//
+func defaultNetrcFile() string {
+
+	if name := os.Getenv("NETRC"); name != "" {
+		return name
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".netrc"
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+//
+// parseNetrc - read the machine and default entries of a netrc file.
+// Macro definitions (macdef) are skipped, and account is ignored.
+//
+func parseNetrc(filename string) ([]NetrcEntry, error) {
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []NetrcEntry
+	var entry *NetrcEntry
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			if strings.HasPrefix(fields[j], "#") {
+				break
+			}
+			value := func() (string, error) {
+				if j+1 >= len(fields) {
+					return "", fmt.Errorf("%s:%d: missing value for %s",
+						filename, i+1, fields[j])
+				}
+				j++
+				return fields[j], nil
+			}
+			switch fields[j] {
+			case "machine":
+				machine, err := value()
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, NetrcEntry{machine: machine})
+				entry = &entries[len(entries)-1]
+			case "default":
+				entries = append(entries, NetrcEntry{})
+				entry = &entries[len(entries)-1]
+			case "login", "password", "account":
+				v, err := value()
+				if err != nil {
+					return nil, err
+				}
+				if entry == nil {
+					return nil, fmt.Errorf("%s:%d: %s outside a machine entry",
+						filename, i+1, fields[j-1])
+				}
+				switch fields[j-1] {
+				case "login":
+					entry.login = v
+				case "password":
+					entry.password = v
+				}
+			case "macdef":
+				// The macro body runs to the next empty line.
+				for i++; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				}
+				j = len(fields)
+			default:
+				return nil, fmt.Errorf("%s:%d: unknown token %q",
+					filename, i+1, fields[j])
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+//
+// netrcLookup - the credentials for hostname: those of its machine
+// entry, else of the default entry. The first matching entry is used.
+//
+func netrcLookup(entries []NetrcEntry, hostname string) (*NetrcEntry, bool) {
+
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	var fallback *NetrcEntry
+	for i := range entries {
+		e := &entries[i]
+		if e.machine == "" {
+			if fallback == nil {
+				fallback = e
+			}
+			continue
+		}
+		if strings.TrimSuffix(strings.ToLower(e.machine), ".") == hostname {
+			return e, true
+		}
+	}
+	return fallback, fallback != nil
+}
+
+//
+// applyNetrc - send basic authentication credentials from the netrc
+// file for the request's host, unless other credentials were given
+//
+func applyNetrc(request *http.Request) {
+
+	if options.netrc == nil || options.username != "" || options.bearer != "" || options.aws != nil {
+		return
+	}
+	if entry, ok := netrcLookup(options.netrc, request.URL.Hostname()); ok && entry.login != "" {
+		request.SetBasicAuth(entry.login, entry.password)
+	}
+}
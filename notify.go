@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Monitor notification events
+const (
+	EventFailure  = "failure"
+	EventRecovery = "recovery"
+)
+
+//
+// MonitorEvent - what -on-failure-exec and -on-failure-webhook are given
+// when a monitored URL goes down or comes back up
+//
+type MonitorEvent struct {
+	Event     string      `json:"event"` // failure or recovery
+	URL       string      `json:"url"`
+	Time      string      `json:"time"`
+	DownSince string      `json:"down_since,omitempty"`
+	Downtime  float64     `json:"downtime_s,omitempty"` // On recovery
+	Result    *ResultJSON `json:"result"`
+}
+
+//
+// transition - the notification event for the probe just recorded, ""
+// if there is none: a failure when the URL goes down, or is down at the
+// first probe, and a recovery when it comes back up
+//
+func (m *MonitorStats) transition(up bool, t time.Time) (event string, downsince time.Time) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case !up && m.downsince.IsZero():
+		m.downsince = t
+		return EventFailure, t
+	case up && !m.downsince.IsZero():
+		downsince, m.downsince = m.downsince, time.Time{}
+		return EventRecovery, downsince
+	}
+	return "", time.Time{}
+}
+
+//
+// runHook - run the -on-failure-exec command, with the event on stdin,
+// and its name and URL in the environment as GOHTTP_EVENT and GOHTTP_URL
+//
+func runHook(command string, event *MonitorEvent, input []byte) error {
+
+	args := strings.Fields(command)
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "GOHTTP_EVENT="+event.Event, "GOHTTP_URL="+event.URL)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	switch {
+	case ctx.Err() != nil:
+		return fmt.Errorf("timed out after %v", checkTimeout)
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+//
+// postWebhook - POST the event to the -on-failure-webhook URL, which
+// must answer with a 2xx status
+//
+func postWebhook(hookurl string, input []byte) error {
+
+	client := &http.Client{Timeout: checkTimeout}
+	response, err := client.Post(hookurl, "application/json", bytes.NewReader(input))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("%s answered %s", hookurl, response.Status)
+	}
+	return nil
+}
+
+//
+// notify - if the probe took the URL down or brought it back up, run
+// the -on-failure-exec command and POST to the -on-failure-webhook URL,
+// reporting any errors on the diagnostic output. They are run in turn,
+// before the next probe of the URL.
+//
+func notify(stats *MonitorStats, t time.Time, request *http.Request, result *probe.ProbeResult, up bool) {
+
+	if options.onfailexec == "" && options.onfailhook == "" {
+		return
+	}
+	name, downsince := stats.transition(up, t)
+	if name == "" {
+		return
+	}
+	event := &MonitorEvent{
+		Event:     name,
+		URL:       request.URL.String(),
+		Time:      formatMachineTime(t),
+		DownSince: formatMachineTime(downsince),
+		Result:    newResultJSON(request, "", result),
+	}
+	if name == EventRecovery {
+		event.Downtime = t.Sub(downsince).Seconds()
+	}
+	event.Result.Body, event.Result.BodyBase64 = "", nil
+	input, err := json.Marshal(event)
+	if err != nil {
+		fmt.Fprintf(diagOut, "ERROR: notification: %v\n", err)
+		return
+	}
+
+	if options.onfailexec != "" {
+		if err := runHook(options.onfailexec, event, input); err != nil {
+			outputLock.Lock()
+			fmt.Fprintf(diagOut, "ERROR: -on-failure-exec: %v\n", err)
+			outputLock.Unlock()
+		}
+	}
+	if options.onfailhook != "" {
+		if err := postWebhook(options.onfailhook, input); err != nil {
+			outputLock.Lock()
+			fmt.Fprintf(diagOut, "ERROR: -on-failure-webhook: %v\n", err)
+			outputLock.Unlock()
+		}
+	}
+}
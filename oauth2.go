@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Tokens are refreshed this long before they expire
+var oauth2ExpiryMargin = 10 * time.Second
+
+//
+// OAuth2Client - obtains access tokens with the OAuth2 client
+// credentials grant (RFC 6749 section 4.4), and caches them until they
+// expire
+//
+type OAuth2Client struct {
+	tokenURL string
+	id       string
+	secret   string
+	scopes   []string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time     // Zero if the token doesn't expire
+	elapsed time.Duration // Time taken to obtain the token
+	fetched int           // Tokens obtained
+}
+
+//
+// oauth2Response - token endpoint response, successful or error
+//
+type oauth2Response struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+//
+// parseScopes - split a comma or space separated list of scopes
+//
+func parseScopes(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+//
+// tokenProber - a prober for the token endpoint, with our TLS, proxy
+// and timeout settings, but none of the probe request's headers or
+// credentials
+//
+func tokenProber() (*probe.Prober, error) {
+
+	return probe.NewProber(probe.ProbeOptions{
+		Timeout:    options.timeout,
+		UserAgent:  options.useragent,
+		RootCAs:    options.rootcas,
+		ClientCert: options.clientcert,
+		ClientKey:  options.clientkey,
+		NoVerify:   options.noverify,
+		Proxy:      options.proxy,
+		Resolver:   dnsResolver(),
+	})
+}
+
+//
+// fetch - request a new token from the token endpoint, authenticating
+// with HTTP basic authentication
+//
+func (c *OAuth2Client) fetch() (string, time.Time, error) {
+
+	prober, err := tokenProber()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+	request, err := http.NewRequest(http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	request.Header.Set("User-Agent", options.useragent)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(c.id), url.QueryEscape(c.secret))
+
+	result := prober.NewSession("").Do(request)
+	if result.Err != nil {
+		return "", time.Time{}, result.Err
+	}
+	var tr oauth2Response
+	jsonerr := json.Unmarshal(result.Body, &tr)
+	switch {
+	case tr.Error != "" && tr.ErrorDescription != "":
+		return "", time.Time{}, fmt.Errorf("token endpoint: %d %s: %s",
+			result.Response.StatusCode, tr.Error, tr.ErrorDescription)
+	case tr.Error != "":
+		return "", time.Time{}, fmt.Errorf("token endpoint: %d %s",
+			result.Response.StatusCode, tr.Error)
+	case result.Response.StatusCode != http.StatusOK:
+		return "", time.Time{}, fmt.Errorf("token endpoint: %s", result.Response.Status)
+	case jsonerr != nil:
+		return "", time.Time{}, fmt.Errorf("token endpoint: %v", jsonerr)
+	case tr.AccessToken == "":
+		return "", time.Time{}, fmt.Errorf("token endpoint: no access_token in response")
+	case tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer"):
+		return "", time.Time{}, fmt.Errorf("token endpoint: unsupported token_type %q", tr.TokenType)
+	}
+	var expires time.Time
+	if tr.ExpiresIn > 0 {
+		expires = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return tr.AccessToken, expires, nil
+}
+
+//
+// Token - the current access token, obtaining a new one if there is
+// none yet or it is about to expire
+//
+func (c *OAuth2Client) Token() (string, error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && (c.expires.IsZero() || time.Until(c.expires) > oauth2ExpiryMargin) {
+		return c.token, nil
+	}
+	start := time.Now()
+	token, expires, err := c.fetch()
+	if err != nil {
+		return "", err
+	}
+	c.token, c.expires, c.elapsed = token, expires, time.Since(start)
+	c.fetched++
+	return c.token, nil
+}
+
+//
+// obtained - has a token been obtained?
+//
+func (c *OAuth2Client) obtained() bool {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fetched > 0
+}
+
+//
+// String - a description of the current token for reports
+//
+func (c *OAuth2Client) String() string {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := fmt.Sprintf("token from %s in %s", c.tokenURL, fmtDuration(c.elapsed))
+	if !c.expires.IsZero() {
+		s += fmt.Sprintf(", expires in %s", fmtTTL(time.Until(c.expires)))
+	}
+	return s
+}
+
+//
+// applyOAuth2 - send the OAuth2 access token as the request's bearer
+// token. If a token can't be obtained, the program exits unless a
+// token was obtained earlier, as in -monitor, when the request is made
+// without one.
+//
+func applyOAuth2(request *http.Request) {
+
+	if options.oauth2 == nil {
+		return
+	}
+	token, err := options.oauth2.Token()
+	if err != nil {
+		if !options.oauth2.obtained() {
+			fatal(classifyError(err), fmt.Errorf("OAuth2: %v", err))
+		}
+		fmt.Fprintf(os.Stderr, "ERROR: OAuth2: %v\n", err)
+		return
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// loadArchive - read the probe results recorded with -record in dir,
+// keyed by URL
+//
+func loadArchive(dir string) (map[string]*ResultJSON, error) {
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	archive := make(map[string]*ResultJSON)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		r := new(ResultJSON)
+		if err := json.Unmarshal(data, r); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if r.Status != 0 {
+			archive[r.URL] = r
+		}
+	}
+	if len(archive) == 0 {
+		return nil, fmt.Errorf("no recorded responses in %s", dir)
+	}
+	return archive, nil
+}
+
+//
+// offlineRoots - the roots recorded chains are verified against: the
+// -cacert files or -roots, else the system roots
+//
+func offlineRoots() (*x509.CertPool, error) {
+
+	if options.rootcas == nil {
+		return x509.SystemCertPool()
+	}
+	return options.rootcas, nil
+}
+
+//
+// offlineTLS - reconstruct the TLS connection state of a recording,
+// verifying the recorded chain as of the time it was recorded
+//
+func offlineTLS(t *TLSJSON, hostname string, when time.Time, roots *x509.CertPool) (*tls.ConnectionState, error) {
+
+	cs := &tls.ConnectionState{
+		NegotiatedProtocol: t.ALPN,
+		ServerName:         t.SNI,
+		DidResume:          t.Resumed,
+		HandshakeComplete:  true,
+	}
+	for version, name := range probe.TLSversion {
+		if name == t.Version {
+			cs.Version = version
+		}
+	}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == t.CipherSuite {
+			cs.CipherSuite = suite.ID
+		}
+	}
+	if len(t.Chain) == 0 {
+		return nil, fmt.Errorf("no certificates recorded (recorded by an older version?)")
+	}
+	for _, der := range t.Chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		cs.PeerCertificates = append(cs.PeerCertificates, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       strings.TrimSuffix(hostname, "."),
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   when,
+	})
+	if err == nil {
+		cs.VerifiedChains = chains
+	}
+	return cs, err
+}
+
+//
+// offlineResult - reconstruct the request and probe result of a
+// recording
+//
+func offlineResult(r *ResultJSON, roots *x509.CertPool) (*http.Request, *probe.ProbeResult, error) {
+
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	request, err := http.NewRequest(method, r.URL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	when, _ := time.Parse(time.RFC3339Nano, r.Time)
+
+	response := &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         r.Proto,
+		Header:        http.Header(r.Headers),
+		ContentLength: -1,
+		Request:       request,
+	}
+	response.ProtoMajor, response.ProtoMinor, _ = http.ParseHTTPVersion(r.Proto)
+	if response.Header == nil {
+		response.Header = make(http.Header)
+	}
+	if n, err := strconv.ParseInt(response.Header.Get("Content-Length"), 10, 64); err == nil {
+		response.ContentLength = n
+	}
+	if response.Proto == "HTTP/2.0" {
+		response.ProtoMajor, response.ProtoMinor = 2, 0
+	}
+	var tlserr error
+	if r.TLS != nil {
+		response.TLS, tlserr = offlineTLS(r.TLS, request.URL.Hostname(), when, roots)
+	}
+
+	body := []byte(r.Body)
+	if r.BodyBase64 != nil {
+		body = r.BodyBase64
+	}
+	result := &probe.ProbeResult{
+		Response:     response,
+		Body:         body,
+		Start:        when,
+		HeaderTime:   time.Duration(r.HeaderTime * float64(time.Millisecond)),
+		TransferTime: time.Duration(r.TransferTime * float64(time.Millisecond)),
+		ResponseTime: time.Duration(r.ResponseTime * float64(time.Millisecond)),
+		EncodedSize:  r.EncodedSize,
+		BodySize:     r.BodySize,
+		WireBytes:    r.WireBytes,
+		Truncated:    r.Truncated,
+	}
+	if r.Digests != nil {
+		result.Digests = make(map[string][]byte)
+		for name, sum := range r.Digests {
+			result.Digests[name], _ = hex.DecodeString(sum)
+		}
+	}
+	return request, result, tlserr
+}
+
+//
+// offlineReport - print the report of one recording
+//
+func offlineReport(w *Report, r *ResultJSON, roots *x509.CertPool) {
+
+	request, result, tlserr := offlineResult(r, roots)
+	if request == nil {
+		fmt.Fprintf(w, "ERROR: %s: %v\n", r.URL, tlserr)
+		setExitStatus(ExitOther)
+		return
+	}
+
+	if !options.bodyonly {
+		fmt.Fprintf(w, "URL: %s\nHostname: %s\n", r.URL, request.URL.Hostname())
+		fmt.Fprintf(w, "Recorded: %s\n", formatTime(result.Start))
+		if r.Address != "" {
+			fmt.Fprintf(w, "Address: %s (recorded)\n", r.Address)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "## ResponseTime: %s\n", fmtDuration(result.ResponseTime))
+		if result.Response.TLS == nil && r.TLS != nil {
+			fmt.Fprintf(w, "## TLS Connection Info: %v\n", tlserr)
+		} else {
+			printTLSinfo(w, result.Response)
+			if tlserr != nil {
+				fmt.Fprintf(w, "   Certificate verification (at recording time): %v\n", tlserr)
+				setExitStatus(ExitCertError)
+			} else if result.Response.TLS != nil {
+				fmt.Fprintln(w, "   Certificate verification (at recording time): OK")
+			}
+		}
+		printStatus(w, result.Response)
+		printHeaders(w, result.Response.Header)
+		printSetCookies(w, result.Response)
+		printTransferInfo(w, result)
+		if options.hash {
+			printDigests(w, result)
+		}
+		if options.domaincheck {
+			printDomainAnalysis(w, result.Response)
+		}
+		if options.auditcookies {
+			printCookieAudit(w, result.Response)
+		}
+	}
+
+	if options.failhttp && result.Response.StatusCode >= 400 {
+		setExitStatus(ExitHTTPError)
+	}
+	if options.assertions.Active() {
+		printAssertions(w, &options.assertions, result)
+	}
+	options.formatter.Result(w, &ProbeOutput{Request: request, Address: r.Address, Result: result})
+}
+
+//
+// offline - print reports from the recordings in the -offline
+// directory, without any network access: for the given URLs, or every
+// recording if none were given. Returns the exit status.
+//
+func offline(urls []string) int {
+
+	archive, err := loadArchive(options.offline)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -offline: %v\n", err)
+		return ExitUsage
+	}
+	roots, err := offlineRoots()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -offline: %v\n", err)
+		return ExitUsage
+	}
+
+	if len(urls) == 0 {
+		for urlstring := range archive {
+			urls = append(urls, urlstring)
+		}
+		sort.Strings(urls)
+	}
+	for i, urlstring := range urls {
+		r, ok := archive[urlstring]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "ERROR: %s: not recorded in %s\n", urlstring, options.offline)
+			setExitStatus(ExitOther)
+			continue
+		}
+		if i > 0 && !options.bodyonly {
+			fmt.Println()
+		}
+		report := NewReport(urlstring)
+		offlineReport(report, r, roots)
+		report.Flush()
+	}
+	options.formatter.Close()
+	return exitStatus
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"strings"
+	"text/template"
 	"time"
+
+	"github.com/shuque/gohttp/probe"
+	"golang.org/x/net/http/httpguts"
 )
 
 // Defaults
@@ -13,6 +23,10 @@ var (
 	defaultTimeout = 5 * time.Second
 	defaultRetries = 0
 	defaultAgent   = "gohttp"
+
+	// How long -expect100 waits for 100 Continue before sending the
+	// body anyway, as curl does
+	defaultContinueWait = time.Second
 )
 
 type arrayFlag []string
@@ -30,25 +44,152 @@ func (i *arrayFlag) Set(value string) error {
 // OptionsStruct
 //
 type Options struct {
-	ipv6only      bool          // Use only IPv6
-	ipv4only      bool          // Use only IPv4
-	timeout       time.Duration // connection timeout in seconds
-	retries       int           // number of retries
-	printbody     bool          // Print body
-	bodyonly      bool          // Print body only
-	queryall      bool          // Query all server addresses
-	sni           string        // Server Name Indication option
-	headers       arrayFlag     // Custom request headers
-	cacert        string        // File containing PEM format CA certs
-	clientcert    string        // File containing PEM format client cert
-	clientkey     string        // File containing PEM format client key
-	username      string        // Username
-	password      string        // Password
-	showcert      bool          // Show peer certificate
-	showcertchain bool          // Show peer certificate chain
-	noredirect    bool          // Don't follow redirects
-	noverify      bool          // Don't verify server certificate
-	useragent     string        // User-Agent string
+	ipv6only      bool               // Use only IPv6
+	ipv4only      bool               // Use only IPv4
+	timeout       time.Duration      // connection timeout in seconds
+	retries       int                // number of retries
+	retryon       map[string]bool    // Conditions to retry on
+	retrydelay    time.Duration      // Backoff before the first retry
+	retrymax      time.Duration      // Longest wait between retries
+	printbody     bool               // Print body
+	bodyonly      bool               // Print body only
+	queryall      bool               // Query all server addresses
+	port          string             // Port to connect to, if not the URL's
+	sni           string             // Server Name Indication option
+	nosni         bool               // Send no Server Name Indication
+	verifyname    string             // Name to verify the certificate for
+	comparesni    bool               // Compare certificates with and without SNI
+	hostheader    string             // Host header to send, if not the URL's
+	hostsni       bool               // Also send the -hostheader name as SNI
+	tlsprint      string             // Browser whose ClientHello to mimic
+	resolve       ResolveMap         // -resolve addresses, by host:port
+	connectto     []ConnectToRule    // -connect-to rules, in order
+	headers       http.Header        // Custom request headers
+	cacert        arrayFlag          // Files containing PEM format CA certs
+	cabundles     []CABundle         // The -cacert files' certificates
+	roots         string             // Trusted roots: system, mozilla or a file
+	rootcas       *x509.CertPool     // Pool of -roots or -cacert, nil for the system's
+	clientcert    string             // File containing PEM format client cert
+	clientkey     string             // File containing PEM format client key
+	username      string             // Username
+	password      string             // Password
+	digestauth    bool               // Use digest instead of basic auth
+	bearer        string             // Bearer token
+	showcert      bool               // Show peer certificate
+	showcertchain bool               // Show peer certificate chain
+	noredirect    bool               // Don't follow redirects
+	noverify      bool               // Don't verify server certificate
+	useragent     string             // User-Agent string
+	byterange     *probe.ByteRange   // Byte range to request
+	checkranges   bool               // Probe range request support
+	adminaddr     string             // Localhost address for pprof/expvar
+	encodings     []string           // Content-Encodings to request
+	outfile       string             // File to save body in
+	remotename    bool               // Save body in file named by server
+	streamoutput  bool               // Stream output lines tagged by probe
+	limitrate     int64              // Maximum body read rate, bytes/sec
+	outputpolicy  string             // Output stream policy: mixed or split
+	color         bool               // Color report lines by severity
+	quiet         int                // Least severe report lines printed
+	maxbody       int64              // Maximum body bytes to read
+	headfallback  bool               // Use HEAD if body is not needed
+	trustsource   bool               // Report trust anchors consulted
+	pretty        bool               // Re-indent JSON bodies
+	jsonpath      []pathStep         // JSON path to extract from body
+	detectmitm    bool               // Check for TLS interception
+	assertions    Assertions         // Checks of the response
+	domaincheck   bool               // Public suffix aware domain analysis
+	hash          bool               // Hash body and verify digests
+	utc           bool               // Print times in UTC
+	timefmt       string             // Layout for printed times
+	failhttp      bool               // Exit non-zero on HTTP status >= 400
+	stableoutput  bool               // Mask nondeterministic output
+	proxy         *url.URL           // Proxy to send requests through
+	checks        arrayFlag          // Custom check commands
+	parallel      int                // Number of URLs to probe at once
+	script        *Script            // Script run against each response
+	monitor       bool               // Probe repeatedly until interrupted
+	interval      time.Duration      // Interval between monitor probes
+	dashboard     bool               // Show monitor results on a dashboard
+	onfailexec    string             // Command to run when a URL goes down or up
+	onfailhook    string             // URL to POST to when a URL goes down or up
+	interactive   bool               // Prompt for requests to send
+	ndjson        bool               // Print results as NDJSON
+	recorddir     string             // Directory to record responses in
+	format        *template.Template // Template to print results with
+	formatter     Formatter          // Prints the results of probes
+	rawrequest    []byte             // Literal HTTP/1.1 request to send
+	verbose       bool               // Dump request and response heads
+	absoluteform  bool               // Send request-target in absolute-form
+	nonormalize   bool               // Send URLs as given, not normalized
+	urlsfile      string             // -urls file, read as it is probed
+	params        []string           // Query parameters to add to URLs
+	hostforms     bool               // Try request-target and Host variants
+	trailingdot   bool               // Compare hostname with trailing dot
+	h2info        bool               // Report HTTP/2 connection details
+	dnsttl        time.Duration      // TTL for DNS cache entries, if >= 0
+	dnscache      bool               // Cache DNS answers, even for one URL
+	nodnscache    bool               // Don't cache DNS answers
+	halfclose     bool               // Test server's half-close handling
+	stallread     *StallSpec         // Test server's handling of a stall
+	netrc         []NetrcEntry       // Credentials from netrc file
+	scenario      string             // Client behavior scenario to run
+	aws           *probe.AWSSigner   // AWS SigV4 request signer
+	awssource     string             // Where the AWS credentials came from
+	statusonly    bool               // Print only UP, WARN or DOWN
+	statusbudget  time.Duration      // Time limit for -probe-status-only
+	oauth2        *OAuth2Client      // OAuth2 client credentials grant
+	cookies       []*http.Cookie     // Cookies to send
+	cookiejar     *CookieJar         // Cookies set by responses
+	cookiejarfile string             // File to load and save cookies in
+	auditcookies  bool               // Audit Set-Cookie attributes
+	conntimeout   time.Duration      // TCP connection timeout, if set
+	deadline      time.Duration      // Time limit for probing all URLs
+	maxtotal      time.Duration      // Time limit for the whole run
+	offline       string             // Report from recordings in directory
+	tlstimeout    time.Duration      // TLS handshake timeout, if set
+	hdrtimeout    time.Duration      // Response header timeout, if set
+	maxtime       time.Duration      // Total request timeout, if set
+	remote        []string           // Agents to probe through
+	remotetoken   string             // File containing the agent token
+	happyeyeballs bool               // Race connections as RFC 8305 does
+	attemptdelay  time.Duration      // Happy Eyeballs attempt delay
+	dnsdetail     bool               // Report explicit DNS queries
+	dnsonly       bool               // Stop after resolving hostnames
+	dnssec        bool               // Report DNSSEC validation status
+	connectonly   bool               // Stop after the TCP connection
+	tlsonly       bool               // Stop after the TLS handshake
+	tcpinfo       bool               // Report TCP_INFO statistics
+	method        string             // Request method
+	cors          *CORSSpec          // CORS preflight to check, if any
+	websocket     bool               // Perform a WebSocket handshake
+	wsprotocols   []string           // WebSocket subprotocols to offer
+	wsextensions  []string           // WebSocket extensions to offer
+	wsping        bool               // Ping over the WebSocket
+	sse           bool               // Read a Server-Sent Events stream
+	sseduration   time.Duration      // How long to read the stream for
+	ssecount      int                // How many events to read, if > 0
+	data          []byte             // Request body to send, if any
+	expect100     bool               // Send Expect: 100-continue
+	trailers      http.Header        // Request trailers to send
+	followaltsvc  bool               // Repeat requests to Alt-Svc services
+	sniff         bool               // Check Content-Type against content
+	extract       bool               // Print HTML title and links
+	extractabs    bool               // Only absolute links
+	crawl         bool               // Crawl the links of the pages
+	crawldepth    int                // How many links deep to crawl
+	samehost      bool               // Only crawl the starting hosts
+	hstspreload   bool               // Check HSTS preload eligibility
+	httpsredirect bool               // Check the HTTP to HTTPS redirect
+	fingerprint   bool               // Identify the CDN or hosting provider
+	clockskew     bool               // Estimate the server's clock skew
+	skewthreshold time.Duration      // Skew to warn about
+	reusetest     bool               // Test connection reuse
+	datafile      string             // File the -data body was read from
+	ascurl        bool               // Print curl commands instead
+	requestfile   []FileRequest      // Requests from a .http file
+	uploadfile    string             // File to stream as the request body
+	negotiate     bool               // Probe content negotiation
 }
 
 // Options
@@ -57,101 +198,1819 @@ var options = Options{
 	ipv4only:      false,
 	timeout:       defaultTimeout,
 	retries:       defaultRetries,
+	retryon:       nil,
+	retrydelay:    defaultRetryDelay,
+	retrymax:      defaultRetryMaxDelay,
 	printbody:     false,
 	bodyonly:      false,
 	queryall:      false,
+	port:          "",
 	sni:           "",
 	headers:       nil,
-	cacert:        "",
+	cacert:        nil,
+	cabundles:     nil,
+	roots:         "system",
+	rootcas:       nil,
 	clientcert:    "",
 	clientkey:     "",
 	username:      "",
 	password:      "",
+	digestauth:    false,
+	bearer:        "",
 	showcert:      false,
 	showcertchain: false,
 	noverify:      false,
-	useragent:     defaultAgent}
+	useragent:     defaultAgent,
+	byterange:     nil,
+	checkranges:   false,
+	adminaddr:     "",
+	encodings:     nil,
+	outfile:       "",
+	remotename:    false,
+	streamoutput:  false,
+	limitrate:     0,
+	outputpolicy:  OutputMixed,
+	color:         false,
+	quiet:         SevInfo,
+	maxbody:       0,
+	headfallback:  false,
+	trustsource:   false,
+	pretty:        false,
+	jsonpath:      nil,
+	detectmitm:    false,
+	assertions:    Assertions{},
+	domaincheck:   false,
+	hash:          false,
+	utc:           false,
+	timefmt:       "",
+	failhttp:      false,
+	stableoutput:  false,
+	proxy:         nil,
+	checks:        nil,
+	parallel:      1,
+	script:        nil,
+	monitor:       false,
+	interval:      defaultInterval,
+	dashboard:     false,
+	onfailexec:    "",
+	onfailhook:    "",
+	interactive:   false,
+	ndjson:        false,
+	recorddir:     "",
+	format:        nil,
+	formatter:     textFormatter{},
+	rawrequest:    nil,
+	verbose:       false,
+	absoluteform:  false,
+	nonormalize:   false,
+	urlsfile:      "",
+	params:        nil,
+	hostforms:     false,
+	trailingdot:   false,
+	h2info:        false,
+	dnsttl:        -1,
+	dnscache:      false,
+	nodnscache:    false,
+	halfclose:     false,
+	stallread:     nil,
+	netrc:         nil,
+	scenario:      "",
+	aws:           nil,
+	awssource:     "",
+	statusonly:    false,
+	statusbudget:  defaultStatusBudget,
+	oauth2:        nil,
+	cookies:       nil,
+	cookiejar:     nil,
+	cookiejarfile: "",
+	auditcookies:  false,
+	conntimeout:   0,
+	deadline:      0,
+	maxtotal:      0,
+	offline:       "",
+	tlstimeout:    0,
+	hdrtimeout:    0,
+	maxtime:       0,
+	remote:        nil,
+	remotetoken:   "",
+	happyeyeballs: false,
+	attemptdelay:  probe.DefaultAttemptDelay,
+	dnsdetail:     false,
+	dnsonly:       false,
+	dnssec:        false,
+	connectonly:   false,
+	tlsonly:       false,
+	tcpinfo:       false,
+	method:        http.MethodGet,
+	cors:          nil,
+	websocket:     false,
+	wsprotocols:   nil,
+	wsextensions:  nil,
+	wsping:        false,
+	sse:           false,
+	sseduration:   0,
+	ssecount:      0,
+	data:          nil,
+	expect100:     false,
+	trailers:      nil,
+	followaltsvc:  false,
+	sniff:         false,
+	extract:       false,
+	extractabs:    false,
+	crawl:         false,
+	crawldepth:    1,
+	samehost:      false,
+	hstspreload:   false,
+	httpsredirect: false,
+	fingerprint:   false,
+	clockskew:     false,
+	skewthreshold: defaultSkewThreshold,
+	reusetest:     false,
+	datafile:      "",
+	ascurl:        false,
+	requestfile:   nil,
+	uploadfile:    "",
+	negotiate:     false,
+	nosni:         false,
+	verifyname:    "",
+	comparesni:    false,
+	hostheader:    "",
+	hostsni:       false,
+	tlsprint:      "",
+	resolve:       nil,
+	connectto:     nil}
+
+//
+// probeOptions - the probe library options corresponding to ours
+//
+func probeOptions() probe.ProbeOptions {
+
+	return probe.ProbeOptions{
+		Timeout:        options.timeout,
+		ConnectTimeout: options.conntimeout,
+		TLSTimeout:     options.tlstimeout,
+		HeaderTimeout:  options.hdrtimeout,
+		MaxTime:        options.maxtime,
+		SNI:            options.sni,
+		HostHeader:     options.hostheader,
+		NoSNI:          options.nosni,
+		VerifyName:     options.verifyname,
+		Headers:        options.headers,
+		UserAgent:      options.useragent,
+		RootCAs:        options.rootcas,
+		ClientCert:     options.clientcert,
+		ClientKey:      options.clientkey,
+		Username:       options.username,
+		Password:       options.password,
+		DigestAuth:     options.digestauth,
+		Bearer:         options.bearer,
+		NoRedirect:     options.noredirect,
+		NoVerify:       options.noverify,
+		Range:          options.byterange,
+		Encodings:      options.encodings,
+		MaxBody:        options.maxbody,
+		LimitRate:      options.limitrate,
+		Hash:           options.hash,
+		Proxy:          options.proxy,
+		HTTP1Only:      options.absoluteform,
+		TLSFingerprint: options.tlsprint,
+		Resolver:       dnsResolver(),
+		ConnectTo:      connectToFunc(),
+		AWS:            options.aws,
+		Jar:            options.cookiejar,
+		Body:           options.data,
+		ExpectContinue: continueWait(),
+		Trailers:       options.trailers,
+		BodyFile:       options.uploadfile,
+		UploadProgress: uploadProgress(),
+		Context:        runContext,
+	}
+}
+
+//
+// uploadProgress - the function to report -upload-file progress with,
+// if any
+//
+func uploadProgress() probe.ProgressFunc {
+
+	if options.uploadfile == "" || options.bodyonly {
+		return nil
+	}
+	return printUploadProgress
+}
+
+//
+// continueWait - how long to wait for 100 Continue before sending the
+// request body, 0 if Expect: 100-continue isn't to be sent
+//
+func continueWait() time.Duration {
+
+	if !options.expect100 && options.uploadfile == "" {
+		return 0
+	}
+	return defaultContinueWait
+}
 
 //
 // doFlags - process command line options
 //
-func doFlags() string {
+func doFlags() []string {
 
 	var authbasic string
+	var authdigest string
+	var tokenfile string
+	var stallread string
+	var usenetrc bool
+	var netrcfile string
+	var awsscope string
+	var oauth2 OAuth2Client
+	var oauth2scopes string
+	var cookies arrayFlag
+	var headers arrayFlag
+	var byterange string
+	var encodings string
+	var limitrate string
+	var maxbody string
+	var jsonpath string
+	var format string
+	var brief bool
+	var outputformat string
+	var color, quiet string
+	var rawrequest string
+	var expectstatus string
+	var expectheaders arrayFlag
+	var expectbody string
+	var timefmt string
+	var proxy string
+	var urlsfile string
+	var requestfile string
+	var script string
+	var remote string
+	var head, optionsreq bool
+	var data string
+	var trailers arrayFlag
+	var forms, formfiles arrayFlag
+	var params arrayFlag
+	var paramfile string
+	var retryon string
+	var resolves, connecttos arrayFlag
+	var accept, uapreset string
+	var corsorigin, corsmethod, corsheaders string
+	var wsprotocols, wsextensions string
 
 	help := flag.Bool("h", false, "print help string")
 	flag.BoolVar(&options.ipv6only, "6", false, "use IPv6 only")
 	flag.BoolVar(&options.ipv4only, "4", false, "use IPv4 only")
 	flag.DurationVar(&options.timeout, "t", defaultTimeout, "query timeout")
+	flag.IntVar(&options.retries, "r", defaultRetries, "maximum # of retries")
+	flag.StringVar(&retryon, "retry-on", defaultRetryOn, "Conditions to retry on: 5xx,connect,timeout,429,...")
+	flag.DurationVar(&options.retrydelay, "retry-delay", defaultRetryDelay, "Backoff before the first retry")
+	flag.DurationVar(&options.retrymax, "retry-max-delay", defaultRetryMaxDelay, "Longest wait between retries")
 	flag.BoolVar(&options.printbody, "printbody", false, "print body")
 	flag.BoolVar(&options.bodyonly, "bodyonly", false, "print body")
 	flag.BoolVar(&options.queryall, "queryall", false, "query all server addresses")
 	flag.BoolVar(&options.noredirect, "noredirect", false, "don't follow redirects")
+	flag.BoolVar(&head, "head", false, "Send a HEAD request")
+	flag.BoolVar(&optionsreq, "options", false, "Send an OPTIONS request")
+	flag.StringVar(&data, "data", "", "POST this request body (or @file)")
+	flag.StringVar(&accept, "accept", "", "Accept header: json, xml, html, text, any, or a value")
+	flag.BoolVar(&options.negotiate, "negotiate", false, "Probe content negotiation with several Accept values")
+	flag.StringVar(&options.uploadfile, "upload-file", "", "Stream file as the request body (PUT)")
+	flag.Var(&forms, "form", "Send a URL-encoded or multipart form field key=value")
+	flag.Var(&formfiles, "form-file", "Send a multipart form file field=@path")
+	flag.Var(&params, "param", "Add a query parameter key=value to the URL")
+	flag.StringVar(&paramfile, "param-file", "", "File of query parameters to add, key=value per line")
+	flag.BoolVar(&options.expect100, "expect100", false, "Send Expect: 100-continue with the body")
+	flag.Var(&trailers, "trailer", "Request trailer to send: key: value")
+	flag.BoolVar(&options.followaltsvc, "follow-altsvc", false, "Repeat the request to Alt-Svc services")
+	flag.StringVar(&corsorigin, "cors-origin", "", "Check a CORS preflight from this origin")
+	flag.StringVar(&corsmethod, "cors-method", http.MethodGet, "Method of the CORS request")
+	flag.StringVar(&corsheaders, "cors-headers", "", "Headers of the CORS request: X-A,X-B")
+	flag.BoolVar(&options.websocket, "websocket", false, "Perform a WebSocket handshake")
+	flag.StringVar(&wsprotocols, "ws-protocols", "", "WebSocket subprotocols to offer: a,b")
+	flag.StringVar(&wsextensions, "ws-extensions", "permessage-deflate", "WebSocket extensions to offer")
+	flag.BoolVar(&options.wsping, "ws-ping", false, "Ping over the WebSocket")
+	flag.BoolVar(&options.sse, "sse", false, "Read a Server-Sent Events stream")
+	flag.DurationVar(&options.sseduration, "sse-duration", 0, "How long to read the event stream for")
+	flag.IntVar(&options.ssecount, "sse-count", 0, "How many events to read")
+	flag.StringVar(&options.port, "port", "", "Port to connect to, if not the URL's")
 	flag.StringVar(&options.sni, "sni", "", "Server Name Indication")
-	flag.Var(&options.headers, "header", "Custom request header: key: value")
-	flag.StringVar(&options.cacert, "cacert", "", "CA cert file")
+	flag.BoolVar(&options.nosni, "no-sni", false, "Send no Server Name Indication")
+	flag.StringVar(&options.tlsprint, "tls-fingerprint", "", "Send a browser's TLS ClientHello: chrome, firefox or ios")
+	flag.Var(&resolves, "resolve", "Use these addresses for host:port: host:port:addr[,addr...]")
+	flag.Var(&connecttos, "connect-to", "Connect to tohost:toport instead of host:port: host:port:tohost:toport")
+	flag.StringVar(&options.verifyname, "verify-name", "", "Verify the certificate for this name")
+	flag.StringVar(&options.verifyname, "cert-name", "", "Verify the certificate for this name")
+	flag.BoolVar(&options.comparesni, "compare-sni", false, "Compare certificates with and without SNI")
+	flag.StringVar(&options.hostheader, "hostheader", "", "Host header to send, if not the URL's")
+	flag.BoolVar(&options.hostsni, "hostheader-sni", false, "Also send the -hostheader name as SNI")
+	flag.Var(&headers, "header", "Custom request header: key: value")
+	flag.Var(&cookies, "cookie", "Cookie to send: name=value")
+	flag.StringVar(&options.cookiejarfile, "cookie-jar", "", "File to load and save cookies in")
+	flag.BoolVar(&options.auditcookies, "audit-cookies", false, "Audit Set-Cookie security attributes")
+	flag.Var(&options.cacert, "cacert", "CA cert file (can be repeated)")
+	flag.StringVar(&options.roots, "roots", "system", "Trusted roots: system, mozilla or a PEM file")
 	flag.StringVar(&options.clientcert, "clientcert", "", "Client cert file")
 	flag.StringVar(&options.clientkey, "clientkey", "", "Client key file")
 	flag.StringVar(&authbasic, "authbasic", "", "Basic auth username:password")
+	flag.StringVar(&authdigest, "authdigest", "", "Digest auth username:password")
+	flag.StringVar(&options.bearer, "authbearer", "", "Bearer token")
+	flag.StringVar(&tokenfile, "token-file", "", "File containing bearer token")
+	flag.BoolVar(&usenetrc, "netrc", false, "Read credentials from ~/.netrc")
+	flag.StringVar(&netrcfile, "netrc-file", "", "Read credentials from netrc file")
+	flag.StringVar(&awsscope, "aws-sigv4", "", "Sign requests with AWS SigV4: region/service")
+	flag.StringVar(&oauth2.tokenURL, "oauth2-token-url", "", "OAuth2 token endpoint")
+	flag.StringVar(&oauth2.id, "oauth2-client-id", "", "OAuth2 client ID")
+	flag.StringVar(&oauth2.secret, "oauth2-client-secret", "", "OAuth2 client secret")
+	flag.StringVar(&oauth2scopes, "oauth2-scopes", "", "OAuth2 scopes to request")
 	flag.BoolVar(&options.showcert, "showcert", false, "Show peer certificate")
 	flag.BoolVar(&options.showcertchain, "showcertchain", false, "Show peer certificate chain")
 	flag.BoolVar(&options.noverify, "noverify", false, "Don't verify server certificate")
+	flag.BoolVar(&options.failhttp, "fail", false, "Exit non-zero on HTTP status >= 400")
+	flag.StringVar(&byterange, "range", "", "Byte range to request: start-end")
+	flag.BoolVar(&options.checkranges, "check-ranges", false, "Probe range request support")
+	flag.StringVar(&options.adminaddr, "admin", "", "Localhost address for pprof/expvar endpoints")
+	flag.StringVar(&encodings, "encodings", "", "Content-Encodings to request: gzip,br,zstd")
+	flag.StringVar(&options.outfile, "o", "", "Save body to file")
+	flag.BoolVar(&options.remotename, "O", false, "Save body to file named by server")
+	flag.BoolVar(&options.streamoutput, "stream", false, "Stream output lines tagged by probe")
+	flag.StringVar(&limitrate, "limit-rate", "", "Maximum body read rate in bytes/sec")
+	flag.StringVar(&options.outputpolicy, "output-policy", OutputMixed, "Output stream policy: mixed or split")
+	flag.StringVar(&color, "color", "auto", "Color output: never, auto or always")
+	flag.StringVar(&quiet, "quiet", "", "Print only report lines of this severity: warning or error")
+	flag.StringVar(&maxbody, "max-body", "", "Maximum body bytes to read")
+	flag.BoolVar(&options.headfallback, "head-fallback", false, "Use HEAD if body is not needed")
+	flag.BoolVar(&options.trustsource, "print-trust-source", false, "Report trust anchors consulted")
+	flag.BoolVar(&options.pretty, "pretty", false, "Re-indent JSON bodies")
+	flag.StringVar(&jsonpath, "jsonpath", "", "Extract fields from JSON body")
+	flag.BoolVar(&options.detectmitm, "detect-mitm", false, "Check for TLS interception")
+	flag.StringVar(&expectstatus, "expect-status", "", "Expected status codes: 200,3xx")
+	flag.Var(&expectheaders, "expect-header", "Expected header: key:regex")
+	flag.StringVar(&expectbody, "expect-body", "", "Regex the body must match")
+	flag.BoolVar(&options.domaincheck, "domain-check", false, "Public suffix aware domain analysis")
+	flag.BoolVar(&options.sniff, "sniff", false, "Check Content-Type against the content")
+	flag.BoolVar(&options.extract, "extract", false, "Print HTML title and links")
+	flag.BoolVar(&options.extractabs, "extract-absolute", false, "Only print absolute links")
+	flag.BoolVar(&options.crawl, "crawl", false, "Crawl the links of the pages")
+	flag.IntVar(&options.crawldepth, "depth", 1, "How many links deep to crawl")
+	flag.BoolVar(&options.samehost, "same-host", false, "Only crawl the starting hosts")
+	flag.BoolVar(&options.hstspreload, "hsts-preload", false, "Check HSTS preload eligibility")
+	flag.BoolVar(&options.httpsredirect, "check-https-redirect", false, "Check the HTTP to HTTPS redirect")
+	flag.BoolVar(&options.fingerprint, "fingerprint", false, "Identify the CDN or hosting provider")
+	flag.BoolVar(&options.clockskew, "clock-skew", false, "Estimate the server's clock skew")
+	flag.DurationVar(&options.skewthreshold, "skew-threshold", defaultSkewThreshold, "Clock skew to warn about")
+	flag.BoolVar(&options.reusetest, "reuse-test", false, "Test keep-alive connection reuse")
+	flag.BoolVar(&options.ascurl, "as-curl", false, "Print equivalent curl commands instead")
+	flag.BoolVar(&options.hash, "hash", false, "Hash body and verify digest headers")
+	flag.BoolVar(&options.utc, "utc", false, "Print times in UTC")
+	flag.StringVar(&timefmt, "timefmt", "", "Format for printed times")
+	flag.BoolVar(&options.stableoutput, "stable-output", false, "Mask nondeterministic output")
+	flag.StringVar(&options.useragent, "user-agent", defaultAgent, "User-Agent string")
+	flag.StringVar(&uapreset, "ua-preset", "", "Send a browser's or client's User-Agent and Accept headers")
+	flag.StringVar(&proxy, "proxy", "", "Proxy URL")
+	flag.String("config", "", "Config file")
+	flag.Var(&options.checks, "check", "Custom check command")
+	flag.StringVar(&urlsfile, "urls", "", "File of URLs to probe")
+	flag.StringVar(&requestfile, "request-file", "", "File of requests in .http format")
+	flag.IntVar(&options.parallel, "parallel", 1, "Number of URLs to probe at once")
+	flag.DurationVar(&options.conntimeout, "connect-timeout", 0, "TCP connection timeout")
+	flag.DurationVar(&options.tlstimeout, "tls-timeout", 0, "TLS handshake timeout")
+	flag.DurationVar(&options.hdrtimeout, "response-header-timeout", 0, "Response header timeout")
+	flag.DurationVar(&options.maxtime, "max-time", 0, "Total request timeout")
+	flag.DurationVar(&options.deadline, "deadline", 0, "Time limit for probing all URLs")
+	flag.DurationVar(&options.maxtotal, "max-total-time", 0, "Time limit for the whole run")
+	flag.StringVar(&script, "script", "", "Starlark script to run against each response")
+	flag.BoolVar(&options.monitor, "monitor", false, "Probe repeatedly until interrupted")
+	flag.DurationVar(&options.interval, "interval", defaultInterval, "Interval between monitor probes")
+	flag.BoolVar(&options.dashboard, "dashboard", false, "Show monitor results on a dashboard")
+	flag.StringVar(&options.onfailexec, "on-failure-exec", "", "Command to run when a URL goes down or up")
+	flag.StringVar(&options.onfailhook, "on-failure-webhook", "", "URL to POST to when a URL goes down or up")
+	flag.BoolVar(&options.interactive, "interactive", false, "Prompt for requests to send")
+	flag.BoolVar(&options.ndjson, "ndjson", false, "Print results as NDJSON")
+	flag.BoolVar(&options.statusonly, "probe-status-only", false, "Print only UP, WARN or DOWN")
+	flag.DurationVar(&options.statusbudget, "status-budget", defaultStatusBudget, "Time limit for -probe-status-only")
+	flag.StringVar(&options.recorddir, "record", "", "Directory to record responses in")
+	flag.StringVar(&options.offline, "offline", "", "Report from responses recorded in directory")
+	flag.StringVar(&remote, "remote", "", "Probe through these agents: agent1,agent2")
+	flag.StringVar(&options.remotetoken, "remote-token-file", "", "File containing the agent token")
+	flag.StringVar(&format, "format", "", "Template to print results with")
+	flag.BoolVar(&brief, "brief", false, "Print a one line summary of each result")
+	flag.StringVar(&outputformat, "output", "text", "Output format: text, json, ndjson, brief, nagios or har")
+	flag.StringVar(&outputformat, "output-format", "text", "Output format: text, json, ndjson, brief, nagios or har")
+	flag.StringVar(&rawrequest, "raw-request", "", "File containing literal HTTP/1.1 request")
+	flag.BoolVar(&options.verbose, "verbose", false, "Dump request and response heads")
+	flag.BoolVar(&options.verbose, "raw", false, "Dump request and response heads")
+	flag.BoolVar(&options.absoluteform, "absolute-form", false, "Send request-target in absolute-form")
+	flag.BoolVar(&options.nonormalize, "no-normalize", false, "Send URLs as given, not normalized")
+	flag.BoolVar(&options.hostforms, "host-forms", false, "Try request-target and Host header variants")
+	flag.BoolVar(&options.trailingdot, "trailing-dot", false, "Compare hostname with and without trailing dot")
+	flag.BoolVar(&options.h2info, "h2-info", false, "Report HTTP/2 connection details")
+	flag.DurationVar(&options.dnsttl, "dns-ttl-override", -1, "TTL for DNS cache entries")
+	flag.BoolVar(&options.dnscache, "dns-cache", false, "Cache DNS answers, honoring their TTLs")
+	flag.BoolVar(&options.nodnscache, "no-dns-cache", false, "Resolve hostnames for every request")
+	flag.BoolVar(&options.dnsdetail, "dns-detail", false, "Report DNS answers, CNAMEs, TTLs and latency")
+	flag.BoolVar(&options.dnsonly, "dnsonly", false, "Stop after resolving hostnames")
+	flag.BoolVar(&options.dnssec, "dnssec", false, "Report DNSSEC validation of the answers")
+	flag.BoolVar(&options.connectonly, "connect-only", false, "Stop after the TCP connection")
+	flag.BoolVar(&options.tlsonly, "tls-only", false, "Stop after the TLS handshake")
+	flag.BoolVar(&options.tcpinfo, "tcp-info", false, "Report TCP RTT, retransmits and delivery rate")
+	flag.BoolVar(&options.halfclose, "half-close", false, "Half-close after sending request")
+	flag.StringVar(&stallread, "stall-read", "", "Stop reading response: bytes:duration")
+	flag.StringVar(&options.scenario, "scenario", "", "Client behavior scenario to run")
+	flag.BoolVar(&options.happyeyeballs, "happy-eyeballs", false, "Race IPv6 and IPv4 connections (RFC 8305)")
+	flag.DurationVar(&options.attemptdelay, "attempt-delay", probe.DefaultAttemptDelay, "Happy Eyeballs connection attempt delay")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `%s, version %s
-Usage: %s [Options] <url>
+Usage: %s [Options] <url> [<url> ...]
+       %s replay-serve [-listen addr] <dir>
+       %s certsweep [-format fmt] <hosts.txt>
+       %s agent [-listen addr] [-token-file file] [-agent-cert file -agent-key file]
+       %s merge <result.json> [<result.json> ...]
+
+    A <url> may also be a bare hostname, IP address or host:port, with
+    or without a path, which is requested with https (http for port 80).
 
     Options:
 	-h                Print this help string
 	-4                Connect to IPv4 addresses only (implies 'queryall')
 	-6                Connect to IPv6 addresses only (implies 'queryall')
 	-t Ns             Query timeout value in seconds (default %v)
-	-r N              Maximum # of retries (default %d)
+	-r N              Maximum # of retries (default %d), of the conditions
+	                  -retry-on lists, with exponential backoff
+	-retry-on list    Conditions to retry on, comma separated (default
+	                  connect,timeout): dns, connect, timeout, tls, error
+	                  (any error), a status code, e.g. 429, or a class,
+	                  e.g. 5xx. Each attempt is logged on the report.
+	-retry-delay Ns   Wait before the first retry, doubled for each one
+	                  after it, with jitter (default 1s). A Retry-After
+	                  header in the response is honored instead.
+	-retry-max-delay Ns
+	                  Longest wait between retries (default 30s)
 	-printbody        Print body
 	-bodyonly         Only print body, no status, headers, etc
 	-queryall         Query all server addresses (implies 'noredirect')
 	-noredirect       Don't follow redirects
+	-head             Send a HEAD request instead of GET
+	-options          Send an OPTIONS request instead of GET, and report
+	                  the Allow header and CORS response headers
+	-data s           Send a POST request with s as the body, or the
+	                  contents of file if s is @file
+	-form key=val     Send a POST request with a form field as the body,
+	                  URL-encoded, or with -form-file, multipart/form-data
+	                  (may be repeated)
+	-form-file field=@path
+	                  Send a multipart/form-data POST request with the
+	                  file as a field (may be repeated). Its type is
+	                  guessed from the extension, or given as
+	                  field=@path;type=mediatype
+	-param key=val    Add a query parameter to the URL(s), URL-encoded, after
+	                  any the URL has (may be repeated)
+	-param-file file  Add the query parameters in file, a key=value per
+	                  line (# comments), before any -param ones
+	-accept type      Send an Accept header: json, xml, html, text or any
+	                  (*/*), or the value given; an Accept -header wins
+	-negotiate        Also send the request with each of those Accept
+	                  values, and none, and report the representation and
+	                  Vary header returned for each, and whether caches
+	                  could confuse them
+	-upload-file file Send a PUT request streaming file as the body, with
+	                  Expect: 100-continue, printing progress to stderr,
+	                  and report the upload time and throughput
+	-expect100        Send the -data body with Expect: 100-continue, and
+	                  report whether and when the server sent 100
+	                  Continue, or rejected the request before the body
+	                  was sent (it is sent anyway after 1s without an
+	                  answer)
+	-trailer key:val  Send a trailer field after the -data body, which is
+	                  then sent chunked (may be repeated)
+	-follow-altsvc    Repeat the request to each h2 or http/1.1
+	                  alternative service advertised in Alt-Svc (which is
+	                  always reported), and compare the results with the
+	                  origin's (h3 alternatives can't be followed)
+	-cors-origin url  Also send the CORS preflight a browser would for a
+	                  cross-origin request from this origin, and report
+	                  whether the response permits it (exit status 1 if
+	                  not)
+	-cors-method m    Method of the cross-origin request (default GET)
+	-cors-headers list
+	                  Request headers of the cross-origin request, e.g.
+	                  Content-Type,X-Requested-With
+	-websocket        Perform the WebSocket opening handshake instead of
+	                  a request (ws:// and wss:// URLs may be given), and
+	                  report whether the server switched protocols with
+	                  the correct Sec-WebSocket-Accept, and the
+	                  negotiated subprotocol and extensions (exit status
+	                  1 if the handshake failed)
+	-ws-protocols list
+	                  WebSocket subprotocols to offer, e.g. graphql-ws,mqtt
+	-ws-extensions list
+	                  WebSocket extensions to offer ("" for none, default
+	                  permessage-deflate)
+	-ws-ping          Also send a ping once connected, and report the
+	                  round-trip time of its pong
+	-sse              Read the response as a Server-Sent Events stream,
+	                  printing each event as it arrives, with its arrival
+	                  time and the gap since the previous one, until the
+	                  server ends the stream or a limit is reached
+	-sse-duration Ns  Stop reading the event stream after Ns (default no
+	                  limit; the -t and -max-time limits don't apply)
+	-sse-count N      Stop after N events (default no limit)
+	-port N           Connect to port N instead of the URL's port (or its
+	                  scheme's default), for every URL given; the Host
+	                  header carries it, and SNI is still the hostname
 	-sni name         Server Name Indication option
+	-no-sni           Send no Server Name Indication, to see the server's
+	                  default certificate (which is still verified for
+	                  the URL's hostname, or -verify-name)
+	-tls-fingerprint browser
+	                  Send the TLS ClientHello of a browser: chrome,
+	                  firefox or ios, so that its JA3/JA4 fingerprint is
+	                  the browser's (with uTLS), to see whether a server
+	                  or CDN answers browsers differently or blocks other
+	                  clients. Also applies to -tls-only, -raw-request
+	                  and -websocket connections; not with -proxy or
+	                  -no-sni
+	-resolve host:port:addr[,addr...]
+	                  Connect to these addresses for host and port,
+	                  instead of looking the host up, as curl --resolve
+	                  does. May be given more than once.
+	-connect-to host:port:tohost:toport
+	                  Connect to tohost:toport instead of host:port, as
+	                  curl --connect-to does; the URL, Host header, SNI
+	                  and certificate name are unchanged. An empty host
+	                  or port matches any, and an empty tohost or toport
+	                  keeps the original. The first matching rule
+	                  applies. Not with -queryall, -4 or -6.
+	-verify-name name Verify the certificate for name, instead of the SNI
+	                  name (the URL's hostname, or -sni)
+	-cert-name name   The same as -verify-name: for an IP address target,
+	                  verify the certificate for the expected hostname
+	-compare-sni      Connect again with the SNI name, with no SNI, and with
+	                  a name the server can't have, and compare the
+	                  certificates presented, to check the default
+	                  virtual host
+	-hostheader name  Send name (host or host:port) as the Host header,
+	                  while connecting to the URL's host, e.g. an origin
+	                  server's IP address behind a CDN. Redirects to other
+	                  hosts send their own Host.
+	-hostheader-sni   Also send the -hostheader name as SNI, and verify the
+	                  certificate for it
 	-header key:val   Send custom request header
-	-cacert file      PEM format CA certificates file
+	-cookie name=val  Send a cookie (may be repeated, or hold several
+	                  separated by ';'). Cookies set by responses,
+	                  including redirects, are sent on later requests
+	-cookie-jar file  Load cookies from file, in Netscape (curl) cookie
+	                  file format, and save them to it on exit
+	-audit-cookies    Audit the cookies set by the response and redirects
+	                  for missing Secure, HttpOnly or SameSite, long
+	                  lifetimes, broad Domain or Path scope, and misused
+	                  __Host- and __Secure- prefixes
+	-cacert file      PEM format CA certificates file. Can be repeated: the
+	                  certificate is accepted if any bundle verifies it,
+	                  and the result for each bundle is reported
+	-roots store      Verify against these roots: system (the default), mozilla
+	                  (the bundled Mozilla CA certificates), or a PEM file,
+	                  to check whether the chain validates elsewhere
 	-clientcert file  PEM format Client certificate file
 	-clientkey file   PEM format Client key file
 	-authbasic creds  username:password string for basic authentication
+	-authdigest creds username:password string for digest authentication
+	                  (RFC 7616: MD5, SHA-256, SHA-512-256, and -sess)
+	-authbearer token Send token as an Authorization: Bearer credential
+	-token-file file  Read the bearer token from file
+	-netrc            Read basic authentication credentials for the host
+	                  from $NETRC, or ~/.netrc
+	-netrc-file file  Read basic authentication credentials from file
+	-aws-sigv4 scope  Sign requests with AWS Signature Version 4 for the
+	                  region/service scope (e.g. us-east-1/s3), with
+	                  credentials from AWS_ACCESS_KEY_ID etc, or the
+	                  AWS_PROFILE profile of ~/.aws/credentials or config
+	-oauth2-token-url url
+	                  Obtain a bearer token from the OAuth2 token endpoint
+	                  url with the client credentials grant, and send it
+	-oauth2-client-id id
+	                  OAuth2 client ID
+	-oauth2-client-secret secret
+	                  OAuth2 client secret
+	-oauth2-scopes list
+	                  Comma or space separated scopes to request
 	-showcert         Show peer certificate
 	-showcertchain    Show peer certificate chain
 	-noverify         Don't verify server certificate
-`, progname, Version, progname, defaultTimeout, defaultRetries)
+	-fail             Exit with status 2 if the HTTP status is >= 400
+	-range start-end  Request byte range and verify 206/Content-Range
+	-check-ranges     Probe Accept-Ranges and single/multi range support
+	-reuse-test       Also send the request twice on a new connection, and
+	                  report whether the second reused it, the server's
+	                  Keep-Alive limits, and the cold and warm latencies
+	-admin addr       Serve pprof/expvar debug endpoints on localhost addr
+	-encodings list   Request Content-Encodings (e.g. gzip,br,zstd) and
+	                  report the server's choice and compression ratio
+	-o file           Stream body to file instead of memory ('-' for stdout)
+	-O                Like -o, naming file from Content-Disposition or URL
+	-stream           Print output as it is produced, each line tagged with
+	                  the probe ID, instead of one complete report per probe
+	-limit-rate N     Throttle body reads to N bytes/sec (k/m/g suffixes)
+	-output-policy p  mixed: everything to stdout (default)
+	                  split: diagnostics to stderr, only payload to stdout
+	-color when       Color the report: errors red, warnings (4xx and 5xx
+	                  statuses, expiring certificates, old TLS versions)
+	                  yellow and successes green: never, always, or auto
+	                  (the default: if it goes to a terminal, and NO_COLOR
+	                  isn't set)
+	-quiet level      Print only report lines of at least this severity,
+	                  warning or error, tagged with the probe ID
+	-max-body N       Stop reading body after N bytes (k/m/g suffixes),
+	                  and with -encodings, stop decoding it after N bytes
+	-head-fallback    Send HEAD instead of GET when the body isn't needed,
+	                  falling back to GET if the server rejects HEAD
+	-print-trust-source
+	                  Report which trust store verified the server
+	-pretty           Re-indent JSON bodies when printing them
+	-jsonpath expr    Print fields of a JSON body, e.g. '$.items[*].id'
+	-detect-mitm      Warn if the TLS connection appears to be intercepted
+	-expect-status s  Assert status is one of s, e.g. 200,204 or 2xx
+	-expect-header key:regex
+	                  Assert a header value matches regex (repeatable)
+	-expect-body re   Assert the body matches regex
+	                  (failed assertions set exit status 1)
+	-domain-check     Check cookie Domain attributes, certificate wildcards
+	                  and redirects against the public suffix list
+	-hsts-preload     Check the Strict-Transport-Security header and the
+	                  site against the HSTS preload list's requirements
+	                  (max-age of a year, includeSubDomains, preload, on
+	                  the registrable domain, HTTP redirecting to HTTPS
+	                  on the same host); ineligible sets exit status 1
+	-check-https-redirect
+	                  Also fetch the http:// URL (for an https URL, on the
+	                  default port), following redirects, and check that
+	                  the first goes straight to HTTPS on the same host,
+	                  and none go back to HTTP; failure sets exit status 1
+	-fingerprint      Identify the CDN or hosting provider in front of the
+	                  origin from the response headers (Server, Via,
+	                  X-Cache, CF-Ray, X-Amz-Cf-Id, ...) and certificate
+	                  issuer and SANs, printing the evidence
+	-clock-skew       Estimate the server's clock skew from its Date header,
+	                  allowing for the response time, and warn if it is
+	                  over -skew-threshold (default 5s)
+	-sniff            Sniff the start of the body as browsers do, and warn
+	                  if it contradicts the Content-Type, or a text type
+	                  has no charset
+	-extract          For HTML, print the title, canonical URL, meta
+	                  refresh target and the href and src URLs
+	-extract-absolute Only print links written as absolute URLs
+	-crawl            Probe the URL(s) and, breadth-first, the links and
+	                  resources of the HTML pages found, printing a line
+	                  per URL, then the broken links (4xx/5xx, errors;
+	                  exit status 2), redirect chains and mixed content
+	-depth N          How many links deep to crawl (default 1)
+	-same-host        Only crawl links on the starting URL's host
+	-hash             Print SHA-256/SHA-512 of the body, and verify Digest,
+	                  Content-Digest, Repr-Digest and Content-MD5 headers
+	-utc              Print all times in UTC
+	-timefmt fmt      Format for printed times: iso8601, rfc3339, rfc1123,
+	                  http, unix, or a Go time layout
+	-stable-output    Replace timings, dates and other nondeterministic
+	                  values with placeholders, and sort headers, so that
+	                  output can be diffed against golden files
+	-user-agent s     User-Agent string (default %s)
+	-ua-preset name   Send the User-Agent, Accept and Accept-Language
+	                  headers of chrome, firefox, safari, curl or
+	                  googlebot (-user-agent, -accept and -header win)
+	-proxy url        Send requests through proxy (http, https or socks5)
+	-config file      Read default options from file (default
+	                  %s)
+	-check cmd        Run cmd as a custom check (repeatable). It is given
+	                  the result as JSON on stdin, prints any findings on
+	                  stdout, and exits 0 for pass or 1 for fail (which
+	                  sets exit status 1)
+	-urls file        Also probe the URLs listed in file, one per line
+	                  ('-' for stdin), reading it as they are probed
+	-request-file file
+	                  Make the requests (method, URL, headers and body) in
+	                  file, in the .http / REST Client format, instead of
+	                  requests to URLs: requests separated by ### lines,
+	                  and {{name}} variables defined by @name = value
+	                  lines, or {{$processEnv NAME}}. -header values
+	                  replace the file's
+	-parallel N       Probe up to N URLs at once (default 1). A summary
+	                  is printed when several URLs are probed
+	-connect-timeout Ns
+	                  TCP connection timeout (default -t)
+	-tls-timeout Ns   TLS handshake timeout (default -t)
+	-response-header-timeout Ns
+	                  Time to wait for the response headers after sending
+	                  the request (default -t)
+	-max-time Ns      Total time for each request, including redirects
+	                  and reading the body (default -t)
+	-deadline Ns      Time limit for probing all of the URLs; probes
+	                  still running are abandoned, and URLs not yet
+	                  probed are skipped and counted in the summary
+	-max-total-time Ns
+	                  Time limit for the whole run: every URL, every
+	                  -queryall address, retry and redirect. Work still
+	                  in progress is cancelled, the rest skipped, and a
+	                  Run Budget section lists what was (exit status 8)
+	-script file      Run a Starlark script against each response. It sees
+	                  resp (status, header, body, time_ms, tls, ...) and
+	                  header, and calls check(cond, msg), warn(msg),
+	                  get(url) and fail(msg); failure sets exit status 1
+	-monitor          Probe the URL(s) every interval, printing a line per
+	                  probe, until interrupted; then print availability
+	                  and latency summaries
+	-interval Ns      Interval between -monitor probes (default %v)
+	-dashboard        With -monitor, show a screen redrawn every second, with
+	                  a line per URL: its state, status, response time,
+	                  availability, time left on its certificate, and a
+	                  sparkline of its recent response times
+	-on-failure-exec cmd
+	                  With -monitor, run cmd when a URL goes down (a failed
+	                  request or a 4xx/5xx status) and when it recovers,
+	                  with the event as JSON on stdin: event (failure or
+	                  recovery), url, time, down_since, downtime_s and the
+	                  probe result; GOHTTP_EVENT and GOHTTP_URL are set
+	-on-failure-webhook URL
+	                  With -monitor, POST the same JSON to URL
+	-interactive      Send the request, then prompt for commands that change
+	                  its method, path and header fields and send it again
+	                  on the same connection, printing the report on each
+	                  response; "help" at the prompt lists the commands
+	-ndjson           Print the result of each probe (or -monitor probe) as
+	                  a JSON object on one line of stdout, with its time,
+	                  URLs, timings, status and TLS details, but no body
+	-probe-status-only
+	                  Print only UP, WARN or DOWN for the URL(s), and exit
+	                  with 0, 1 or 2, within the -status-budget. DOWN: the
+	                  request failed or timed out, a 5xx status, or a
+	                  failed -expect-*; WARN: a 4xx status, a response
+	                  slower than half the budget, or a certificate
+	                  expiring within 7 days
+	-status-budget Ns Time limit for -probe-status-only (default %v)
+	-record dir       Save each response in dir, to be served locally by
+	                  replay-serve, or reported on with -offline
+	-offline dir      Print reports from the responses recorded in dir
+	                  with -record (for the given URLs, or all of them),
+	                  without any network access. Certificates are
+	                  verified as of the recording time; options that
+	                  need the network are ignored
+	-remote agent1,agent2
+	                  Have each of the agents (host:port or URL, running
+	                  '%s agent') probe the URL(s) at once, and print
+	                  their results side by side with how they differ.
+	                  Give agents serving TLS as https://host:port; their
+	                  certificates are verified with -roots or -cacert
+	-remote-token-file file
+	                  File containing the agents' shared token (default
+	                  $GOHTTP_AGENT_TOKEN)
+	-format tmpl      Print each result with a Go template instead of the
+	                  report, e.g. '{{.Status}} {{.TimingTotal}}'. Fields:
+	                  URL, Method, Address, LocalAddress, Time, Error,
+	                  Status, StatusText, Proto, Header, TLS (Version,
+	                  CipherSuite, ALPN, SNI, Resumed, Certs),
+	                  CertDaysLeft, TimingHeader, TimingTransfer,
+	                  TimingTotal, EncodedSize, BodySize, WireBytes,
+	                  Truncated, Digests and Body; functions ms, join, json
+	-brief            Print each result as one line: status, protocol, total
+	                  time, TLS version, days until the certificate
+	                  expires, body size and URL
+	-output f, -output-format f
+	                  Print the results in this format: text (the report,
+	                  the default), json (an array of result objects),
+	                  ndjson (as -ndjson), brief (as -brief), nagios (a
+	                  plugin status line per URL with performance data,
+	                  exiting with the worst state, or UNKNOWN (3) if
+	                  cut short by -max-total-time or an interrupt) or
+	                  har (an HTTP Archive, for HAR viewers)
+	-raw-request file Send the literal HTTP/1.1 request in file over a new
+	                  connection to the URL's server, instead of building
+	                  one; options that modify requests don't apply to it
+	-as-curl          Print a curl command line making the same request
+	                  (method, headers, auth, body, TLS and connection
+	                  options) for each URL, instead of probing it
+	-verbose, -raw    Print the request line and headers as sent, and the
+	                  response status line and headers in wire format
+	-absolute-form    Send the request-target as an absolute URI, as to a
+	                  proxy, instead of a path (implies HTTP/1.1)
+	-no-normalize     Send URLs as given: without the lower-cased hostname,
+	                  default port removal, percent-encoding normalization
+	                  and dot-segment resolution otherwise applied (and
+	                  reported); Unicode hostnames are still converted to
+	                  punycode (IDNA) for DNS, SNI and the Host header
+	-host-forms       Also try absolute-form and unusual Host headers
+	                  (explicit or mismatched port, trailing dot, IP
+	                  literal), and report how the server handles them
+	-trailing-dot     Compare the hostname with and without a trailing
+	                  dot: DNS answers, certificate names and the server's
+	                  response to each Host form (implied by a URL whose
+	                  hostname ends with a dot)
+	-h2-info          If HTTP/2 is negotiated, report the server's
+	                  SETTINGS, flow control, server push, RST_STREAM and
+	                  GOAWAY frames, seen on a second connection
+	-dns-detail       Also query the resolver directly for the A and AAAA
+	                  records, and report the answers and their TTLs, the
+	                  CNAME chain, the resolver and the query latencies
+	-dnsonly          Resolve the hostnames and stop, without connecting
+	                  (implies -dns-detail)
+	-dnssec           Also report whether the resolver authenticated the
+	                  A, AAAA and HTTPS answers with DNSSEC (the AD bit),
+	                  as DANE depends on; needs a validating resolver
+	-connect-only     Connect to the server and stop, reporting the address
+	                  and connect time, without sending a request
+	-tls-only         Stop after the TLS handshake, reporting the handshake
+	                  time, TLS details and certificates (with -showcert
+	                  etc), without sending a request
+	-tcp-info         Report the kernel's TCP statistics for the connection
+	                  after the exchange: RTT and its variance,
+	                  retransmits, delivery rate and congestion window
+	                  (Linux only)
+	-dns-ttl-override Ns
+	                  Cache DNS answers for Ns instead of the record TTL
+	                  when probing several URLs or with -monitor, which
+	                  resolve each name once per TTL and report cache
+	                  hits and misses (0 re-resolves for every request)
+	-dns-cache        Cache DNS answers, honoring their TTLs, even for a
+	                  single URL (the default with several URLs, and with
+	                  -monitor, whose lines report each lookup as a cache
+	                  hit, or a miss and its resolution time)
+	-no-dns-cache     Resolve hostnames for every request, so that each
+	                  probe's latency includes DNS resolution
+	-half-close       Also send the request on a new connection and close
+	                  its write side, and report what the server delivers
+	                  and how it ends the connection (FIN or RST)
+	-stall-read N:dur Also stop reading the response after N bytes for
+	                  dur (e.g. 16k:10s) on a new connection, and report
+	                  how the server reacts
+	-scenario name    Also run a scripted sequence of client behaviors on
+	                  new connections, and summarize how the server copes.
+	                  flaky-client: aborts after the headers and mid-body,
+	                  immediate and repeated reconnects, and resuming the
+	                  aborted download with a Range request
+	-happy-eyeballs   Also race connections to the server's addresses as
+	                  RFC 8305 does, IPv6 first, and report each attempt,
+	                  the first attempt time of each address family, and
+	                  which family won, and after how much fallback delay
+	-attempt-delay Ns Time between Happy Eyeballs connection attempts
+	                  (default 250ms)
+`+configHelp+exitCodesHelp, progname, Version, progname, progname, progname, progname, progname,
+			defaultTimeout, defaultRetries, defaultAgent, defaultConfigFile(), defaultInterval,
+			defaultStatusBudget, progname)
 	}
 
-	flag.Parse()
+	if err := loadDefaults(os.Args[1:]); err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(ExitOK)
+		}
+		os.Exit(ExitUsage)
+	}
 
 	if authbasic != "" {
-		tmp := strings.SplitN(authbasic, ":", 2)
-		options.username = tmp[0]
-		options.password = tmp[1]
+		username, password, err := parseCredentials(authbasic)
+		if err != nil {
+			fmt.Printf("ERROR: -authbasic: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.username = username
+		options.password = password
+	}
+
+	if authdigest != "" {
+		if authbasic != "" {
+			fmt.Printf("ERROR: -authbasic and -authdigest are mutually exclusive\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		username, password, err := parseCredentials(authdigest)
+		if err != nil {
+			fmt.Printf("ERROR: -authdigest: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.username = username
+		options.password = password
+		options.digestauth = true
+	}
+
+	if tokenfile != "" {
+		token, err := readToken(tokenfile)
+		if err != nil {
+			fmt.Printf("ERROR: -token-file: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.bearer = token
+	}
+
+	if usenetrc && netrcfile == "" {
+		netrcfile = defaultNetrcFile()
+	}
+	if netrcfile != "" {
+		entries, err := parseNetrc(netrcfile)
+		if err != nil {
+			fmt.Printf("ERROR: netrc: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.netrc = entries
+	}
+
+	if options.bearer != "" && options.username != "" {
+		fmt.Printf("ERROR: a bearer token cannot be used with -authbasic or -authdigest\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	if awsscope != "" {
+		if options.bearer != "" || options.username != "" {
+			fmt.Printf("ERROR: -aws-sigv4 cannot be used with other authentication\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		region, service, err := parseAWSScope(awsscope)
+		if err != nil {
+			fmt.Printf("ERROR: -aws-sigv4: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		creds, source, err := awsCredentials()
+		if err != nil {
+			fmt.Printf("ERROR: -aws-sigv4: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.aws = &probe.AWSSigner{Region: region, Service: service, Credentials: creds}
+		options.awssource = source
+	}
+	if oauth2.tokenURL != "" {
+		switch {
+		case oauth2.id == "" || oauth2.secret == "":
+			fmt.Printf("ERROR: -oauth2-token-url requires -oauth2-client-id and -oauth2-client-secret\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.bearer != "" || options.username != "" || options.aws != nil:
+			fmt.Printf("ERROR: -oauth2-token-url cannot be used with other authentication\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		if _, err := parseURL(oauth2.tokenURL); err != nil {
+			fmt.Printf("ERROR: -oauth2-token-url: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		oauth2.scopes = parseScopes(oauth2scopes)
+		options.oauth2 = &oauth2
+	} else if oauth2.id != "" || oauth2.secret != "" || oauth2scopes != "" {
+		fmt.Printf("ERROR: OAuth2 client options require -oauth2-token-url\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if !httpguts.ValidHeaderFieldValue(options.bearer) {
+		fmt.Printf("ERROR: bearer token contains illegal characters\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	for _, c := range cookies {
+		parsed, err := parseCookies(c)
+		if err != nil {
+			fmt.Printf("ERROR: -cookie: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.cookies = append(options.cookies, parsed...)
+	}
+	jar, err := loadCookieJar(options.cookiejarfile)
+	if err != nil {
+		fmt.Printf("ERROR: -cookie-jar: %s\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	options.cookiejar = jar
+
+	for _, header := range headers {
+		key, value, err := parseHeader(header)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		if options.headers == nil {
+			options.headers = make(http.Header)
+		}
+		options.headers.Add(key, value)
+	}
+	for _, trailer := range trailers {
+		key, value, err := parseHeader(trailer)
+		if err != nil {
+			fmt.Printf("ERROR: -trailer: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		if options.trailers == nil {
+			options.trailers = make(http.Header)
+		}
+		options.trailers.Add(key, value)
+	}
+
+	if corsorigin != "" {
+		u, err := url.Parse(corsorigin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			fmt.Printf("ERROR: -cors-origin must be an origin, e.g. https://app.example\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		headers, err := parseCORSHeaders(corsheaders)
+		if err != nil || !httpguts.ValidHeaderFieldName(corsmethod) {
+			fmt.Printf("ERROR: -cors-method or -cors-headers: invalid method or header name\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.cors = &CORSSpec{origin: u.Scheme + "://" + u.Host, method: corsmethod, headers: headers}
+	} else if corsheaders != "" || corsmethod != http.MethodGet {
+		fmt.Printf("ERROR: -cors-method and -cors-headers need -cors-origin\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if byterange != "" {
+		br, err := probe.ParseByteRange(byterange)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.byterange = br
+	}
+
+	if encodings != "" {
+		list, err := probe.ParseEncodings(encodings)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.encodings = list
+	}
+
+	if limitrate != "" {
+		rate, err := probe.ParseSize(limitrate)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.limitrate = rate
+	}
+
+	if maxbody != "" {
+		size, err := probe.ParseSize(maxbody)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.maxbody = size
+	}
+
+	given := 0
+	for _, set := range []bool{outputformat != "text", format != "", brief, options.ndjson} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		fmt.Printf("ERROR: only one of -output, -format, -brief and -ndjson can be given\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	switch {
+	case brief:
+		outputformat = "brief"
+	case options.ndjson:
+		outputformat = "ndjson"
+	}
+	if outputformat == "brief" {
+		format = briefFormat
+	}
+	if format != "" {
+		tmpl, err := parseFormat(format)
+		if err != nil {
+			fmt.Printf("ERROR: -format: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.format = tmpl
+		options.formatter = templateFormatter{}
+		options.bodyonly = true
+	} else if options.formatter = newFormatter(outputformat); options.formatter == nil {
+		fmt.Printf("ERROR: -output: must be text, json, ndjson, brief, nagios or har\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	} else if outputformat != "text" {
+		options.bodyonly = true
+	}
+	options.ndjson = outputformat == "ndjson"
+
+	if rawrequest != "" {
+		raw, err := readRawRequest(rawrequest)
+		if err != nil {
+			fmt.Printf("ERROR: -raw-request: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.rawrequest = raw
+	}
+
+	if stallread != "" {
+		stall, err := parseStall(stallread)
+		if err != nil {
+			fmt.Printf("ERROR: -stall-read: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.stallread = stall
+	}
+
+	if options.scenario != "" {
+		if _, ok := scenarios[options.scenario]; !ok {
+			fmt.Printf("ERROR: unknown -scenario %q (available: %s)\n",
+				options.scenario, scenarioNames())
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	if jsonpath != "" {
+		steps, err := parseJSONPath(jsonpath)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.jsonpath = steps
+	}
+
+	if expectstatus != "" {
+		list, err := parseExpectStatus(expectstatus)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.assertions.status = list
+	}
+
+	for _, expect := range expectheaders {
+		ha, err := parseExpectHeader(expect)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.assertions.headers = append(options.assertions.headers, ha)
+	}
+
+	if expectbody != "" {
+		regex, err := regexp.Compile(expectbody)
+		if err != nil {
+			fmt.Printf("ERROR: -expect-body: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.assertions.body = regex
+	}
+
+	if timefmt != "" {
+		layout, err := parseTimeFormat(timefmt)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.timefmt = layout
+	}
+
+	if script != "" {
+		s, err := loadScript(script)
+		if err != nil {
+			fmt.Printf("ERROR: -script: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.script = s
+	}
+
+	if proxy != "" {
+		u, err := parseProxy(proxy)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.proxy = u
+	}
+
+	if err := setOutputPolicy(options.outputpolicy); err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	if options.color, err = colorMode(color); err != nil {
+		fmt.Printf("ERROR: -color: %s\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	if quiet != "" {
+		level, ok := quietLevels[quiet]
+		if !ok {
+			fmt.Printf("ERROR: -quiet: must be warning or error\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.quiet = level
+	}
+
+	if err := checkOutfileOptions(); err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
 	}
 
 	if options.ipv6only && options.ipv4only {
 		fmt.Printf("ERROR: Cannot specify both -4 and -6. Choose one.\n")
 		flag.Usage()
-		os.Exit(4)
+		os.Exit(ExitUsage)
 	}
 
 	if options.ipv6only || options.ipv4only {
 		options.queryall = true
 	}
 
+	if options.queryall && options.proxy != nil {
+		fmt.Printf("ERROR: -proxy cannot be used with -queryall, -4 or -6\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
 	if options.queryall {
 		options.noredirect = true
 	}
 
-	if *help || (flag.NArg() != 1) {
-		if flag.NArg() != 0 {
-			fmt.Fprintf(os.Stderr, "ERROR: incorrect number of arguments\n")
+	if *help || (flag.NArg() == 0 && urlsfile == "" && requestfile == "" && options.offline == "") {
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	urls := flag.Args()
+	for i := range urls {
+		urls[i] = targetURL(urls[i])
+	}
+	if options.websocket {
+		for i := range urls {
+			urls[i] = webSocketURL(urls[i])
 		}
+	}
+	for _, urlstring := range urls {
+		if _, err := parseURL(urlstring); err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	// Probing the URLs one after another, the -urls file is read as
+	// they are probed, which can begin before all of it arrives on
+	// stdin. Other modes need the whole list.
+	streamed := !options.monitor && !options.interactive && !options.statusonly &&
+		!options.crawl && !options.ascurl && !options.dnsonly && remote == "" &&
+		options.offline == ""
+	if urlsfile != "" && streamed {
+		if urlsfile != "-" {
+			f, err := os.Open(urlsfile)
+			if err != nil {
+				fmt.Printf("ERROR: -urls: %s\n", err)
+				flag.Usage()
+				os.Exit(ExitUsage)
+			}
+			f.Close()
+		}
+		options.urlsfile = urlsfile
+	} else if urlsfile != "" {
+		err := readURLList(urlsfile, func(urlstring string, err error) bool {
+			if err != nil {
+				fmt.Printf("ERROR: -urls: %s\n", err)
+				flag.Usage()
+				os.Exit(ExitUsage)
+			}
+			urls = append(urls, urlstring)
+			return true
+		})
+		if err != nil {
+			fmt.Printf("ERROR: -urls: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	if paramfile != "" {
+		list, err := readParamFile(paramfile)
+		if err != nil {
+			fmt.Printf("ERROR: -param-file: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		params = append(arrayFlag(list), params...)
+	}
+	if options.port != "" {
+		if _, err := parsePort(options.port); err != nil {
+			fmt.Printf("ERROR: -port: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		if requestfile != "" {
+			fmt.Printf("ERROR: -port cannot be used with -request-file\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+	if params != nil && requestfile != "" {
+		fmt.Printf("ERROR: -param and -param-file cannot be used with -request-file\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	options.params = params
+	for i := range urls {
+		u, err := prepareURL(urls[i])
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		urls[i] = u
+	}
+
+	if requestfile != "" {
+		switch {
+		case len(urls) > 0 || options.urlsfile != "" || data != "" || head || optionsreq || options.rawrequest != nil:
+			fmt.Printf("ERROR: -request-file cannot be used with URLs, -urls, -data, -head, -options or -raw-request\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.monitor || options.crawl || options.statusonly || options.ascurl ||
+			options.websocket || remote != "" || options.offline != "":
+			fmt.Printf("ERROR: -request-file cannot be used with -monitor, -crawl, -probe-status-only, -as-curl, -websocket, -remote or -offline\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		requests, err := parseRequestFile(requestfile)
+		if err != nil {
+			fmt.Printf("ERROR: -request-file: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.requestfile = requests
+		for _, req := range requests {
+			urls = append(urls, req.URL)
+		}
+	}
+
+	if len(urls) == 0 && options.urlsfile == "" && options.offline == "" {
+		fmt.Printf("ERROR: no URLs to probe\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.conntimeout < 0 || options.tlstimeout < 0 || options.hdrtimeout < 0 ||
+		options.maxtime < 0 || options.deadline < 0 || options.maxtotal < 0 {
+		fmt.Printf("ERROR: timeouts, -deadline and -max-total-time must not be negative\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	if options.retries < 0 || options.retrydelay < 0 || options.retrymax < 0 {
+		fmt.Printf("ERROR: -r, -retry-delay and -retry-max-delay must not be negative\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	on, err := parseRetryOn(retryon)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	options.retryon = on
+
+	if options.maxtotal > 0 && (options.monitor || options.interactive) {
+		fmt.Printf("ERROR: -max-total-time cannot be used with -monitor or -interactive\n")
 		flag.Usage()
-		os.Exit(4)
+		os.Exit(ExitUsage)
+	}
+
+	if remote != "" {
+		for _, agent := range strings.Split(remote, ",") {
+			if agent = strings.TrimSpace(agent); agent != "" {
+				options.remote = append(options.remote, agent)
+			}
+		}
+		if options.monitor || options.offline != "" || outputToFile() {
+			fmt.Printf("ERROR: -remote cannot be used with -monitor, -offline, -o or -O\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	if options.dnsonly {
+		options.dnsdetail = true
+	}
+
+	if head || optionsreq {
+		switch {
+		case head && optionsreq:
+			fmt.Printf("ERROR: -head and -options cannot be used together\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case outputToFile() || options.byterange != nil || options.headfallback || options.rawrequest != nil:
+			fmt.Printf("ERROR: -head and -options cannot be used with -o, -O, -range, -head-fallback or -raw-request\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case head:
+			options.method = http.MethodHead
+		default:
+			options.method = http.MethodOptions
+		}
+	}
+
+	// An Accept field given with -header wins over -accept.
+	if accept != "" && options.headers.Get("Accept") == "" {
+		if options.headers == nil {
+			options.headers = make(http.Header)
+		}
+		options.headers.Set("Accept", acceptValue(accept))
+	}
+
+	if uapreset != "" {
+		if err := applyUAPreset(uapreset); err != nil {
+			fmt.Printf("ERROR: -ua-preset: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	if options.uploadfile != "" {
+		switch {
+		case data != "" || forms != nil || formfiles != nil || options.requestfile != nil:
+			fmt.Printf("ERROR: -upload-file cannot be used with -data, -form, -form-file or -request-file\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case head || optionsreq || options.rawrequest != nil:
+			fmt.Printf("ERROR: -upload-file cannot be used with -head, -options or -raw-request\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		if fi, err := os.Stat(options.uploadfile); err != nil || !fi.Mode().IsRegular() {
+			fmt.Printf("ERROR: -upload-file: not a regular file: %s\n", options.uploadfile)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.method = http.MethodPut
+	}
+
+	if forms != nil || formfiles != nil {
+		if data != "" || head || optionsreq || options.rawrequest != nil || options.requestfile != nil {
+			fmt.Printf("ERROR: -form and -form-file cannot be used with -data, -head, -options, -raw-request or -request-file\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		body, contenttype, err := buildForm(forms, formfiles)
+		if err != nil {
+			fmt.Printf("ERROR: -form: %s\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.data = body
+		options.method = http.MethodPost
+		if options.headers == nil {
+			options.headers = make(http.Header)
+		}
+		if options.headers.Get("Content-Type") == "" {
+			options.headers.Set("Content-Type", contenttype)
+		}
+	}
+
+	if data != "" {
+		switch {
+		case head || optionsreq || options.rawrequest != nil:
+			fmt.Printf("ERROR: -data cannot be used with -head, -options or -raw-request\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case strings.HasPrefix(data, "@"):
+			body, err := ioutil.ReadFile(data[1:])
+			if err != nil {
+				fmt.Printf("ERROR: -data: %s\n", err)
+				flag.Usage()
+				os.Exit(ExitUsage)
+			}
+			options.data = body
+			options.datafile = data[1:]
+		default:
+			options.data = []byte(data)
+		}
+		options.method = http.MethodPost
+	} else if (options.expect100 || options.trailers != nil) && options.requestfile == nil &&
+		options.data == nil && options.uploadfile == "" {
+		fmt.Printf("ERROR: -expect100 and -trailer need a request body (-data)\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.connectonly || options.tlsonly {
+		switch {
+		case options.connectonly && options.tlsonly:
+			fmt.Printf("ERROR: -connect-only and -tls-only cannot be used together\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.proxy != nil || options.monitor || options.rawrequest != nil || outputToFile():
+			fmt.Printf("ERROR: -connect-only and -tls-only cannot be used with -proxy, -monitor, -raw-request, -o or -O\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	if options.websocket {
+		switch {
+		case options.proxy != nil || options.monitor || options.rawrequest != nil || outputToFile():
+			fmt.Printf("ERROR: -websocket cannot be used with -proxy, -monitor, -raw-request, -o or -O\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.connectonly || options.tlsonly:
+			fmt.Printf("ERROR: -websocket cannot be used with -connect-only or -tls-only\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.wsprotocols = splitWebSocketList(wsprotocols)
+		options.wsextensions = splitWebSocketList(wsextensions)
+	} else if wsprotocols != "" || options.wsping {
+		fmt.Printf("ERROR: -ws-protocols and -ws-ping need -websocket\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.extractabs {
+		options.extract = true
+	}
+	if (options.sniff || options.extract) && outputToFile() {
+		fmt.Printf("ERROR: -sniff and -extract cannot be used with -o or -O\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.crawl {
+		switch {
+		case options.monitor || options.rawrequest != nil || outputToFile():
+			fmt.Printf("ERROR: -crawl cannot be used with -monitor, -raw-request, -o or -O\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.method != http.MethodGet:
+			fmt.Printf("ERROR: -crawl only makes GET requests\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.crawldepth < 0:
+			fmt.Printf("ERROR: -depth must not be negative\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	if options.roots != "system" && options.cacert != nil {
+		fmt.Printf("ERROR: -roots and -cacert cannot be used together\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	rootcas, err := loadRoots(options.roots)
+	if err != nil {
+		fmt.Printf("ERROR: -roots: %v\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	options.rootcas = rootcas
+	if options.cacert != nil {
+		options.cabundles, options.rootcas, err = loadCABundles(options.cacert)
+		if err != nil {
+			fmt.Printf("ERROR: -cacert: %v\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	if options.nosni {
+		switch {
+		case options.sni != "":
+			fmt.Printf("ERROR: -no-sni and -sni cannot be used together\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.proxy != nil || options.rawrequest != nil || options.websocket:
+			fmt.Printf("ERROR: -no-sni cannot be used with -proxy, -raw-request or -websocket\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+	if options.tlsprint != "" {
+		if _, err := probe.TLSFingerprintHello(options.tlsprint); err != nil {
+			fmt.Printf("ERROR: -tls-fingerprint: %v\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		if options.proxy != nil || options.nosni {
+			fmt.Printf("ERROR: -tls-fingerprint cannot be used with -proxy or -no-sni\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+	for _, entry := range resolves {
+		hostport, iplist, err := parseResolve(entry)
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		if options.resolve == nil {
+			options.resolve = make(ResolveMap)
+		}
+		options.resolve[hostport] = append(options.resolve[hostport], iplist...)
+	}
+	for _, entry := range connecttos {
+		rule, err := parseConnectTo(entry)
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.connectto = append(options.connectto, rule)
+	}
+	if (options.resolve != nil || options.connectto != nil) && options.proxy != nil {
+		fmt.Printf("ERROR: -resolve and -connect-to cannot be used with -proxy\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	if options.connectto != nil && options.queryall {
+		fmt.Printf("ERROR: -connect-to cannot be used with -queryall, -4 or -6\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	if options.hostheader != "" {
+		if strings.ContainsAny(options.hostheader, " \t\r\n/@") {
+			fmt.Printf("ERROR: -hostheader must be a host or host:port\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+	if options.hostsni {
+		switch {
+		case options.hostheader == "":
+			fmt.Printf("ERROR: -hostheader-sni requires -hostheader\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.sni != "" || options.nosni:
+			fmt.Printf("ERROR: -hostheader-sni cannot be used with -sni or -no-sni\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		options.sni = options.hostheader
+		if host, _, err := net.SplitHostPort(options.hostheader); err == nil {
+			options.sni = host
+		}
+	}
+	if options.comparesni && options.proxy != nil {
+		fmt.Printf("ERROR: -compare-sni cannot be used with -proxy\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.ascurl && (options.rawrequest != nil || options.websocket || options.monitor || options.crawl) {
+		fmt.Printf("ERROR: -as-curl cannot be used with -raw-request, -websocket, -monitor or -crawl\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.nodnscache && (options.dnscache || options.dnsttl >= 0) {
+		fmt.Printf("ERROR: -no-dns-cache cannot be used with -dns-cache or -dns-ttl-override\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.skewthreshold <= 0 {
+		fmt.Printf("ERROR: -skew-threshold must be positive\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.followaltsvc && options.proxy != nil {
+		fmt.Printf("ERROR: -follow-altsvc cannot be used with -proxy\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.sse {
+		switch {
+		case options.monitor || options.rawrequest != nil || outputToFile():
+			fmt.Printf("ERROR: -sse cannot be used with -monitor, -raw-request, -o or -O\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.connectonly || options.tlsonly || options.websocket:
+			fmt.Printf("ERROR: -sse cannot be used with -connect-only, -tls-only or -websocket\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.sseduration < 0 || options.ssecount < 0:
+			fmt.Printf("ERROR: -sse-duration and -sse-count must not be negative\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	} else if options.sseduration != 0 || options.ssecount != 0 {
+		fmt.Printf("ERROR: -sse-duration and -sse-count need -sse\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.happyeyeballs {
+		switch {
+		case options.ipv4only || options.ipv6only || options.proxy != nil:
+			fmt.Printf("ERROR: -happy-eyeballs cannot be used with -4, -6 or -proxy\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.attemptdelay <= 0:
+			fmt.Printf("ERROR: -attempt-delay must be positive\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	if options.parallel < 1 {
+		fmt.Printf("ERROR: -parallel must be at least 1\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.statusonly {
+		switch {
+		case options.statusbudget <= 0:
+			fmt.Printf("ERROR: -status-budget must be positive\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.monitor || outputToFile():
+			fmt.Printf("ERROR: -probe-status-only cannot be used with -monitor, -o or -O\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	if err := checkMonitorOptions(); err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	if err := checkInteractiveOptions(urls); err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.outfile != "" && (len(urls) > 1 || options.urlsfile != "") {
+		fmt.Printf("ERROR: -o cannot be used with more than one URL or -urls (use -O)\n")
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+
+	if options.rawrequest != nil {
+		switch {
+		case outputToFile():
+			fmt.Printf("ERROR: -raw-request cannot be used with -o or -O\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		case options.proxy != nil || options.monitor:
+			fmt.Printf("ERROR: -raw-request cannot be used with -proxy or -monitor\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+	}
+
+	if options.recorddir != "" {
+		if outputToFile() {
+			fmt.Printf("ERROR: -record cannot be used with -o or -O\n")
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
+		if fi, err := os.Stat(options.recorddir); err != nil || !fi.IsDir() {
+			fmt.Printf("ERROR: -record: not a directory: %s\n", options.recorddir)
+			flag.Usage()
+			os.Exit(ExitUsage)
+		}
 	}
 
-	return flag.Args()[0]
+	return urls
 }
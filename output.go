@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Serializes writes of probe reports to stdout
+var outputLock sync.Mutex
+
+//
+// Output policies: "mixed" writes everything to stdout; "split" writes
+// diagnostics to stderr and only the requested payload to stdout.
+//
+const (
+	OutputMixed = "mixed"
+	OutputSplit = "split"
+)
+
+// Where diagnostic output goes, according to the output policy
+var diagOut io.Writer = os.Stdout
+
+//
+// setOutputPolicy - direct diagnostic output according to the policy
+//
+func setOutputPolicy(policy string) error {
+
+	switch policy {
+	case OutputMixed:
+		diagOut = os.Stdout
+	case OutputSplit:
+		diagOut = os.Stderr
+	default:
+		return fmt.Errorf("unknown output policy: %s", policy)
+	}
+	return nil
+}
+
+//
+// Report - collects the output of a single probe. By default the report
+// is buffered and written out in one piece by Flush, so that reports of
+// concurrently running probes never interleave. In streaming mode, each
+// complete line is instead written immediately, tagged with the probe ID.
+// With -color, lines are colored by severity, and with -quiet, only
+// those at least as severe as its level are written, tagged like
+// streamed ones. Payload output (e.g. the body) written via Payload() is kept separate
+// under the split output policy, and is never tagged.
+//
+type Report struct {
+	id      string
+	stream  bool
+	out     io.Writer
+	buf     bytes.Buffer
+	split   bool
+	payload bytes.Buffer
+	headers bool // In a header section, for renderLine
+}
+
+//
+// NewReport - create a report for the probe with the given ID
+//
+func NewReport(id string) *Report {
+	return &Report{
+		id:     id,
+		stream: options.streamoutput,
+		out:    diagOut,
+		split:  options.outputpolicy == OutputSplit,
+	}
+}
+
+//
+// Payload - return the writer for the requested payload of the probe
+//
+func (r *Report) Payload() io.Writer {
+
+	if r.split {
+		return &r.payload
+	}
+	return r
+}
+
+//
+// Write - implements io.Writer
+//
+func (r *Report) Write(p []byte) (int, error) {
+
+	r.buf.Write(p)
+	if r.stream {
+		r.writeLines()
+	}
+	return len(p), nil
+}
+
+//
+// writeLines - write out any complete buffered lines, tagged with the
+// probe ID.
+//
+func (r *Report) writeLines() {
+
+	outputLock.Lock()
+	defer outputLock.Unlock()
+
+	for {
+		i := bytes.IndexByte(r.buf.Bytes(), '\n')
+		if i < 0 {
+			return
+		}
+		line := r.render(string(r.buf.Next(i + 1)))
+		if line != "" {
+			io.WriteString(r.out, "["+r.id+"] "+line)
+		}
+	}
+}
+
+//
+// render - the buffered lines as printed, colored and filtered; s is
+// returned as is when neither -color nor -quiet is in effect
+//
+func (r *Report) render(s string) string {
+
+	if !options.color && options.quiet == SevInfo {
+		return s
+	}
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line != "" {
+			b.WriteString(r.renderLine(line))
+		}
+	}
+	return b.String()
+}
+
+//
+// Flush - write out the buffered report atomically
+//
+func (r *Report) Flush() {
+
+	if r.stream || options.quiet != SevInfo {
+		if r.buf.Len() > 0 {
+			r.buf.WriteByte('\n')
+			r.writeLines()
+		}
+	}
+
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	io.WriteString(r.out, r.render(r.buf.String()))
+	r.buf.Reset()
+	os.Stdout.Write(r.payload.Bytes())
+	r.payload.Reset()
+}
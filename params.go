@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+//
+// readParamFile - the query parameters in a -param-file: a key=value
+// per line, taken as written (not yet URL-encoded). Blank lines and
+// lines starting with # are ignored.
+//
+func readParamFile(filename string) ([]string, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var params []string
+	lineno := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.IndexByte(line, '=') <= 0 {
+			return nil, fmt.Errorf("%s:%d: must be key=value", filename, lineno)
+		}
+		params = append(params, line)
+	}
+	return params, scanner.Err()
+}
+
+//
+// addParams - the URL with the key=value parameters URL-encoded and
+// appended to its query, in the order given, after any it already has
+//
+func addParams(urlstring string, params []string) (string, error) {
+
+	u, err := url.Parse(urlstring)
+	if err != nil {
+		return "", err
+	}
+	var query []string
+	if u.RawQuery != "" {
+		query = append(query, u.RawQuery)
+	}
+	for _, param := range params {
+		key, value, err := splitFormField(param)
+		if err != nil {
+			return "", fmt.Errorf("invalid parameter %q: must be key=value", param)
+		}
+		query = append(query, url.QueryEscape(key)+"="+url.QueryEscape(value))
+	}
+	u.RawQuery = strings.Join(query, "&")
+	u.ForceQuery = false
+	return u.String(), nil
+}
@@ -0,0 +1,186 @@
+package probe
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+//
+// DigestChallenge - the parameters of a Digest WWW-Authenticate
+// challenge (RFC 7616)
+//
+type DigestChallenge struct {
+	Realm     string
+	Nonce     string
+	Opaque    string
+	Algorithm string
+	QOP       []string
+	Userhash  bool
+}
+
+// Digest algorithms supported, by their (upper case) names
+var digestHashes = map[string]func() hash.Hash{
+	"MD5":         md5.New,
+	"SHA-256":     sha256.New,
+	"SHA-512-256": sha512.New512_256,
+}
+
+//
+// splitAuthParams - split the comma separated auth-params of a
+// challenge, which may contain commas inside quoted strings
+//
+func splitAuthParams(s string) map[string]string {
+
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " \t,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+	return params
+}
+
+//
+// ParseDigestChallenge - find and parse the Digest challenge among the
+// response's WWW-Authenticate headers, preferring the strongest
+// supported algorithm if the server offers several
+//
+func ParseDigestChallenge(header http.Header) (*DigestChallenge, error) {
+
+	var best *DigestChallenge
+	rank := map[string]int{"MD5": 1, "SHA-256": 2, "SHA-512-256": 3}
+	for _, value := range header.Values("Www-Authenticate") {
+		scheme := strings.SplitN(strings.TrimSpace(value), " ", 2)
+		if len(scheme) != 2 || !strings.EqualFold(scheme[0], "Digest") {
+			continue
+		}
+		params := splitAuthParams(scheme[1])
+		c := &DigestChallenge{
+			Realm:     params["realm"],
+			Nonce:     params["nonce"],
+			Opaque:    params["opaque"],
+			Algorithm: strings.ToUpper(params["algorithm"]),
+			Userhash:  strings.EqualFold(params["userhash"], "true"),
+		}
+		if c.Algorithm == "" {
+			c.Algorithm = "MD5"
+		}
+		for _, q := range strings.Split(params["qop"], ",") {
+			if q = strings.TrimSpace(q); q != "" {
+				c.QOP = append(c.QOP, q)
+			}
+		}
+		if _, ok := digestHashes[strings.TrimSuffix(c.Algorithm, "-SESS")]; !ok || c.Nonce == "" {
+			continue
+		}
+		if best == nil || rank[strings.TrimSuffix(c.Algorithm, "-SESS")] > rank[strings.TrimSuffix(best.Algorithm, "-SESS")] {
+			best = c
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no supported Digest challenge")
+	}
+	return best, nil
+}
+
+//
+// Authorization - the Authorization header value answering the
+// challenge for a request with method and request-target uri
+//
+func (c *DigestChallenge) Authorization(method, uri, username, password string) (string, error) {
+
+	newhash := digestHashes[strings.TrimSuffix(c.Algorithm, "-SESS")]
+	h := func(s string) string {
+		hh := newhash()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(b)
+	nc := "00000001"
+
+	ha1 := h(username + ":" + c.Realm + ":" + password)
+	if strings.HasSuffix(c.Algorithm, "-SESS") {
+		ha1 = h(ha1 + ":" + c.Nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	qop := ""
+	for _, q := range c.QOP {
+		if q == "auth" {
+			qop = q
+		}
+	}
+	if len(c.QOP) > 0 && qop == "" {
+		return "", fmt.Errorf("unsupported Digest qop: %s", strings.Join(c.QOP, ","))
+	}
+
+	var response string
+	if qop != "" {
+		response = h(ha1 + ":" + c.Nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c.Nonce + ":" + ha2)
+	}
+
+	user := username
+	if c.Userhash {
+		user = h(username + ":" + c.Realm)
+	}
+	fields := []string{
+		fmt.Sprintf("username=%q", user),
+		fmt.Sprintf("realm=%q", c.Realm),
+		fmt.Sprintf("nonce=%q", c.Nonce),
+		fmt.Sprintf("uri=%q", uri),
+		"algorithm=" + c.Algorithm,
+		fmt.Sprintf("response=%q", response),
+	}
+	if c.Opaque != "" {
+		fields = append(fields, fmt.Sprintf("opaque=%q", c.Opaque))
+	}
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	if c.Userhash {
+		fields = append(fields, "userhash=true")
+	}
+	return "Digest " + strings.Join(fields, ", "), nil
+}
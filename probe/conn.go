@@ -0,0 +1,201 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+//
+// trackedConn - net.Conn that counts the bytes read and written on it,
+// and keeps its TCP statistics from when it was closed
+//
+type trackedConn struct {
+	net.Conn
+	bytesread    int64
+	byteswritten int64
+	mu           sync.Mutex
+	closed       bool
+	stats        *TCPStats
+	statserr     error
+}
+
+func (c *trackedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(&c.bytesread, int64(n))
+	return n, err
+}
+
+func (c *trackedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.byteswritten, int64(n))
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		c.stats, c.statserr = readTCPStats(c.Conn)
+		if c.stats != nil {
+			c.stats.Closed = true
+		}
+	}
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+//
+// tcpStats - the connection's TCP statistics: now, or when it was
+// closed, if it has been
+//
+func (c *trackedConn) tcpStats() (*TCPStats, error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return c.stats, c.statserr
+	}
+	return readTCPStats(c.Conn)
+}
+
+//
+// connTracker - keeps track of the connections dialed by a client
+//
+type connTracker struct {
+	mu    sync.Mutex
+	conns []*trackedConn
+}
+
+//
+// dialContext - return a dial function for http.Transport that records
+// the connections it makes. If address is non-empty, always connect to
+// it instead of the address derived from the request URL. Otherwise,
+// the addresses opts.ConnectTo gives for the address are tried in turn
+// instead of it, and if opts.Resolver is set, it is used to find the
+// addresses of hostnames, which are tried in turn.
+//
+func (t *connTracker) dialContext(address string, opts *ProbeOptions) func(context.Context, string, string) (net.Conn, error) {
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialer := new(net.Dialer)
+		dialer.Timeout = opts.connectTimeout()
+		dial := func(addr string) (net.Conn, error) {
+			if opts.Resolver != nil {
+				return dialResolved(ctx, dialer, network, addr, opts.Resolver)
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}
+		var targets []string
+		if opts.ConnectTo != nil {
+			targets = opts.ConnectTo(addr)
+		}
+		var conn net.Conn
+		var err error
+		switch {
+		case address != "":
+			conn, err = dialer.DialContext(ctx, network, address)
+		case targets != nil:
+			err = errors.New("no addresses to connect to")
+			for _, target := range targets {
+				if conn, err = dial(target); err == nil {
+					break
+				}
+			}
+		default:
+			conn, err = dial(addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		tc := &trackedConn{Conn: conn}
+		t.mu.Lock()
+		t.conns = append(t.conns, tc)
+		t.mu.Unlock()
+		return tc, nil
+	}
+}
+
+//
+// dialResolved - connect to addr, resolving its host with resolve and
+// trying each of the addresses until one succeeds
+//
+func dialResolved(ctx context.Context, dialer *net.Dialer, network, addr string, resolve ResolveFunc) (net.Conn, error) {
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	ips, err := resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	if err == nil {
+		err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	return nil, err
+}
+
+//
+// bytesRead - total bytes read from all tracked connections
+//
+func (t *connTracker) bytesRead() int64 {
+
+	var total int64
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range t.conns {
+		total += atomic.LoadInt64(&c.bytesread)
+	}
+	return total
+}
+
+//
+// bytesWritten - total bytes written to all tracked connections
+//
+func (t *connTracker) bytesWritten() int64 {
+
+	var total int64
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range t.conns {
+		total += atomic.LoadInt64(&c.byteswritten)
+	}
+	return total
+}
+
+//
+// last - the most recent connection, or nil if there is none
+//
+func (t *connTracker) last() *trackedConn {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.conns) == 0 {
+		return nil
+	}
+	return t.conns[len(t.conns)-1]
+}
+
+//
+// lastRemoteAddr - the remote address of the most recent connection
+//
+func (t *connTracker) lastRemoteAddr() string {
+
+	if conn := t.last(); conn != nil {
+		return conn.RemoteAddr().String()
+	}
+	return ""
+}
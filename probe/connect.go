@@ -0,0 +1,68 @@
+package probe
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/url"
+	"time"
+)
+
+//
+// ConnectResult - the result of connecting to a server without making
+// an HTTP request
+//
+type ConnectResult struct {
+	RemoteAddr    string               // Address connected to
+	LocalAddr     string               // Local address of the connection
+	ConnectTime   time.Duration        // Time to establish the TCP connection
+	HandshakeTime time.Duration        // Time for the TLS handshake
+	TLS           *tls.ConnectionState // TLS connection state, if any
+	Err           error                // Error connecting or in the handshake
+}
+
+//
+// Connect - connect to the server for u, or the session's address, and
+// if handshake is true and u is https, perform the TLS handshake,
+// offering the protocols an HTTP request would. The connection is then
+// closed without sending anything.
+//
+func (s *Session) Connect(u *url.URL, handshake bool) *ConnectResult {
+
+	result := new(ConnectResult)
+	opts := &s.prober.Options
+	if opts.Proxy != nil {
+		result.Err = errors.New("cannot connect directly through a proxy")
+		return result
+	}
+	ctx, cancel := opts.maxTimeContext()
+	defer cancel()
+
+	start := time.Now()
+	conn, err := s.tracker.dialContext(s.address, opts)(ctx, "tcp", directAddr(u))
+	result.ConnectTime = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.RemoteAddr = conn.RemoteAddr().String()
+	result.LocalAddr = conn.LocalAddr().String()
+	if !handshake || u.Scheme != "https" {
+		conn.Close()
+		return result
+	}
+
+	alpn := []string{"h2", "http/1.1"}
+	if opts.HTTP1Only {
+		alpn = alpn[1:]
+	}
+	start = time.Now()
+	tlsconn, cs, err := s.handshake(ctx, conn, u, alpn)
+	result.HandshakeTime = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.TLS = cs
+	tlsconn.Close()
+	return result
+}
@@ -0,0 +1,81 @@
+package probe
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+//
+// ContinueReport - how the server handled a request sent with Expect:
+// 100-continue. Times are from when the request headers were written.
+//
+type ContinueReport struct {
+	Continued    bool          // The server sent 100 Continue
+	ContinueTime time.Duration // When it did
+	BodySent     bool          // The body was (at least partly) sent
+	BodyTime     time.Duration // When sending the body began
+	FinalTime    time.Duration // When the final response headers arrived
+	mu           sync.Mutex
+	headers      time.Time
+}
+
+//
+// since - the time since the request headers were written
+//
+func (c *ContinueReport) since() time.Duration {
+
+	if c.headers.IsZero() {
+		return 0
+	}
+	return time.Since(c.headers)
+}
+
+//
+// watchedBody - a request body that records when it is first read,
+// which is when the transport starts sending it
+//
+type watchedBody struct {
+	io.ReadCloser
+	report *ContinueReport
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+
+	b.report.mu.Lock()
+	if !b.report.BodySent {
+		b.report.BodySent = true
+		b.report.BodyTime = b.report.since()
+	}
+	b.report.mu.Unlock()
+	return b.ReadCloser.Read(p)
+}
+
+//
+// watchContinue - if the request expects 100-continue, add hooks to the
+// trace and the request body to report how the server handled it, and
+// return the request to send and the report, else nil
+//
+func watchContinue(request *http.Request, trace *httptrace.ClientTrace) (*http.Request, *ContinueReport) {
+
+	if request.Header.Get("Expect") != "100-continue" || request.Body == nil || request.Body == http.NoBody {
+		return request, nil
+	}
+	report := new(ContinueReport)
+	trace.WroteHeaders = func() {
+		report.mu.Lock()
+		report.headers = time.Now()
+		report.mu.Unlock()
+	}
+	trace.Got100Continue = func() {
+		report.mu.Lock()
+		report.Continued = true
+		report.ContinueTime = report.since()
+		report.mu.Unlock()
+	}
+	request = request.Clone(request.Context())
+	request.Body = &watchedBody{ReadCloser: request.Body, report: report}
+	return request, report
+}
@@ -0,0 +1,146 @@
+package probe
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+//
+// Hash algorithms computed over the body, keyed by their names in the
+// RFC 9530 Hash Algorithms for HTTP Digest Fields registry. "sha" and
+// "md5" are only used by the older RFC 3230 Digest and Content-MD5.
+//
+var DigestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+	"sha":     sha1.New,
+	"md5":     md5.New,
+}
+
+//
+// bodyHasher - io.Writer computing all DigestAlgorithms at once
+//
+type bodyHasher struct {
+	hashes map[string]hash.Hash
+}
+
+func newBodyHasher() *bodyHasher {
+
+	h := &bodyHasher{hashes: make(map[string]hash.Hash)}
+	for name, newhash := range DigestAlgorithms {
+		h.hashes[name] = newhash()
+	}
+	return h
+}
+
+func (h *bodyHasher) Write(p []byte) (int, error) {
+	for _, hh := range h.hashes {
+		hh.Write(p)
+	}
+	return len(p), nil
+}
+
+//
+// Sums - return the digests computed so far
+//
+func (h *bodyHasher) Sums() map[string][]byte {
+
+	sums := make(map[string][]byte)
+	for name, hh := range h.hashes {
+		sums[name] = hh.Sum(nil)
+	}
+	return sums
+}
+
+//
+// ServerDigest - an integrity value supplied by the server
+//
+type ServerDigest struct {
+	Header    string // Header the value came from
+	Algorithm string // Algorithm, lower case
+	Value     []byte // Decoded digest value
+	Err       error  // Error parsing the value
+}
+
+//
+// ParseDigestFields - parse an RFC 9530 Content-Digest or Repr-Digest
+// header, a structured field dictionary of byte sequences, e.g.
+// sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:
+//
+func ParseDigestFields(header, value string) []ServerDigest {
+
+	var digests []ServerDigest
+
+	for _, member := range strings.Split(value, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		tmp := strings.SplitN(member, "=", 2)
+		d := ServerDigest{Header: header, Algorithm: strings.ToLower(strings.TrimSpace(tmp[0]))}
+		if len(tmp) != 2 || len(tmp[1]) < 2 || !strings.HasPrefix(tmp[1], ":") ||
+			!strings.HasSuffix(tmp[1], ":") {
+			d.Err = fmt.Errorf("malformed value: %q", member)
+		} else {
+			d.Value, d.Err = base64.StdEncoding.DecodeString(tmp[1][1 : len(tmp[1])-1])
+		}
+		digests = append(digests, d)
+	}
+	return digests
+}
+
+//
+// ParseLegacyDigest - parse an RFC 3230 Digest header, e.g.
+// SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=
+//
+func ParseLegacyDigest(value string) []ServerDigest {
+
+	var digests []ServerDigest
+
+	for _, member := range strings.Split(value, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		tmp := strings.SplitN(member, "=", 2)
+		d := ServerDigest{Header: "Digest", Algorithm: strings.ToLower(strings.TrimSpace(tmp[0]))}
+		if len(tmp) != 2 {
+			d.Err = fmt.Errorf("malformed value: %q", member)
+		} else {
+			d.Value, d.Err = base64.StdEncoding.DecodeString(tmp[1])
+		}
+		digests = append(digests, d)
+	}
+	return digests
+}
+
+//
+// ServerDigests - collect all integrity values from the response headers
+//
+func ServerDigests(header http.Header) []ServerDigest {
+
+	var digests []ServerDigest
+
+	if v := header.Get("Content-Digest"); v != "" {
+		digests = append(digests, ParseDigestFields("Content-Digest", v)...)
+	}
+	if v := header.Get("Repr-Digest"); v != "" {
+		digests = append(digests, ParseDigestFields("Repr-Digest", v)...)
+	}
+	if v := header.Get("Digest"); v != "" {
+		digests = append(digests, ParseLegacyDigest(v)...)
+	}
+	if v := header.Get("Content-MD5"); v != "" {
+		d := ServerDigest{Header: "Content-MD5", Algorithm: "md5"}
+		d.Value, d.Err = base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+		digests = append(digests, d)
+	}
+	return digests
+}
@@ -0,0 +1,242 @@
+package probe
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+//
+// CloseReport - how a server ended a connection on which the client
+// misbehaved, and what it delivered before it did
+//
+type CloseReport struct {
+	Status    int           // Response status, 0 if none was received
+	Complete  bool          // The whole response body was received
+	BytesRead int64         // Bytes of response received
+	Ending    string        // How the connection ended
+	Elapsed   time.Duration // Time from the request until the end
+}
+
+//
+// closeWrite - half-close the connection: send a TLS close_notify if it
+// is a TLS connection, and a TCP FIN
+//
+func closeWrite(conn net.Conn) error {
+
+	if tlsconn, ok := conn.(*tls.Conn); ok {
+		if err := tlsconn.CloseWrite(); err != nil {
+			return err
+		}
+		conn = tlsconn.NetConn()
+	}
+	if tc, ok := conn.(*trackedConn); ok {
+		conn = tc.Conn
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		return tcp.CloseWrite()
+	}
+	return errors.New("cannot half-close connection")
+}
+
+//
+// connEnding - describe how the connection ended, from the error that
+// reading it ended with
+//
+func connEnding(err error) string {
+
+	var neterr net.Error
+	switch {
+	case err == io.EOF:
+		return "graceful close (FIN)"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "reset (RST)"
+	case errors.As(err, &neterr) && neterr.Timeout():
+		return "still open at timeout"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "closed without TLS close_notify"
+	}
+	return err.Error()
+}
+
+//
+// resetConn - close the connection abruptly, with a TCP RST rather than
+// a FIN, as a client that crashed or was killed would
+//
+func resetConn(conn net.Conn) {
+
+	raw := conn
+	if tlsconn, ok := raw.(*tls.Conn); ok {
+		raw = tlsconn.NetConn()
+	}
+	if tc, ok := raw.(*trackedConn); ok {
+		raw = tc.Conn
+	}
+	if tcp, ok := raw.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+		tcp.Close()
+		return
+	}
+	conn.Close()
+}
+
+//
+// stallReader - io.Reader that pauses once, after the first after bytes
+// have been read through it
+//
+type stallReader struct {
+	r       io.Reader
+	after   int64
+	pause   time.Duration
+	read    int64
+	stalled bool
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+
+	if !s.stalled {
+		if s.read >= s.after {
+			time.Sleep(s.pause)
+			s.stalled = true
+		} else if remaining := s.after - s.read; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	n, err := s.r.Read(p)
+	s.read += int64(n)
+	return n, err
+}
+
+//
+// observe - read the response to request from r, and then, if
+// waitclose is true, wait for the server to end the connection
+//
+func observe(request *http.Request, r io.Reader, waitclose bool, start time.Time) *CloseReport {
+
+	counter := &countingReader{r: r}
+	reader := bufio.NewReader(counter)
+	report := new(CloseReport)
+
+	response, err := http.ReadResponse(reader, request)
+	if err == nil {
+		report.Status = response.StatusCode
+		_, err = io.Copy(ioutil.Discard, response.Body)
+		report.Complete = err == nil
+		if err == nil && waitclose {
+			_, err = io.Copy(ioutil.Discard, reader)
+			if err == nil {
+				err = io.EOF
+			}
+		}
+	}
+
+	report.BytesRead = counter.Count()
+	report.Elapsed = time.Since(start)
+	if err == nil {
+		report.Ending = "kept open, response complete"
+	} else {
+		report.Ending = connEnding(err)
+	}
+	return report
+}
+
+//
+// sendDirect - connect for the request, and write it as HTTP/1.1
+//
+func (s *Session) sendDirect(request *http.Request, timeout time.Duration) (net.Conn, error) {
+
+	if s.prober.Options.Proxy != nil {
+		return nil, errors.New("cannot be used through a proxy")
+	}
+	conn, _, err := s.dialDirect(request.URL, []string{"http/1.1"})
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+//
+// HalfClose - send the request on a new connection, half-close it, and
+// report how the server responds and then ends the connection. Servers
+// should still send the whole response; some reset the connection.
+//
+func (s *Session) HalfClose(request *http.Request) (*CloseReport, error) {
+
+	start := time.Now()
+	conn, err := s.sendDirect(request, s.prober.Options.maxTime())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := closeWrite(conn); err != nil {
+		return nil, err
+	}
+	return observe(request, conn, true, start), nil
+}
+
+//
+// StallRead - send the request on a new connection, read the first
+// after bytes of the response, stop reading for pause, and then read
+// the rest, reporting how the server reacted to the stall
+//
+func (s *Session) StallRead(request *http.Request, after int64, pause time.Duration) (*CloseReport, error) {
+
+	start := time.Now()
+	timeout := s.prober.Options.maxTime()
+	if timeout > 0 {
+		timeout += pause
+	}
+	conn, err := s.sendDirect(request, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return observe(request, &stallReader{r: conn, after: after, pause: pause}, false, start), nil
+}
+
+//
+// ReadPartial - send the request on a new connection, and read the
+// response headers and at most limit bytes of the body (all of it if
+// limit is negative). If the body wasn't read to the end, the
+// connection is then reset, aborting the transfer.
+//
+func (s *Session) ReadPartial(request *http.Request, limit int64) (*http.Response, []byte, error) {
+
+	conn, err := s.sendDirect(request, s.prober.Options.maxTime())
+	if err != nil {
+		return nil, nil, err
+	}
+	response, err := http.ReadResponse(bufio.NewReader(conn), request)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	var body io.Reader = response.Body
+	if limit >= 0 {
+		body = io.LimitReader(response.Body, limit)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		conn.Close()
+		return response, data, err
+	}
+	if limit >= 0 && response.ContentLength != int64(len(data)) {
+		resetConn(conn)
+	} else {
+		conn.Close()
+	}
+	return response, data, nil
+}
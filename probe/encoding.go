@@ -0,0 +1,160 @@
+package probe
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+//
+// ContentEncodings - content codings we can request and decode
+//
+var ContentEncodings = map[string]bool{
+	"gzip":     true,
+	"br":       true,
+	"zstd":     true,
+	"deflate":  true,
+	"identity": true,
+}
+
+//
+// ParseEncodings - parse comma separated list of content codings
+//
+func ParseEncodings(s string) ([]string, error) {
+
+	var encodings []string
+	for _, e := range strings.Split(s, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" {
+			continue
+		}
+		if !ContentEncodings[e] {
+			return nil, fmt.Errorf("unsupported content encoding: %s", e)
+		}
+		encodings = append(encodings, e)
+	}
+	if encodings == nil {
+		return nil, fmt.Errorf("no content encodings specified")
+	}
+	return encodings, nil
+}
+
+//
+// decoderFor - return a reader that decodes the given content coding
+//
+func decoderFor(encoding string, r io.Reader) (io.ReadCloser, error) {
+
+	switch encoding {
+	case "", "identity":
+		return ioutil.NopCloser(r), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		return deflateReader(r)
+	case "br":
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	case "zstd":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+//
+// deflateReader - return a reader that decodes the deflate content
+// coding, which RFC 9110 defines as a zlib stream (RFC 1950). Some
+// servers send raw deflate data (RFC 1951) instead, so that is decoded
+// if the data doesn't start with a zlib header.
+//
+func deflateReader(r io.Reader) (io.ReadCloser, error) {
+
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint(header[0])<<8|uint(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+//
+// decoderChain - the decoders DecodeReader stacked, outermost first.
+// Closing it closes all of them.
+//
+type decoderChain []io.ReadCloser
+
+func (c decoderChain) Read(p []byte) (int, error) {
+	return c[0].Read(p)
+}
+
+func (c decoderChain) Close() error {
+
+	var err error
+	for _, decoder := range c {
+		if e := decoder.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//
+// DecodeReader - wrap r with decoders undoing the (possibly multiple)
+// content codings listed in the Content-Encoding header, which are
+// applied in the order listed. Closing the reader returned closes the
+// decoders, but not r.
+//
+func DecodeReader(header http.Header, r io.Reader) (io.ReadCloser, error) {
+
+	var chain decoderChain
+	encodings := strings.Split(header.Get("Content-Encoding"), ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		decoder, err := decoderFor(encoding, r)
+		if err != nil {
+			chain.Close()
+			return nil, err
+		}
+		chain = append(decoderChain{decoder}, chain...)
+		r = decoder
+	}
+	return chain, nil
+}
+
+//
+// DecodeBody - undo the content codings applied to body, decoding at
+// most limit bytes if limit > 0, so that a small compressed body cannot
+// expand without bound. Reports whether the decoded body was cut short.
+//
+func DecodeBody(header http.Header, body []byte, limit int64) ([]byte, bool, error) {
+
+	r, err := DecodeReader(header, bytes.NewReader(body))
+	if err != nil {
+		return body, false, err
+	}
+	defer r.Close()
+	var reader io.Reader = r
+	var limiter *limitedReader
+	if limit > 0 {
+		limiter = &limitedReader{r: r, remaining: limit}
+		reader = limiter
+	}
+	decoded, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return body, false, fmt.Errorf("decode %s: %v", header.Get("Content-Encoding"), err)
+	}
+	return decoded, limiter != nil && limiter.truncated, nil
+}
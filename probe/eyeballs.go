@@ -0,0 +1,165 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// Connection Attempt Delay recommended by RFC 8305
+var DefaultAttemptDelay = 250 * time.Millisecond
+
+//
+// ConnectAttempt - one connection attempt of a Happy Eyeballs race
+//
+type ConnectAttempt struct {
+	Address string        // Address connected to
+	IPv6    bool          // Address is an IPv6 address
+	Started time.Duration // When the attempt started, after the race did
+	Elapsed time.Duration // Time the attempt took to succeed or fail
+	Err     error         // Error, if the attempt failed
+	Aborted bool          // Abandoned because another attempt won
+}
+
+//
+// RaceReport - the attempts of a Happy Eyeballs race, in the order they
+// were started, and which of them won
+//
+type RaceReport struct {
+	Attempts []ConnectAttempt
+	Winner   int           // Index of the winning attempt, -1 if none
+	Delay    time.Duration // Connection Attempt Delay used
+	Elapsed  time.Duration // Time until the race was won or lost
+}
+
+//
+// interleaveFamilies - order addresses for connection attempts as RFC
+// 8305 section 4 describes: alternating between address families,
+// starting with the family of the first address
+//
+func interleaveFamilies(ips []net.IP) []net.IP {
+
+	var first, second []net.IP
+	for _, ip := range ips {
+		if (ip.To4() == nil) == (ips[0].To4() == nil) {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+	var ordered []net.IP
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			ordered = append(ordered, first[i])
+		}
+		if i < len(second) {
+			ordered = append(ordered, second[i])
+		}
+	}
+	return ordered
+}
+
+//
+// preferIPv6 - the addresses, IPv6 first, keeping the order within each
+// family, as the destination address selection of RFC 6724 would
+// generally sort them
+//
+func preferIPv6(ips []net.IP) []net.IP {
+
+	var v6, v4 []net.IP
+	for _, ip := range ips {
+		if ip.To4() == nil {
+			v6 = append(v6, ip)
+		} else {
+			v4 = append(v4, ip)
+		}
+	}
+	return append(v6, v4...)
+}
+
+//
+// RaceConnect - make TCP connections to port on the addresses the way
+// RFC 8305 Happy Eyeballs does: starting with IPv6, a new attempt on
+// the next address (alternating families) every delay, or as soon as
+// the last one fails, until one succeeds. The other attempts are then
+// abandoned, and the connections are closed. Each attempt is limited
+// by the connect timeout.
+//
+func (p *Prober) RaceConnect(ips []net.IP, port string, delay time.Duration) *RaceReport {
+
+	report := &RaceReport{Winner: -1, Delay: delay}
+	if len(ips) == 0 {
+		return report
+	}
+	ordered := interleaveFamilies(preferIPv6(ips))
+	report.Attempts = make([]ConnectAttempt, len(ordered))
+	for i, ip := range ordered {
+		report.Attempts[i].Address = net.JoinHostPort(ip.String(), port)
+		report.Attempts[i].IPv6 = ip.To4() == nil
+	}
+
+	ctx, cancel := context.WithCancel(p.Options.context())
+	defer cancel()
+	type outcome struct {
+		i    int
+		conn net.Conn
+		err  error
+	}
+	outcomes := make(chan outcome, len(ordered))
+	start := time.Now()
+	next, pending := 0, 0
+	var tick <-chan time.Time
+	launch := func() {
+		i := next
+		if i > 0 {
+			report.Attempts[i].Started = time.Since(start)
+		}
+		go func() {
+			dialer := &net.Dialer{Timeout: p.Options.connectTimeout()}
+			conn, err := dialer.DialContext(ctx, "tcp", report.Attempts[i].Address)
+			outcomes <- outcome{i, conn, err}
+		}()
+		next++
+		pending++
+		tick = nil
+		if next < len(ordered) {
+			tick = time.After(delay)
+		}
+	}
+
+	launch()
+	for pending > 0 {
+		select {
+		case o := <-outcomes:
+			pending--
+			a := &report.Attempts[o.i]
+			a.Elapsed = time.Since(start) - a.Started
+			switch {
+			case o.err == nil:
+				o.conn.Close()
+				if report.Winner < 0 {
+					report.Winner = o.i
+					report.Elapsed = time.Since(start)
+					cancel()
+				}
+			case report.Winner >= 0 && errors.Is(o.err, context.Canceled):
+				a.Aborted = true
+			default:
+				a.Err = o.err
+				if report.Winner < 0 && next < len(ordered) {
+					launch()
+				}
+			}
+		case <-tick:
+			if report.Winner < 0 {
+				launch()
+			}
+		}
+	}
+	if report.Winner < 0 {
+		report.Elapsed = time.Since(start)
+	}
+	report.Attempts = report.Attempts[:next]
+	return report
+}
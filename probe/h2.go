@@ -0,0 +1,220 @@
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+//
+// H2GoAway - a GOAWAY frame received from the server
+//
+type H2GoAway struct {
+	LastStreamID uint32
+	ErrCode      http2.ErrCode
+	Debug        string
+}
+
+//
+// H2Info - HTTP/2 level details of a connection to a server, observed
+// by making a request on it frame by frame
+//
+type H2Info struct {
+	ALPN         string          // Protocol negotiated by ALPN
+	Settings     []http2.Setting // SETTINGS sent by the server, in order
+	Status       int             // Final response status
+	Interim      []int           // Statuses of 1xx interim responses, e.g. 103
+	Frames       map[string]int  // Count of frames received, by type
+	PushPromises int             // PUSH_PROMISE frames received
+	WindowGrowth int64           // Total flow-control credit granted
+	RSTStream    []http2.ErrCode // RST_STREAM error codes received
+	GoAway       *H2GoAway       // GOAWAY received, if any
+}
+
+//
+// Setting - the value of the server's setting id, and whether it sent
+// one
+//
+func (h *H2Info) Setting(id http2.SettingID) (uint32, bool) {
+
+	for _, s := range h.Settings {
+		if s.ID == id {
+			return s.Val, true
+		}
+	}
+	return 0, false
+}
+
+//
+// h2Headers - HPACK encode the request header fields of request
+//
+func h2Headers(request *http.Request) []byte {
+
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+	enc.WriteField(hpack.HeaderField{Name: ":method", Value: request.Method})
+	enc.WriteField(hpack.HeaderField{Name: ":scheme", Value: request.URL.Scheme})
+	enc.WriteField(hpack.HeaderField{Name: ":authority", Value: host})
+	enc.WriteField(hpack.HeaderField{Name: ":path", Value: request.URL.RequestURI()})
+	for key, values := range request.Header {
+		for _, value := range values {
+			enc.WriteField(hpack.HeaderField{Name: strings.ToLower(key), Value: value})
+		}
+	}
+	return buf.Bytes()
+}
+
+//
+// H2Diagnostics - make the request over a new HTTP/2 connection driven
+// frame by frame, and report what the server sent on it. Server push
+// is enabled so that pushed streams can be counted; they are refused
+// with RST_STREAM. The request must not have a body.
+//
+func (s *Session) H2Diagnostics(request *http.Request) (*H2Info, error) {
+
+	if request.URL.Scheme != "https" {
+		return nil, errors.New("HTTP/2 diagnostics require https")
+	}
+	if s.prober.Options.Proxy != nil {
+		return nil, errors.New("HTTP/2 diagnostics cannot be made through a proxy")
+	}
+
+	conn, cs, err := s.dialDirect(request.URL, []string{http2.NextProtoTLS})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	info := &H2Info{ALPN: cs.NegotiatedProtocol, Frames: make(map[string]int)}
+	if info.ALPN != http2.NextProtoTLS {
+		return info, fmt.Errorf("server did not negotiate h2 (ALPN %q)", info.ALPN)
+	}
+	if s.prober.Options.maxTime() > 0 {
+		conn.SetDeadline(time.Now().Add(s.prober.Options.maxTime()))
+	}
+
+	if _, err := conn.Write([]byte(http2.ClientPreface)); err != nil {
+		return info, err
+	}
+	framer := http2.NewFramer(conn, conn)
+	decoder := hpack.NewDecoder(4096, nil)
+	framer.ReadMetaHeaders = decoder
+	if err := framer.WriteSettings(http2.Setting{ID: http2.SettingEnablePush, Val: 1}); err != nil {
+		return info, err
+	}
+	err = framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: h2Headers(request),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	if err != nil {
+		return info, err
+	}
+
+	// PUSH_PROMISE header blocks are decoded whole, only to keep the
+	// HPACK state in step with the server's encoder. The framer only
+	// expects CONTINUATION frames after HEADERS, so while a block
+	// continues, its checks are turned off, and the frames checked here.
+	var promise []byte
+	var promiseStream uint32
+	continuePromise := func(fragment []byte, ended bool, stream uint32) {
+		promise = append(promise, fragment...)
+		if !ended {
+			promiseStream = stream
+			framer.AllowIllegalReads, framer.ReadMetaHeaders = true, nil
+			return
+		}
+		promiseStream = 0
+		framer.AllowIllegalReads, framer.ReadMetaHeaders = false, decoder
+		decoder.SetEmitFunc(func(hpack.HeaderField) {})
+		decoder.Write(promise)
+		decoder.Close()
+		promise = promise[:0]
+	}
+
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			return info, err
+		}
+		info.Frames[frame.Header().Type.String()]++
+		if promiseStream != 0 {
+			if f, ok := frame.(*http2.ContinuationFrame); !ok || f.StreamID != promiseStream {
+				return info, http2.ConnectionError(http2.ErrCodeProtocol)
+			}
+		}
+
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				continue
+			}
+			f.ForeachSetting(func(setting http2.Setting) error {
+				info.Settings = append(info.Settings, setting)
+				return nil
+			})
+			if err := framer.WriteSettingsAck(); err != nil {
+				return info, err
+			}
+		case *http2.MetaHeadersFrame:
+			if f.StreamID == 1 && info.Status == 0 {
+				var status int
+				fmt.Sscan(f.PseudoValue("status"), &status)
+				// 1xx header blocks (e.g. 103 Early Hints) come before
+				// the final response's (RFC 9113, section 8.1)
+				if status >= 100 && status < 200 && !f.StreamEnded() {
+					info.Interim = append(info.Interim, status)
+					continue
+				}
+				info.Status = status
+			}
+			if f.StreamID == 1 && f.StreamEnded() {
+				framer.WriteGoAway(0, http2.ErrCodeNo, nil)
+				return info, nil
+			}
+		case *http2.DataFrame:
+			if n := uint32(len(f.Data())); n > 0 {
+				framer.WriteWindowUpdate(0, n)
+				framer.WriteWindowUpdate(f.StreamID, n)
+			}
+			if f.StreamID == 1 && f.StreamEnded() {
+				framer.WriteGoAway(0, http2.ErrCodeNo, nil)
+				return info, nil
+			}
+		case *http2.PushPromiseFrame:
+			info.PushPromises++
+			framer.WriteRSTStream(f.PromiseID, http2.ErrCodeCancel)
+			continuePromise(f.HeaderBlockFragment(), f.HeadersEnded(), f.StreamID)
+		case *http2.ContinuationFrame:
+			continuePromise(f.HeaderBlockFragment(), f.HeadersEnded(), f.StreamID)
+		case *http2.WindowUpdateFrame:
+			info.WindowGrowth += int64(f.Increment)
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				framer.WritePing(true, f.Data)
+			}
+		case *http2.RSTStreamFrame:
+			info.RSTStream = append(info.RSTStream, f.ErrCode)
+			if f.StreamID == 1 {
+				return info, nil
+			}
+		case *http2.GoAwayFrame:
+			info.GoAway = &H2GoAway{
+				LastStreamID: f.LastStreamID,
+				ErrCode:      f.ErrCode,
+				Debug:        string(f.DebugData()),
+			}
+			return info, nil
+		}
+	}
+}
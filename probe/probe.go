@@ -0,0 +1,283 @@
+//
+// Package probe makes the HTTP requests behind the gohttp diagnostic
+// tool, and records the timings, sizes, TLS connection state and other
+// details that it reports, for use by other Go programs.
+//
+//	prober, err := probe.NewProber(probe.DefaultOptions())
+//	if err != nil {
+//		...
+//	}
+//	result := prober.Probe("https://www.example.com/")
+//	if result.Err == nil {
+//		fmt.Println(result.ResponseTime, result.Response.TLS.Version)
+//	}
+//
+package probe
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//
+// ProbeOptions - how requests are made and responses read
+//
+type ProbeOptions struct {
+	Timeout        time.Duration  // Connection and request timeout
+	ConnectTimeout time.Duration  // TCP connection timeout, if not Timeout
+	TLSTimeout     time.Duration  // TLS handshake timeout, if not Timeout
+	HeaderTimeout  time.Duration  // Response header timeout, if not Timeout
+	MaxTime        time.Duration  // Total request timeout, if not Timeout
+	SNI            string         // Server Name Indication
+	NoSNI          bool           // Send no Server Name Indication
+	VerifyName     string         // Verify the certificate for this name
+	Headers        http.Header    // Custom request headers
+	HostHeader     string         // Host header to send, if not the URL's
+	UserAgent      string         // User-Agent string
+	CACert         string         // File containing PEM format CA certs
+	RootCAs        *x509.CertPool // Trusted roots, if not CACert's or the system's
+	ClientCert     string         // File containing PEM format client cert
+	ClientKey      string         // File containing PEM format client key
+	Username       string         // Basic (or Digest) auth username
+	Password       string         // Basic (or Digest) auth password
+	DigestAuth     bool           // Use Digest instead of Basic auth
+	Bearer         string         // Bearer token to send, if any
+	NoRedirect     bool           // Don't follow redirects
+	NoVerify       bool           // Don't verify server certificate
+	Range          *ByteRange     // Byte range to request
+	Encodings      []string       // Content-Encodings to request and decode
+	MaxBody        int64          // Maximum body bytes to read, if > 0
+	LimitRate      int64          // Maximum body read rate in bytes/sec, if > 0
+	Hash           bool           // Compute digests of the body
+	Proxy          *url.URL       // Proxy to send requests through, if any
+	HTTP1Only      bool           // Don't negotiate HTTP/2
+	TLSFingerprint string         // Browser whose ClientHello to send: chrome, firefox or ios
+	Resolver       ResolveFunc    // Resolves hostnames when dialing, if set
+	ConnectTo      ConnectToFunc  // Addresses to dial instead of a host:port, if set
+	AWS            *AWSSigner     // Sign requests with AWS SigV4, if set
+	Jar            http.CookieJar // Cookie jar, if any
+	Body           []byte         // Request body, if any
+	ExpectContinue time.Duration  // Wait for 100 Continue before the body, if > 0
+	Trailers       http.Header    // Trailers to send after the Body, if any
+	BodyFile       string         // File to stream as the body, if no Body
+	UploadProgress ProgressFunc   // Called every ProgressInterval sending the body
+
+	// Cancels the requests made and connections dialed, if set
+	Context context.Context
+}
+
+//
+// phaseTimeout - the timeout for a phase of a request: its own if set,
+// else Timeout
+//
+func (o *ProbeOptions) phaseTimeout(timeout time.Duration) time.Duration {
+
+	if timeout > 0 {
+		return timeout
+	}
+	return o.Timeout
+}
+
+// The timeouts for connecting, the TLS handshake, the response headers
+// and the whole request
+func (o *ProbeOptions) connectTimeout() time.Duration { return o.phaseTimeout(o.ConnectTimeout) }
+func (o *ProbeOptions) tlsTimeout() time.Duration     { return o.phaseTimeout(o.TLSTimeout) }
+func (o *ProbeOptions) headerTimeout() time.Duration  { return o.phaseTimeout(o.HeaderTimeout) }
+func (o *ProbeOptions) maxTime() time.Duration        { return o.phaseTimeout(o.MaxTime) }
+
+//
+// context - the context requests are made and connections dialed in
+//
+func (o *ProbeOptions) context() context.Context {
+
+	if o.Context != nil {
+		return o.Context
+	}
+	return context.Background()
+}
+
+//
+// maxTimeContext - a context, derived from context(), for a connection
+// made without a transport, which ends after MaxTime if that is set
+//
+func (o *ProbeOptions) maxTimeContext() (context.Context, context.CancelFunc) {
+
+	if timeout := o.maxTime(); timeout > 0 {
+		return context.WithTimeout(o.context(), timeout)
+	}
+	return context.WithCancel(o.context())
+}
+
+//
+// ResolveFunc - returns the addresses of a hostname, in the order they
+// should be tried, giving up if ctx is cancelled
+//
+type ResolveFunc func(ctx context.Context, hostname string) ([]net.IP, error)
+
+//
+// ConnectToFunc - returns the addresses (host:port) to try in turn,
+// instead of the host:port addr, or nil to connect to addr itself
+// (an empty list fails the connection)
+//
+type ConnectToFunc func(addr string) []string
+
+//
+// DefaultOptions - the options gohttp uses when given no flags
+//
+func DefaultOptions() ProbeOptions {
+	return ProbeOptions{
+		Timeout:   5 * time.Second,
+		UserAgent: "gohttp",
+	}
+}
+
+//
+// ProbeResult - the response to a request, and what was measured
+// making it
+//
+type ProbeResult struct {
+	Response     *http.Response    // Response, with its body already read
+	Body         []byte            // Body, decoded if Encodings were given
+	Start        time.Time         // When the request was sent
+	HeaderTime   time.Duration     // Time until the response headers
+	TransferTime time.Duration     // Time to transfer the body
+	ResponseTime time.Duration     // Total time
+	EncodedSize  int64             // Body bytes as transferred
+	BodySize     int64             // Body bytes after decoding
+	WireBytes    int64             // Bytes received on the connection
+	Truncated    bool              // Raw or decoded body cut short by MaxBody
+	Digests      map[string][]byte // Body digests, keyed by algorithm
+	DecodeErr    error             // Error undoing content codings
+	Err          error             // Error making the request
+	RemoteAddr   string            // Address connected to for the response
+	LocalAddr    string            // Local address of that connection
+	Reused       bool              // That connection was reused
+	IdleTime     time.Duration     // How long it was idle before, if so
+	Interim      []InterimResponse // 1xx responses that came before it
+	Continue     *ContinueReport   // How Expect: 100-continue went, if sent
+	Upload       *UploadReport     // How the request body was sent, if any
+	Hops         []HopConn         // Connections used, one per request sent
+}
+
+//
+// HopConn - the connection one of the requests made for a result, the
+// original or a redirect, was sent on
+//
+type HopConn struct {
+	RemoteAddr string               // Address connected to
+	Reused     bool                 // The connection was reused
+	TLS        *tls.ConnectionState // Its TLS state, nil if not TLS
+}
+
+//
+// InterimResponse - an informational (1xx) response, such as 100
+// Continue or 103 Early Hints, received before the final response
+//
+type InterimResponse struct {
+	StatusCode int           // Status, e.g. 103
+	Header     http.Header   // Its header fields, e.g. Link for 103
+	Elapsed    time.Duration // When it arrived, after the request was sent
+}
+
+//
+// Prober - makes requests according to a set of ProbeOptions
+//
+type Prober struct {
+	Options   ProbeOptions
+	tlsconfig *tls.Config
+}
+
+//
+// NewProber - return a Prober using the given options. This fails if
+// the CA or client certificate files cannot be loaded.
+//
+func NewProber(opts ProbeOptions) (*Prober, error) {
+
+	tlsconfig, err := tlsConfig(&opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TLSFingerprint != "" {
+		if _, err := TLSFingerprintHello(opts.TLSFingerprint); err != nil {
+			return nil, err
+		}
+		if opts.Proxy != nil || opts.NoSNI {
+			return nil, errors.New("a TLS fingerprint cannot be used with a proxy or without SNI")
+		}
+	}
+	return &Prober{Options: opts, tlsconfig: tlsconfig}, nil
+}
+
+//
+// NewRequest - return a request for url carrying the configured
+// User-Agent, Range, Accept-Encoding, authorization and custom headers,
+// and the Body, if any (with Expect: 100-continue if ExpectContinue is
+// set, and sent chunked, followed by the Trailers, if there are any)
+//
+func (p *Prober) NewRequest(method, url string) (*http.Request, error) {
+
+	var body io.Reader
+	if p.Options.Body != nil {
+		body = bytes.NewReader(p.Options.Body)
+	}
+	request, err := http.NewRequestWithContext(p.Options.context(), method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body == nil && p.Options.BodyFile != "" {
+		if err := setBodyFile(request, p.Options.BodyFile); err != nil {
+			return nil, err
+		}
+	}
+	hasbody := request.Body != nil && request.Body != http.NoBody
+	request.Header.Add("User-Agent", p.Options.UserAgent)
+	if hasbody && p.Options.ExpectContinue > 0 {
+		request.Header.Set("Expect", "100-continue")
+	}
+	if hasbody && len(p.Options.Trailers) > 0 {
+		request.ContentLength = -1
+		request.Trailer = p.Options.Trailers.Clone()
+	}
+	if p.Options.Encodings != nil {
+		request.Header.Set("Accept-Encoding", strings.Join(p.Options.Encodings, ", "))
+	}
+	if p.Options.Range != nil {
+		request.Header.Set("Range", p.Options.Range.String())
+	}
+	for key, values := range p.Options.Headers {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
+	if p.Options.HostHeader != "" {
+		request.Host = p.Options.HostHeader
+	}
+	switch {
+	case p.Options.Bearer != "":
+		request.Header.Set("Authorization", "Bearer "+p.Options.Bearer)
+	case p.Options.Username != "" && !p.Options.DigestAuth:
+		request.SetBasicAuth(p.Options.Username, p.Options.Password)
+	}
+	return request, nil
+}
+
+//
+// Probe - GET url, connecting to the address its hostname resolves to
+//
+func (p *Prober) Probe(url string) *ProbeResult {
+
+	request, err := p.NewRequest(http.MethodGet, url)
+	if err != nil {
+		return &ProbeResult{Err: err}
+	}
+	return p.NewSession("").Do(request)
+}
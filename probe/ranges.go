@@ -0,0 +1,164 @@
+package probe
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//
+// ByteRange - a single byte range to request. A negative
+// start denotes a suffix range (last N bytes), and a negative end an
+// open ended range (start to end of representation).
+//
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+//
+// ParseByteRange - parse a "start-end", "start-" or "-suffix" string
+//
+func ParseByteRange(s string) (*ByteRange, error) {
+
+	var err error
+
+	tmp := strings.SplitN(s, "-", 2)
+	if len(tmp) != 2 || (tmp[0] == "" && tmp[1] == "") {
+		return nil, fmt.Errorf("invalid range %q: must be start-end", s)
+	}
+
+	br := &ByteRange{Start: -1, End: -1}
+	if tmp[0] != "" {
+		br.Start, err = strconv.ParseInt(tmp[0], 10, 64)
+		if err != nil || br.Start < 0 {
+			return nil, fmt.Errorf("invalid range start %q", tmp[0])
+		}
+	}
+	if tmp[1] != "" {
+		br.End, err = strconv.ParseInt(tmp[1], 10, 64)
+		if err != nil || br.End < 0 {
+			return nil, fmt.Errorf("invalid range end %q", tmp[1])
+		}
+	}
+	if br.Start >= 0 && br.End >= 0 && br.End < br.Start {
+		return nil, fmt.Errorf("invalid range %q: end precedes start", s)
+	}
+	return br, nil
+}
+
+//
+// String - return the Range header value for the byte range
+//
+func (br *ByteRange) String() string {
+
+	switch {
+	case br.Start < 0:
+		return fmt.Sprintf("bytes=-%d", br.End)
+	case br.End < 0:
+		return fmt.Sprintf("bytes=%d-", br.Start)
+	default:
+		return fmt.Sprintf("bytes=%d-%d", br.Start, br.End)
+	}
+}
+
+//
+// ParseContentRange - parse a "bytes first-last/complete" Content-Range
+// header value. complete is -1 if the length is given as "*".
+//
+func ParseContentRange(s string) (first, last, complete int64, err error) {
+
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, 0, fmt.Errorf("unsupported range unit: %q", s)
+	}
+	s = strings.TrimPrefix(s, "bytes ")
+
+	tmp := strings.SplitN(s, "/", 2)
+	if len(tmp) != 2 {
+		return 0, 0, 0, fmt.Errorf("malformed Content-Range: %q", s)
+	}
+	if tmp[1] == "*" {
+		complete = -1
+	} else if complete, err = strconv.ParseInt(tmp[1], 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed complete length: %q", tmp[1])
+	}
+
+	r := strings.SplitN(tmp[0], "-", 2)
+	if len(r) != 2 {
+		return 0, 0, 0, fmt.Errorf("malformed byte range: %q", tmp[0])
+	}
+	if first, err = strconv.ParseInt(r[0], 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed first byte pos: %q", r[0])
+	}
+	if last, err = strconv.ParseInt(r[1], 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed last byte pos: %q", r[1])
+	}
+	return first, last, complete, nil
+}
+
+//
+// CheckContentRange - verify that the response to a ranged request is a
+// 206 whose Content-Range and body length agree with the requested range.
+// Returns a list of problems found.
+//
+func CheckContentRange(br *ByteRange, response *http.Response, bodylen int64) []string {
+
+	var problems []string
+
+	if response.StatusCode != http.StatusPartialContent {
+		return append(problems, fmt.Sprintf("expected status 206, got %d",
+			response.StatusCode))
+	}
+
+	cr := response.Header.Get("Content-Range")
+	if cr == "" {
+		return append(problems, "206 response has no Content-Range header")
+	}
+	first, last, complete, err := ParseContentRange(cr)
+	if err != nil {
+		return append(problems, err.Error())
+	}
+
+	if last < first {
+		problems = append(problems, fmt.Sprintf("invalid Content-Range: %s", cr))
+	}
+	if complete >= 0 && last >= complete {
+		problems = append(problems, fmt.Sprintf("Content-Range exceeds complete length: %s", cr))
+	}
+
+	switch {
+	case br.Start < 0:
+		if complete >= 0 && first != max64(complete-br.End, 0) {
+			problems = append(problems, fmt.Sprintf("suffix range mismatch: requested last %d bytes, got %s", br.End, cr))
+		}
+		if complete >= 0 && last != complete-1 {
+			problems = append(problems, fmt.Sprintf("suffix range does not end at last byte: %s", cr))
+		}
+	case br.End < 0:
+		if first != br.Start {
+			problems = append(problems, fmt.Sprintf("range start mismatch: requested %d, got %d", br.Start, first))
+		}
+	default:
+		if first != br.Start {
+			problems = append(problems, fmt.Sprintf("range start mismatch: requested %d, got %d", br.Start, first))
+		}
+		if last != br.End && !(complete >= 0 && last == complete-1 && br.End >= complete) {
+			problems = append(problems, fmt.Sprintf("range end mismatch: requested %d, got %d", br.End, last))
+		}
+	}
+
+	if bodylen >= 0 && bodylen != last-first+1 {
+		problems = append(problems, fmt.Sprintf("body length %d does not match Content-Range length %d",
+			bodylen, last-first+1))
+	}
+	return problems
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
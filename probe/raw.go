@@ -0,0 +1,144 @@
+package probe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//
+// directAddr - the host:port to connect to for u
+//
+func directAddr(u *url.URL) string {
+
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+//
+// handshake - perform the TLS handshake for u on conn, offering alpn as
+// the protocols, with the TLSFingerprint browser's ClientHello if set.
+// conn is closed if the handshake fails.
+//
+func (s *Session) handshake(ctx context.Context, conn net.Conn, u *url.URL, alpn []string) (net.Conn, *tls.ConnectionState, error) {
+
+	config := s.prober.tlsconfig.Clone()
+	if config.ServerName == "" {
+		config.ServerName = u.Hostname()
+	}
+	config.NextProtos = alpn
+	if timeout := s.prober.Options.tlsTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if fingerprint := s.prober.Options.TLSFingerprint; fingerprint != "" {
+		fc, err := handshakeAs(ctx, conn, config, u.Hostname(), fingerprint, alpn)
+		if err != nil {
+			return nil, nil, err
+		}
+		cs := fc.ConnectionState()
+		return fc, &cs, nil
+	}
+	tlsconn := tls.Client(conn, config)
+	if err := tlsconn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	cs := tlsconn.ConnectionState()
+	return tlsconn, &cs, nil
+}
+
+//
+// dialDirect - connect to the server for u, or the session's address,
+// performing the TLS handshake for https with alpn as the offered
+// protocols, to speak HTTP on the connection without a transport
+//
+func (s *Session) dialDirect(u *url.URL, alpn []string) (net.Conn, *tls.ConnectionState, error) {
+
+	opts := &s.prober.Options
+	ctx, cancel := opts.maxTimeContext()
+	defer cancel()
+	conn, err := s.tracker.dialContext(s.address, opts)(ctx, "tcp", directAddr(u))
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != "https" {
+		return conn, nil, nil
+	}
+	return s.handshake(ctx, conn, u, alpn)
+}
+
+//
+// rawRequest - the parsed form of the raw request, for reading its
+// response. If it can't be parsed (which may be the point of sending
+// it), a request with the method from its request line is used.
+//
+func rawRequest(u *url.URL, raw []byte) *http.Request {
+
+	request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		method := http.MethodGet
+		if fields := strings.Fields(string(raw)); len(fields) > 0 {
+			method = fields[0]
+		}
+		request = &http.Request{Method: method, Header: make(http.Header)}
+	}
+	request.URL = u
+	return request
+}
+
+//
+// DoRaw - send raw, a literal HTTP/1.1 request, unchanged over a new
+// connection to the server for u, and read the response into memory.
+// None of the options that modify requests are applied to it.
+//
+func (s *Session) DoRaw(u *url.URL, raw []byte) *ProbeResult {
+
+	result := new(ProbeResult)
+	if s.prober.Options.Proxy != nil {
+		result.Err = errors.New("raw requests cannot be sent through a proxy")
+		return result
+	}
+
+	result.Start = time.Now()
+	// Raw requests are HTTP/1.1 messages.
+	conn, cs, err := s.dialDirect(u, []string{"http/1.1"})
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer conn.Close()
+	if s.prober.Options.maxTime() > 0 {
+		conn.SetDeadline(time.Now().Add(s.prober.Options.maxTime()))
+	}
+	result.RemoteAddr = conn.RemoteAddr().String()
+	result.LocalAddr = conn.LocalAddr().String()
+
+	wirebytes := s.tracker.bytesRead()
+	if _, err := conn.Write(raw); err != nil {
+		result.Err = err
+		return result
+	}
+	result.Response, result.Err = http.ReadResponse(bufio.NewReader(conn), rawRequest(u, raw))
+	result.HeaderTime = time.Since(result.Start)
+	if result.Err != nil {
+		return result
+	}
+	result.Response.TLS = cs
+	s.read(result, wirebytes)
+	return result
+}
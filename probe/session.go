@@ -0,0 +1,338 @@
+package probe
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"time"
+)
+
+// How often Save reports download progress
+var ProgressInterval = 500 * time.Millisecond
+
+//
+// ProgressFunc - called periodically by Save with the number of body
+// bytes transferred so far, and the Content-Length (-1 if unknown)
+//
+type ProgressFunc func(transferred, total int64, elapsed time.Duration)
+
+//
+// OpenFunc - called by Save once the response headers have arrived, to
+// obtain the writer the body is saved to
+//
+type OpenFunc func(response *http.Response) (io.Writer, error)
+
+//
+// Session - an HTTP client whose requests all go to one address, and
+// which keeps track of the connections it makes. Connections are kept
+// alive and reused between requests in the same session.
+//
+type Session struct {
+	prober  *Prober
+	address string
+	client  *http.Client
+	tracker *connTracker
+}
+
+//
+// NewSession - return a session for the prober. If address (host:port)
+// is non-empty, always connect to it instead of the address derived
+// from the request URL (or the proxy address, so address should not be
+// given with a Proxy).
+//
+func (p *Prober) NewSession(address string) *Session {
+
+	client := &http.Client{
+		Timeout: p.Options.maxTime(),
+		Jar:     p.Options.Jar,
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig:       p.tlsconfig.Clone(),
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   p.Options.tlsTimeout(),
+		ResponseHeaderTimeout: p.Options.headerTimeout(),
+		ExpectContinueTimeout: p.Options.ExpectContinue,
+	}
+
+	if p.Options.Encodings != nil {
+		transport.DisableCompression = true
+	}
+
+	if p.Options.HTTP1Only {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	if p.Options.Proxy != nil {
+		transport.Proxy = http.ProxyURL(p.Options.Proxy)
+	}
+
+	tracker := new(connTracker)
+	transport.DialContext = tracker.dialContext(address, &p.Options)
+	if p.Options.NoSNI {
+		transport.DialTLSContext = dialTLSNoSNI(transport, &p.Options)
+	}
+
+	client.Transport = transport
+	if p.Options.TLSFingerprint != "" {
+		client.Transport = newFingerprintTransport(transport, &p.Options)
+	}
+	if p.Options.AWS != nil {
+		client.Transport = &signingTransport{base: client.Transport, signer: p.Options.AWS}
+	}
+
+	if p.Options.NoRedirect {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	return &Session{prober: p, address: address, client: client, tracker: tracker}
+}
+
+//
+// Client - the underlying HTTP client
+//
+func (s *Session) Client() *http.Client {
+	return s.client
+}
+
+//
+// BytesRead - total bytes read from all of the session's connections
+//
+func (s *Session) BytesRead() int64 {
+	return s.tracker.bytesRead()
+}
+
+//
+// RemoteAddr - the address of the server (or proxy) the session last
+// connected to, or "" if it hasn't connected
+//
+func (s *Session) RemoteAddr() string {
+	return s.tracker.lastRemoteAddr()
+}
+
+//
+// BytesWritten - total bytes written to all of the session's connections
+//
+func (s *Session) BytesWritten() int64 {
+	return s.tracker.bytesWritten()
+}
+
+//
+// send - send the request, and return a result containing the
+// response, whose body has not yet been read, and the addresses of the
+// connection it came on (the last one, if there were redirects).
+//
+func (s *Session) send(request *http.Request) *ProbeResult {
+	return s.sendWith(s.client, request)
+}
+
+//
+// sendWith - send the request as send does, with a variant of the
+// session's client
+//
+func (s *Session) sendWith(client *http.Client, request *http.Request) *ProbeResult {
+
+	var err error
+
+	result := new(ProbeResult)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			result.RemoteAddr = info.Conn.RemoteAddr().String()
+			result.LocalAddr = info.Conn.LocalAddr().String()
+			result.Reused = info.Reused
+			result.IdleTime = info.IdleTime
+			hop := HopConn{RemoteAddr: result.RemoteAddr, Reused: info.Reused}
+			if conn, ok := info.Conn.(interface{ ConnectionState() tls.ConnectionState }); ok {
+				cs := conn.ConnectionState()
+				hop.TLS = &cs
+			}
+			result.Hops = append(result.Hops, hop)
+		},
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			result.Interim = append(result.Interim, InterimResponse{
+				StatusCode: code,
+				Header:     http.Header(header).Clone(),
+				Elapsed:    time.Since(result.Start),
+			})
+			return nil
+		},
+	}
+	request, result.Upload = watchUpload(request, s.prober.Options.UploadProgress)
+	request, result.Continue = watchContinue(request, trace)
+	request = request.WithContext(httptrace.WithClientTrace(request.Context(), trace))
+	result.Start = time.Now()
+	result.Response, err = client.Do(request)
+	if result.Continue != nil {
+		result.Continue.mu.Lock()
+		result.Continue.FinalTime = result.Continue.since()
+		result.Continue.mu.Unlock()
+	}
+	if err == nil && s.prober.Options.DigestAuth {
+		result.Response, err = s.digestRetry(client, request, result.Response)
+	}
+	result.HeaderTime = time.Since(result.Start)
+	result.Err = err
+	return result
+}
+
+//
+// digestRetry - if the response is a 401 with a Digest challenge, make
+// the request again with client answering the challenge, and return the
+// response to that instead. A request body is sent again with GetBody.
+//
+func (s *Session) digestRetry(client *http.Client, request *http.Request, response *http.Response) (*http.Response, error) {
+
+	if response.StatusCode != http.StatusUnauthorized {
+		return response, nil
+	}
+	challenge, err := ParseDigestChallenge(response.Header)
+	if err != nil {
+		return response, nil
+	}
+
+	// Answer for the URL that was challenged, which may have been
+	// reached by a redirect.
+	target := response.Request
+	hasBody := target.Body != nil && target.Body != http.NoBody
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+	if hasBody && target.GetBody == nil {
+		return nil, errors.New("cannot send the request body again to answer the Digest challenge")
+	}
+
+	auth, err := challenge.Authorization(target.Method, target.URL.RequestURI(),
+		s.prober.Options.Username, s.prober.Options.Password)
+	if err != nil {
+		return nil, err
+	}
+	retry := target.Clone(request.Context())
+	if hasBody {
+		if retry.Body, err = target.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	retry.Header.Set("Authorization", auth)
+	return client.Do(retry)
+}
+
+//
+// Do - make the request, and read the response body into memory
+//
+func (s *Session) Do(request *http.Request) *ProbeResult {
+
+	wirebytes := s.tracker.bytesRead()
+	result := s.send(request)
+	if result.Err != nil {
+		return result
+	}
+	s.read(result, wirebytes)
+	return result
+}
+
+//
+// read - read the body of the response in result into memory, and
+// close it. wirebytes is the session's byte count when the request was
+// sent.
+//
+func (s *Session) read(result *ProbeResult, wirebytes int64) {
+
+	response := result.Response
+	defer response.Body.Close()
+
+	reader, tap := s.bodyReader(response)
+	t1 := time.Now()
+	body, err := ioutil.ReadAll(reader)
+	result.TransferTime = time.Since(t1)
+	result.ResponseTime = time.Since(result.Start)
+	tap.record(result)
+	if s.prober.Options.Encodings != nil && err == nil {
+		var truncated bool
+		body, truncated, result.DecodeErr = DecodeBody(response.Header, body, s.prober.Options.MaxBody)
+		result.Truncated = result.Truncated || truncated
+	}
+	result.Body = body
+	result.BodySize = int64(len(body))
+	result.WireBytes = s.tracker.bytesRead() - wirebytes
+	result.Err = err
+}
+
+//
+// Save - like Do, but stream the body to the writer returned by open,
+// decoding it first if content encodings were negotiated, instead of
+// reading it into memory. If progress is non-nil, it is called every
+// ProgressInterval during the transfer.
+//
+func (s *Session) Save(request *http.Request, open OpenFunc, progress ProgressFunc) *ProbeResult {
+
+	wirebytes := s.tracker.bytesRead()
+	result := s.send(request)
+	if result.Err != nil {
+		return result
+	}
+	response := result.Response
+	defer response.Body.Close()
+
+	out, err := open(response)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	body, tap := s.bodyReader(response)
+	var limiter *limitedReader
+	if s.prober.Options.Encodings != nil {
+		decoded, err := DecodeReader(response.Header, body)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		defer decoded.Close()
+		body = decoded
+		if s.prober.Options.MaxBody > 0 {
+			limiter = &limitedReader{r: body, remaining: s.prober.Options.MaxBody}
+			body = limiter
+		}
+	}
+
+	t1 := time.Now()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	if progress != nil {
+		go func() {
+			defer close(stopped)
+			ticker := time.NewTicker(ProgressInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					progress(tap.counter.Count(), response.ContentLength, time.Since(t1))
+				}
+			}
+		}()
+	} else {
+		close(stopped)
+	}
+	n, err := io.Copy(out, body)
+	close(done)
+	<-stopped
+
+	result.BodySize = n
+	tap.record(result)
+	if limiter != nil && limiter.truncated {
+		result.Truncated = true
+	}
+	result.TransferTime = time.Since(t1)
+	result.ResponseTime = time.Since(result.Start)
+	result.WireBytes = s.tracker.bytesRead() - wirebytes
+	result.Err = err
+	return result
+}
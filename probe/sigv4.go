@@ -0,0 +1,250 @@
+package probe
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// AWSCredentials - the credentials requests are signed with
+//
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // For temporary credentials, if any
+}
+
+//
+// AWSSigner - signs requests with AWS Signature Version 4 for a region
+// and service, e.g. "us-east-1" and "s3"
+//
+type AWSSigner struct {
+	Region      string
+	Service     string
+	Credentials AWSCredentials
+
+	mu   sync.Mutex
+	last *SigV4Signature
+}
+
+//
+// SigV4Signature - the intermediate values of a request signature, for
+// comparison with what a service reports it expected
+//
+type SigV4Signature struct {
+	Time             time.Time
+	Scope            string
+	SignedHeaders    string
+	CanonicalRequest string
+	StringToSign     string
+	Signature        string
+}
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	sigV4TimeFormat  = "20060102T150405Z"
+	sigV4DateFormat  = "20060102"
+	sigV4Termination = "aws4_request"
+)
+
+//
+// sigV4Escape - URI encode s as SigV4 requires: every byte except the
+// unreserved characters is percent encoded, and so is '/' unless path
+// is true
+//
+func sigV4Escape(s string, path bool) string {
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && path:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+//
+// canonicalURI - the canonical path of u. Services other than S3
+// expect the path segments to be encoded twice.
+//
+func (a *AWSSigner) canonicalURI(u *url.URL) string {
+
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	path = sigV4Escape(path, true)
+	if a.Service != "s3" {
+		path = sigV4Escape(path, true)
+	}
+	return path
+}
+
+//
+// canonicalQuery - the query string of u, with its parameters encoded
+// and sorted by name and then value
+//
+func canonicalQuery(u *url.URL) string {
+
+	var params []string
+	for key, values := range u.Query() {
+		for _, value := range values {
+			params = append(params, sigV4Escape(key, false)+"="+sigV4Escape(value, false))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+//
+// payloadHash - the hex SHA-256 of the request body, leaving the body
+// readable, or UNSIGNED-PAYLOAD if it can't be read again
+//
+func payloadHash(request *http.Request) (string, error) {
+
+	if request.Body == nil || request.Body == http.NoBody {
+		sum := sha256.Sum256(nil)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	if request.GetBody == nil {
+		return unsignedPayload, nil
+	}
+	body, err := request.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+//
+// Sign - add the X-Amz-Date, X-Amz-Security-Token (for temporary
+// credentials), X-Amz-Content-Sha256 (for S3) and Authorization headers
+// signing request, as made at time now. All of the request's other
+// headers, and Host, are signed.
+//
+func (a *AWSSigner) Sign(request *http.Request, now time.Time) (*SigV4Signature, error) {
+
+	now = now.UTC()
+	hash, err := payloadHash(request)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Del("Authorization")
+	request.Header.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	if a.Service == "s3" {
+		request.Header.Set("X-Amz-Content-Sha256", hash)
+	}
+	if a.Credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", a.Credentials.SessionToken)
+	}
+
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+	headers := map[string]string{"host": strings.TrimSpace(host)}
+	for key, values := range request.Header {
+		var trimmed []string
+		for _, value := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(value), " "))
+		}
+		headers[strings.ToLower(key)] = strings.Join(trimmed, ",")
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonheaders bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&canonheaders, "%s:%s\n", name, headers[name])
+	}
+
+	sig := &SigV4Signature{Time: now, SignedHeaders: strings.Join(names, ";")}
+	sig.Scope = strings.Join([]string{now.Format(sigV4DateFormat), a.Region, a.Service,
+		sigV4Termination}, "/")
+	sig.CanonicalRequest = strings.Join([]string{
+		request.Method,
+		a.canonicalURI(request.URL),
+		canonicalQuery(request.URL),
+		canonheaders.String(),
+		sig.SignedHeaders,
+		hash,
+	}, "\n")
+	crhash := sha256.Sum256([]byte(sig.CanonicalRequest))
+	sig.StringToSign = strings.Join([]string{sigV4Algorithm, now.Format(sigV4TimeFormat),
+		sig.Scope, hex.EncodeToString(crhash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+a.Credentials.SecretAccessKey), now.Format(sigV4DateFormat))
+	key = hmacSHA256(key, a.Region)
+	key = hmacSHA256(key, a.Service)
+	key = hmacSHA256(key, sigV4Termination)
+	sig.Signature = hex.EncodeToString(hmacSHA256(key, sig.StringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, a.Credentials.AccessKeyID, sig.Scope, sig.SignedHeaders, sig.Signature))
+
+	a.mu.Lock()
+	a.last = sig
+	a.mu.Unlock()
+	return sig, nil
+}
+
+//
+// Last - the signature of the most recently signed request, if any
+//
+func (a *AWSSigner) Last() *SigV4Signature {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.last
+}
+
+//
+// signingTransport - an http.RoundTripper that signs each request it
+// sends, including those following redirects
+//
+type signingTransport struct {
+	base   http.RoundTripper
+	signer *AWSSigner
+}
+
+func (t *signingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	signed := request.Clone(request.Context())
+	if _, err := t.signer.Sign(signed, time.Now()); err != nil {
+		if request.Body != nil {
+			request.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(signed)
+}
@@ -0,0 +1,137 @@
+package probe
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// SSEEvent - an event received from a Server-Sent Events stream
+//
+type SSEEvent struct {
+	Type    string        // Event type ("message" if not given)
+	Data    string        // Data, its lines joined by newlines
+	ID      string        // Last event ID, after this event
+	Retry   time.Duration // Reconnection time the server set, if any
+	Arrived time.Time     // When the event was complete
+}
+
+//
+// EventStream - a response being read as a text/event-stream
+//
+type EventStream struct {
+	Response *http.Response
+	Comments int // Comment lines (e.g. keepalives) seen so far
+	reader   *bufio.Reader
+	cancel   context.CancelFunc
+	lastID   string
+	retry    time.Duration
+}
+
+//
+// OpenEvents - send the request for a Server-Sent Events stream, and
+// return the result, holding the response, and the stream to read the
+// events from, which must be closed. The whole request time limit does
+// not apply; the stream is instead cut off after duration, if non-zero.
+//
+func (s *Session) OpenEvents(request *http.Request, duration time.Duration) (*ProbeResult, *EventStream) {
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if duration > 0 {
+		ctx, cancel = context.WithTimeout(request.Context(), duration)
+	} else {
+		ctx, cancel = context.WithCancel(request.Context())
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Accept", "text/event-stream")
+	request.Header.Set("Cache-Control", "no-cache")
+
+	streaming := *s.client
+	streaming.Timeout = 0
+	result := s.sendWith(&streaming, request)
+	if result.Err != nil {
+		cancel()
+		return result, nil
+	}
+	return result, &EventStream{
+		Response: result.Response,
+		reader:   bufio.NewReader(result.Response.Body),
+		cancel:   cancel,
+	}
+}
+
+//
+// Next - read the next event from the stream, parsed as the HTML Living
+// Standard describes. Returns io.EOF when the server ends the stream,
+// and context.DeadlineExceeded when the duration limit is reached.
+//
+func (e *EventStream) Next() (*SSEEvent, error) {
+
+	event := new(SSEEvent)
+	var data []string
+	seen := false
+	for {
+		line, err := e.reader.ReadString('\n')
+		if err != nil {
+			if ctxerr := e.Response.Request.Context().Err(); ctxerr != nil {
+				err = ctxerr
+			}
+			// An incomplete event at the end of the stream is discarded.
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if !seen {
+				event = new(SSEEvent)
+				continue
+			}
+			event.Data = strings.Join(data, "\n")
+			if event.Type == "" {
+				event.Type = "message"
+			}
+			event.ID = e.lastID
+			event.Retry = e.retry
+			event.Arrived = time.Now()
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			e.Comments++
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "data":
+			data = append(data, value)
+			seen = true
+		case "event":
+			event.Type = value
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				e.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				e.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+//
+// Close - stop reading the stream, and close the connection
+//
+func (e *EventStream) Close() {
+
+	e.cancel()
+	e.Response.Body.Close()
+}
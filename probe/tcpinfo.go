@@ -0,0 +1,52 @@
+package probe
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+//
+// TCPStats - kernel statistics of a TCP connection (Linux TCP_INFO)
+//
+type TCPStats struct {
+	RTT          time.Duration // Smoothed round trip time
+	RTTVar       time.Duration // Round trip time variance
+	MinRTT       time.Duration // Minimum round trip time seen
+	Retransmits  uint32        // Segments retransmitted in total
+	Lost         uint32        // Segments currently presumed lost
+	DeliveryRate uint64        // Most recent delivery rate, bytes/sec
+	SegsOut      uint32        // Segments sent
+	SegsIn       uint32        // Segments received
+	Cwnd         uint32        // Congestion window, in segments
+	MSS          uint32        // Sender maximum segment size
+	Closed       bool          // Read when the connection was closed
+}
+
+// Error returned where TCP_INFO isn't available
+var ErrNoTCPInfo = errors.New("TCP statistics are only available on Linux")
+
+//
+// tcpConn - the TCP connection underneath conn
+//
+func tcpConn(conn net.Conn) (*net.TCPConn, bool) {
+
+	if tc, ok := conn.(*trackedConn); ok {
+		conn = tc.Conn
+	}
+	tcp, ok := conn.(*net.TCPConn)
+	return tcp, ok
+}
+
+//
+// TCPStats - the kernel's statistics for the connection the session
+// last used, read now, or when it was closed, if it has been
+//
+func (s *Session) TCPStats() (*TCPStats, error) {
+
+	conn := s.tracker.last()
+	if conn == nil {
+		return nil, errors.New("no connection")
+	}
+	return conn.tcpStats()
+}
@@ -0,0 +1,50 @@
+//go:build linux
+// +build linux
+
+package probe
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+//
+// readTCPStats - read the TCP_INFO of a connection
+//
+func readTCPStats(conn net.Conn) (*TCPStats, error) {
+
+	tcp, ok := tcpConn(conn)
+	if !ok {
+		return nil, errors.New("not a TCP connection")
+	}
+	raw, err := tcp.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var info *unix.TCPInfo
+	var sockerr error
+	err = raw.Control(func(fd uintptr) {
+		info, sockerr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if err == nil {
+		err = sockerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TCPStats{
+		RTT:          time.Duration(info.Rtt) * time.Microsecond,
+		RTTVar:       time.Duration(info.Rttvar) * time.Microsecond,
+		MinRTT:       time.Duration(info.Min_rtt) * time.Microsecond,
+		Retransmits:  info.Total_retrans,
+		Lost:         info.Lost,
+		DeliveryRate: info.Delivery_rate,
+		SegsOut:      info.Segs_out,
+		SegsIn:       info.Segs_in,
+		Cwnd:         info.Snd_cwnd,
+		MSS:          info.Snd_mss,
+	}, nil
+}
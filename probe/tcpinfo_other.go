@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package probe
+
+import "net"
+
+//
+// readTCPStats - TCP_INFO isn't available on this platform
+//
+func readTCPStats(conn net.Conn) (*TCPStats, error) {
+	return nil, ErrNoTCPInfo
+}
@@ -0,0 +1,217 @@
+package probe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+)
+
+//
+// TLSversion - map TLS verson number to string
+//
+var TLSversion = map[uint16]string{
+	0x0300: "SSL3.0",
+	0x0301: "TLS1.0",
+	0x0302: "TLS1.1",
+	0x0303: "TLS1.2",
+	0x0304: "TLS1.3",
+}
+
+//
+// KeyUsage value to string
+//
+var KeyUsage = map[x509.KeyUsage]string{
+	x509.KeyUsageDigitalSignature:  "DigitalSignature",
+	x509.KeyUsageContentCommitment: "ContentCommitment",
+	x509.KeyUsageKeyEncipherment:   "KeyEncipherment",
+	x509.KeyUsageDataEncipherment:  "DataEncipherment",
+	x509.KeyUsageKeyAgreement:      "KeyAgreement",
+	x509.KeyUsageCertSign:          "CertSign",
+	x509.KeyUsageCRLSign:           "CRLSign",
+	x509.KeyUsageEncipherOnly:      "EncipherOnly",
+	x509.KeyUsageDecipherOnly:      "DecipherOnly",
+}
+
+//
+// ExtendedKeyUsage value to string
+//
+var ExtendedKeyUsage = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "Any",
+	x509.ExtKeyUsageServerAuth:                     "ServerAuth",
+	x509.ExtKeyUsageClientAuth:                     "ClientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "CodeSigning",
+	x509.ExtKeyUsageEmailProtection:                "EmailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "IPSECEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "IPSECTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "IPSECUser",
+	x509.ExtKeyUsageTimeStamping:                   "TimeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "MicrosoftServerGatedCrypto",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "NetscapeServerGatedCrypto",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "MicrosoftCommercialCodeSigning",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "MicrosoftKernelCodeSigning",
+}
+
+//
+// KU2Strings -
+//
+func KU2Strings(ku x509.KeyUsage) string {
+
+	var result []string
+	for k, v := range KeyUsage {
+		if ku&k == k {
+			result = append(result, v)
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+//
+// EKU2Strings -
+//
+func EKU2Strings(ekulist []x509.ExtKeyUsage) string {
+
+	var result []string
+	for _, eku := range ekulist {
+		result = append(result, ExtendedKeyUsage[eku])
+	}
+	return strings.Join(result, " ")
+}
+
+//
+// KeySizeInBits -
+//
+func KeySizeInBits(publickey interface{}) int {
+
+	switch v := publickey.(type) {
+	case *rsa.PublicKey:
+		return v.Size() * 8
+	case *ecdsa.PublicKey:
+		return v.X.BitLen() + v.Y.BitLen()
+	case *ed25519.PublicKey:
+		return 256
+	default:
+		return 0
+	}
+}
+
+//
+// tlsConfig - the TLS configuration for the given options
+//
+func tlsConfig(opts *ProbeOptions) (*tls.Config, error) {
+
+	tlsconfig := new(tls.Config)
+
+	if opts.SNI != "" {
+		tlsconfig.ServerName = strings.TrimSuffix(opts.SNI, ".")
+	}
+
+	if opts.NoVerify {
+		tlsconfig.InsecureSkipVerify = true
+	} else if opts.CACert != "" {
+		cacert, err := ioutil.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, err
+		}
+		cacertpool := x509.NewCertPool()
+		cacertpool.AppendCertsFromPEM(cacert)
+		tlsconfig.RootCAs = cacertpool
+	} else if opts.RootCAs != nil {
+		tlsconfig.RootCAs = opts.RootCAs
+	}
+
+	// Verifying the certificate for a name other than the one sent as
+	// SNI needs verification done here instead of by crypto/tls.
+	if opts.VerifyName != "" && !opts.NoVerify {
+		tlsconfig.InsecureSkipVerify = true
+		tlsconfig.VerifyConnection = verifyFor(tlsconfig.RootCAs, opts.VerifyName)
+	}
+
+	// Otherwise RootCAs is deliberately left nil rather than set from
+	// x509.SystemCertPool(), so that on Windows and macOS verification
+	// goes through the platform verifier (see the trust source
+	// reporting in gohttp).
+
+	if opts.ClientCert != "" {
+		clientcreds, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsconfig.Certificates = []tls.Certificate{clientcreds}
+	}
+
+	return tlsconfig, nil
+}
+
+//
+// verifyFor - a VerifyConnection function verifying the server's
+// certificate chain against roots (nil for the system roots), for name
+//
+func verifyFor(roots *x509.CertPool, name string) func(tls.ConnectionState) error {
+
+	name = strings.TrimSuffix(name, ".")
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("tls: server presented no certificate")
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range cs.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+			DNSName:       name,
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
+
+//
+// dialTLSNoSNI - a DialTLSContext function for the transport that does
+// the TLS handshake itself, to send no SNI, which crypto/tls only omits
+// when the config has no ServerName. The certificate is verified for
+// VerifyName, or the URL's hostname.
+//
+func dialTLSNoSNI(transport *http.Transport, opts *ProbeOptions) func(context.Context, string, string) (net.Conn, error) {
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := transport.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		config := transport.TLSClientConfig.Clone()
+		config.ServerName = ""
+		config.NextProtos = []string{"http/1.1"}
+		if transport.ForceAttemptHTTP2 {
+			config.NextProtos = []string{"h2", "http/1.1"}
+		}
+		if !opts.NoVerify {
+			name := opts.VerifyName
+			if name == "" {
+				name, _, _ = net.SplitHostPort(addr)
+			}
+			config.InsecureSkipVerify = true
+			config.VerifyConnection = verifyFor(config.RootCAs, name)
+		}
+		if timeout := opts.tlsTimeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		tlsconn := tls.Client(conn, config)
+		if err := tlsconn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsconn, nil
+	}
+}
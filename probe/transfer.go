@@ -0,0 +1,166 @@
+package probe
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//
+// countingReader - io.Reader that counts the bytes read through it
+//
+type countingReader struct {
+	r     io.Reader
+	count int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.count, int64(n))
+	return n, err
+}
+
+func (c *countingReader) Count() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+//
+// throttledReader - io.Reader that limits the average rate at which it
+// can be read, to simulate a slow client.
+//
+type throttledReader struct {
+	r     io.Reader
+	rate  int64 // bytes per second
+	t0    time.Time
+	total int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+
+	if t.t0.IsZero() {
+		t.t0 = time.Now()
+	}
+
+	// Read at most a tenth of a second's worth at a time, so that the
+	// server sees a steady trickle rather than bursts.
+	chunk := t.rate / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := t.r.Read(p)
+	t.total += int64(n)
+	due := time.Duration(float64(t.total) / float64(t.rate) * float64(time.Second))
+	if wait := due - time.Since(t.t0); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+//
+// limitedReader - io.Reader that stops after limit bytes, and records
+// whether there was more data beyond the limit.
+//
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	truncated bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+
+	if l.remaining <= 0 {
+		// Peek at one more byte, to tell whether we cut the body short.
+		var b [1]byte
+		if n, _ := l.r.Read(b[:]); n > 0 {
+			l.truncated = true
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+//
+// ParseSize - parse a size in bytes, with optional k, m or g suffix
+//
+func ParseSize(s string) (int64, error) {
+
+	multiplier := int64(1)
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1024
+	case strings.HasSuffix(s, "m"):
+		multiplier = 1024 * 1024
+	case strings.HasSuffix(s, "g"):
+		multiplier = 1024 * 1024 * 1024
+	}
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return size * multiplier, nil
+}
+
+//
+// bodyTap - the readers wrapped around a response body to observe it
+//
+type bodyTap struct {
+	counter *countingReader
+	limiter *limitedReader
+	hasher  *bodyHasher
+}
+
+//
+// bodyReader - wrap the response body with a counter of the bytes
+// transferred, a throttle if LimitRate was given, a cutoff if MaxBody
+// was given, and hashes of the content if Hash was given.
+//
+func (s *Session) bodyReader(response *http.Response) (io.Reader, *bodyTap) {
+
+	opts := &s.prober.Options
+	tap := new(bodyTap)
+	var r io.Reader = response.Body
+
+	if opts.MaxBody > 0 {
+		tap.limiter = &limitedReader{r: r, remaining: opts.MaxBody}
+		r = tap.limiter
+	}
+	if opts.Hash {
+		tap.hasher = newBodyHasher()
+		r = io.TeeReader(r, tap.hasher)
+	}
+	tap.counter = &countingReader{r: r}
+	r = tap.counter
+	if opts.LimitRate > 0 {
+		r = &throttledReader{r: r, rate: opts.LimitRate}
+	}
+	return r, tap
+}
+
+//
+// record - save what the tap observed in the result
+//
+func (t *bodyTap) record(result *ProbeResult) {
+
+	result.EncodedSize = t.counter.Count()
+	result.Truncated = t.limiter != nil && t.limiter.truncated
+	if t.hasher != nil {
+		result.Digests = t.hasher.Sums()
+	}
+}
@@ -0,0 +1,111 @@
+package probe
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+//
+// UploadReport - how the request body was sent
+//
+type UploadReport struct {
+	Bytes    int64         // Body bytes sent
+	Time     time.Duration // From starting to send the body to finishing
+	mu       sync.Mutex
+	start    time.Time
+	total    int64 // Content-Length, -1 if unknown
+	progress ProgressFunc
+	reported time.Time
+}
+
+//
+// Sent - the bytes sent, and how long sending them took
+//
+func (u *UploadReport) Sent() (int64, time.Duration) {
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.Bytes, u.Time
+}
+
+//
+// countedBody - a request body that counts the bytes the transport
+// reads from it to send, and times the reading
+//
+type countedBody struct {
+	io.ReadCloser
+	report *UploadReport
+}
+
+func (b *countedBody) Read(p []byte) (int, error) {
+
+	b.report.mu.Lock()
+	if b.report.start.IsZero() {
+		b.report.start = time.Now()
+	}
+	b.report.mu.Unlock()
+	n, err := b.ReadCloser.Read(p)
+	b.report.mu.Lock()
+	b.report.Bytes += int64(n)
+	b.report.Time = time.Since(b.report.start)
+	bytes, elapsed := b.report.Bytes, b.report.Time
+	progress := b.report.progress != nil && time.Since(b.report.reported) >= ProgressInterval
+	if progress {
+		b.report.reported = time.Now()
+	}
+	b.report.mu.Unlock()
+	if progress {
+		b.report.progress(bytes, b.report.total, elapsed)
+	}
+	return n, err
+}
+
+//
+// watchUpload - if the request has a body, count and time sending it,
+// calling progress (if not nil) every ProgressInterval, and return the
+// request to send and the report, else nil
+//
+func watchUpload(request *http.Request, progress ProgressFunc) (*http.Request, *UploadReport) {
+
+	if request.Body == nil || request.Body == http.NoBody {
+		return request, nil
+	}
+	total := request.ContentLength
+	if total == 0 {
+		total = -1
+	}
+	report := &UploadReport{total: total, progress: progress, reported: time.Now()}
+	request = request.Clone(request.Context())
+	request.Body = &countedBody{ReadCloser: request.Body, report: report}
+	return request, report
+}
+
+//
+// setBodyFile - stream the file as the request's body, reopening it
+// if the body must be sent again, e.g. after a 307 redirect
+//
+func setBodyFile(request *http.Request, filename string) error {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	request.Body = f
+	request.ContentLength = info.Size()
+	if info.Size() == 0 {
+		f.Close()
+		request.Body = http.NoBody
+	}
+	request.GetBody = func() (io.ReadCloser, error) {
+		return os.Open(filename)
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+// The browsers whose ClientHello TLSFingerprint can mimic, with uTLS
+var tlsFingerprints = map[string]utls.ClientHelloID{
+	"chrome":  utls.HelloChrome_Auto,
+	"firefox": utls.HelloFirefox_Auto,
+	"ios":     utls.HelloIOS_Auto,
+}
+
+//
+// TLSFingerprintHello - the browser ClientHello a TLSFingerprint name
+// (chrome, firefox or ios) mimics, e.g. "Chrome 102"
+//
+func TLSFingerprintHello(name string) (string, error) {
+
+	hello, ok := tlsFingerprints[name]
+	if !ok {
+		return "", fmt.Errorf("unknown TLS fingerprint %q (chrome, firefox or ios)", name)
+	}
+	return hello.Client + " " + hello.Version, nil
+}
+
+//
+// tlsState - a uTLS connection state as a crypto/tls one
+//
+func tlsState(cs utls.ConnectionState) tls.ConnectionState {
+
+	return tls.ConnectionState{
+		Version:                     cs.Version,
+		HandshakeComplete:           cs.HandshakeComplete,
+		DidResume:                   cs.DidResume,
+		CipherSuite:                 cs.CipherSuite,
+		NegotiatedProtocol:          cs.NegotiatedProtocol,
+		ServerName:                  cs.ServerName,
+		PeerCertificates:            cs.PeerCertificates,
+		VerifiedChains:              cs.VerifiedChains,
+		SignedCertificateTimestamps: cs.SignedCertificateTimestamps,
+		OCSPResponse:                cs.OCSPResponse,
+	}
+}
+
+//
+// fingerprintConn - a TLS connection made with a browser's ClientHello.
+// Its ConnectionState is a crypto/tls one, so that the transports and
+// the reports see it as they would a crypto/tls connection.
+//
+type fingerprintConn struct {
+	*utls.UConn
+}
+
+func (c *fingerprintConn) ConnectionState() tls.ConnectionState {
+	return tlsState(c.UConn.ConnectionState())
+}
+
+//
+// utlsConfig - the uTLS form of a crypto/tls client config, for host
+//
+func utlsConfig(config *tls.Config, host string) *utls.Config {
+
+	uconfig := &utls.Config{
+		ServerName:         config.ServerName,
+		RootCAs:            config.RootCAs,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if uconfig.ServerName == "" {
+		uconfig.ServerName = strings.TrimSuffix(host, ".")
+	}
+	for _, cert := range config.Certificates {
+		uconfig.Certificates = append(uconfig.Certificates, utls.Certificate{
+			Certificate: cert.Certificate,
+			PrivateKey:  cert.PrivateKey,
+			Leaf:        cert.Leaf,
+		})
+	}
+	if verify := config.VerifyConnection; verify != nil {
+		uconfig.VerifyConnection = func(cs utls.ConnectionState) error {
+			return verify(tlsState(cs))
+		}
+	}
+	return uconfig
+}
+
+//
+// handshakeAs - perform the TLS handshake on conn for host, with the
+// ClientHello of the browser named by fingerprint. If alpn is non-nil,
+// it replaces the protocols the browser offers. conn is closed if the
+// handshake fails.
+//
+func handshakeAs(ctx context.Context, conn net.Conn, config *tls.Config, host, fingerprint string, alpn []string) (*fingerprintConn, error) {
+
+	uconn := utls.UClient(conn, utlsConfig(config, host), tlsFingerprints[fingerprint])
+	if alpn != nil {
+		if err := uconn.BuildHandshakeState(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		for _, ext := range uconn.Extensions {
+			if e, ok := ext.(*utls.ALPNExtension); ok {
+				e.AlpnProtocols = alpn
+			}
+		}
+		// Marshal the ClientHello again, with the protocols replaced
+		if err := uconn.BuildHandshakeState(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err := uconn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &fingerprintConn{uconn}, nil
+}
+
+//
+// fingerprintTransport - a round tripper for clients that send a
+// browser's ClientHello. net/http only speaks HTTP/2 over crypto/tls
+// connections, so each https connection is dialed and handshaken here,
+// and then used with x/net/http2 if the server chose h2, or else handed
+// to the HTTP/1.1 transport, which also carries the http requests.
+//
+type fingerprintTransport struct {
+	h1   *http.Transport
+	h2   *http2.Transport
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	opts *ProbeOptions
+
+	mu      sync.Mutex
+	h2conns map[string]*h2Conn            // HTTP/2 connections, by host:port
+	h1hosts map[string]bool               // Servers that chose HTTP/1.1
+	pending map[string][]*fingerprintConn // Handshaken, for the HTTP/1.1 transport
+}
+
+//
+// h2Conn - an HTTP/2 client connection, and the connection it is on
+//
+type h2Conn struct {
+	cc   *http2.ClientConn
+	conn *fingerprintConn
+}
+
+//
+// newFingerprintTransport - a fingerprintTransport using transport for
+// HTTP/1.1, and its dialer and TLS configuration
+//
+func newFingerprintTransport(transport *http.Transport, opts *ProbeOptions) *fingerprintTransport {
+
+	t := &fingerprintTransport{
+		h1:      transport,
+		h2:      &http2.Transport{DisableCompression: transport.DisableCompression},
+		dial:    transport.DialContext,
+		opts:    opts,
+		h2conns: make(map[string]*h2Conn),
+		h1hosts: make(map[string]bool),
+		pending: make(map[string][]*fingerprintConn),
+	}
+	transport.ForceAttemptHTTP2 = false
+	transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if conn := t.takePending(addr); conn != nil {
+			return conn, nil
+		}
+		conn, err := t.dialTLS(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if conn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+			conn.Close()
+			return nil, errors.New("server switched from HTTP/1.1 to HTTP/2")
+		}
+		return conn, nil
+	}
+	return t
+}
+
+//
+// dialTLS - connect to addr and perform the TLS handshake with the
+// browser's ClientHello, offering only HTTP/1.1 if HTTP1Only is set
+//
+func (t *fingerprintTransport) dialTLS(ctx context.Context, network, addr string) (*fingerprintConn, error) {
+
+	conn, err := t.dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if timeout := t.opts.tlsTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var alpn []string
+	if t.opts.HTTP1Only {
+		alpn = []string{"http/1.1"}
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	return handshakeAs(ctx, conn, t.h1.TLSClientConfig, host, t.opts.TLSFingerprint, alpn)
+}
+
+//
+// takePending - a handshaken connection to addr waiting for the
+// HTTP/1.1 transport, if there is one
+//
+func (t *fingerprintTransport) takePending(addr string) *fingerprintConn {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns := t.pending[addr]
+	if len(conns) == 0 {
+		return nil
+	}
+	t.pending[addr] = conns[1:]
+	return conns[0]
+}
+
+func (t *fingerprintTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	if request.URL.Scheme != "https" {
+		return t.h1.RoundTrip(request)
+	}
+	addr := directAddr(request.URL)
+
+	t.mu.Lock()
+	c := t.h2conns[addr]
+	if c != nil && !c.cc.CanTakeNewRequest() {
+		delete(t.h2conns, addr)
+		c = nil
+	}
+	h1 := t.h1hosts[addr]
+	t.mu.Unlock()
+	switch {
+	case c != nil:
+		return t.roundTripH2(c, request, true)
+	case h1:
+		return t.roundTripH1(request)
+	}
+
+	conn, err := t.dialTLS(request.Context(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if conn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+		t.mu.Lock()
+		t.h1hosts[addr] = true
+		t.pending[addr] = append(t.pending[addr], conn)
+		t.mu.Unlock()
+		return t.roundTripH1(request)
+	}
+	cc, err := t.h2.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c = &h2Conn{cc: cc, conn: conn}
+	t.mu.Lock()
+	t.h2conns[addr] = c
+	t.mu.Unlock()
+	return t.roundTripH2(c, request, false)
+}
+
+//
+// roundTripH1 - send the request with the HTTP/1.1 transport, and set
+// the response's TLS state, which net/http only does for crypto/tls
+// connections
+//
+func (t *fingerprintTransport) roundTripH1(request *http.Request) (*http.Response, error) {
+
+	var conn net.Conn
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { conn = info.Conn },
+	}
+	request = request.WithContext(httptrace.WithClientTrace(request.Context(), trace))
+	response, err := t.h1.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	if fc, ok := conn.(*fingerprintConn); ok && response.TLS == nil {
+		cs := fc.ConnectionState()
+		response.TLS = &cs
+	}
+	return response, nil
+}
+
+//
+// roundTripH2 - send the request on the HTTP/2 connection, reporting
+// the connection to the request's trace as net/http would
+//
+func (t *fingerprintTransport) roundTripH2(c *h2Conn, request *http.Request, reused bool) (*http.Response, error) {
+
+	if trace := httptrace.ContextClientTrace(request.Context()); trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: c.conn, Reused: reused})
+	}
+	return c.cc.RoundTrip(request)
+}
+
+//
+// CloseIdleConnections - close the idle HTTP/1.1 connections, the
+// HTTP/2 connections, and any handshaken connections not yet used
+//
+func (t *fingerprintTransport) CloseIdleConnections() {
+
+	t.h1.CloseIdleConnections()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for addr, c := range t.h2conns {
+		c.cc.Close()
+		delete(t.h2conns, addr)
+	}
+	for addr, conns := range t.pending {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		delete(t.pending, addr)
+	}
+}
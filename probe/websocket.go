@@ -0,0 +1,257 @@
+package probe
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GUID appended to the key to compute Sec-WebSocket-Accept (RFC 6455)
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes
+const (
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+//
+// WebSocketResult - the outcome of a WebSocket opening handshake, and
+// of the ping sent over the connection if one was
+//
+type WebSocketResult struct {
+	Response      *http.Response // Handshake response (no body)
+	HandshakeTime time.Duration  // Time from the request to the response
+	RemoteAddr    string         // Address connected to
+	LocalAddr     string         // Local address of the connection
+	Upgraded      bool           // 101 with Upgrade: websocket
+	Accept        string         // The Sec-WebSocket-Accept expected
+	AcceptOK      bool           // The server sent the expected value
+	Protocol      string         // Subprotocol the server selected
+	Extensions    []string       // Extensions the server accepted
+	Pinged        bool           // A ping was sent and its pong received
+	PingRTT       time.Duration  // Round-trip time of the ping
+	PingErr       error          // Error waiting for the pong
+	Err           error          // Error in the handshake
+}
+
+//
+// webSocketAccept - the Sec-WebSocket-Accept value for key
+//
+func webSocketAccept(key string) string {
+
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+//
+// headerTokens - the comma separated elements of the header's values
+//
+func headerTokens(header http.Header, key string) []string {
+
+	var tokens []string
+	for _, value := range header.Values(key) {
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+//
+// writeFrame - write a final, masked client frame
+//
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+
+	// Control frame payloads are at most 125 bytes.
+	if len(payload) > 125 {
+		return errors.New("frame payload too long")
+	}
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+//
+// readFrame - read a frame, returning its opcode and payload
+//
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	masked := head[1]&0x80 != 0
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	if length > 1<<20 {
+		// Only the frames' opcodes matter here: skip large messages.
+		_, err := io.CopyN(ioutil.Discard, r, int64(length))
+		return opcode, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
+
+//
+// ping - send a ping on the connection, and wait for the pong echoing
+// its payload, skipping any messages the server sends meanwhile
+//
+func ping(conn net.Conn, r *bufio.Reader) (time.Duration, error) {
+
+	payload := []byte(fmt.Sprintf("gohttp %d", time.Now().UnixNano()))
+	start := time.Now()
+	if err := writeFrame(conn, wsOpPing, payload); err != nil {
+		return 0, err
+	}
+	for {
+		opcode, data, err := readFrame(r)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case opcode == wsOpPong && bytes.Equal(data, payload):
+			return time.Since(start), nil
+		case opcode == wsOpClose:
+			return 0, errors.New("server closed the connection instead of answering")
+		case opcode == wsOpPing:
+			writeFrame(conn, wsOpPong, data)
+		}
+	}
+}
+
+//
+// WebSocket - perform the WebSocket opening handshake (RFC 6455) for
+// the request, which must be for an http or https URL (ws and wss), on
+// a new HTTP/1.1 connection, offering the subprotocols and extensions
+// given. If the server upgrades the connection and doping is true, a
+// ping is sent and its round-trip time measured. The connection is then
+// closed with a close frame.
+//
+func (s *Session) WebSocket(request *http.Request, protocols, extensions []string, doping bool) *WebSocketResult {
+
+	result := new(WebSocketResult)
+	if s.prober.Options.Proxy != nil {
+		result.Err = errors.New("WebSocket handshakes cannot be sent through a proxy")
+		return result
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		result.Err = err
+		return result
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	result.Accept = webSocketAccept(key)
+
+	request = request.Clone(request.Context())
+	request.Method = http.MethodGet
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Del("Accept-Encoding")
+	if len(protocols) > 0 {
+		request.Header.Set("Sec-WebSocket-Protocol", strings.Join(protocols, ", "))
+	}
+	if len(extensions) > 0 {
+		request.Header.Set("Sec-WebSocket-Extensions", strings.Join(extensions, ", "))
+	}
+
+	start := time.Now()
+	conn, cs, err := s.dialDirect(request.URL, []string{"http/1.1"})
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer conn.Close()
+	if timeout := s.prober.Options.maxTime(); timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	result.RemoteAddr = conn.RemoteAddr().String()
+	result.LocalAddr = conn.LocalAddr().String()
+
+	if err := request.Write(conn); err != nil {
+		result.Err = err
+		return result
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	result.HandshakeTime = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	response.TLS = cs
+	result.Response = response
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		response.Body.Close()
+		return result
+	}
+
+	result.Upgraded = strings.EqualFold(response.Header.Get("Upgrade"), "websocket")
+	result.AcceptOK = response.Header.Get("Sec-WebSocket-Accept") == result.Accept
+	result.Protocol = response.Header.Get("Sec-WebSocket-Protocol")
+	result.Extensions = headerTokens(response.Header, "Sec-WebSocket-Extensions")
+	if !result.Upgraded || !result.AcceptOK {
+		return result
+	}
+
+	if doping {
+		result.PingRTT, result.PingErr = ping(conn, reader)
+		result.Pinged = result.PingErr == nil
+	}
+	// Close normally (1000), without waiting for the server's close.
+	writeFrame(conn, wsOpClose, []byte{0x03, 0xE8})
+	return result
+}
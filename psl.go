@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+//
+// isPublicSuffix - is the domain itself a public suffix (e.g. "co.uk")?
+//
+func isPublicSuffix(domain string) bool {
+
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	return suffix == domain
+}
+
+//
+// registrableDomain - the eTLD+1 of host, or host itself if it has none
+// (IP addresses, public suffixes, single label names).
+//
+func registrableDomain(host string) string {
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+//
+// domainMatch - RFC 6265 section 5.1.3 domain matching
+//
+func domainMatch(host, domain string) bool {
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	return host == domain || (strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil)
+}
+
+//
+// redirectChain - reconstruct the URLs requested, in order, from the
+// final response by following each request's Response back to the
+// redirect that caused it.
+//
+func redirectChain(response *http.Response) []*http.Response {
+
+	var chain []*http.Response
+	for r := response; r != nil; {
+		chain = append([]*http.Response{r}, chain...)
+		if r.Request == nil {
+			break
+		}
+		r = r.Request.Response
+	}
+	return chain
+}
+
+//
+// cookieDomainFindings - evaluate the Domain attributes of the cookies
+// set in a response, as a browser applying the public suffix list would.
+//
+func cookieDomainFindings(response *http.Response) []string {
+
+	var findings []string
+
+	host := response.Request.URL.Hostname()
+	for _, cookie := range response.Cookies() {
+		if cookie.Domain == "" {
+			continue
+		}
+		domain := strings.TrimPrefix(strings.ToLower(cookie.Domain), ".")
+		switch {
+		case isPublicSuffix(domain):
+			findings = append(findings, fmt.Sprintf(
+				"cookie %s: Domain=%s is a public suffix; browsers will reject it",
+				cookie.Name, cookie.Domain))
+		case !domainMatch(host, domain):
+			findings = append(findings, fmt.Sprintf(
+				"cookie %s: Domain=%s does not domain-match %s; browsers will reject it",
+				cookie.Name, cookie.Domain, host))
+		case domain == registrableDomain(host) && domain != strings.ToLower(host):
+			findings = append(findings, fmt.Sprintf(
+				"cookie %s: Domain=%s is shared with every subdomain of %s",
+				cookie.Name, cookie.Domain, domain))
+		}
+	}
+	return findings
+}
+
+//
+// wildcardFindings - flag certificate wildcard names whose scope covers
+// an entire public suffix (e.g. *.co.uk), which is misissuance.
+//
+func wildcardFindings(response *http.Response) []string {
+
+	var findings []string
+
+	if response.TLS == nil || len(response.TLS.PeerCertificates) == 0 {
+		return findings
+	}
+	for _, name := range response.TLS.PeerCertificates[0].DNSNames {
+		if !strings.HasPrefix(name, "*.") {
+			continue
+		}
+		if base := name[2:]; isPublicSuffix(base) {
+			findings = append(findings, fmt.Sprintf(
+				"certificate wildcard %s covers the public suffix %s (misissuance)", name, base))
+		}
+	}
+	return findings
+}
+
+//
+// redirectFindings - flag redirects between registrable domains
+//
+func redirectFindings(chain []*http.Response) []string {
+
+	var findings []string
+	var previous *url.URL
+
+	for _, r := range chain {
+		current := r.Request.URL
+		if previous != nil {
+			from := registrableDomain(previous.Hostname())
+			to := registrableDomain(current.Hostname())
+			if from != to {
+				findings = append(findings, fmt.Sprintf(
+					"redirect crosses registrable domain: %s -> %s (%s -> %s)",
+					previous.Hostname(), current.Hostname(), from, to))
+			}
+		}
+		previous = current
+	}
+	return findings
+}
+
+//
+// printDomainAnalysis - print public suffix aware analysis of cookies,
+// certificate wildcards and redirects.
+//
+func printDomainAnalysis(w io.Writer, response *http.Response) {
+
+	var findings []string
+
+	chain := redirectChain(response)
+	for _, r := range chain {
+		findings = append(findings, cookieDomainFindings(r)...)
+	}
+	findings = append(findings, wildcardFindings(response)...)
+	findings = append(findings, redirectFindings(chain)...)
+
+	host := response.Request.URL.Hostname()
+	fmt.Fprintln(w, "## Public Suffix Analysis:")
+	fmt.Fprintf(w, "   Host: %s\n", host)
+	if net.ParseIP(host) == nil {
+		suffix, icann := publicsuffix.PublicSuffix(strings.ToLower(host))
+		fmt.Fprintf(w, "   Public suffix: %s (ICANN: %v)\n", suffix, icann)
+		fmt.Fprintf(w, "   Registrable domain: %s\n", registrableDomain(host))
+	}
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "   No issues found")
+	}
+	for _, finding := range findings {
+		fmt.Fprintf(w, "   WARNING: %s\n", finding)
+	}
+}
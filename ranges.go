@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// printRangeResult - print the result of verifying a -range request
+//
+func printRangeResult(w io.Writer, br *probe.ByteRange, result *probe.ProbeResult) {
+
+	fmt.Fprintln(w, "## Range Request:")
+	fmt.Fprintf(w, "   Requested: %s\n", br)
+	fmt.Fprintf(w, "   Content-Range: %s\n", result.Response.Header.Get("Content-Range"))
+	problems := probe.CheckContentRange(br, result.Response, result.BodySize)
+	if len(problems) == 0 {
+		fmt.Fprintln(w, "   Result: OK")
+		return
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(w, "   ERROR: %s\n", problem)
+	}
+}
+
+//
+// rangeRequest - issue a GET for request's URL with the given Range
+// header value, and return the result.
+//
+func rangeRequest(session *probe.Session, request *http.Request, byterange string) *probe.ProbeResult {
+
+	rangereq := request.Clone(request.Context())
+	rangereq.Header.Set("Range", byterange)
+	return readResponse(session, rangereq)
+}
+
+//
+// checkRanges - probe the server's support for single and multiple
+// byte range requests, and report the results.
+//
+func checkRanges(w io.Writer, session *probe.Session, request *http.Request) {
+
+	fmt.Fprintln(w, "## Range Support:")
+
+	result := rangeRequest(session, request, "bytes=0-0")
+	if result.Err != nil {
+		fmt.Fprintf(w, "   Single range: ERROR %v\n", result.Err)
+		return
+	}
+	acceptranges := result.Response.Header.Get("Accept-Ranges")
+	if acceptranges == "" {
+		acceptranges = "(not present)"
+	}
+	fmt.Fprintf(w, "   Accept-Ranges: %s\n", acceptranges)
+
+	problems := probe.CheckContentRange(&probe.ByteRange{Start: 0, End: 0}, result.Response,
+		result.BodySize)
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "   Single range: OK (%s)\n", result.Response.Header.Get("Content-Range"))
+	} else {
+		fmt.Fprintf(w, "   Single range: FAIL (%s)\n", strings.Join(problems, "; "))
+		return
+	}
+
+	result = rangeRequest(session, request, "bytes=0-0,-1")
+	if result.Err != nil {
+		fmt.Fprintf(w, "   Multiple ranges: ERROR %v\n", result.Err)
+		return
+	}
+	switch result.Response.StatusCode {
+	case http.StatusPartialContent:
+		mediatype, params, err := mime.ParseMediaType(result.Response.Header.Get("Content-Type"))
+		if err == nil && mediatype == "multipart/byteranges" && params["boundary"] != "" {
+			fmt.Fprintln(w, "   Multiple ranges: OK (multipart/byteranges)")
+		} else if result.Response.Header.Get("Content-Range") != "" {
+			fmt.Fprintf(w, "   Multiple ranges: COALESCED (%s)\n",
+				result.Response.Header.Get("Content-Range"))
+		} else {
+			fmt.Fprintf(w, "   Multiple ranges: FAIL (206 with Content-Type %q)\n",
+				result.Response.Header.Get("Content-Type"))
+		}
+	case http.StatusOK:
+		fmt.Fprintln(w, "   Multiple ranges: NOT SUPPORTED (full 200 response)")
+	default:
+		fmt.Fprintf(w, "   Multiple ranges: NOT SUPPORTED (%d %s)\n",
+			result.Response.StatusCode, http.StatusText(result.Response.StatusCode))
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// readRawRequest - read a -raw-request file. The request is sent as it
+// is, except that a file with no carriage returns at all is taken to
+// have been written by hand, and its line endings are converted to
+// CRLF.
+//
+func readRawRequest(filename string) ([]byte, error) {
+
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%s: empty request", filename)
+	}
+	if !bytes.ContainsRune(raw, '\r') {
+		raw = bytes.ReplaceAll(raw, []byte("\n"), []byte("\r\n"))
+	}
+	return raw, nil
+}
+
+//
+// readRawResponse - send the -raw-request to the server for the
+// request's URL, and read the response
+//
+func readRawResponse(session *probe.Session, request *http.Request) *probe.ProbeResult {
+
+	statProbes.Add(1)
+	result := session.DoRaw(request.URL, options.rawrequest)
+	if result.Err != nil {
+		statProbeErrors.Add(1)
+	}
+	return result
+}
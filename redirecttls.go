@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// chainHosts - the number of distinct hosts in the redirect chain
+//
+func chainHosts(chain []*http.Response) int {
+
+	hosts := make(map[string]bool)
+	for _, r := range chain {
+		if r.Request != nil {
+			hosts[r.Request.URL.Host] = true
+		}
+	}
+	return len(hosts)
+}
+
+//
+// printRedirectTLS - when redirects led across hosts, print the
+// connection and certificate used for each host contacted, not just the
+// final one reported in the TLS Connection Info
+//
+func printRedirectTLS(w io.Writer, result *probe.ProbeResult) {
+
+	chain := redirectChain(result.Response)
+	if chainHosts(chain) < 2 || len(result.Hops) < len(chain) {
+		return
+	}
+	fmt.Fprintln(w, "## Redirect Hosts:")
+	seen := make(map[string]bool)
+	for i, r := range chain {
+		host := r.Request.URL.Host
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+		hop := result.Hops[i]
+		reused := ""
+		if hop.Reused {
+			reused = " (reused)"
+		}
+		fmt.Fprintf(w, "   [%d] %s %s via %s%s\n", i, r.Request.URL.Scheme, host, hop.RemoteAddr, reused)
+		if hop.TLS == nil {
+			fmt.Fprintln(w, "      TLS: none")
+			continue
+		}
+		fmt.Fprintf(w, "      TLS: %s %s, ALPN %s\n", probe.TLSversion[hop.TLS.Version],
+			tls.CipherSuiteName(hop.TLS.CipherSuite), hop.TLS.NegotiatedProtocol)
+		if len(hop.TLS.PeerCertificates) == 0 {
+			continue
+		}
+		leaf := hop.TLS.PeerCertificates[0]
+		if options.showcert || options.showcertchain {
+			printCertDetails(w, leaf)
+			continue
+		}
+		fmt.Fprintf(w, "      Subject: %v\n", leaf.Subject)
+		fmt.Fprintf(w, "      Issuer:  %v\n", leaf.Issuer)
+		fmt.Fprintf(w, "      Expiration: %s\n", formatTime(leaf.NotAfter))
+	}
+}
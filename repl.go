@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+const replHelp = `Commands:
+   send                 Send the request (again)
+   METHOD [path|URL]    Set the method, and the target, and send: GET /api
+   method METHOD        Set the method
+   path path|URL        Set the target path and query, on the same host
+   header Name: value   Set a header field, replacing any value it had
+   header Name:         Remove a header field
+   headers              Print the header fields set and removed
+   show                 Print the request as it would be sent
+   history              Print the commands entered
+   !N, !!               Repeat command N, or the last command
+   help                 Print this help
+   quit                 Leave (as does end of input)
+`
+
+// An HTTP method: a token, written in upper case to tell it apart from
+// the commands
+var replMethod = regexp.MustCompile(`^[A-Z][A-Z-]*$`)
+
+//
+// replState - the request the -interactive prompt sends, as changed by
+// the commands entered so far
+//
+type replState struct {
+	method  string
+	target  *url.URL
+	set     http.Header     // Header fields set at the prompt
+	removed map[string]bool // Header fields removed at the prompt
+	history []string        // Commands entered, for history and !N
+	sent    int             // Requests sent
+}
+
+//
+// checkInteractiveOptions - sanity check -interactive and the options
+// that it can't be combined with
+//
+func checkInteractiveOptions(urls []string) error {
+
+	switch {
+	case !options.interactive:
+		return nil
+	case len(urls) != 1 || options.requestfile != nil:
+		return fmt.Errorf("-interactive takes one URL")
+	case options.monitor || options.crawl || options.statusonly || options.ascurl:
+		return fmt.Errorf("-interactive cannot be used with -monitor, -crawl, -probe-status-only or -as-curl")
+	case options.connectonly || options.tlsonly || options.websocket || options.sse:
+		return fmt.Errorf("-interactive cannot be used with -connect-only, -tls-only, -websocket or -sse")
+	case options.rawrequest != nil || options.queryall || outputToFile():
+		return fmt.Errorf("-interactive cannot be used with -raw-request, -queryall, -o or -O")
+	}
+	switch options.formatter.(type) {
+	case *jsonFormatter, *nagiosFormatter, *harFormatter:
+		return fmt.Errorf("-interactive prints each response as it comes: -output must be text, ndjson or brief")
+	}
+	return nil
+}
+
+//
+// setTarget - make the path and query of ref, resolved against the
+// current target, the one to request. It must stay on the same host,
+// since the prompt's session is with that server.
+//
+func (r *replState) setTarget(ref string) error {
+
+	u, err := r.target.Parse(ref)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != r.target.Scheme || u.Host != r.target.Host {
+		return fmt.Errorf("the session is with %s://%s; run %s again for another server",
+			r.target.Scheme, r.target.Host, progname)
+	}
+	u.Fragment = ""
+	r.target = u
+	return nil
+}
+
+//
+// setHeader - for "Name: value", set the header field; for "Name:",
+// remove it, including any the options add
+//
+func (r *replState) setHeader(arg string) error {
+
+	i := strings.IndexByte(arg, ':')
+	if i <= 0 {
+		return fmt.Errorf("usage: header Name: value, or header Name: to remove it")
+	}
+	key := http.CanonicalHeaderKey(strings.TrimSpace(arg[:i]))
+	value := strings.TrimSpace(arg[i+1:])
+	if value == "" {
+		r.set.Del(key)
+		r.removed[key] = true
+		return nil
+	}
+	r.set.Set(key, value)
+	delete(r.removed, key)
+	return nil
+}
+
+//
+// printHeaderChanges - print the header fields set and removed
+//
+func (r *replState) printHeaderChanges() {
+
+	if len(r.set) == 0 && len(r.removed) == 0 {
+		fmt.Fprintln(diagOut, "   No header fields changed")
+		return
+	}
+	for _, key := range headerKeys(r.set) {
+		fmt.Fprintf(diagOut, "   %s: %s\n", key, r.set.Get(key))
+	}
+	for key := range r.removed {
+		fmt.Fprintf(diagOut, "   %s: (removed)\n", key)
+	}
+}
+
+//
+// request - the request as the commands have made it
+//
+func (r *replState) request(prober *probe.Prober) (*http.Request, error) {
+
+	request, err := newRequest(prober, r.method, r.target.String())
+	if err != nil {
+		return nil, err
+	}
+	for key := range r.removed {
+		request.Header.Del(key)
+	}
+	for key, values := range r.set {
+		if key == "Host" {
+			request.Host = values[0]
+			continue
+		}
+		request.Header[key] = values
+	}
+	return request, nil
+}
+
+//
+// send - send the request on the session, and print the report on the
+// response, and whether it came on the session's open connection
+//
+func (r *replState) send(prober *probe.Prober, session *probe.Session) {
+
+	request, err := r.request(prober)
+	if err != nil {
+		fmt.Fprintf(diagOut, "ERROR: %v\n", err)
+		return
+	}
+	r.sent++
+	report := NewReport(r.target.Host)
+	result := querySession(report, prober, session, request, "")
+	if result.RemoteAddr != "" {
+		connection := "new connection"
+		if result.Reused {
+			connection = "connection reused"
+		}
+		fmt.Fprintf(report, "## Request %d: %s %s, %s\n", r.sent, request.Method, request.URL.RequestURI(), connection)
+	}
+	report.Flush()
+}
+
+//
+// recall - the command a history reference, !N or !!, refers to
+//
+func (r *replState) recall(ref string) (string, error) {
+
+	if len(r.history) == 0 {
+		return "", fmt.Errorf("no commands in the history")
+	}
+	if ref == "!!" {
+		return r.history[len(r.history)-1], nil
+	}
+	n, err := strconv.Atoi(ref[1:])
+	if err != nil || n < 1 || n > len(r.history) {
+		return "", fmt.Errorf("%s: no such command in the history (1 to %d)", ref, len(r.history))
+	}
+	return r.history[n-1], nil
+}
+
+//
+// run - carry out a command entered at the prompt. Returns true when
+// it is time to leave.
+//
+func (r *replState) run(line string, prober *probe.Prober, session *probe.Session) bool {
+
+	var err error
+	word, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i > 0 {
+		word, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch word {
+	case "quit", "exit":
+		return true
+	case "help", "?":
+		fmt.Fprint(diagOut, replHelp)
+	case "send":
+		r.send(prober, session)
+	case "method":
+		if !replMethod.MatchString(arg) {
+			err = fmt.Errorf("usage: method METHOD, in upper case")
+		} else {
+			r.method = arg
+		}
+	case "path":
+		if arg == "" {
+			err = fmt.Errorf("usage: path path|URL")
+		} else {
+			err = r.setTarget(arg)
+		}
+	case "header":
+		err = r.setHeader(arg)
+	case "headers":
+		r.printHeaderChanges()
+	case "show":
+		var request *http.Request
+		if request, err = r.request(prober); err == nil {
+			printRequestDump(diagOut, request)
+		}
+	case "history":
+		for i, command := range r.history {
+			fmt.Fprintf(diagOut, "%5d  %s\n", i+1, command)
+		}
+	default:
+		if !replMethod.MatchString(word) {
+			err = fmt.Errorf("unknown command %q; \"help\" lists the commands", word)
+			break
+		}
+		if arg != "" {
+			if err = r.setTarget(arg); err != nil {
+				break
+			}
+		}
+		r.method = word
+		r.send(prober, session)
+	}
+	if err != nil {
+		fmt.Fprintf(diagOut, "ERROR: %v\n", err)
+	}
+	return false
+}
+
+//
+// interactive - the -interactive mode: send the request for the URL,
+// then prompt for commands that change its method, target path and
+// header fields, and send it again, on the same session, so that the
+// connection is reused while the server keeps it open. An interrupt
+// cancels the request in progress and ends the session. Returns the
+// exit status.
+//
+func interactive(prober *probe.Prober, urlstring string) int {
+
+	target, err := url.Parse(urlstring)
+	if err != nil {
+		fatal(ExitUsage, err)
+	}
+	r := &replState{
+		method:  options.method,
+		target:  target,
+		set:     make(http.Header),
+		removed: make(map[string]bool),
+	}
+	session := prober.NewSession("")
+
+	fmt.Fprintf(diagOut, "%s %s: session with %s://%s; \"help\" lists the commands\n",
+		progname, Version, target.Scheme, target.Host)
+	r.send(prober, session)
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	for runContext.Err() == nil {
+		fmt.Fprintf(diagOut, "%s> ", progname)
+		var line string
+		var ok bool
+		select {
+		case line, ok = <-lines:
+		case <-runContext.Done():
+		}
+		if !ok {
+			fmt.Fprintln(diagOut)
+			break
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			if line, err = r.recall(line); err != nil {
+				fmt.Fprintf(diagOut, "ERROR: %v\n", err)
+				continue
+			}
+			fmt.Fprintln(diagOut, line)
+		}
+		r.history = append(r.history, line)
+		if r.run(line, prober, session) {
+			break
+		}
+	}
+	return exitStatus
+}
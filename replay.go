@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Default address replay-serve listens on
+var defaultReplayAddress = "127.0.0.1:8080"
+
+//
+// Response headers that describe the message framing or connection
+// rather than the content, and so aren't replayed as recorded
+//
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Content-Length",
+	"Transfer-Encoding",
+	"Trailer",
+	"Upgrade",
+}
+
+//
+// recordFilename - the file in the -record directory holding the
+// response for a method and URL. Recording the same URL again replaces
+// the earlier capture.
+//
+func recordFilename(dir, method, urlstring string) string {
+
+	sum := sha256.Sum256([]byte(method + " " + urlstring))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+//
+// recordResult - save the final response of a probe to the -record
+// directory, for replay-serve. The body is saved as read, so a body
+// cut short by -max-body is recorded truncated, and a body decoded
+// with -encodings is recorded without its Content-Encoding. The
+// address connected to and the certificates the server sent are
+// recorded too, for -offline.
+//
+func recordResult(request *http.Request, address string, result *probe.ProbeResult) error {
+
+	r := newResultJSON(request, address, result)
+	r.Time = result.Start.Format(time.RFC3339Nano)
+	if cs := result.Response.TLS; cs != nil {
+		for _, cert := range cs.PeerCertificates {
+			r.TLS.Chain = append(r.TLS.Chain, cert.Raw)
+		}
+	}
+	if options.encodings != nil && result.DecodeErr == nil {
+		r.Headers = result.Response.Header.Clone()
+		delete(r.Headers, "Content-Encoding")
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(recordFilename(options.recorddir, r.Method, r.URL), data, 0644)
+}
+
+//
+// replayKey - key identifying a recorded response: the method, and
+// the URL path and query. The host isn't used, since replayed
+// responses are all served from one local address.
+//
+func replayKey(method, requesturi string) string {
+	return method + " " + requesturi
+}
+
+//
+// loadRecordings - read the responses recorded in dir
+//
+func loadRecordings(dir string) (map[string]*ResultJSON, error) {
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	recordings := make(map[string]*ResultJSON)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		r := new(ResultJSON)
+		if err := json.Unmarshal(data, r); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if r.Status == 0 {
+			continue
+		}
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if r.Method == "" {
+			r.Method = http.MethodGet
+		}
+		recordings[replayKey(r.Method, u.RequestURI())] = r
+	}
+	if len(recordings) == 0 {
+		return nil, fmt.Errorf("no recorded responses in %s", dir)
+	}
+	return recordings, nil
+}
+
+//
+// replayHandler - serve the recorded response matching each request
+//
+func replayHandler(recordings map[string]*ResultJSON) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		r, ok := recordings[replayKey(req.Method, req.URL.RequestURI())]
+		if !ok && req.Method == http.MethodHead {
+			r, ok = recordings[replayKey(http.MethodGet, req.URL.RequestURI())]
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s %s -> not recorded\n", req.Method, req.URL.RequestURI())
+			http.Error(w, "no recorded response for "+req.URL.RequestURI(), http.StatusNotFound)
+			return
+		}
+
+		body := []byte(r.Body)
+		if r.BodyBase64 != nil {
+			body = r.BodyBase64
+		}
+		for key, values := range r.Headers {
+			w.Header()[key] = values
+		}
+		for _, key := range hopHeaders {
+			w.Header().Del(key)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(r.Status)
+		if req.Method != http.MethodHead {
+			w.Write(body)
+		}
+		fmt.Fprintf(os.Stderr, "%s %s -> %d (recorded from %s)\n",
+			req.Method, req.URL.RequestURI(), r.Status, r.URL)
+	}
+}
+
+//
+// replayServe - the replay-serve command: serve the responses recorded
+// with -record from a local address. Returns the exit status.
+//
+func replayServe(args []string) int {
+
+	flags := flag.NewFlagSet("replay-serve", flag.ContinueOnError)
+	listen := flags.String("listen", defaultReplayAddress, "Loopback address to listen on")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s replay-serve [-listen addr] <dir>
+
+    Serve the responses recorded in dir with -record, matching requests
+    by method, path and query.
+
+    Options:
+	-listen addr      Loopback address to listen on (default %s)
+`, progname, defaultReplayAddress)
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+	if err := checkLoopbackAddress(*listen); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -listen: %v\n", err)
+		return ExitUsage
+	}
+
+	recordings, err := loadRecordings(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return ExitOther
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return ExitOther
+	}
+	fmt.Fprintf(os.Stderr, "Serving %d recorded responses on http://%s/\n",
+		len(recordings), listener.Addr())
+	if err := http.Serve(listener, replayHandler(recordings)); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return ExitOther
+	}
+	return ExitOK
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// FileRequest - a request defined in a -request-file
+//
+type FileRequest struct {
+	Name   string // From "### name" or "# @name name", if given
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte // nil if none
+	line   int    // Line of the request line, for errors
+}
+
+// A {{name}} or {{$processEnv NAME}} variable reference
+var requestFileVar = regexp.MustCompile(`\{\{\s*(\$processEnv\s+)?([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// An "@name = value" variable definition
+var requestFileDef = regexp.MustCompile(`^@([A-Za-z0-9_.-]+)\s*=\s*(.*)$`)
+
+//
+// expandRequestVars - substitute the file variables, and environment
+// variables referred to with {{$processEnv NAME}}, in s
+//
+func expandRequestVars(s string, vars map[string]string) (string, error) {
+
+	var err error
+	s = requestFileVar.ReplaceAllStringFunc(s, func(ref string) string {
+		m := requestFileVar.FindStringSubmatch(ref)
+		if m[1] != "" {
+			return os.Getenv(m[2])
+		}
+		value, ok := vars[m[2]]
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined variable %s", m[2])
+		}
+		return value
+	})
+	return s, err
+}
+
+//
+// parseRequestFile - read a request file in the .http / REST Client
+// format: requests separated by lines beginning "###", each a request
+// line ("METHOD URL [HTTP/1.1]", or just a URL for GET), optional
+// query continuation lines beginning "?" or "&", headers, and after a
+// blank line, the body ("< path" to read it from a file). Lines
+// beginning "#" or "//" before the body are comments, and "@name =
+// value" lines define variables used as {{name}}.
+//
+func parseRequestFile(filename string) ([]FileRequest, error) {
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	var requests []FileRequest
+	var req *FileRequest
+	var body []string
+	name := ""
+	inbody := false
+
+	finish := func() error {
+		if req == nil {
+			return nil
+		}
+		for len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "" {
+			body = body[:len(body)-1]
+		}
+		if len(body) > 0 {
+			text, err := expandRequestVars(strings.Join(body, "\n"), vars)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %v", filename, req.line, err)
+			}
+			if path := strings.TrimSpace(text); strings.HasPrefix(path, "< ") && !strings.Contains(path, "\n") {
+				path = strings.TrimSpace(path[2:])
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(filepath.Dir(filename), path)
+				}
+				if req.Body, err = ioutil.ReadFile(path); err != nil {
+					return fmt.Errorf("%s:%d: %v", filename, req.line, err)
+				}
+			} else {
+				req.Body = []byte(text)
+			}
+		}
+		if _, err := parseURL(req.URL); err != nil {
+			return fmt.Errorf("%s:%d: %v", filename, req.line, err)
+		}
+		requests = append(requests, *req)
+		req, body, name, inbody = nil, nil, "", false
+		return nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "###") {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			name = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			continue
+		}
+		if inbody {
+			body = append(body, line)
+			continue
+		}
+		switch {
+		case trimmed == "":
+			if req != nil {
+				inbody = true
+			}
+			continue
+		case strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//"):
+			comment := strings.TrimSpace(strings.TrimLeft(trimmed, "#/"))
+			if strings.HasPrefix(comment, "@name ") {
+				name = strings.TrimSpace(comment[len("@name "):])
+			}
+			continue
+		case req == nil && strings.HasPrefix(trimmed, "@"):
+			m := requestFileDef.FindStringSubmatch(trimmed)
+			if m == nil {
+				return nil, fmt.Errorf("%s:%d: invalid variable definition", filename, lineno)
+			}
+			value, err := expandRequestVars(m[2], vars)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+			}
+			vars[m[1]] = value
+			continue
+		}
+
+		line, err := expandRequestVars(trimmed, vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+		}
+		switch {
+		case req == nil:
+			fields := strings.Fields(line)
+			req = &FileRequest{Name: name, Method: http.MethodGet, Header: make(http.Header), line: lineno}
+			if len(fields) > 1 && !strings.HasPrefix(fields[1], "HTTP/") {
+				req.Method, fields = strings.ToUpper(fields[0]), fields[1:]
+			}
+			if len(fields) > 2 || (len(fields) == 2 && !strings.HasPrefix(fields[1], "HTTP/")) {
+				return nil, fmt.Errorf("%s:%d: invalid request line", filename, lineno)
+			}
+			req.URL = fields[0]
+		case len(req.Header) == 0 && (line[0] == '?' || line[0] == '&'):
+			req.URL += line
+		default:
+			key, value, err := parseHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+			}
+			req.Header.Add(key, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("%s: no requests", filename)
+	}
+	return requests, nil
+}
+
+//
+// applyFileRequest - set the request method, body and headers options
+// to those of the file's request. Headers given with -header replace
+// the file's.
+//
+func applyFileRequest(req FileRequest, headers http.Header, useragent string) {
+
+	options.method = req.Method
+	options.data = req.Body
+	options.useragent = useragent
+	options.headers = req.Header.Clone()
+	if ua := options.headers.Get("User-Agent"); ua != "" {
+		options.useragent = ua
+		options.headers.Del("User-Agent")
+	}
+	for key, values := range headers {
+		options.headers[key] = values
+	}
+}
+
+//
+// probeRequestFile - probe each of the -request-file requests in turn,
+// with a prober for its method, headers and body
+//
+func probeRequestFile(summary *Summary) {
+
+	headers, useragent := options.headers, options.useragent
+	for _, req := range options.requestfile {
+		applyFileRequest(req, headers, useragent)
+		prober, err := probe.NewProber(probeOptions())
+		if err != nil {
+			fatal(ExitOther, err)
+		}
+		if req.Name != "" && !options.bodyonly {
+			fmt.Printf("### %s\n", req.Name)
+		}
+		probeAll(prober, []string{req.URL}, summary)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// ResultJSON - machine readable form of a probe result
+//
+type ResultJSON struct {
+	Target       string              `json:"target,omitempty"`
+	URL          string              `json:"url"`
+	Method       string              `json:"method,omitempty"`
+	Address      string              `json:"address,omitempty"`
+	LocalAddress string              `json:"local_address,omitempty"`
+	Time         string              `json:"time"`
+	Error        string              `json:"error,omitempty"`
+	Status       int                 `json:"status,omitempty"`
+	Proto        string              `json:"proto,omitempty"`
+	Headers      map[string][]string `json:"headers,omitempty"`
+	TLS          *TLSJSON            `json:"tls,omitempty"`
+	HeaderTime   float64             `json:"header_time_ms"`
+	TransferTime float64             `json:"transfer_time_ms"`
+	ResponseTime float64             `json:"response_time_ms"`
+	EncodedSize  int64               `json:"encoded_size"`
+	BodySize     int64               `json:"body_size"`
+	WireBytes    int64               `json:"wire_bytes"`
+	Truncated    bool                `json:"truncated,omitempty"`
+	Digests      map[string]string   `json:"digests,omitempty"`
+	Body         string              `json:"body,omitempty"`
+	BodyBase64   []byte              `json:"body_base64,omitempty"`
+}
+
+//
+// TLSJSON - machine readable form of the TLS connection state
+//
+type TLSJSON struct {
+	Version     string     `json:"version"`
+	CipherSuite string     `json:"cipher_suite"`
+	ALPN        string     `json:"alpn,omitempty"`
+	SNI         string     `json:"sni,omitempty"`
+	Resumed     bool       `json:"resumed"`
+	Certs       []CertJSON `json:"certificates"`
+	Chain       [][]byte   `json:"chain_der,omitempty"`
+}
+
+//
+// CertJSON - machine readable form of a certificate
+//
+type CertJSON struct {
+	Subject   string   `json:"subject"`
+	Issuer    string   `json:"issuer"`
+	Serial    string   `json:"serial"`
+	NotBefore string   `json:"not_before"`
+	NotAfter  string   `json:"not_after"`
+	DNSNames  []string `json:"dns_names,omitempty"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
+
+func newTLSJSON(cs *tls.ConnectionState) *TLSJSON {
+
+	t := &TLSJSON{
+		Version:     probe.TLSversion[cs.Version],
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:        cs.NegotiatedProtocol,
+		SNI:         cs.ServerName,
+		Resumed:     cs.DidResume,
+	}
+	for _, cert := range cs.PeerCertificates {
+		t.Certs = append(t.Certs, CertJSON{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			Serial:    cert.SerialNumber.Text(16),
+			NotBefore: formatMachineTime(cert.NotBefore),
+			NotAfter:  formatMachineTime(cert.NotAfter),
+			DNSNames:  cert.DNSNames,
+		})
+	}
+	return t
+}
+
+//
+// newResultJSON - the machine readable form of a result for request,
+// made to address (empty if the hostname's address was used). The
+// address actually connected to is reported instead, if known.
+//
+func newResultJSON(request *http.Request, address string, result *probe.ProbeResult) *ResultJSON {
+
+	r := &ResultJSON{
+		URL:          request.URL.String(),
+		Method:       request.Method,
+		Address:      address,
+		LocalAddress: result.LocalAddr,
+		Time:         formatMachineTime(result.Start),
+		HeaderTime:   milliseconds(result.HeaderTime),
+		TransferTime: milliseconds(result.TransferTime),
+		ResponseTime: milliseconds(result.ResponseTime),
+		EncodedSize:  result.EncodedSize,
+		BodySize:     result.BodySize,
+		WireBytes:    result.WireBytes,
+		Truncated:    result.Truncated,
+	}
+	if result.RemoteAddr != "" {
+		r.Address = result.RemoteAddr
+	}
+	if result.Err != nil {
+		r.Error = result.Err.Error()
+	}
+	if result.Response == nil {
+		return r
+	}
+
+	response := result.Response
+	r.URL = response.Request.URL.String()
+	r.Status = response.StatusCode
+	r.Proto = response.Proto
+	r.Headers = response.Header
+	if response.TLS != nil {
+		r.TLS = newTLSJSON(response.TLS)
+	}
+	if result.Digests != nil {
+		r.Digests = make(map[string]string)
+		for name, sum := range result.Digests {
+			r.Digests[name] = hex.EncodeToString(sum)
+		}
+	}
+	if utf8.Valid(result.Body) {
+		r.Body = string(result.Body)
+	} else {
+		r.BodyBase64 = result.Body
+	}
+	return r
+}
+
+//
+// ndjsonLine - the -ndjson line for a result: its JSON form without the
+// body, with the URL requested as the target, since the url is the
+// final one after redirects
+//
+func ndjsonLine(request *http.Request, address string, result *probe.ProbeResult) string {
+
+	r := newResultJSON(request, address, result)
+	r.Target = request.URL.String()
+	r.Body, r.BodyBase64 = "", nil
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Sprintf(`{"target":%q,"error":%q}`, r.Target, err)
+	}
+	return string(line)
+}
+
+//
+// errorResultJSON - the result object for a URL that couldn't be
+// probed, e.g. because its hostname didn't resolve
+//
+func errorResultJSON(urlstring string, err error) *ResultJSON {
+
+	return &ResultJSON{Target: urlstring, URL: urlstring,
+		Time: formatMachineTime(time.Now()), Error: err.Error()}
+}
+
+//
+// ndjsonError - the -ndjson line for a URL that couldn't be probed
+//
+func ndjsonError(urlstring string, err error) string {
+
+	line, _ := json.Marshal(errorResultJSON(urlstring, err))
+	return string(line)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Retry defaults: what is retried, and the backoff
+var (
+	defaultRetryOn       = "connect,timeout"
+	defaultRetryDelay    = time.Second
+	defaultRetryMaxDelay = 30 * time.Second
+)
+
+// Error classes -retry-on takes, by the exit code classifyError gives
+var retryErrorClasses = map[string]int{
+	"dns":     ExitDNS,
+	"connect": ExitConnect,
+	"timeout": ExitTimeout,
+	"tls":     ExitTLS,
+}
+
+// Status classes -retry-on takes: a status code, or a class like 5xx
+var retryStatusClass = regexp.MustCompile(`^[1-5]([0-9][0-9]|xx)$`)
+
+//
+// parseRetryOn - the conditions in a -retry-on list, e.g.
+// "5xx,connect,timeout,429": error classes, "error" for any error,
+// status codes and status classes
+//
+func parseRetryOn(list string) (map[string]bool, error) {
+
+	on := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if _, ok := retryErrorClasses[item]; !ok && item != "error" &&
+			!retryStatusClass.MatchString(item) {
+			return nil, fmt.Errorf("-retry-on: unknown condition %q (dns, connect, timeout, tls, error, a status code or 5xx)", item)
+		}
+		on[item] = true
+	}
+	return on, nil
+}
+
+//
+// retryCondition - the -retry-on condition the result meets, or ""
+// if it shouldn't be retried
+//
+func retryCondition(result *probe.ProbeResult) string {
+
+	on := options.retryon
+	if result.Err != nil {
+		status := classifyError(result.Err)
+		for name, code := range retryErrorClasses {
+			if code == status && on[name] {
+				return name
+			}
+		}
+		if status == ExitCertError && on["tls"] {
+			return "tls"
+		}
+		if status != ExitInterrupted && on["error"] {
+			return "error"
+		}
+		return ""
+	}
+	code := strconv.Itoa(result.Response.StatusCode)
+	switch {
+	case on[code]:
+		return code
+	case on[code[:1]+"xx"]:
+		return code[:1] + "xx"
+	}
+	return ""
+}
+
+//
+// retryAfter - the wait a Retry-After header field asks for, in delay
+// seconds or as an HTTP date, and whether there was one
+//
+func retryAfter(header http.Header) (time.Duration, bool) {
+
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+//
+// backoff - how long to wait before retry n (from 1): -retry-delay,
+// doubled for each retry after the first, up to -retry-max-delay, with
+// "equal jitter": a random half of it, so that clients retrying at once
+// spread out. A Retry-After in the response is honored instead, up to
+// -retry-max-delay.
+//
+func backoff(n int, result *probe.ProbeResult) (time.Duration, string) {
+
+	if result.Response != nil {
+		if wait, ok := retryAfter(result.Response.Header); ok {
+			if wait > options.retrymax {
+				return options.retrymax, fmt.Sprintf("Retry-After %s, capped by -retry-max-delay", fmtDuration(wait))
+			}
+			return wait, "Retry-After"
+		}
+	}
+	delay := options.retrydelay
+	for i := 1; i < n && delay < options.retrymax; i++ {
+		delay *= 2
+	}
+	if delay > options.retrymax {
+		delay = options.retrymax
+	}
+	if half := int64(delay / 2); half > 0 {
+		delay = time.Duration(half + rand.Int63n(half+1))
+	}
+	return delay.Round(time.Millisecond), "backoff"
+}
+
+//
+// attemptOutcome - how an attempt ended, for its log line
+//
+func attemptOutcome(result *probe.ProbeResult) string {
+
+	if result.Err != nil {
+		return result.Err.Error()
+	}
+	return result.Response.Status
+}
+
+//
+// retryResponse - send the request, and with -r N, send it again up to
+// N times while the result meets a -retry-on condition, waiting with
+// exponential backoff between attempts. Each attempt after the first
+// failed one is logged on the report. It stops early when the run is
+// interrupted or out of time, or if the request body can't be sent
+// again. Returns the last attempt's result.
+//
+func retryResponse(w *Report, session *probe.Session, request *http.Request) *probe.ProbeResult {
+
+	attempts := options.retries + 1
+	req := request
+	for n := 1; ; n++ {
+		result := readResponse(session, req)
+		condition := retryCondition(result)
+		switch {
+		case n > 1 && condition == "":
+			fmt.Fprintf(w, "## Attempt %d/%d: %s\n", n, attempts, attemptOutcome(result))
+			return result
+		case condition == "" || request.Context().Err() != nil:
+			return result
+		case n == attempts:
+			if n > 1 {
+				fmt.Fprintf(w, "## Attempt %d/%d: %s (%s); no retries left\n",
+					n, attempts, attemptOutcome(result), condition)
+			}
+			return result
+		case request.Body != nil && request.GetBody == nil:
+			fmt.Fprintf(w, "## Attempt %d/%d: %s (%s); not retried, the request body can't be sent again\n",
+				n, attempts, attemptOutcome(result), condition)
+			return result
+		}
+
+		wait, why := backoff(n, result)
+		fmt.Fprintf(w, "## Attempt %d/%d: %s (%s); retrying in %s (%s)\n",
+			n, attempts, attemptOutcome(result), condition, fmtDuration(wait), why)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-request.Context().Done():
+			timer.Stop()
+			fmt.Fprintf(w, "## Retries abandoned: stopped by %s\n", stopReason())
+			return result
+		}
+
+		req = request.Clone(request.Context())
+		if request.GetBody != nil {
+			req.Body, _ = request.GetBody()
+		}
+	}
+}
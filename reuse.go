@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// keepAliveParams - the parameters of a Keep-Alive header, e.g.
+// "timeout=5, max=100"
+//
+func keepAliveParams(header http.Header) map[string]string {
+
+	params := make(map[string]string)
+	for _, value := range header.Values("Keep-Alive") {
+		for _, param := range strings.Split(value, ",") {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 {
+				params[strings.ToLower(kv[0])] = unquote(kv[1])
+			}
+		}
+	}
+	return params
+}
+
+//
+// checkReuse - send the request twice in a row on a new session, and
+// report whether the second reused the first's connection, how long
+// the server says it keeps idle connections, and how much faster the
+// warm request was than the cold one
+//
+func checkReuse(w io.Writer, prober *probe.Prober, request *http.Request, address string) {
+
+	fmt.Fprintln(w, "## Connection Reuse:")
+	session := prober.NewSession(address)
+	var results [2]*probe.ProbeResult
+	for i := range results {
+		req := request.Clone(request.Context())
+		if request.GetBody != nil {
+			req.Body, _ = request.GetBody()
+		}
+		results[i] = readResponse(session, req)
+		if results[i].Err != nil {
+			fmt.Fprintf(w, "   Request %d failed: %v\n", i+1, results[i].Err)
+			return
+		}
+	}
+	cold, warm := results[0], results[1]
+
+	response := cold.Response
+	fmt.Fprintf(w, "   Protocol: %s\n", response.Proto)
+	if response.Close {
+		fmt.Fprintln(w, "   Connection: close (the server won't keep the connection open)")
+	}
+	params := keepAliveParams(response.Header)
+	if timeout, ok := params["timeout"]; ok {
+		fmt.Fprintf(w, "   Keep-Alive timeout: %ss\n", timeout)
+	} else if response.ProtoMajor == 1 {
+		fmt.Fprintln(w, "   Keep-Alive timeout: not advertised")
+	}
+	if max, ok := params["max"]; ok {
+		fmt.Fprintf(w, "   Keep-Alive max requests: %s\n", max)
+	}
+
+	fmt.Fprintf(w, "   Cold request: %s, connection %s\n", fmtDuration(cold.ResponseTime), cold.RemoteAddr)
+	fmt.Fprintf(w, "   Warm request: %s, connection %s\n", fmtDuration(warm.ResponseTime), warm.RemoteAddr)
+	if !warm.Reused {
+		fmt.Fprintln(w, "   Result: NOT REUSED (the second request made a new connection)")
+		return
+	}
+	fmt.Fprintf(w, "   Result: REUSED after %s idle\n", fmtDuration(warm.IdleTime))
+	saved := cold.ResponseTime - warm.ResponseTime
+	if saved < 0 {
+		fmt.Fprintf(w, "   Warm request was %s slower\n", fmtDuration(-saved))
+		return
+	}
+	fmt.Fprintf(w, "   Warm request was %s faster (%.0f%%)\n", fmtDuration(saved),
+		100*float64(saved)/float64(cold.ResponseTime))
+}
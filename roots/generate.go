@@ -0,0 +1,138 @@
+//go:build ignore
+// +build ignore
+
+//
+// generate - write mozilla.pem, the bundle for gohttp -roots mozilla,
+// from a PEM bundle of Mozilla's CA certificates trusted for server
+// authentication, such as the curl project's extract of certdata.txt.
+// Certificates that have expired are left out. Run by go generate in
+// the top directory:
+//
+//	go run roots/generate.go [-source description] [-o file] <file or URL>
+//
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Where curl publishes its extract of Mozilla's certdata.txt
+const defaultBundle = "https://curl.se/ca/cacert.pem"
+
+//
+// readBundle - read the bundle from a file or an http(s) URL
+//
+func readBundle(name string) ([]byte, error) {
+
+	if !strings.HasPrefix(name, "https://") && !strings.HasPrefix(name, "http://") {
+		return ioutil.ReadFile(name)
+	}
+	response, err := http.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", name, response.Status)
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+//
+// label - the name to list a certificate under
+//
+func label(cert *x509.Certificate) string {
+
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.Subject.OrganizationalUnit) > 0 {
+		return cert.Subject.OrganizationalUnit[0]
+	}
+	return cert.Subject.String()
+}
+
+func main() {
+
+	source := flag.String("source", "", "Description of the bundle, for the header (default its name)")
+	outfile := flag.String("o", "roots/mozilla.pem", "File to write")
+	flag.Parse()
+
+	name := defaultBundle
+	if flag.NArg() == 1 {
+		name = flag.Arg(0)
+	} else if flag.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "Usage: go run roots/generate.go [-source description] [-o file] [<file or URL>]\n")
+		os.Exit(2)
+	}
+	if *source == "" {
+		*source = name
+	}
+
+	data, err := readBundle(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	now := time.Now().UTC()
+	var body bytes.Buffer
+	var kept, expired int
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		if now.After(cert.NotAfter) {
+			fmt.Fprintf(os.Stderr, "Dropping %s: expired %s\n", label(cert), cert.NotAfter.Format("2006-01-02"))
+			expired++
+			continue
+		}
+		l := label(cert)
+		fmt.Fprintf(&body, "\n%s\n%s\n", l, strings.Repeat("=", len(l)))
+		pem.Encode(&body, block)
+		kept++
+	}
+	if kept == 0 {
+		fmt.Fprintf(os.Stderr, "ERROR: %s: no certificates\n", name)
+		os.Exit(1)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, `##
+## Mozilla CA certificates trusted for server authentication, bundled
+## for gohttp -roots mozilla.
+##
+## Source: %s
+## Generated %s by roots/generate.go: %d certificates, leaving
+## out %d that had expired. To update, run go generate in the top
+## directory, which fetches %s
+## (curl's extract of Mozilla's certdata.txt).
+##
+`, *source, now.Format("2006-01-02"), kept, expired, defaultBundle)
+	io.Copy(&out, &body)
+
+	if err := ioutil.WriteFile(*outfile, out.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Abort and reconnect cycles made by the flaky-client scenario
+var scenarioCycles = 5
+
+//
+// ScenarioStep - the outcome of one step of a scenario
+//
+type ScenarioStep struct {
+	name    string
+	ok      bool
+	detail  string
+	elapsed time.Duration
+}
+
+//
+// Scenario - a scripted sequence of client behaviors, run against the
+// request's target on new connections made by session
+//
+type Scenario func(session *probe.Session, request *http.Request) []ScenarioStep
+
+// Scenarios available to -scenario, by name
+var scenarios = map[string]Scenario{
+	"flaky-client": flakyClient,
+}
+
+//
+// scenarioNames - the names of the available scenarios, for usage and
+// error messages
+//
+func scenarioNames() string {
+
+	var names []string
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+//
+// step - time fn, and return its outcome as a ScenarioStep
+//
+func step(name string, fn func() (bool, string)) ScenarioStep {
+
+	start := time.Now()
+	ok, detail := fn()
+	return ScenarioStep{name: name, ok: ok, detail: detail, elapsed: time.Since(start)}
+}
+
+//
+// flakyClient - abort transfers after the headers and midway through
+// the body, reconnect immediately and repeatedly, and resume an aborted
+// download with a Range request, checking the server still answers
+// each time and that the resumed body matches the original.
+//
+func flakyClient(session *probe.Session, request *http.Request) []ScenarioStep {
+
+	var steps []ScenarioStep
+
+	var status int
+	var body []byte
+	steps = append(steps, step("baseline request", func() (bool, string) {
+		response, data, err := session.ReadPartial(request, -1)
+		if err != nil {
+			return false, err.Error()
+		}
+		status, body = response.StatusCode, data
+		return true, fmt.Sprintf("%d, %d bytes", status, len(body))
+	}))
+	if status == 0 {
+		return steps
+	}
+
+	reconnect := func() (bool, string) {
+		response, data, err := session.ReadPartial(request, -1)
+		switch {
+		case err != nil:
+			return false, err.Error()
+		case response.StatusCode != status:
+			return false, fmt.Sprintf("status %d, expected %d", response.StatusCode, status)
+		case !bytes.Equal(data, body):
+			return false, fmt.Sprintf("%d, body differs from baseline", response.StatusCode)
+		}
+		return true, fmt.Sprintf("%d, %d bytes", response.StatusCode, len(data))
+	}
+
+	steps = append(steps, step("abort after headers", func() (bool, string) {
+		response, _, err := session.ReadPartial(request, 0)
+		if err != nil {
+			return false, err.Error()
+		}
+		return true, fmt.Sprintf("%d, connection reset", response.StatusCode)
+	}))
+	steps = append(steps, step("immediate reconnect", reconnect))
+
+	half := int64(len(body) / 2)
+	var prefix []byte
+	steps = append(steps, step(fmt.Sprintf("abort mid-body (%d bytes)", half), func() (bool, string) {
+		response, data, err := session.ReadPartial(request, half)
+		if err != nil {
+			return false, err.Error()
+		}
+		prefix = data
+		return true, fmt.Sprintf("%d, %d bytes, connection reset", response.StatusCode, len(data))
+	}))
+	steps = append(steps, step("immediate reconnect", reconnect))
+
+	steps = append(steps, step(fmt.Sprintf("%d abort/reconnect cycles", scenarioCycles), func() (bool, string) {
+		good := 0
+		var last string
+		for i := 0; i < scenarioCycles; i++ {
+			session.ReadPartial(request, 0)
+			ok, detail := reconnect()
+			if ok {
+				good++
+			} else {
+				last = detail
+			}
+		}
+		if good < scenarioCycles {
+			return false, fmt.Sprintf("%d/%d reconnects succeeded, last failure: %s",
+				good, scenarioCycles, last)
+		}
+		return true, fmt.Sprintf("%d/%d reconnects succeeded", good, scenarioCycles)
+	}))
+
+	steps = append(steps, step("resume aborted download", func() (bool, string) {
+		if status != http.StatusOK || half == 0 {
+			return false, "skipped: baseline was not a 200 with a body"
+		}
+		resume := request.Clone(request.Context())
+		resume.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(prefix)))
+		response, rest, err := session.ReadPartial(resume, -1)
+		if err != nil {
+			return false, err.Error()
+		}
+		if response.StatusCode != http.StatusPartialContent {
+			return false, fmt.Sprintf("%d, range not honored", response.StatusCode)
+		}
+		whole := sha256.Sum256(append(append([]byte{}, prefix...), rest...))
+		if whole != sha256.Sum256(body) {
+			return false, fmt.Sprintf("206 (%s), resumed body differs from baseline",
+				response.Header.Get("Content-Range"))
+		}
+		return true, fmt.Sprintf("206 (%s), resumed body matches",
+			response.Header.Get("Content-Range"))
+	}))
+
+	return steps
+}
+
+//
+// runScenario - run the -scenario and summarize how the server coped
+//
+func runScenario(w io.Writer, session *probe.Session, request *http.Request) {
+
+	fmt.Fprintf(w, "## Scenario: %s\n", options.scenario)
+	steps := scenarios[options.scenario](session, request)
+	passed := 0
+	for _, s := range steps {
+		result := "FAIL"
+		if s.ok {
+			result = "OK"
+			passed++
+		}
+		fmt.Fprintf(w, "   %-30s %-4s %s (%s)\n", s.name+":", result, s.detail,
+			fmtDuration(s.elapsed))
+	}
+	fmt.Fprintf(w, "   Result: %d/%d steps OK\n", passed, len(steps))
+	if passed < len(steps) {
+		setExitStatus(ExitAssertion)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+	"go.starlark.net/lib/json"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+//
+// Script - a Starlark script run against each response, given with
+// -script. The script sees the response as "resp" (and its headers
+// as "header"), and reports with:
+//
+//	check(cond, msg)  record an assertion, which fails if cond is false
+//	warn(msg)         record a warning
+//	get(url)          make another request, returning its response
+//	fail(msg)         stop the script, failing it
+//	print(...)        print a line in the report
+//
+// json.decode() is available to parse JSON bodies.
+//
+type Script struct {
+	filename string
+	program  *starlark.Program
+}
+
+// Names the script may use besides the Starlark builtins
+var scriptPredeclared = map[string]bool{
+	"resp":   true,
+	"header": true,
+	"check":  true,
+	"warn":   true,
+	"get":    true,
+	"json":   true,
+}
+
+//
+// loadScript - read and compile a -script file, so that syntax errors
+// are reported before any requests are made
+//
+func loadScript(filename string) (*Script, error) {
+
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// Scripts are flat sequences of checks, so allow if and for
+	// statements outside of functions.
+	resolve.AllowGlobalReassign = true
+	_, program, err := starlark.SourceProgram(filename, src,
+		func(name string) bool { return scriptPredeclared[name] })
+	if err != nil {
+		return nil, err
+	}
+	return &Script{filename: filename, program: program}, nil
+}
+
+//
+// scriptTLS - the TLS connection state as a Starlark struct
+//
+func scriptTLS(response *http.Response) starlark.Value {
+
+	cs := response.TLS
+	if cs == nil {
+		return starlark.None
+	}
+	fields := starlark.StringDict{
+		"version": starlark.String(probe.TLSversion[cs.Version]),
+		"cipher":  starlark.String(tls.CipherSuiteName(cs.CipherSuite)),
+		"alpn":    starlark.String(cs.NegotiatedProtocol),
+		"sni":     starlark.String(cs.ServerName),
+		"resumed": starlark.Bool(cs.DidResume),
+	}
+	if len(cs.PeerCertificates) > 0 {
+		cert := cs.PeerCertificates[0]
+		fields["cert_subject"] = starlark.String(cert.Subject.String())
+		fields["cert_issuer"] = starlark.String(cert.Issuer.String())
+		fields["cert_days_left"] = starlark.MakeInt(int(time.Until(cert.NotAfter).Hours() / 24))
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, fields)
+}
+
+//
+// scriptHeaders - response headers as a Starlark dict, keyed by the
+// canonical header name, with multiple values joined by commas
+//
+func scriptHeaders(header http.Header) *starlark.Dict {
+
+	dict := starlark.NewDict(len(header))
+	for key, values := range header {
+		dict.SetKey(starlark.String(key), starlark.String(strings.Join(values, ",")))
+	}
+	return dict
+}
+
+//
+// scriptResponse - a result as a Starlark struct
+//
+func scriptResponse(result *probe.ProbeResult) starlark.Value {
+
+	fields := starlark.StringDict{
+		"error":   starlark.None,
+		"status":  starlark.MakeInt(0),
+		"time_ms": starlark.Float(milliseconds(result.ResponseTime)),
+	}
+	if result.Err != nil {
+		fields["error"] = starlark.String(result.Err.Error())
+	}
+	if response := result.Response; response != nil {
+		fields["url"] = starlark.String(response.Request.URL.String())
+		fields["status"] = starlark.MakeInt(response.StatusCode)
+		fields["proto"] = starlark.String(response.Proto)
+		fields["header"] = scriptHeaders(response.Header)
+		fields["body"] = starlark.String(result.Body)
+		fields["size"] = starlark.MakeInt64(result.BodySize)
+		fields["ttfb_ms"] = starlark.Float(milliseconds(result.HeaderTime))
+		fields["tls"] = scriptTLS(response)
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, fields)
+}
+
+//
+// scriptPosition - the position in the script of the innermost call
+// frame that isn't in a builtin, where an error occurred
+//
+func scriptPosition(stack starlark.CallStack) string {
+
+	for i := 0; i < len(stack); i++ {
+		if pos := stack.At(i).Pos; pos.Filename() != "<builtin>" {
+			return pos.String()
+		}
+	}
+	return "?"
+}
+
+//
+// run - run the script against a result, writing what it reports
+// to w. Returns false if any check failed or the script failed.
+//
+func (s *Script) run(w io.Writer, prober *probe.Prober, result *probe.ProbeResult) bool {
+
+	passed := true
+
+	check := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var cond starlark.Value
+		var msg string
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &cond, &msg); err != nil {
+			return nil, err
+		}
+		if cond.Truth() {
+			fmt.Fprintf(w, "   PASS  %s\n", msg)
+		} else {
+			fmt.Fprintf(w, "   FAIL  %s\n", msg)
+			passed = false
+		}
+		return starlark.None, nil
+	}
+
+	warn := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var msg string
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &msg); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(w, "   WARNING: %s\n", msg)
+		return starlark.None, nil
+	}
+
+	get := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var urlstring string
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &urlstring); err != nil {
+			return nil, err
+		}
+		if _, err := parseURL(urlstring); err != nil {
+			return nil, err
+		}
+		request, err := prober.NewRequest(http.MethodGet, urlstring)
+		if err != nil {
+			return nil, err
+		}
+		applyNetrc(request)
+		return scriptResponse(readResponse(prober.NewSession(""), request)), nil
+	}
+
+	resp := scriptResponse(result)
+	header, _ := resp.(*starlarkstruct.Struct).Attr("header")
+
+	predeclared := starlark.StringDict{
+		"resp":   resp,
+		"header": header,
+		"check":  starlark.NewBuiltin("check", check),
+		"warn":   starlark.NewBuiltin("warn", warn),
+		"get":    starlark.NewBuiltin("get", get),
+		"json":   json.Module,
+	}
+	thread := &starlark.Thread{
+		Name: s.filename,
+		Print: func(thread *starlark.Thread, msg string) {
+			fmt.Fprintf(w, "   %s\n", msg)
+		},
+	}
+
+	if _, err := s.program.Init(thread, predeclared); err != nil {
+		var evalerr *starlark.EvalError
+		if errors.As(err, &evalerr) {
+			err = fmt.Errorf("%s: %s", scriptPosition(evalerr.CallStack), evalerr.Msg)
+		}
+		fmt.Fprintf(w, "   FAIL  %v\n", err)
+		passed = false
+	}
+	return passed
+}
+
+//
+// printScript - run the -script against the result. A failed script
+// sets exit status 1, like a failed assertion.
+//
+func printScript(w io.Writer, prober *probe.Prober, result *probe.ProbeResult) {
+
+	fmt.Fprintf(w, "## Script: %s\n", options.script.filename)
+	if !options.script.run(w, prober, result) {
+		setExitStatus(ExitAssertion)
+	}
+}
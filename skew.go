@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Default -skew-threshold
+const defaultSkewThreshold = 5 * time.Second
+
+//
+// clockSkew - estimate how far the server's clock is ahead of ours
+// (negative if behind) from the response's Date header. The server
+// stamped it some time between our sending the request and receiving
+// the headers, and it has a resolution of one second, so the skew lies
+// within the bounds returned; the estimate is their midpoint.
+//
+func clockSkew(result *probe.ProbeResult) (estimate, low, high time.Duration, err error) {
+
+	date, err := http.ParseTime(result.Response.Header.Get("Date"))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	received := result.Start.Add(result.HeaderTime)
+	low = date.Sub(received)
+	high = date.Add(time.Second).Sub(result.Start)
+	return (low + high) / 2, low, high, nil
+}
+
+//
+// printClockSkew - report the server's clock skew, as estimated from
+// the Date header, warning if it is certainly more than -skew-threshold
+//
+func printClockSkew(w io.Writer, result *probe.ProbeResult) {
+
+	date := result.Response.Header.Get("Date")
+	if date == "" {
+		fmt.Fprintln(w, "## Clock Skew: UNKNOWN (no Date header)")
+		return
+	}
+	estimate, low, high, err := clockSkew(result)
+	if err != nil {
+		fmt.Fprintf(w, "## Clock Skew: UNKNOWN (invalid Date %q)\n", date)
+		return
+	}
+
+	fmt.Fprintln(w, "## Clock Skew:")
+	fmt.Fprintf(w, "   Server Date: %s\n", date)
+	fmt.Fprintf(w, "   Local time: %s\n", formatTime(result.Start.Add(result.HeaderTime).Round(time.Millisecond)))
+	direction := "ahead of"
+	if estimate < 0 {
+		direction = "behind"
+		estimate = -estimate
+	}
+	fmt.Fprintf(w, "   Skew: server %s %s local time (+/- %s)\n",
+		fmtDuration(estimate), direction, fmtDuration((high-low)/2))
+	threshold := options.skewthreshold
+	if low > threshold || high < -threshold {
+		fmt.Fprintf(w, "   WARNING: skew exceeds %s: certificates may seem not yet valid or\n", threshold)
+		fmt.Fprintln(w, "            expired, and cookies expire early or late")
+	}
+}
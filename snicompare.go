@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// SNI sent to see what a server presents for a name it doesn't serve
+const bogusSNI = "gohttp-sni-test.invalid"
+
+//
+// SNICert - the leaf certificate presented for one SNI value
+//
+type SNICert struct {
+	Label       string // What was sent, for the report
+	ServerName  string // SNI sent, "" for none
+	Fingerprint string // SHA-256 of the certificate
+	Subject     string
+	Names       []string // SAN dNSNames
+	Err         error
+}
+
+//
+// sniCert - connect to address, send serverName as SNI (none if ""),
+// and collect the leaf certificate presented. Verification is left to
+// the caller, so that mismatched certificates are still reported.
+//
+func sniCert(address, serverName, label string) *SNICert {
+
+	c := &SNICert{Label: label, ServerName: serverName}
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: options.timeout},
+		Config:    &tls.Config{ServerName: serverName, InsecureSkipVerify: true},
+	}
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		c.Err = err
+		return c
+	}
+	defer conn.Close()
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		c.Err = fmt.Errorf("no certificate presented")
+		return c
+	}
+	sum := sha256.Sum256(certs[0].Raw)
+	c.Fingerprint = fmt.Sprintf("%x", sum)
+	c.Subject = certs[0].Subject.String()
+	c.Names = certs[0].DNSNames
+	return c
+}
+
+//
+// compareSNI - connect to the server again three times, sending the SNI
+// name, no SNI, and a name it can't serve, and compare the certificates
+// presented, which shows whether the default virtual host (the one
+// clients without SNI reach) and unknown names get the right certificate
+//
+func compareSNI(w io.Writer, request *http.Request, address, remote string) {
+
+	hostname := strings.TrimSuffix(request.URL.Hostname(), ".")
+	name := hostname
+	if options.sni != "" {
+		name = strings.TrimSuffix(options.sni, ".")
+	}
+	fmt.Fprintln(w, "## SNI Comparison:")
+	if request.URL.Scheme != "https" {
+		fmt.Fprintln(w, "   Not an https URL")
+		return
+	}
+	if net.ParseIP(name) != nil {
+		fmt.Fprintln(w, "   No SNI is sent for an IP address")
+		return
+	}
+	if remote != "" {
+		address = remote
+	}
+
+	certs := []*SNICert{
+		sniCert(address, name, "SNI "+name),
+		sniCert(address, "", "No SNI"),
+		sniCert(address, bogusSNI, "SNI "+bogusSNI),
+	}
+	for _, c := range certs {
+		fmt.Fprintf(w, "   %s:\n", c.Label)
+		if c.Err != nil {
+			fmt.Fprintf(w, "      ERROR: %v\n", c.Err)
+			continue
+		}
+		fmt.Fprintf(w, "      Subject: %s\n", c.Subject)
+		fmt.Fprintf(w, "      Names: %s\n", strings.Join(c.Names, " "))
+		fmt.Fprintf(w, "      SHA-256: %s\n", c.Fingerprint)
+	}
+
+	sni := certs[0]
+	if sni.Err != nil {
+		return
+	}
+	for _, c := range certs[1:] {
+		switch {
+		case c.Err != nil:
+			fmt.Fprintf(w, "   %s: handshake failed, the server has no default certificate\n", c.Label)
+		case c.Fingerprint == sni.Fingerprint:
+			fmt.Fprintf(w, "   %s: same certificate\n", c.Label)
+		case nameCovered(c.Names, hostname):
+			fmt.Fprintf(w, "   %s: different certificate, also valid for %s\n", c.Label, hostname)
+		default:
+			fmt.Fprintf(w, "   %s: DIFFERENT certificate, not valid for %s\n", c.Label, hostname)
+		}
+	}
+}
+
+//
+// nameCovered - does one of the certificate's names, which may be
+// wildcards, match hostname?
+//
+func nameCovered(names []string, hostname string) bool {
+
+	hostname = strings.ToLower(hostname)
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if name == hostname {
+			return true
+		}
+		if strings.HasPrefix(name, "*.") {
+			i := strings.IndexByte(hostname, '.')
+			if i > 0 && hostname[i+1:] == name[2:] {
+				return true
+			}
+		}
+	}
+	return false
+}
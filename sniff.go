@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+//
+// textualType - is the media type one whose content is text?
+//
+func textualType(mediatype string) bool {
+
+	switch {
+	case strings.HasPrefix(mediatype, "text/"):
+		return true
+	case mediatype == "application/json" || strings.HasSuffix(mediatype, "+json"):
+		return true
+	case mediatype == "application/xml" || strings.HasSuffix(mediatype, "+xml"):
+		return true
+	case mediatype == "application/javascript" || mediatype == "application/ecmascript":
+		return true
+	}
+	return false
+}
+
+//
+// xmlType - is the media type an XML one?
+//
+func xmlType(mediatype string) bool {
+	return mediatype == "text/xml" || mediatype == "application/xml" || strings.HasSuffix(mediatype, "+xml")
+}
+
+//
+// sniffMismatch - does the sniffed media type contradict the declared
+// one? The sniffer only recognizes some formats: text it can't place is
+// text/plain, and anything else application/octet-stream, so those only
+// contradict declared types of the other kind.
+//
+func sniffMismatch(declared, sniffed string) bool {
+
+	switch {
+	case declared == sniffed:
+		return false
+	case sniffed == "application/octet-stream":
+		return textualType(declared)
+	case sniffed == "text/plain":
+		return !textualType(declared)
+	case xmlType(declared) && xmlType(sniffed):
+		return false
+	}
+	return true
+}
+
+//
+// printSniffCheck - compare the declared Content-Type with what the
+// first 512 bytes of the body look like, as browsers' content sniffing
+// would, and warn when they disagree, or a text type has no charset
+//
+func printSniffCheck(w io.Writer, response *http.Response, body []byte) {
+
+	fmt.Fprintln(w, "## Content Type Check:")
+	declared := response.Header.Get("Content-Type")
+	if len(body) == 0 {
+		fmt.Fprintf(w, "   Declared: %s\n", declared)
+		fmt.Fprintln(w, "   Empty body, nothing to sniff")
+		return
+	}
+	sniffed := http.DetectContentType(body)
+	sniffedtype, _, _ := mime.ParseMediaType(sniffed)
+	nosniff := strings.EqualFold(strings.TrimSpace(response.Header.Get("X-Content-Type-Options")), "nosniff")
+
+	if declared == "" {
+		fmt.Fprintln(w, "   Declared: none")
+		fmt.Fprintf(w, "   Sniffed: %s\n", sniffed)
+		fmt.Fprintln(w, "   WARNING: no Content-Type, browsers will guess from the content")
+		return
+	}
+	fmt.Fprintf(w, "   Declared: %s\n", declared)
+	fmt.Fprintf(w, "   Sniffed: %s\n", sniffed)
+	mediatype, params, err := mime.ParseMediaType(declared)
+	if err != nil {
+		fmt.Fprintf(w, "   WARNING: invalid Content-Type: %v\n", err)
+		return
+	}
+
+	ok := true
+	if sniffMismatch(mediatype, sniffedtype) {
+		ok = false
+		fmt.Fprintf(w, "   WARNING: the content looks like %s, not %s\n", sniffedtype, mediatype)
+		if !nosniff {
+			fmt.Fprintln(w, "   WARNING: without X-Content-Type-Options: nosniff, browsers may sniff it")
+		}
+	}
+	if strings.HasPrefix(mediatype, "text/") && params["charset"] == "" {
+		ok = false
+		fmt.Fprintf(w, "   WARNING: %s without a charset parameter\n", mediatype)
+	}
+	if ok {
+		fmt.Fprintln(w, "   OK: consistent with the content")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// printEvent - print an event with its arrival time and the gap since
+// the previous one (or since the response headers, for the first)
+//
+func printEvent(w io.Writer, n int, event *probe.SSEEvent, gap time.Duration) {
+
+	fmt.Fprintf(w, "   #%d %s (+%s) event: %s", n, formatTime(event.Arrived), fmtDuration(gap), event.Type)
+	if event.ID != "" {
+		fmt.Fprintf(w, " id: %s", event.ID)
+	}
+	fmt.Fprintln(w)
+	for _, line := range strings.Split(event.Data, "\n") {
+		fmt.Fprintf(w, "      %s\n", line)
+	}
+}
+
+//
+// sseSingle - make the request for a Server-Sent Events stream, print
+// the response headers, and then each event as it arrives, until the
+// server ends the stream, or the -sse-duration or -sse-count limit is
+// reached. The report is flushed after each event, so that it can be
+// followed. Returns the result.
+//
+func sseSingle(w *Report, prober *probe.Prober, request *http.Request, address string) *probe.ProbeResult {
+
+	session := prober.NewSession(address)
+	statProbes.Add(1)
+	result, stream := session.OpenEvents(request, options.sseduration)
+	if result.Err != nil {
+		statProbeErrors.Add(1)
+		setExitStatus(classifyError(result.Err))
+		fmt.Fprintln(w, result.Err)
+		return result
+	}
+	defer stream.Close()
+
+	response := result.Response
+	fmt.Fprintf(w, "## ResponseTime: %s\n", fmtDuration(result.HeaderTime))
+	printConnection(w, result)
+	printTLSinfo(w, response)
+	printStatus(w, response)
+	printHeaders(w, response.Header)
+	if options.failhttp && response.StatusCode >= 400 {
+		setExitStatus(ExitHTTPError)
+	}
+	if ctype, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); ctype != "text/event-stream" {
+		fmt.Fprintf(w, "## Events: NONE (Content-Type %q is not text/event-stream)\n",
+			response.Header.Get("Content-Type"))
+		setExitStatus(ExitAssertion)
+		return result
+	}
+
+	fmt.Fprintln(w, "## Events:")
+	w.Flush()
+	start := time.Now()
+	last := start
+	var gaps []time.Duration
+	var end string
+	count := 0
+	for n := 1; ; n++ {
+		if options.ssecount > 0 && n > options.ssecount {
+			end = fmt.Sprintf("event limit (%d) reached", options.ssecount)
+			break
+		}
+		event, err := stream.Next()
+		if err != nil {
+			switch {
+			case err == io.EOF:
+				end = "server ended the stream"
+			case errors.Is(err, context.DeadlineExceeded):
+				end = fmt.Sprintf("duration limit (%s) reached", fmtDuration(options.sseduration))
+			default:
+				end = fmt.Sprintf("ERROR: %v", err)
+				setExitStatus(classifyError(err))
+			}
+			break
+		}
+		count++
+		gap := event.Arrived.Sub(last)
+		last = event.Arrived
+		if n > 1 {
+			gaps = append(gaps, gap)
+		}
+		printEvent(w, n, event, gap)
+		w.Flush()
+	}
+
+	result.ResponseTime = time.Since(result.Start)
+	fmt.Fprintf(w, "## End of Events: %s after %s\n", end, fmtDuration(time.Since(start)))
+	fmt.Fprintf(w, "   Events: %d, comments: %d", count, stream.Comments)
+	if len(gaps) > 0 {
+		min, max, total := gaps[0], gaps[0], time.Duration(0)
+		for _, g := range gaps {
+			if g < min {
+				min = g
+			}
+			if g > max {
+				max = g
+			}
+			total += g
+		}
+		fmt.Fprintf(w, ", gaps min/avg/max: %s/%s/%s", fmtDuration(min),
+			fmtDuration(total/time.Duration(len(gaps))), fmtDuration(max))
+	}
+	fmt.Fprintln(w)
+	return result
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+//
+// Placeholders printed for nondeterministic values with -stable-output
+//
+const (
+	stableDuration = "<duration>"
+	stableTime     = "<time>"
+	stableRate     = "<rate>"
+	stableValue    = "<volatile>"
+)
+
+//
+// Response headers whose values change from one request to the next
+//
+var volatileHeaders = map[string]bool{
+	"Date":             true,
+	"Age":              true,
+	"Expires":          true,
+	"Last-Modified":    true,
+	"Etag":             true,
+	"Set-Cookie":       true,
+	"X-Request-Id":     true,
+	"X-Amz-Request-Id": true,
+	"X-Amz-Cf-Id":      true,
+	"X-Amz-Id-2":       true,
+	"Cf-Ray":           true,
+	"X-Served-By":      true,
+	"X-Timer":          true,
+	"Report-To":        true,
+	"Nel":              true,
+	"Server-Timing":    true,
+	"Traceparent":      true,
+}
+
+//
+// fmtDuration - format a duration, or a placeholder with -stable-output
+//
+func fmtDuration(d time.Duration) string {
+
+	if options.stableoutput {
+		return stableDuration
+	}
+	return d.String()
+}
+
+//
+// fmtRate - format a transfer rate, or a placeholder with -stable-output
+//
+func fmtRate(bytespersec float64) string {
+
+	if options.stableoutput {
+		return stableRate
+	}
+	return fmt.Sprintf("%.0f bytes/sec", bytespersec)
+}
+
+//
+// headerKeys - the header names in the order they should be printed:
+// sorted if -stable-output is given, since map order is random.
+//
+func headerKeys(header http.Header) []string {
+
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	if options.stableoutput {
+		sort.Strings(keys)
+	}
+	return keys
+}
+
+//
+// headerValue - the printed value of a header, masking volatile header
+// values with -stable-output
+//
+func headerValue(key string, values []string) string {
+
+	if options.stableoutput && volatileHeaders[http.CanonicalHeaderKey(key)] {
+		return stableValue
+	}
+	return strings.Join(values, ",")
+}
+
+//
+// fmtLocalAddr - format the local address of a connection, whose port
+// the kernel picks, or a placeholder with -stable-output
+//
+func fmtLocalAddr(addr string) string {
+
+	if options.stableoutput {
+		return stableValue
+	}
+	return addr
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+//
+// Histogram layout: values below histLinear are counted exactly; above
+// that, each power of two is split into histSub logarithmic sub-buckets,
+// giving a worst case relative error of about 3% in reported quantiles.
+// Memory use is fixed regardless of how many samples are recorded.
+//
+const (
+	histSubBits = 5
+	histSub     = 1 << histSubBits
+	histLinear  = 2 * histSub
+	histBuckets = histLinear + (64-histSubBits-1)*histSub
+)
+
+//
+// LatencyHistogram - streaming summary of a series of durations, in the
+// style of an HDR histogram. Used to aggregate results of long running
+// or large batch probes without retaining every sample.
+//
+type LatencyHistogram struct {
+	counts [histBuckets]uint64
+	count  uint64
+	sum    float64
+	min    time.Duration
+	max    time.Duration
+}
+
+func histIndex(v uint64) int {
+
+	if v < histLinear {
+		return int(v)
+	}
+	msb := bits.Len64(v) - 1
+	shift := uint(msb - histSubBits)
+	mantissa := v >> shift
+	return histLinear + (msb-histSubBits-1)*histSub + int(mantissa-histSub)
+}
+
+//
+// histRange - return the lowest and highest values counted in a bucket
+//
+func histRange(index int) (low, high uint64) {
+
+	if index < histLinear {
+		return uint64(index), uint64(index)
+	}
+	index -= histLinear
+	msb := index/histSub + histSubBits + 1
+	shift := uint(msb - histSubBits)
+	mantissa := uint64(index%histSub + histSub)
+	return mantissa << shift, ((mantissa + 1) << shift) - 1
+}
+
+//
+// Record - add a sample to the histogram
+//
+func (h *LatencyHistogram) Record(d time.Duration) {
+
+	if d < 0 {
+		d = 0
+	}
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.counts[histIndex(uint64(d))]++
+	h.count++
+	h.sum += float64(d)
+}
+
+//
+// Count - number of samples recorded
+//
+func (h *LatencyHistogram) Count() uint64 {
+	return h.count
+}
+
+//
+// Min - smallest sample recorded
+//
+func (h *LatencyHistogram) Min() time.Duration {
+	return h.min
+}
+
+//
+// Max - largest sample recorded
+//
+func (h *LatencyHistogram) Max() time.Duration {
+	return h.max
+}
+
+//
+// Mean - arithmetic mean of the samples recorded
+//
+func (h *LatencyHistogram) Mean() time.Duration {
+
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / float64(h.count))
+}
+
+//
+// Quantile - estimate the q'th quantile (0 <= q <= 1) of the samples
+//
+func (h *LatencyHistogram) Quantile(q float64) time.Duration {
+
+	if h.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.min
+	}
+	if q >= 1 {
+		return h.max
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen < rank {
+			continue
+		}
+		low, high := histRange(i)
+		value := time.Duration(low + (high-low)/2)
+		if value < h.min {
+			value = h.min
+		}
+		if value > h.max {
+			value = h.max
+		}
+		return value
+	}
+	return h.max
+}
+
+//
+// Merge - add the samples of another histogram to this one
+//
+func (h *LatencyHistogram) Merge(other *LatencyHistogram) {
+
+	if other.count == 0 {
+		return
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+// Default time budget for -probe-status-only
+var defaultStatusBudget = 2 * time.Second
+
+// Certificates expiring within this long are a WARN for -probe-status-only
+var statusCertWarning = 7 * 24 * time.Hour
+
+//
+// Probe states reported by -probe-status-only, from best to worst, and
+// their exit codes
+//
+const (
+	StatusUp   = 0
+	StatusWarn = 1
+	StatusDown = 2
+)
+
+var statusWords = []string{"UP", "WARN", "DOWN"}
+
+//
+// probeState - the state of a probe result: DOWN if the request failed,
+// the status was 5xx, or an -expect-* assertion failed; WARN if the
+// status was 4xx, the response took over half the budget, or the
+// certificate expires soon; else UP
+//
+func probeState(result *probe.ProbeResult) int {
+
+	if result.Err != nil || result.Response.StatusCode >= 500 {
+		return StatusDown
+	}
+	if options.assertions.Active() {
+		if _, ok := checkAssertions(&options.assertions, result); !ok {
+			return StatusDown
+		}
+	}
+	if result.Response.StatusCode >= 400 || result.ResponseTime > options.statusbudget/2 {
+		return StatusWarn
+	}
+	if certExpiresSoon(result.Response.TLS) {
+		return StatusWarn
+	}
+	return StatusUp
+}
+
+//
+// certExpiresSoon - does the peer certificate of the connection expire
+// within statusCertWarning?
+//
+func certExpiresSoon(cs *tls.ConnectionState) bool {
+
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return false
+	}
+	return time.Until(cs.PeerCertificates[0].NotAfter) < statusCertWarning
+}
+
+//
+// statusOnly - probe the URLs in parallel, print the single word for
+// the worst of their states, and return its exit code. URLs that
+// haven't answered when the budget runs out are DOWN.
+//
+func statusOnly(prober *probe.Prober, urls []string) int {
+
+	states := make(chan int, len(urls))
+	for _, urlstring := range urls {
+		go func(urlstring string) {
+			request := getRequest(prober, urlstring)
+			states <- probeState(readResponse(prober.NewSession(""), request))
+		}(urlstring)
+	}
+
+	worst := StatusUp
+	deadline := time.NewTimer(options.statusbudget)
+	defer deadline.Stop()
+	for range urls {
+		select {
+		case state := <-states:
+			if state > worst {
+				worst = state
+			}
+		case <-deadline.C:
+			worst = StatusDown
+		}
+		if worst == StatusDown {
+			break
+		}
+	}
+	fmt.Println(statusWords[worst])
+	return worst
+}
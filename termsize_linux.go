@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+//
+// terminalSize - the columns and rows of the terminal, 0 if f isn't one
+//
+func terminalSize(f *os.File) (cols, rows int) {
+
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0
+	}
+	return int(ws.Col), int(ws.Row)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "os"
+
+//
+// terminalSize - the terminal size isn't available on this platform;
+// the COLUMNS and LINES environment variables are used instead
+//
+func terminalSize(f *os.File) (cols, rows int) {
+	return 0, 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// Named time formats accepted by -timefmt. Anything else is taken to
+// be a Go time layout string.
+//
+var timeFormats = map[string]string{
+	"iso8601":  "2006-01-02T15:04:05Z07:00",
+	"rfc3339":  time.RFC3339,
+	"rfc3339n": time.RFC3339Nano,
+	"rfc1123":  time.RFC1123,
+	"http":     "Mon, 02 Jan 2006 15:04:05 GMT",
+	"unix":     "unix",
+}
+
+//
+// parseTimeFormat - resolve a -timefmt value to a layout
+//
+func parseTimeFormat(s string) (string, error) {
+
+	if layout, ok := timeFormats[strings.ToLower(s)]; ok {
+		return layout, nil
+	}
+	if !strings.ContainsAny(s, "0123456789") {
+		return "", fmt.Errorf("invalid time format: %q", s)
+	}
+	return s, nil
+}
+
+//
+// formatTime - format a time for human readable output, honoring -utc
+// and -timefmt. Times are always converted to a single zone (local, or
+// UTC with -utc), so that output never mixes them. Without -timefmt,
+// times are printed in Go's default format.
+//
+func formatTime(t time.Time) string {
+
+	if options.stableoutput {
+		return stableTime
+	}
+	if options.utc {
+		t = t.UTC()
+	} else {
+		t = t.Local()
+	}
+	switch options.timefmt {
+	case "":
+		return t.String()
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case timeFormats["http"]:
+		return t.UTC().Format(options.timefmt)
+	default:
+		return t.Format(options.timefmt)
+	}
+}
+
+//
+// formatMachineTime - format a time for machine readable output: ISO
+// 8601 (RFC 3339) unless -timefmt says otherwise.
+//
+func formatMachineTime(t time.Time) string {
+
+	if options.timefmt != "" {
+		return formatTime(t)
+	}
+	if options.utc {
+		t = t.UTC()
+	} else {
+		t = t.Local()
+	}
+	return t.Format(time.RFC3339Nano)
+}
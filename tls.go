@@ -1,222 +1,136 @@
 package main
 
 import (
-	"crypto/ecdsa"
-	"crypto/ed25519"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
 	"net/http"
 	"strings"
-)
-
-//
-// TLSversion - map TLS verson number to string
-//
-var TLSversion = map[uint16]string{
-	0x0300: "SSL3.0",
-	0x0301: "TLS1.0",
-	0x0302: "TLS1.1",
-	0x0303: "TLS1.2",
-	0x0304: "TLS1.3",
-}
-
-//
-// KeyUsage value to string
-//
-var KeyUsage = map[x509.KeyUsage]string{
-	x509.KeyUsageDigitalSignature:  "DigitalSignature",
-	x509.KeyUsageContentCommitment: "ContentCommitment",
-	x509.KeyUsageKeyEncipherment:   "KeyEncipherment",
-	x509.KeyUsageDataEncipherment:  "DataEncipherment",
-	x509.KeyUsageKeyAgreement:      "KeyAgreement",
-	x509.KeyUsageCertSign:          "CertSign",
-	x509.KeyUsageCRLSign:           "CRLSign",
-	x509.KeyUsageEncipherOnly:      "EncipherOnly",
-	x509.KeyUsageDecipherOnly:      "DecipherOnly",
-}
-
-//
-// ExtendedKeyUsage value to string
-//
-var ExtendedKeyUsage = map[x509.ExtKeyUsage]string{
-	x509.ExtKeyUsageAny:                            "Any",
-	x509.ExtKeyUsageServerAuth:                     "ServerAuth",
-	x509.ExtKeyUsageClientAuth:                     "ClientAuth",
-	x509.ExtKeyUsageCodeSigning:                    "CodeSigning",
-	x509.ExtKeyUsageEmailProtection:                "EmailProtection",
-	x509.ExtKeyUsageIPSECEndSystem:                 "IPSECEndSystem",
-	x509.ExtKeyUsageIPSECTunnel:                    "IPSECTunnel",
-	x509.ExtKeyUsageIPSECUser:                      "IPSECUser",
-	x509.ExtKeyUsageTimeStamping:                   "TimeStamping",
-	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
-	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "MicrosoftServerGatedCrypto",
-	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "NetscapeServerGatedCrypto",
-	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "MicrosoftCommercialCodeSigning",
-	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "MicrosoftKernelCodeSigning",
-}
+	"time"
 
-//
-// KU2Strings -
-//
-func KU2Strings(ku x509.KeyUsage) string {
-
-	var result []string
-	for k, v := range KeyUsage {
-		if ku&k == k {
-			result = append(result, v)
-		}
-	}
-	return strings.Join(result, " ")
-}
-
-//
-// EKU2Strings -
-//
-func EKU2Strings(ekulist []x509.ExtKeyUsage) string {
-
-	var result []string
-	for _, eku := range ekulist {
-		result = append(result, ExtendedKeyUsage[eku])
-	}
-	return strings.Join(result, " ")
-}
-
-//
-// KeySizeInBits -
-//
-func KeySizeInBits(publickey interface{}) int {
+	"github.com/shuque/gohttp/probe"
+)
 
-	switch v := publickey.(type) {
-	case *rsa.PublicKey:
-		return v.Size() * 8
-	case *ecdsa.PublicKey:
-		return v.X.BitLen() + v.Y.BitLen()
-	case *ed25519.PublicKey:
-		return 256
-	default:
-		return 0
-	}
-}
+// Days before expiry from which the certificate is warned about
+const certExpiryWarning = 30
 
 //
 // printCertDetails --
 // Print some details of the certificate.
 //
-func printCertDetails(cert *x509.Certificate) {
+func printCertDetails(w io.Writer, cert *x509.Certificate) {
 
-	fmt.Printf("   X509 version: %d\n", cert.Version)
-	fmt.Printf("   Serial#: %x\n", cert.SerialNumber)
-	fmt.Printf("   Subject: %v\n", cert.Subject)
-	fmt.Printf("   Issuer:  %v\n", cert.Issuer)
+	fmt.Fprintf(w, "   X509 version: %d\n", cert.Version)
+	fmt.Fprintf(w, "   Serial#: %x\n", cert.SerialNumber)
+	fmt.Fprintf(w, "   Subject: %v\n", cert.Subject)
+	fmt.Fprintf(w, "   Issuer:  %v\n", cert.Issuer)
 	for _, dnsName := range cert.DNSNames {
-		fmt.Printf("   SAN dNSName: %s\n", dnsName)
+		fmt.Fprintf(w, "   SAN dNSName: %s\n", dnsName)
 	}
 	for _, ipAddress := range cert.IPAddresses {
-		fmt.Printf("   SAN IPaddress: %s\n", ipAddress)
+		fmt.Fprintf(w, "   SAN IPaddress: %s\n", ipAddress)
 	}
 	for _, emailAddress := range cert.EmailAddresses {
-		fmt.Printf("   SAN emailAddress: %s\n", emailAddress)
+		fmt.Fprintf(w, "   SAN emailAddress: %s\n", emailAddress)
 	}
 	for _, uri := range cert.URIs {
-		fmt.Printf("   SAN URI: %v\n", uri)
-	}
-	fmt.Printf("   Signature Algorithm: %v\n", cert.SignatureAlgorithm)
-	fmt.Printf("   PublicKey Algorithm: %v %d-Bits\n",
-		cert.PublicKeyAlgorithm, KeySizeInBits(cert.PublicKey))
-	fmt.Printf("   Inception:  %v\n", cert.NotBefore)
-	fmt.Printf("   Expiration: %v\n", cert.NotAfter)
-	fmt.Printf("   KU: %v\n", KU2Strings(cert.KeyUsage))
-	fmt.Printf("   EKU: %v\n", EKU2Strings(cert.ExtKeyUsage))
+		fmt.Fprintf(w, "   SAN URI: %v\n", uri)
+	}
+	fmt.Fprintf(w, "   Signature Algorithm: %v\n", cert.SignatureAlgorithm)
+	fmt.Fprintf(w, "   PublicKey Algorithm: %v %d-Bits\n",
+		cert.PublicKeyAlgorithm, probe.KeySizeInBits(cert.PublicKey))
+	fmt.Fprintf(w, "   Inception:  %s\n", formatTime(cert.NotBefore))
+	fmt.Fprintf(w, "   Expiration: %s\n", formatTime(cert.NotAfter))
+	fmt.Fprintf(w, "   KU: %v\n", probe.KU2Strings(cert.KeyUsage))
+	fmt.Fprintf(w, "   EKU: %v\n", probe.EKU2Strings(cert.ExtKeyUsage))
 	if cert.BasicConstraintsValid {
-		fmt.Printf("   Is CA?: %v\n", cert.IsCA)
-	}
-	fmt.Printf("   SKI: %x\n", cert.SubjectKeyId)
-	fmt.Printf("   AKI: %x\n", cert.AuthorityKeyId)
-	fmt.Printf("   OSCP Servers: %v\n", cert.OCSPServer)
-	fmt.Printf("   CA Issuer URL: %v\n", cert.IssuingCertificateURL)
-	fmt.Printf("   CRL Distribution: %v\n", cert.CRLDistributionPoints)
-	fmt.Printf("   Policy OIDs: %v\n", cert.PolicyIdentifiers)
+		fmt.Fprintf(w, "   Is CA?: %v\n", cert.IsCA)
+	}
+	fmt.Fprintf(w, "   SKI: %x\n", cert.SubjectKeyId)
+	fmt.Fprintf(w, "   AKI: %x\n", cert.AuthorityKeyId)
+	fmt.Fprintf(w, "   OSCP Servers: %v\n", cert.OCSPServer)
+	fmt.Fprintf(w, "   CA Issuer URL: %v\n", cert.IssuingCertificateURL)
+	fmt.Fprintf(w, "   CRL Distribution: %v\n", cert.CRLDistributionPoints)
+	fmt.Fprintf(w, "   Policy OIDs: %v\n", cert.PolicyIdentifiers)
 }
 
 //
 // printCertChainDetails -
 //
-func printCertChainDetails(chain []*x509.Certificate) {
+func printCertChainDetails(w io.Writer, chain []*x509.Certificate) {
 
-	fmt.Printf("## -------------- FULL Certificate Chain ----------------\n")
+	fmt.Fprintf(w, "## -------------- FULL Certificate Chain ----------------\n")
 	for i, cert := range chain {
-		fmt.Printf("## Certificate at Depth: %d\n", i)
-		printCertDetails(cert)
+		fmt.Fprintf(w, "## Certificate at Depth: %d\n", i)
+		printCertDetails(w, cert)
 	}
 }
 
 //
 // printVerifiedChains -
 //
-func printVerifiedChains(chains [][]*x509.Certificate) {
+func printVerifiedChains(w io.Writer, chains [][]*x509.Certificate) {
 
 	for i, row := range chains {
-		fmt.Printf("## Verified Certificate Chain %d:\n", i)
+		fmt.Fprintf(w, "## Verified Certificate Chain %d:\n", i)
 		for j, cert := range row {
-			fmt.Printf("  %2d %v\n", j, cert.Subject)
-			fmt.Printf("     %v\n", cert.Issuer)
+			fmt.Fprintf(w, "  %2d %v\n", j, cert.Subject)
+			fmt.Fprintf(w, "     %v\n", cert.Issuer)
 		}
 	}
 }
 
-func getTLSConfig() *tls.Config {
-
-	tlsconfig := new(tls.Config)
+func printTLSinfo(w io.Writer, response *http.Response) {
 
-	if options.sni != "" {
-		tlsconfig.ServerName = options.sni
+	if response.TLS == nil {
+		fmt.Fprintln(w, "## TLS Connection Info: NONE")
+		return
 	}
-
-	if options.noverify {
-		tlsconfig.InsecureSkipVerify = true
-	} else if options.cacert != "" {
-		cacert, err := ioutil.ReadFile(options.cacert)
-		if err != nil {
-			log.Fatal(err)
+	fmt.Fprintln(w, "## TLS Connection Info:")
+	fmt.Fprintf(w, "   TLS version: %s\n", probe.TLSversion[response.TLS.Version])
+	fmt.Fprintf(w, "   TLS Resumed: %v\n", response.TLS.DidResume)
+	fmt.Fprintf(w, "   TLS CipherSuite: %s\n", tls.CipherSuiteName(response.TLS.CipherSuite))
+	fmt.Fprintf(w, "   TLS ALPN: %s\n", response.TLS.NegotiatedProtocol)
+	if options.nosni {
+		fmt.Fprintln(w, "   TLS SNI: none sent")
+		if certs := response.TLS.PeerCertificates; len(certs) > 0 && !options.showcert && !options.showcertchain {
+			fmt.Fprintf(w, "   Default certificate: %s\n", certs[0].Subject)
+			fmt.Fprintf(w, "   Default certificate names: %s\n", strings.Join(certs[0].DNSNames, " "))
 		}
-		cacertpool := x509.NewCertPool()
-		cacertpool.AppendCertsFromPEM(cacert)
-		tlsconfig.RootCAs = cacertpool
+	} else {
+		fmt.Fprintf(w, "   TLS SNI: %s\n", response.TLS.ServerName)
 	}
-
-	if options.clientcert != "" {
-		clientcreds, err := tls.LoadX509KeyPair(options.clientcert, options.clientkey)
-		if err != nil {
-			log.Fatal(err)
+	if options.verifyname != "" {
+		fmt.Fprintf(w, "   Verified for: %s\n", options.verifyname)
+	}
+	if response.TLS.Version < tls.VersionTLS12 {
+		fmt.Fprintf(w, "   WARNING: %s is deprecated (RFC 8996)\n", probe.TLSversion[response.TLS.Version])
+	}
+	if certs := response.TLS.PeerCertificates; len(certs) > 0 {
+		left := time.Until(certs[0].NotAfter)
+		if days := int(left.Hours() / 24); left < 0 {
+			fmt.Fprintln(w, "   ERROR: the certificate has expired")
+		} else if days < certExpiryWarning {
+			fmt.Fprintf(w, "   WARNING: the certificate expires in %d days\n", days)
 		}
-		tlsconfig.Certificates = []tls.Certificate{clientcreds}
 	}
-
-	return tlsconfig
-}
-
-func printTLSinfo(response *http.Response) {
-
-	if response.TLS == nil {
-		fmt.Println("## TLS Connection Info: NONE")
-		return
+	if options.trustsource {
+		printTrustSource(w)
+	}
+	if response.Request != nil {
+		printBundleVerification(w, response.TLS, response.Request.URL.Hostname())
 	}
-	fmt.Println("## TLS Connection Info:")
-	fmt.Printf("   TLS version: %s\n", TLSversion[response.TLS.Version])
-	fmt.Printf("   TLS Resumed: %v\n", response.TLS.DidResume)
-	fmt.Printf("   TLS CipherSuite: %s\n", tls.CipherSuiteName(response.TLS.CipherSuite))
-	fmt.Printf("   TLS ALPN: %s\n", response.TLS.NegotiatedProtocol)
-	fmt.Printf("   TLS SNI: %s\n", response.TLS.ServerName)
 
 	if options.showcertchain {
-		printCertChainDetails(response.TLS.PeerCertificates)
-		printVerifiedChains(response.TLS.VerifiedChains)
+		printCertChainDetails(w, response.TLS.PeerCertificates)
+		printVerifiedChains(w, response.TLS.VerifiedChains)
 	} else if options.showcert {
-		fmt.Println("   ## Peer Certificate:")
-		printCertDetails(response.TLS.PeerCertificates[0])
+		fmt.Fprintln(w, "   ## Peer Certificate:")
+		printCertDetails(w, response.TLS.PeerCertificates[0])
+	}
+
+	if options.detectmitm {
+		printMitmDetection(w, response.TLS)
 	}
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// dotForms - the hostname without and with a trailing dot
+//
+func dotForms(hostname string) (bare, dotted string) {
+
+	bare = strings.TrimSuffix(hostname, ".")
+	return bare, bare + "."
+}
+
+//
+// lookupSorted - the sorted addresses of hostname, as strings
+//
+func lookupSorted(hostname string) ([]string, error) {
+
+	ips, err := net.LookupIP(hostname)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = ip.String()
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+//
+// withPort - host with the URL's port appended, if it has one
+//
+func withPort(host, port string) string {
+
+	if port == "" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+//
+// checkTrailingDot - compare the fully qualified (trailing dot) and
+// bare forms of the URL's hostname: what each resolves to, whether the
+// certificate covers each, and how the server responds to a Host
+// header in each form. SNI never has the trailing dot (RFC 6066).
+//
+func checkTrailingDot(w io.Writer, request *http.Request, address string, result *probe.ProbeResult) {
+
+	hostname := request.URL.Hostname()
+	if net.ParseIP(hostname) != nil {
+		return
+	}
+	bare, dotted := dotForms(hostname)
+	fmt.Fprintf(w, "## Trailing Dot: %s vs %s\n", dotted, bare)
+
+	if options.proxy == nil {
+		var lists [2][]string
+		for i, name := range []string{dotted, bare} {
+			addrs, err := lookupSorted(name)
+			if err != nil {
+				fmt.Fprintf(w, "   DNS %s: %v\n", name, err)
+				continue
+			}
+			lists[i] = addrs
+			fmt.Fprintf(w, "   DNS %s: %s\n", name, strings.Join(addrs, " "))
+		}
+		if lists[0] != nil && lists[1] != nil {
+			same := strings.Join(lists[0], " ") == strings.Join(lists[1], " ")
+			fmt.Fprintf(w, "   DNS answers identical: %v\n", same)
+		}
+	}
+
+	if cs := result.Response.TLS; cs != nil {
+		fmt.Fprintf(w, "   SNI sent: %s\n", cs.ServerName)
+		if len(cs.PeerCertificates) > 0 {
+			leaf := cs.PeerCertificates[0]
+			for _, name := range []string{dotted, bare} {
+				fmt.Fprintf(w, "   Certificate valid for %s: %v\n", name, leaf.VerifyHostname(name) == nil)
+			}
+		}
+	}
+
+	port := request.URL.Port()
+	compareHostForms(w, request, address, []HostForm{
+		{name: "Host " + withPort(dotted, port), host: withPort(dotted, port)},
+		{name: "Host " + withPort(bare, port), host: withPort(bare, port)},
+	})
+}
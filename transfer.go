@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// bodyNeeded - do the options require the response body? If not, and
+// -head-fallback was given, we can make do with a HEAD request.
+//
+func bodyNeeded() bool {
+	return options.printbody || options.bodyonly || outputToFile() ||
+		options.encodings != nil || options.byterange != nil ||
+		options.jsonpath != nil || options.assertions.body != nil
+}
+
+//
+// headRequest - return a HEAD request for the same resource as request
+//
+func headRequest(request *http.Request) *http.Request {
+
+	headreq := request.Clone(request.Context())
+	headreq.Method = http.MethodHead
+	return headreq
+}
+
+//
+// printTransferInfo - print timing and size details of the body transfer
+//
+func printTransferInfo(w io.Writer, result *probe.ProbeResult) {
+
+	fmt.Fprintln(w, "## Transfer:")
+	if result.Response.Request != nil && result.Response.Request.Method == http.MethodHead {
+		fmt.Fprintf(w, "   Request method: HEAD (no body)\n")
+	}
+	fmt.Fprintf(w, "   Time to headers: %s\n", fmtDuration(result.HeaderTime))
+	fmt.Fprintf(w, "   Body transfer time: %s\n", fmtDuration(result.TransferTime))
+	if result.Response.Uncompressed {
+		fmt.Fprintf(w, "   Transfer size: unknown (transparently decompressed)\n")
+	} else {
+		fmt.Fprintf(w, "   Transfer size: %d\n", result.EncodedSize)
+	}
+	fmt.Fprintf(w, "   Body size: %d\n", result.BodySize)
+	if result.Truncated {
+		fmt.Fprintf(w, "   Body truncated: at %d bytes (-max-body)\n", options.maxbody)
+	}
+	fmt.Fprintf(w, "   Wire bytes received: %d\n", result.WireBytes)
+	if secs := result.TransferTime.Seconds(); secs > 0 {
+		fmt.Fprintf(w, "   Throughput: %s\n", fmtRate(float64(result.EncodedSize)/secs))
+	}
+	if options.limitrate > 0 {
+		fmt.Fprintf(w, "   Rate limit: %d bytes/sec\n", options.limitrate)
+	}
+	if result.Upload != nil {
+		sent, elapsed := result.Upload.Sent()
+		fmt.Fprintf(w, "   Upload size: %d\n", sent)
+		fmt.Fprintf(w, "   Upload time: %s\n", fmtDuration(elapsed))
+		if secs := elapsed.Seconds(); secs > 0 {
+			fmt.Fprintf(w, "   Upload throughput: %s\n", fmtRate(float64(sent)/secs))
+		}
+	}
+}
+
+//
+// printTCPStats - print the kernel's statistics for the connection the
+// response came on (Linux only)
+//
+func printTCPStats(w io.Writer, session *probe.Session) {
+
+	stats, err := session.TCPStats()
+	if err != nil {
+		fmt.Fprintf(w, "## TCP Info: %v\n", err)
+		return
+	}
+	when := "after the exchange"
+	if stats.Closed {
+		when = "when the connection closed"
+	}
+	fmt.Fprintf(w, "## TCP Info (%s):\n", when)
+	fmt.Fprintf(w, "   RTT: %s (variance %s, minimum %s)\n",
+		fmtDuration(stats.RTT), fmtDuration(stats.RTTVar), fmtDuration(stats.MinRTT))
+	fmt.Fprintf(w, "   Retransmits: %d (segments lost: %d)\n", stats.Retransmits, stats.Lost)
+	fmt.Fprintf(w, "   Delivery rate: %s\n", fmtRate(float64(stats.DeliveryRate)))
+	fmt.Fprintf(w, "   Segments: %d sent, %d received\n", stats.SegsOut, stats.SegsIn)
+	fmt.Fprintf(w, "   Congestion window: %d segments (MSS %d)\n", stats.Cwnd, stats.MSS)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	_ "embed"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+)
+
+//
+// The Mozilla CA certificates, for -roots mozilla: verifying against
+// them shows whether a chain would validate for clients using Mozilla's
+// root store (Firefox, and most Linux distributions), whatever this
+// machine trusts. roots/generate.go makes the bundle.
+//
+//go:generate go run roots/generate.go
+//go:embed roots/mozilla.pem
+var mozillaRoots []byte
+
+//
+// Locations Go's crypto/x509 consults for the system roots on Unix-like
+// systems, in order, when SSL_CERT_FILE and SSL_CERT_DIR are not set.
+//
+var unixCertFiles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+	"/usr/local/etc/ssl/cert.pem",
+	"/etc/openssl/certs/ca-certificates.crt",
+}
+
+var unixCertDirs = []string{
+	"/etc/ssl/certs",
+	"/etc/pki/tls/certs",
+	"/system/etc/security/cacerts",
+}
+
+//
+// systemTrustSource - describe where the platform's trusted roots come
+// from. On Windows and macOS, leaving tls.Config.RootCAs nil makes Go
+// defer to the platform verifier, which honors roots pushed by group
+// policy or MDM; elsewhere Go reads a PEM bundle from the filesystem.
+//
+func systemTrustSource() string {
+
+	switch runtime.GOOS {
+	case "windows":
+		return "system: Windows certificate store (CryptoAPI, includes enterprise roots)"
+	case "darwin", "ios":
+		return "system: macOS Security framework (keychains, includes MDM roots)"
+	}
+
+	if file := os.Getenv("SSL_CERT_FILE"); file != "" {
+		return fmt.Sprintf("system: %s (from SSL_CERT_FILE)", file)
+	}
+	if dir := os.Getenv("SSL_CERT_DIR"); dir != "" {
+		return fmt.Sprintf("system: %s (from SSL_CERT_DIR)", dir)
+	}
+	for _, file := range unixCertFiles {
+		if _, err := os.Stat(file); err == nil {
+			return "system: " + file
+		}
+	}
+	for _, dir := range unixCertDirs {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return "system: " + dir
+		}
+	}
+	return "system: no root certificates found"
+}
+
+//
+// loadRoots - the pool of certificates for -roots: the bundled Mozilla
+// ones, or those in a PEM file. Returns nil for the system's.
+//
+func loadRoots(roots string) (*x509.CertPool, error) {
+
+	var pem []byte
+	switch roots {
+	case "system":
+		return nil, nil
+	case "mozilla":
+		pem = mozillaRoots
+	default:
+		var err error
+		if pem, err = ioutil.ReadFile(roots); err != nil {
+			return nil, err
+		}
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", roots)
+	}
+	return pool, nil
+}
+
+//
+// CABundle - the certificates of one -cacert file
+//
+type CABundle struct {
+	File string
+	Pool *x509.CertPool
+}
+
+//
+// loadCABundles - read the -cacert files, returning each one's
+// certificates, and a pool of all of them to verify with
+//
+func loadCABundles(files []string) ([]CABundle, *x509.CertPool, error) {
+
+	var bundles []CABundle
+	all := x509.NewCertPool()
+	for _, file := range files {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no certificates in %s", file)
+		}
+		all.AppendCertsFromPEM(pem)
+		bundles = append(bundles, CABundle{File: file, Pool: pool})
+	}
+	return bundles, all, nil
+}
+
+//
+// printBundleVerification - with more than one -cacert file, verify the
+// server's chain against each on its own, and report which accept it
+//
+func printBundleVerification(w io.Writer, cs *tls.ConnectionState, hostname string) {
+
+	if len(options.cabundles) < 2 || len(cs.PeerCertificates) == 0 {
+		return
+	}
+	if options.verifyname != "" {
+		hostname = options.verifyname
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	fmt.Fprintln(w, "   CA bundle verification:")
+	for _, bundle := range options.cabundles {
+		chains, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+			DNSName:       strings.TrimSuffix(hostname, "."),
+			Roots:         bundle.Pool,
+			Intermediates: intermediates,
+		})
+		if err != nil {
+			fmt.Fprintf(w, "      %s: FAILED: %v\n", bundle.File, err)
+			continue
+		}
+		root := chains[0][len(chains[0])-1]
+		fmt.Fprintf(w, "      %s: OK (root %s)\n", bundle.File, root.Subject)
+	}
+}
+
+//
+// trustSource - describe the trust anchors used to verify the server
+//
+func trustSource() string {
+
+	switch {
+	case options.noverify:
+		return "none (verification disabled)"
+	case options.cacert != nil:
+		return "file: " + strings.Join(options.cacert, ", ")
+	case options.roots == "mozilla":
+		return "mozilla: bundled Mozilla CA certificates"
+	case options.roots != "system":
+		return "file: " + options.roots
+	default:
+		return systemTrustSource()
+	}
+}
+
+//
+// printTrustSource - print the trust anchors used
+//
+func printTrustSource(w io.Writer) {
+	fmt.Fprintf(w, "   Trust source: %s\n", trustSource())
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//
+// UAPreset - the User-Agent and content preference headers a client
+// sends, for -ua-preset
+//
+type UAPreset struct {
+	userAgent      string
+	accept         string
+	acceptLanguage string
+}
+
+var uaPresets = map[string]UAPreset{
+	"chrome": {
+		userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+		acceptLanguage: "en-US,en;q=0.9",
+	},
+	"firefox": {
+		userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+		accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+		acceptLanguage: "en-US,en;q=0.5",
+	},
+	"safari": {
+		userAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+		accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		acceptLanguage: "en-US,en;q=0.9",
+	},
+	"curl": {
+		userAgent: "curl/8.7.1",
+		accept:    "*/*",
+	},
+	"googlebot": {
+		userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		accept:    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+	},
+}
+
+//
+// uaPresetNames - the -ua-preset names, for messages
+//
+func uaPresetNames() string {
+
+	names := make([]string, 0, len(uaPresets))
+	for name := range uaPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+//
+// applyUAPreset - send the preset's User-Agent, unless -user-agent was
+// given, and its Accept and Accept-Language headers, unless given with
+// -header or -accept
+//
+func applyUAPreset(name string) error {
+
+	preset, ok := uaPresets[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown preset %q (one of %s)", name, uaPresetNames())
+	}
+	if options.useragent == defaultAgent {
+		options.useragent = preset.userAgent
+	}
+	if options.headers == nil {
+		options.headers = make(http.Header)
+	}
+	if options.headers.Get("Accept") == "" && preset.accept != "" {
+		options.headers.Set("Accept", preset.accept)
+	}
+	if options.headers.Get("Accept-Language") == "" && preset.acceptLanguage != "" {
+		options.headers.Set("Accept-Language", preset.acceptLanguage)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+//
+// parseURL - parse and check the URL given on the command line, so that
+// a malformed one is reported precisely rather than surfacing later as
+// a confusing DNS or connection error.
+//
+func parseURL(s string) (*url.URL, error) {
+
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("empty URL")
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		var urlerr *url.Error
+		if errors.As(err, &urlerr) {
+			err = urlerr.Err
+		}
+		return nil, fmt.Errorf("invalid URL %q: %v", s, err)
+	}
+
+	switch {
+	case u.Scheme == "" || u.Opaque != "":
+		return nil, fmt.Errorf("invalid URL %q: missing scheme (http:// or https://)", s)
+	case portMap[strings.ToLower(u.Scheme)] == "":
+		return nil, fmt.Errorf("invalid URL %q: unsupported scheme %q", s, u.Scheme)
+	case u.Hostname() == "":
+		return nil, fmt.Errorf("invalid URL %q: missing host", s)
+	}
+
+	if port := u.Port(); port != "" {
+		if _, err := parsePort(port); err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %v", s, err)
+		}
+	} else if strings.HasSuffix(u.Host, ":") {
+		return nil, fmt.Errorf("invalid URL %q: empty port", s)
+	}
+	return u, nil
+}
+
+//
+// targetURL - the URL for a target given on the command line: a URL is
+// left alone, and a bare hostname, IP address (IPv6 in brackets, or
+// not, without a port) or host:port, optionally followed by a path,
+// becomes an https URL, or an http one for port 80
+//
+func targetURL(s string) string {
+
+	if strings.Contains(s, "://") {
+		return s
+	}
+	host, path := s, "/"
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		host, path = s[:i], s[i:]
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		host = "[" + host + "]"
+	}
+	scheme := "https"
+	if _, port, err := net.SplitHostPort(host); err == nil && port == "80" {
+		scheme = "http"
+	}
+	return scheme + "://" + host + path
+}
+
+//
+// parsePort - parse a TCP port number, in decimal digits only (no sign)
+//
+func parsePort(s string) (int, error) {
+
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 || strings.Trim(s, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid port %q: must be 1-65535", s)
+	}
+	return port, nil
+}
+
+//
+// replacePort - the URL with its port, explicit or implied by its
+// scheme, replaced by port
+//
+func replacePort(urlstring, port string) (string, error) {
+
+	u, err := url.Parse(urlstring)
+	if err != nil {
+		return "", err
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), port)
+	return u.String(), nil
+}
+
+//
+// parseHeader - parse a "key: value" custom request header, checking
+// that the name is a valid token and the value contains no control
+// characters (in particular no CR or LF, which would let the value
+// smuggle in additional headers).
+//
+func parseHeader(s string) (key, value string, err error) {
+
+	tmp := strings.SplitN(s, ":", 2)
+	if len(tmp) != 2 {
+		return "", "", fmt.Errorf("invalid header %q: must be \"key: value\"", s)
+	}
+	key = tmp[0]
+	value = strings.TrimSpace(tmp[1])
+
+	switch {
+	case key == "":
+		return "", "", fmt.Errorf("invalid header %q: empty name", s)
+	case strings.TrimSpace(key) != key:
+		return "", "", fmt.Errorf("invalid header %q: whitespace around name", s)
+	case !httpguts.ValidHeaderFieldName(key):
+		return "", "", fmt.Errorf("invalid header %q: illegal character in name", s)
+	case !httpguts.ValidHeaderFieldValue(value):
+		return "", "", fmt.Errorf("invalid header %q: illegal character in value", s)
+	}
+	return key, value, nil
+}
+
+//
+// readToken - read a bearer token from a file, ignoring surrounding
+// whitespace such as a trailing newline
+//
+func readToken(filename string) (string, error) {
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s: empty token", filename)
+	}
+	return token, nil
+}
+
+//
+// parseCredentials - parse a "username:password" string
+//
+func parseCredentials(s string) (username, password string, err error) {
+
+	tmp := strings.SplitN(s, ":", 2)
+	if len(tmp) != 2 || tmp[0] == "" {
+		return "", "", fmt.Errorf("invalid credentials: must be username:password")
+	}
+	return tmp[0], tmp[1], nil
+}
+
+//
+// parseProxy - parse and check a -proxy URL
+//
+func parseProxy(s string) (*url.URL, error) {
+
+	u, err := url.Parse(s)
+	if err != nil {
+		var urlerr *url.Error
+		if errors.As(err, &urlerr) {
+			err = urlerr.Err
+		}
+		return nil, fmt.Errorf("invalid proxy %q: %v", s, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: scheme must be http, https or socks5", s)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", s)
+	}
+	if port := u.Port(); port != "" {
+		if _, err := parsePort(port); err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", s, err)
+		}
+	}
+	return u, nil
+}
+
+//
+// splitHostField - split the host field off the front of s, up to the
+// next colon: a hostname or IPv4 address, an IPv6 address in brackets,
+// or empty. Returns the host, without brackets, and the rest of s after
+// the colon.
+//
+func splitHostField(s string) (host, rest string, err error) {
+
+	if strings.HasPrefix(s, "[") {
+		i := strings.IndexByte(s, ']')
+		if i < 0 {
+			return "", "", fmt.Errorf("missing ] after IPv6 address")
+		}
+		host, rest = s[1:i], s[i+1:]
+		if net.ParseIP(host) == nil || !strings.Contains(host, ":") {
+			return "", "", fmt.Errorf("invalid IPv6 address %q", host)
+		}
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("no port field after [%s]", host)
+		}
+		return host, rest[1:], nil
+	}
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return "", "", fmt.Errorf("no port field")
+	}
+	return s[:i], s[i+1:], nil
+}
+
+//
+// validHostname - is s a syntactically valid hostname, or IP address?
+//
+func validHostname(s string) bool {
+
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//
+// ResolveMap - the -resolve addresses, by the host:port they apply to
+//
+type ResolveMap map[string][]net.IP
+
+//
+// parseResolve - parse a -resolve entry, host:port:addr[,addr...] as
+// curl takes it, where the addresses are IPv4 or IPv6, the latter
+// optionally in brackets. Returns the host:port it applies to, with the
+// host in lower case, and the addresses.
+//
+func parseResolve(s string) (string, []net.IP, error) {
+
+	host, rest, err := splitHostField(s)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid -resolve %q: %v: must be host:port:addr[,addr...]", s, err)
+	}
+	i := strings.IndexByte(rest, ':')
+	if i < 0 {
+		return "", nil, fmt.Errorf("invalid -resolve %q: must be host:port:addr[,addr...]", s)
+	}
+	port, addrs := rest[:i], rest[i+1:]
+
+	switch {
+	case host == "":
+		return "", nil, fmt.Errorf("invalid -resolve %q: empty host", s)
+	case !validHostname(host):
+		return "", nil, fmt.Errorf("invalid -resolve %q: invalid host %q", s, host)
+	}
+	if _, err := parsePort(port); err != nil {
+		return "", nil, fmt.Errorf("invalid -resolve %q: %v", s, err)
+	}
+	var iplist []net.IP
+	for _, addr := range strings.Split(addrs, ",") {
+		addr = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(addr), "["), "]")
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return "", nil, fmt.Errorf("invalid -resolve %q: invalid address %q", s, addr)
+		}
+		iplist = append(iplist, ip)
+	}
+	return net.JoinHostPort(strings.ToLower(host), port), iplist, nil
+}
+
+//
+// ConnectToRule - a -connect-to entry: connections to host:port go to
+// tohost:toport instead. An empty host or port matches any, and an
+// empty tohost or toport keeps the original one.
+//
+type ConnectToRule struct {
+	host, port     string
+	tohost, toport string
+}
+
+//
+// parseConnectTo - parse a -connect-to entry, host:port:tohost:toport
+// as curl takes it, with IPv6 addresses in brackets
+//
+func parseConnectTo(s string) (ConnectToRule, error) {
+
+	var rule ConnectToRule
+	usage := "must be host:port:tohost:toport"
+	host, rest, err := splitHostField(s)
+	if err != nil {
+		return rule, fmt.Errorf("invalid -connect-to %q: %v: %s", s, err, usage)
+	}
+	i := strings.IndexByte(rest, ':')
+	if i < 0 {
+		return rule, fmt.Errorf("invalid -connect-to %q: %s", s, usage)
+	}
+	port := rest[:i]
+	tohost, toport, err := splitHostField(rest[i+1:])
+	if err != nil {
+		return rule, fmt.Errorf("invalid -connect-to %q: %v: %s", s, err, usage)
+	}
+
+	for _, h := range []string{host, tohost} {
+		if h != "" && !validHostname(h) {
+			return rule, fmt.Errorf("invalid -connect-to %q: invalid host %q", s, h)
+		}
+	}
+	for _, p := range []string{port, toport} {
+		if p == "" {
+			continue
+		}
+		if _, err := parsePort(p); err != nil {
+			return rule, fmt.Errorf("invalid -connect-to %q: %v", s, err)
+		}
+	}
+	if tohost == "" && toport == "" {
+		return rule, fmt.Errorf("invalid -connect-to %q: no host or port to connect to instead", s)
+	}
+	return ConnectToRule{host: strings.ToLower(host), port: port, tohost: tohost, toport: toport}, nil
+}
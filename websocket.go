@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/shuque/gohttp/probe"
+)
+
+//
+// webSocketURL - the http or https URL for a ws or wss URL, which is
+// where the WebSocket handshake is sent. Other URLs are unchanged.
+//
+func webSocketURL(s string) string {
+
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasPrefix(lower, "ws://"):
+		return "http://" + s[len("ws://"):]
+	case strings.HasPrefix(lower, "wss://"):
+		return "https://" + s[len("wss://"):]
+	}
+	return s
+}
+
+//
+// splitWebSocketList - the elements of a comma separated -ws-* list
+//
+func splitWebSocketList(s string) []string {
+
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+//
+// webSocketSingle - perform the WebSocket handshake for the request (to
+// address, if non-empty), and report whether the server upgraded the
+// connection correctly, what it negotiated, and the -ws-ping round-trip
+// time. A failed handshake sets exit status 1, as a failed assertion
+// does. Returns a result holding the handshake response.
+//
+func webSocketSingle(w *Report, prober *probe.Prober, request *http.Request, address string) *probe.ProbeResult {
+
+	session := prober.NewSession(address)
+	ws := session.WebSocket(request, options.wsprotocols, options.wsextensions, options.wsping)
+	result := &probe.ProbeResult{
+		Response:     ws.Response,
+		ResponseTime: ws.HandshakeTime,
+		RemoteAddr:   ws.RemoteAddr,
+		LocalAddr:    ws.LocalAddr,
+		Err:          ws.Err,
+	}
+	if ws.Err != nil {
+		setExitStatus(classifyError(ws.Err))
+		fmt.Fprintln(w, ws.Err)
+		return result
+	}
+
+	fmt.Fprintf(w, "## ResponseTime: %s\n", fmtDuration(ws.HandshakeTime))
+	printConnection(w, result)
+	printTLSinfo(w, ws.Response)
+	printStatus(w, ws.Response)
+	printHeaders(w, ws.Response.Header)
+
+	fmt.Fprintln(w, "## WebSocket:")
+	accept := ws.Response.Header.Get("Sec-WebSocket-Accept")
+	switch {
+	case ws.Response.StatusCode != http.StatusSwitchingProtocols:
+		fmt.Fprintf(w, "   Handshake: FAILED, status %d instead of 101\n", ws.Response.StatusCode)
+	case !ws.Upgraded:
+		fmt.Fprintf(w, "   Handshake: FAILED, Upgrade %q instead of websocket\n",
+			ws.Response.Header.Get("Upgrade"))
+	case !ws.AcceptOK:
+		fmt.Fprintf(w, "   Handshake: FAILED, Sec-WebSocket-Accept %q, should be %q\n",
+			accept, ws.Accept)
+	default:
+		fmt.Fprintf(w, "   Handshake: OK (101, Sec-WebSocket-Accept %s is correct)\n", accept)
+	}
+	if !ws.Upgraded || !ws.AcceptOK {
+		setExitStatus(ExitAssertion)
+		return result
+	}
+
+	if len(options.wsprotocols) > 0 {
+		switch {
+		case ws.Protocol == "":
+			fmt.Fprintf(w, "   Subprotocol: none selected (offered %s)\n", strings.Join(options.wsprotocols, ", "))
+		case !listContains(options.wsprotocols, ws.Protocol, false, false):
+			fmt.Fprintf(w, "   Subprotocol: %s, which was NOT offered\n", ws.Protocol)
+			setExitStatus(ExitAssertion)
+		default:
+			fmt.Fprintf(w, "   Subprotocol: %s\n", ws.Protocol)
+		}
+	} else if ws.Protocol != "" {
+		fmt.Fprintf(w, "   Subprotocol: %s, although none was offered\n", ws.Protocol)
+		setExitStatus(ExitAssertion)
+	}
+	if len(ws.Extensions) > 0 {
+		fmt.Fprintf(w, "   Extensions: %s\n", strings.Join(ws.Extensions, ", "))
+	} else {
+		fmt.Fprintln(w, "   Extensions: none")
+	}
+
+	if options.wsping {
+		if ws.PingErr != nil {
+			fmt.Fprintf(w, "   Ping: no pong: %v\n", ws.PingErr)
+			setExitStatus(ExitAssertion)
+		} else {
+			fmt.Fprintf(w, "   Ping: pong received in %s\n", fmtDuration(ws.PingRTT))
+		}
+	}
+	return result
+}